@@ -18,7 +18,10 @@ api.interceptors.response.use(
   response => {
     const data = response.data
     if (data.code !== 0) {
-      return Promise.reject(new Error(data.message))
+      // keep the backend code, e.g. 428 tells the login page to show a captcha
+      const err = new Error(data.message)
+      err.code = data.code
+      return Promise.reject(err)
     }
     return data.data
   },
@@ -7,18 +7,21 @@ export const useMessageStore = defineStore('message', () => {
   const currentMessages = ref([])
   const unreadCount = ref(0)
 
-  // fetch all conversations
+  // fetch conversations, pinned ones first
   async function fetchConversations() {
     const data = await api.get('/conversations')
-    conversations.value = data.list || []
+    conversations.value = [...(data.pinned || []), ...(data.list || [])]
     return data
   }
 
-  // fetch messages with a specific user (peerOpenId is the open_id string)
-  async function fetchMessages(peerOpenId, page = 1, pageSize = 20) {
-    const data = await api.get('/messages', {
-      params: { peer_id: peerOpenId, page, page_size: pageSize }
-    })
+  // fetch messages with a specific user (peerOpenId is the open_id string),
+  // newest first; pass the oldest loaded message id as beforeId to load earlier ones
+  async function fetchMessages(peerOpenId, beforeId = 0, pageSize = 20) {
+    const params = { peer_id: peerOpenId, page_size: pageSize }
+    if (beforeId) {
+      params.before_id = beforeId
+    }
+    const data = await api.get('/messages', { params })
     return data
   }
 
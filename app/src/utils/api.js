@@ -46,7 +46,10 @@ api.interceptors.response.use(
     } else {
       appStore.showToast(backendMsg || '网络错误', 'error')
     }
-    return Promise.reject(new Error(backendMsg || error.message))
+    // keep the backend code, e.g. 428 tells the login page to show a captcha
+    const err = new Error(backendMsg || error.message)
+    err.code = error.response?.data?.code
+    return Promise.reject(err)
   }
 )
 
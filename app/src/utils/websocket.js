@@ -3,6 +3,8 @@ let reconnectTimer = null
 let reconnectAttempts = 0
 const maxReconnectAttempts = 5
 let currentToken = null
+// reconnect delay suggested by the server before it restarts
+let restartDelay = null
 
 // message event listeners for chat functionality
 const messageListeners = new Set()
@@ -77,6 +79,13 @@ export function connectWebSocket(token) {
   ws.onclose = (event) => {
     console.log('WebSocket: Disconnected', event.code, event.reason)
     ws = null
+    // 1012: server restarting, reconnect after the suggested delay without using up attempts
+    if (event.code === 1012) {
+      const delay = restartDelay ?? 3000
+      restartDelay = null
+      reconnectTimer = setTimeout(() => connectWebSocket(), delay)
+      return
+    }
     attemptReconnect()
   }
 
@@ -107,6 +116,9 @@ export function setOnMessageCallback(callback) {
 
 function handleMessage(message) {
   switch (message.type) {
+    case 'server_restart':
+      restartDelay = message.data?.reconnect_after_ms ?? null
+      break
     case 'match_found':
     case 'match_success':
     case 'match_rejected':
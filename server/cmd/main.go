@@ -1,11 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"pinche/config"
 	"pinche/internal/cache"
 	"pinche/internal/database"
+	"pinche/internal/job"
 	"pinche/internal/logger"
+	"pinche/internal/metrics"
 	"pinche/internal/router"
+	"pinche/internal/service"
+	"pinche/internal/tracing"
 	"pinche/internal/websocket"
 )
 
@@ -27,12 +39,18 @@ func main() {
 	defer logger.Sync()
 	logger.Info("Logger initialized", "level", cfg.Log.Level)
 
+	// init tracing, spans are exported in batches until the shutdown below
+	if err := tracing.Init(&cfg.Tracing); err != nil {
+		logger.Fatal("Failed to init tracing", "error", err)
+	}
+
 	// init database
 	if err := database.Init(&cfg.Database); err != nil {
 		logger.Fatal("Failed to init database", "error", err)
 	}
 	defer database.Close()
 	logger.Info("Database connected", "host", cfg.Database.Host, "db", cfg.Database.DBName)
+	metrics.RegisterDB(database.DB, cfg.Database.DBName)
 
 	// init redis cache
 	if err := cache.Init(&cfg.Redis); err != nil {
@@ -44,13 +62,57 @@ func main() {
 	wsHub := websocket.NewHub()
 	go wsHub.Run()
 	logger.Info("WebSocket hub started")
+	metrics.RegisterGauge("websocket", "connected_clients", "Open websocket connections.", func() float64 {
+		return float64(wsHub.ClientCount())
+	})
 
-	// setup router
-	r := router.Setup(cfg, wsHub)
+	// setup router, services register their background jobs and hand async work to the worker group
+	scheduler := job.NewScheduler()
+	workers := job.NewGroup()
+	healthService := service.NewHealthService(cfg)
+	r := router.Setup(cfg, wsHub, scheduler, workers, healthService)
+	scheduler.Start()
 
 	// start server
-	logger.Info("Server starting", "port", cfg.Server.Port)
-	if err := r.Run(":" + cfg.Server.Port); err != nil {
-		logger.Fatal("Failed to start server", "error", err)
+	srv := &http.Server{
+		Addr:    ":" + cfg.Server.Port,
+		Handler: r,
+	}
+	go func() {
+		logger.Info("Server starting", "port", cfg.Server.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("Failed to start server", "error", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	logger.Info("Server shutting down", "signal", sig.String(), "timeout", cfg.Server.ShutdownTimeout)
+
+	// fail readiness first and keep serving for a while, so the load balancer stops sending traffic
+	healthService.SetShuttingDown()
+	time.Sleep(time.Duration(cfg.Server.ShutdownDelay) * time.Second)
+
+	// one deadline for all steps, database and redis are closed by the deferred calls afterwards
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout)*time.Second)
+	defer cancel()
+
+	// stop accepting connections and wait for in-flight requests
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("HTTP server shutdown failed", "error", err)
+	}
+	// websocket connections are hijacked and not covered by Shutdown
+	wsHub.Stop(ctx)
+	if err := scheduler.Stop(ctx); err != nil {
+		logger.Error("Job scheduler stop failed", "error", err)
+	}
+	if err := workers.Wait(ctx); err != nil {
+		logger.Error("Background tasks not drained", "error", err)
+	}
+	// export the spans of the last requests and tasks
+	if err := tracing.Shutdown(ctx); err != nil {
+		logger.Error("Tracing shutdown failed", "error", err)
 	}
+	logger.Info("Server stopped")
 }
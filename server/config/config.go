@@ -3,18 +3,99 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	COS      COSConfig
-	Log      LogConfig
-	Admin    AdminConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	Redis      RedisConfig
+	JWT        JWTConfig
+	COS        COSConfig
+	Log        LogConfig
+	Admin      AdminConfig
+	Job        JobConfig
+	Chat       ChatConfig
+	Moderation ModerationConfig
+	RateLimit  RateLimitConfig
+	LoginGuard LoginGuardConfig
+	Metrics    MetricsConfig
+	Tracing    TracingConfig
+}
+
+// TracingConfig sets up OpenTelemetry tracing. Spans of requests, queries, Redis calls,
+// COS uploads and websocket pushes are exported to a collector or printed to stdout.
+type TracingConfig struct {
+	Exporter    string  // none, otlp (gRPC), otlphttp or stdout
+	Endpoint    string  // collector host:port, empty uses OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4317 (4318 for otlphttp)
+	Insecure    bool    // connect to the collector without TLS
+	SampleRatio float64 // share of new traces recorded, traces started by callers follow their sampling decision
+	ServiceName string
+}
+
+type MetricsConfig struct {
+	Enabled bool   // serve Prometheus metrics on /metrics
+	Token   string // bearer token required to scrape /metrics, /metrics is not served without one
+}
+
+// LoginGuardConfig holds the brute-force protection of user and admin password login.
+// Failures are counted per account and per client IP within a day.
+type LoginGuardConfig struct {
+	CaptchaAfter   int // failures on one account before a captcha is required, 0 disables
+	LockAfter      int // failures on one account before it is locked, 0 disables
+	IPCaptchaAfter int // failures from one IP before a captcha is required, 0 disables
+	IPLockAfter    int // failures from one IP before it is locked, 0 disables
+	LockMinutes    int // first lock duration, doubled with every further failure
+	MaxLockMinutes int // upper bound of the lock duration
+}
+
+// RateLimitConfig holds the per-route API rate limits, each rule is set as "<limit>/<window>", e.g. "10/1m"
+type RateLimitConfig struct {
+	Enabled    bool
+	LoginIP    RateLimitRule // login attempts per client IP
+	LoginPhone RateLimitRule // login attempts per phone number, slows down password guessing across IPs
+	Register   RateLimitRule // registrations per client IP
+	Upload     RateLimitRule // uploads per user
+	Grab       RateLimitRule // trip grabs per user
+	Appeal     RateLimitRule // appeals of banned users per client IP
+	Captcha    RateLimitRule // captchas per client IP
+	AdminLogin RateLimitRule // admin login attempts per client IP
+}
+
+// RateLimitRule allows Limit requests per sliding Window, a zero Limit disables the rule
+type RateLimitRule struct {
+	Limit  int
+	Window time.Duration
+}
+
+type ModerationConfig struct {
+	ContactDetection bool   // detect phone numbers and WeChat IDs in public trip remarks
+	ContactAction    string // block, mask or flag, applied to detected contact details
+}
+
+type ChatConfig struct {
+	RecallWindowSeconds    int // how long after sending a message can be recalled
+	LiveLocationHours      int // how long after departure matched users can still share live location
+	RatePerMinute          int // messages a user can send per minute, 0 disables
+	UnrepliedLimit         int // messages to a non-friend before they reply, 0 disables
+	NewConversationsPerDay int // conversations with non-friends a user can start per day, 0 disables
+	SpamFlagThreshold      int // limit hits within a day before the user is flagged to admins, 0 disables
+}
+
+type JobConfig struct {
+	BanExpiryInterval        int // seconds between checks for expired bans
+	StatsRollupInterval      int // seconds between stats rollup runs
+	StatsRollupWindowHours   int // hours of hourly stats recomputed each run
+	StatsFunnelWindowDays    int // days of route funnel recomputed each run, trips keep progressing after publish
+	StatsBackfillDays        int // days of history rolled up when the rollup tables are empty
+	AnnouncementPushInterval int // seconds between checks for urgent announcements to push
+	BroadcastInterval        int // seconds between broadcast delivery batches
+	BroadcastBatchSize       int // recipients per broadcast delivery batch
+	BroadcastRatePerSecond   int // max broadcast messages sent per second
+	SensitiveWordInterval    int // seconds between reloads of the sensitive word dictionary
 }
 
 type AdminConfig struct {
@@ -40,7 +121,9 @@ type LogConfig struct {
 }
 
 type ServerConfig struct {
-	Port string
+	Port            string
+	ShutdownDelay   int // seconds readiness fails before shutdown starts, for the load balancer to move traffic away
+	ShutdownTimeout int // seconds to finish requests, close websockets and drain background tasks on shutdown
 }
 
 type DatabaseConfig struct {
@@ -69,7 +152,9 @@ func Load() *Config {
 
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
+			Port:            getEnv("SERVER_PORT", "8080"),
+			ShutdownDelay:   getEnvInt("SERVER_SHUTDOWN_DELAY", 5),
+			ShutdownTimeout: getEnvInt("SERVER_SHUTDOWN_TIMEOUT", 15),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "127.0.0.1"),
@@ -107,6 +192,60 @@ func Load() *Config {
 			Username: getEnv("ADMIN_USERNAME", "admin"),
 			Password: getEnv("ADMIN_PASSWORD", ""),
 		},
+		Job: JobConfig{
+			BanExpiryInterval:        getEnvInt("JOB_BAN_EXPIRY_INTERVAL", 60),
+			StatsRollupInterval:      getEnvInt("JOB_STATS_ROLLUP_INTERVAL", 300),
+			StatsRollupWindowHours:   getEnvInt("JOB_STATS_ROLLUP_WINDOW_HOURS", 48),
+			StatsFunnelWindowDays:    getEnvInt("JOB_STATS_FUNNEL_WINDOW_DAYS", 14),
+			StatsBackfillDays:        getEnvInt("JOB_STATS_BACKFILL_DAYS", 90),
+			AnnouncementPushInterval: getEnvInt("JOB_ANNOUNCEMENT_PUSH_INTERVAL", 30),
+			BroadcastInterval:        getEnvInt("JOB_BROADCAST_INTERVAL", 5),
+			BroadcastBatchSize:       getEnvInt("JOB_BROADCAST_BATCH_SIZE", 200),
+			BroadcastRatePerSecond:   getEnvInt("JOB_BROADCAST_RATE_PER_SECOND", 50),
+			SensitiveWordInterval:    getEnvInt("JOB_SENSITIVE_WORD_INTERVAL", 60),
+		},
+		Chat: ChatConfig{
+			RecallWindowSeconds:    getEnvInt("CHAT_RECALL_WINDOW_SECONDS", 120),
+			LiveLocationHours:      getEnvInt("CHAT_LIVE_LOCATION_HOURS", 3),
+			RatePerMinute:          getEnvInt("CHAT_RATE_PER_MINUTE", 30),
+			UnrepliedLimit:         getEnvInt("CHAT_UNREPLIED_LIMIT", 3),
+			NewConversationsPerDay: getEnvInt("CHAT_NEW_CONVERSATIONS_PER_DAY", 20),
+			SpamFlagThreshold:      getEnvInt("CHAT_SPAM_FLAG_THRESHOLD", 5),
+		},
+		Moderation: ModerationConfig{
+			ContactDetection: getEnvBool("MODERATION_CONTACT_DETECTION", false),
+			ContactAction:    getEnv("MODERATION_CONTACT_ACTION", "mask"),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:    getEnvBool("RATE_LIMIT_ENABLED", true),
+			LoginIP:    getEnvRateLimit("RATE_LIMIT_LOGIN_IP", "20/1m"),
+			LoginPhone: getEnvRateLimit("RATE_LIMIT_LOGIN_PHONE", "10/15m"),
+			Register:   getEnvRateLimit("RATE_LIMIT_REGISTER", "5/1h"),
+			Upload:     getEnvRateLimit("RATE_LIMIT_UPLOAD", "30/1m"),
+			Grab:       getEnvRateLimit("RATE_LIMIT_GRAB", "10/1m"),
+			Appeal:     getEnvRateLimit("RATE_LIMIT_APPEAL", "5/10m"),
+			Captcha:    getEnvRateLimit("RATE_LIMIT_CAPTCHA", "30/1m"),
+			AdminLogin: getEnvRateLimit("RATE_LIMIT_ADMIN_LOGIN", "10/1m"),
+		},
+		Metrics: MetricsConfig{
+			Enabled: getEnvBool("METRICS_ENABLED", false),
+			Token:   getEnv("METRICS_TOKEN", ""),
+		},
+		Tracing: TracingConfig{
+			Exporter:    getEnv("TRACING_EXPORTER", "none"),
+			Endpoint:    getEnv("TRACING_ENDPOINT", ""),
+			Insecure:    getEnvBool("TRACING_INSECURE", true),
+			SampleRatio: getEnvFloat("TRACING_SAMPLE_RATIO", 1),
+			ServiceName: getEnv("TRACING_SERVICE_NAME", "pinche-server"),
+		},
+		LoginGuard: LoginGuardConfig{
+			CaptchaAfter:   getEnvInt("LOGIN_CAPTCHA_AFTER", 3),
+			LockAfter:      getEnvInt("LOGIN_LOCK_AFTER", 5),
+			IPCaptchaAfter: getEnvInt("LOGIN_IP_CAPTCHA_AFTER", 10),
+			IPLockAfter:    getEnvInt("LOGIN_IP_LOCK_AFTER", 30),
+			LockMinutes:    getEnvInt("LOGIN_LOCK_MINUTES", 5),
+			MaxLockMinutes: getEnvInt("LOGIN_MAX_LOCK_MINUTES", 1440),
+		},
 	}
 }
 
@@ -134,3 +273,37 @@ func getEnvBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
+
+// getEnvRateLimit parses a "<limit>/<window>" rule such as "10/1m", invalid values fall back to the default
+func getEnvRateLimit(key, defaultValue string) RateLimitRule {
+	if rule, ok := parseRateLimitRule(os.Getenv(key)); ok {
+		return rule
+	}
+	rule, _ := parseRateLimitRule(defaultValue)
+	return rule
+}
+
+func parseRateLimitRule(value string) (RateLimitRule, bool) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return RateLimitRule{}, false
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || limit < 0 {
+		return RateLimitRule{}, false
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || window <= 0 {
+		return RateLimitRule{}, false
+	}
+	return RateLimitRule{Limit: limit, Window: window}, true
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRateLimitRule(t *testing.T) {
+	tests := []struct {
+		value string
+		want  RateLimitRule
+		ok    bool
+	}{
+		{"10/1m", RateLimitRule{Limit: 10, Window: time.Minute}, true},
+		{" 5 / 30s ", RateLimitRule{Limit: 5, Window: 30 * time.Second}, true},
+		{"0/1h", RateLimitRule{Limit: 0, Window: time.Hour}, true},
+		{"100/1h30m", RateLimitRule{Limit: 100, Window: 90 * time.Minute}, true},
+		{"", RateLimitRule{}, false},
+		{"10", RateLimitRule{}, false},
+		{"10/", RateLimitRule{}, false},
+		{"/1m", RateLimitRule{}, false},
+		{"-1/1m", RateLimitRule{}, false},
+		{"ten/1m", RateLimitRule{}, false},
+		{"10/minute", RateLimitRule{}, false},
+		{"10/0s", RateLimitRule{}, false},
+		{"10/-1m", RateLimitRule{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRateLimitRule(tt.value)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseRateLimitRule(%q) = %+v, %v, want %+v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGetEnvRateLimitFallsBackToDefault(t *testing.T) {
+	t.Setenv("TEST_RATE_LIMIT", "invalid")
+	got := getEnvRateLimit("TEST_RATE_LIMIT", "3/10s")
+	if want := (RateLimitRule{Limit: 3, Window: 10 * time.Second}); got != want {
+		t.Errorf("getEnvRateLimit = %+v, want %+v", got, want)
+	}
+
+	t.Setenv("TEST_RATE_LIMIT", "7/2m")
+	got = getEnvRateLimit("TEST_RATE_LIMIT", "3/10s")
+	if want := (RateLimitRule{Limit: 7, Window: 2 * time.Minute}); got != want {
+		t.Errorf("getEnvRateLimit = %+v, want %+v", got, want)
+	}
+}
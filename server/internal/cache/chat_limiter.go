@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ChatLimiter keeps the counters behind the private chat anti-spam limits
+type ChatLimiter struct{}
+
+func NewChatLimiter() *ChatLimiter {
+	return &ChatLimiter{}
+}
+
+// SendRate returns how many messages the user sent in the current minute
+func (l *ChatLimiter) SendRate(ctx context.Context, senderID uint64) (int64, error) {
+	return l.count(ctx, l.sendRateKey(senderID))
+}
+
+// HitSendRate counts a message sent by the user in the current minute
+func (l *ChatLimiter) HitSendRate(ctx context.Context, senderID uint64) error {
+	_, err := l.hit(ctx, l.sendRateKey(senderID), 2*time.Minute, false)
+	return err
+}
+
+// NewConversations returns how many conversations the user started today
+func (l *ChatLimiter) NewConversations(ctx context.Context, senderID uint64) (int64, error) {
+	return l.count(ctx, l.newConversationKey(senderID))
+}
+
+// HitNewConversation counts a conversation the user started today
+func (l *ChatLimiter) HitNewConversation(ctx context.Context, senderID uint64) error {
+	_, err := l.hit(ctx, l.newConversationKey(senderID), 25*time.Hour, false)
+	return err
+}
+
+// Unreplied returns how many messages sender sent to receiver since receiver last replied
+func (l *ChatLimiter) Unreplied(ctx context.Context, senderID, receiverID uint64) (int64, error) {
+	return l.count(ctx, l.unrepliedKey(senderID, receiverID))
+}
+
+// AddUnreplied counts a message from sender to receiver that is still waiting for a reply
+func (l *ChatLimiter) AddUnreplied(ctx context.Context, senderID, receiverID uint64) error {
+	_, err := l.hit(ctx, l.unrepliedKey(senderID, receiverID), ChatUnrepliedTTL, true)
+	return err
+}
+
+// ResetUnreplied clears the counter once receiver replied to sender
+func (l *ChatLimiter) ResetUnreplied(ctx context.Context, senderID, receiverID uint64) error {
+	return Delete(ctx, l.unrepliedKey(senderID, receiverID))
+}
+
+// HitViolation counts a limit the user ran into and returns the count within ChatViolationTTL
+func (l *ChatLimiter) HitViolation(ctx context.Context, userID uint64) (int64, error) {
+	return l.hit(ctx, fmt.Sprintf("%s%d", KeyPrefixChatViolation, userID), ChatViolationTTL, false)
+}
+
+func (l *ChatLimiter) sendRateKey(senderID uint64) string {
+	return fmt.Sprintf("%s%d:%d", KeyPrefixChatRate, senderID, time.Now().Unix()/60)
+}
+
+func (l *ChatLimiter) newConversationKey(senderID uint64) string {
+	return fmt.Sprintf("%s%d:%s", KeyPrefixChatNewConv, senderID, time.Now().Format("20060102"))
+}
+
+func (l *ChatLimiter) unrepliedKey(senderID, receiverID uint64) string {
+	return fmt.Sprintf("%s%d:%d", KeyPrefixChatUnreplied, senderID, receiverID)
+}
+
+// count reads a counter, a missing counter is 0
+func (l *ChatLimiter) count(ctx context.Context, key string) (int64, error) {
+	n, err := Client.Get(ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+// hit increments a counter, the TTL is set when the counter is created or, with refresh, on every hit
+func (l *ChatLimiter) hit(ctx context.Context, key string, ttl time.Duration, refresh bool) (int64, error) {
+	n, err := Client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if n == 1 || refresh {
+		if err := Client.Expire(ctx, key, ttl).Err(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
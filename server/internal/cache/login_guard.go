@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LoginGuard keeps the failed login counters, login locks and captcha codes
+type LoginGuard struct{}
+
+func NewLoginGuard() *LoginGuard {
+	return &LoginGuard{}
+}
+
+// HitFailure counts a failed login of subject and returns the count within LoginFailureTTL,
+// the TTL restarts with every failure
+func (g *LoginGuard) HitFailure(ctx context.Context, subject string) (int64, error) {
+	key := KeyPrefixLoginFail + subject
+	n, err := Client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if err := Client.Expire(ctx, key, LoginFailureTTL).Err(); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// Failures returns the failed login counts of the subjects, in the same order
+func (g *LoginGuard) Failures(ctx context.Context, subjects ...string) ([]int64, error) {
+	keys := make([]string, len(subjects))
+	for i, subject := range subjects {
+		keys[i] = KeyPrefixLoginFail + subject
+	}
+	values, err := Client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]int64, len(values))
+	for i, value := range values {
+		if s, ok := value.(string); ok {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			counts[i] = n
+		}
+	}
+	return counts, nil
+}
+
+// ClearFailures resets the failed login count of subject after a successful login
+func (g *LoginGuard) ClearFailures(ctx context.Context, subject string) error {
+	return Delete(ctx, KeyPrefixLoginFail+subject)
+}
+
+// Lock rejects logins of subject for d
+func (g *LoginGuard) Lock(ctx context.Context, subject string, d time.Duration) error {
+	return Client.Set(ctx, KeyPrefixLoginLock+subject, 1, d).Err()
+}
+
+// LockedFor returns how long logins of subject are still locked, 0 if not locked
+func (g *LoginGuard) LockedFor(ctx context.Context, subject string) (time.Duration, error) {
+	ttl, err := Client.PTTL(ctx, KeyPrefixLoginLock+subject).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		// -2 for a missing key, -1 can not happen as locks are always set with a TTL
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// SaveCaptcha stores the code of a captcha for CaptchaTTL
+func (g *LoginGuard) SaveCaptcha(ctx context.Context, id, code string) error {
+	return Client.Set(ctx, KeyPrefixCaptcha+id, code, CaptchaTTL).Err()
+}
+
+// TakeCaptcha returns the code of a captcha and deletes it, so every captcha is checked only once.
+// An unknown or expired captcha returns an empty code.
+func (g *LoginGuard) TakeCaptcha(ctx context.Context, id string) (string, error) {
+	key := KeyPrefixCaptcha + id
+	var get *redis.StringCmd
+	_, err := Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return get.Val(), nil
+}
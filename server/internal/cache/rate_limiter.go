@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// rateLimitTimeout bounds a rate limit check, so a slow Redis does not hold up requests
+const rateLimitTimeout = 200 * time.Millisecond
+
+// slidingWindowScript keeps the request timestamps of the window in a sorted set.
+// KEYS[1] key, ARGV: now (ms), window (ms), limit, member.
+// Returns {allowed, count in window, oldest timestamp in window}.
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], 0, now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], window)
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local first = now
+if oldest[2] then
+	first = tonumber(oldest[2])
+end
+return {allowed, count, first}
+`)
+
+// RateLimitResult is the outcome of a rate limit check
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	ResetAt   time.Time // when the oldest request in the window expires and a slot frees up
+}
+
+// RateLimiter is a sliding window rate limiter shared by all instances through Redis
+type RateLimiter struct{}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{}
+}
+
+// Allow records a request for key if fewer than limit requests were made within window
+func (l *RateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	c, cancel := context.WithTimeout(ctx, rateLimitTimeout)
+	defer cancel()
+
+	now := time.Now()
+	member := fmt.Sprintf("%d-%d", now.UnixNano(), rand.Int63())
+	res, err := slidingWindowScript.Run(c, Client, []string{KeyPrefixRateLimit + key},
+		now.UnixMilli(), window.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit script result: %v", res)
+	}
+
+	return &RateLimitResult{
+		Allowed:   res[0] == 1,
+		Remaining: limit - int(res[1]),
+		ResetAt:   time.UnixMilli(res[2]).Add(window),
+	}, nil
+}
@@ -20,12 +20,31 @@ var (
 const (
 	KeyPrefixTrip     = "trip:"
 	KeyPrefixTripList = "trip_list:"
+	KeyPrefixHotRoute = "hot_routes:"
+
+	KeyPrefixChatRate      = "chat_rate:"
+	KeyPrefixChatUnreplied = "chat_unreplied:"
+	KeyPrefixChatNewConv   = "chat_new_conv:"
+	KeyPrefixChatViolation = "chat_violation:"
+
+	KeyPrefixRateLimit = "rate_limit:"
+
+	KeyPrefixLoginFail = "login_fail:"
+	KeyPrefixLoginLock = "login_lock:"
+	KeyPrefixCaptcha   = "captcha:"
 )
 
 // default TTL
 const (
 	TripDetailTTL = 10 * time.Minute
 	TripListTTL   = 5 * time.Minute
+	HotRoutesTTL  = 10 * time.Minute
+
+	ChatUnrepliedTTL = 7 * 24 * time.Hour
+	ChatViolationTTL = 24 * time.Hour
+
+	LoginFailureTTL = 24 * time.Hour
+	CaptchaTTL      = 5 * time.Minute
 )
 
 func Init(cfg *config.RedisConfig) error {
@@ -34,6 +53,7 @@ func Init(cfg *config.RedisConfig) error {
 		Password: cfg.Password,
 		DB:       cfg.DB,
 	})
+	Client.AddHook(tracingHook{})
 
 	if err := Client.Ping(ctx).Err(); err != nil {
 		logger.Error("Failed to connect to Redis", "error", err)
@@ -52,7 +72,7 @@ func Close() {
 }
 
 // Get retrieves value from cache and unmarshal to target
-func Get(key string, target interface{}) error {
+func Get(ctx context.Context, key string, target interface{}) error {
 	val, err := Client.Get(ctx, key).Result()
 	if err != nil {
 		return err
@@ -61,7 +81,7 @@ func Get(key string, target interface{}) error {
 }
 
 // Set stores value in cache with TTL
-func Set(key string, value interface{}, ttl time.Duration) error {
+func Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
 	data, err := json.Marshal(value)
 	if err != nil {
 		return err
@@ -70,7 +90,7 @@ func Set(key string, value interface{}, ttl time.Duration) error {
 }
 
 // Delete removes key from cache
-func Delete(keys ...string) error {
+func Delete(ctx context.Context, keys ...string) error {
 	if len(keys) == 0 {
 		return nil
 	}
@@ -78,7 +98,7 @@ func Delete(keys ...string) error {
 }
 
 // DeleteByPattern removes all keys matching pattern
-func DeleteByPattern(pattern string) error {
+func DeleteByPattern(ctx context.Context, pattern string) error {
 	iter := Client.Scan(ctx, 0, pattern, 0).Iterator()
 	var keys []string
 	for iter.Next(ctx) {
@@ -94,7 +114,7 @@ func DeleteByPattern(pattern string) error {
 }
 
 // Exists checks if key exists
-func Exists(key string) (bool, error) {
+func Exists(ctx context.Context, key string) (bool, error) {
 	n, err := Client.Exists(ctx, key).Result()
 	return n > 0, err
 }
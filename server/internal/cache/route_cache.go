@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"pinche/internal/logger"
+	"pinche/internal/model"
+)
+
+// RouteCache caches the public hot routes, entries simply expire after HotRoutesTTL
+type RouteCache struct{}
+
+func NewRouteCache() *RouteCache {
+	return &RouteCache{}
+}
+
+// GetHotRoutes gets hot routes from cache
+// Returns nil, nil if not found (cache miss)
+func (c *RouteCache) GetHotRoutes(ctx context.Context, req *model.HotRoutesReq) ([]*model.RouteSupplyDemand, error) {
+	key := c.hotRoutesKey(req)
+	var routes []*model.RouteSupplyDemand
+	err := Get(ctx, key, &routes)
+	if err == redis.Nil {
+		logger.Debug("Cache miss for hot routes", "key", key)
+		return nil, nil
+	}
+	if err != nil {
+		logger.Error("Cache get hot routes failed", "key", key, "error", err)
+		return nil, err
+	}
+	logger.Debug("Cache hit for hot routes", "key", key)
+	return routes, nil
+}
+
+// SetHotRoutes stores hot routes in cache
+func (c *RouteCache) SetHotRoutes(ctx context.Context, req *model.HotRoutesReq, routes []*model.RouteSupplyDemand) error {
+	key := c.hotRoutesKey(req)
+	if err := Set(ctx, key, routes, HotRoutesTTL); err != nil {
+		logger.Error("Cache set hot routes failed", "key", key, "error", err)
+		return err
+	}
+	logger.Debug("Cache set hot routes", "key", key, "count", len(routes), "ttl", HotRoutesTTL)
+	return nil
+}
+
+func (c *RouteCache) hotRoutesKey(req *model.HotRoutesReq) string {
+	return fmt.Sprintf("%s%s:%d:%d", KeyPrefixHotRoute, req.City, req.Days, req.Limit)
+}
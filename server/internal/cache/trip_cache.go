@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	"pinche/internal/logger"
+	"pinche/internal/metrics"
 	"pinche/internal/model"
 )
 
@@ -20,29 +22,32 @@ func NewTripCache() *TripCache {
 
 // GetTrip gets trip from cache
 // Returns nil, nil if not found (cache miss)
-func (c *TripCache) GetTrip(id uint64) (*model.Trip, error) {
+func (c *TripCache) GetTrip(ctx context.Context, id uint64) (*model.Trip, error) {
 	key := TripKey(id)
 	var trip model.Trip
-	err := Get(key, &trip)
+	err := Get(ctx, key, &trip)
 	if err == redis.Nil {
 		logger.Debug("Cache miss for trip", "trip_id", id)
+		metrics.CacheRequests.WithLabelValues("trip", metrics.CacheMiss).Inc()
 		return nil, nil
 	}
 	if err != nil {
 		logger.Error("Cache get trip failed", "trip_id", id, "error", err)
+		metrics.CacheRequests.WithLabelValues("trip", metrics.CacheError).Inc()
 		return nil, err
 	}
 	logger.Debug("Cache hit for trip", "trip_id", id)
+	metrics.CacheRequests.WithLabelValues("trip", metrics.CacheHit).Inc()
 	return &trip, nil
 }
 
 // SetTrip stores trip in cache
-func (c *TripCache) SetTrip(trip *model.Trip) error {
+func (c *TripCache) SetTrip(ctx context.Context, trip *model.Trip) error {
 	if trip == nil {
 		return nil
 	}
 	key := TripKey(trip.ID)
-	if err := Set(key, trip, TripDetailTTL); err != nil {
+	if err := Set(ctx, key, trip, TripDetailTTL); err != nil {
 		logger.Error("Cache set trip failed", "trip_id", trip.ID, "error", err)
 		return err
 	}
@@ -51,9 +56,9 @@ func (c *TripCache) SetTrip(trip *model.Trip) error {
 }
 
 // InvalidateTrip removes trip from cache
-func (c *TripCache) InvalidateTrip(id uint64) error {
+func (c *TripCache) InvalidateTrip(ctx context.Context, id uint64) error {
 	key := TripKey(id)
-	if err := Delete(key); err != nil {
+	if err := Delete(ctx, key); err != nil {
 		logger.Error("Cache invalidate trip failed", "trip_id", id, "error", err)
 		return err
 	}
@@ -68,30 +73,33 @@ type TripListResult struct {
 }
 
 // GetTripList gets trip list from cache
-func (c *TripCache) GetTripList(req *model.TripListReq) (*TripListResult, error) {
+func (c *TripCache) GetTripList(ctx context.Context, req *model.TripListReq) (*TripListResult, error) {
 	key := c.listCacheKey(req)
 	var result TripListResult
-	err := Get(key, &result)
+	err := Get(ctx, key, &result)
 	if err == redis.Nil {
 		logger.Debug("Cache miss for trip list", "key", key)
+		metrics.CacheRequests.WithLabelValues("trip_list", metrics.CacheMiss).Inc()
 		return nil, nil
 	}
 	if err != nil {
 		logger.Error("Cache get trip list failed", "key", key, "error", err)
+		metrics.CacheRequests.WithLabelValues("trip_list", metrics.CacheError).Inc()
 		return nil, err
 	}
 	logger.Debug("Cache hit for trip list", "key", key)
+	metrics.CacheRequests.WithLabelValues("trip_list", metrics.CacheHit).Inc()
 	return &result, nil
 }
 
 // SetTripList stores trip list in cache
-func (c *TripCache) SetTripList(req *model.TripListReq, list []*model.Trip, total int64) error {
+func (c *TripCache) SetTripList(ctx context.Context, req *model.TripListReq, list []*model.Trip, total int64) error {
 	key := c.listCacheKey(req)
 	result := &TripListResult{
 		List:  list,
 		Total: total,
 	}
-	if err := Set(key, result, TripListTTL); err != nil {
+	if err := Set(ctx, key, result, TripListTTL); err != nil {
 		logger.Error("Cache set trip list failed", "key", key, "error", err)
 		return err
 	}
@@ -100,9 +108,9 @@ func (c *TripCache) SetTripList(req *model.TripListReq, list []*model.Trip, tota
 }
 
 // InvalidateTripLists removes all trip list caches
-func (c *TripCache) InvalidateTripLists() error {
+func (c *TripCache) InvalidateTripLists(ctx context.Context) error {
 	pattern := KeyPrefixTripList + "*"
-	if err := DeleteByPattern(pattern); err != nil {
+	if err := DeleteByPattern(ctx, pattern); err != nil {
 		logger.Error("Cache invalidate trip lists failed", "error", err)
 		return err
 	}
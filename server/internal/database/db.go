@@ -1,13 +1,17 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"pinche/config"
 	"pinche/internal/logger"
+	"pinche/internal/tracing"
 )
 
 var DB *sql.DB
@@ -46,3 +50,12 @@ func Close() {
 		logger.Info("Database connection closed")
 	}
 }
+
+// StartSpan starts the span of a query made within a traced request or task, end it with tracing.End.
+// Methods running a batch of unrelated statements pass an empty query.
+func StartSpan(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	if query == "" {
+		return tracing.StartChild(ctx, name, semconv.DBSystemMySQL)
+	}
+	return tracing.StartChild(ctx, name, semconv.DBSystemMySQL, semconv.DBQueryText(query))
+}
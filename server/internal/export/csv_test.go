@@ -0,0 +1,51 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"", ""},
+		{"张三", "张三"},
+		{"a=b", "a=b"},
+		{"=1+1", "'=1+1"},
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+86 138", "'+86 138"},
+		{"-cmd|' /C calc'!A0", "'-cmd|' /C calc'!A0"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"-12.5", "-12.5"},
+		{"+3", "+3"},
+	}
+	for _, tt := range tests {
+		if got := escapeFormula(tt.cell); got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+}
+
+func TestCSVWriterEscapesCells(t *testing.T) {
+	var buf bytes.Buffer
+	w := newCSVWriter(&buf)
+	row := []string{"=1+1", "ok"}
+	if err := w.WriteRow(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "\xEF\xBB\xBF'=1+1,ok\n"
+	if got := buf.String(); got != want {
+		t.Errorf("csv output = %q, want %q", got, want)
+	}
+	if row[0] != "=1+1" {
+		t.Errorf("WriteRow modified the row of the caller: %q", row[0])
+	}
+}
@@ -0,0 +1,45 @@
+// Package export writes tabular admin exports as CSV or XLSX, streaming rows
+// to the underlying writer so memory use does not grow with the row count
+package export
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Writer writes a header row followed by data rows
+type Writer interface {
+	WriteRow(cells []string) error
+	// Close flushes buffered data and finishes the file, it does not close the underlying writer
+	Close() error
+}
+
+// NewWriter creates a writer for the format, sheet is used as the XLSX sheet name
+func NewWriter(format string, w io.Writer, sheet string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatXLSX:
+		return newXLSXWriter(w, sheet)
+	default:
+		return nil, errors.New("unsupported export format: " + format)
+	}
+}
+
+// IsValidFormat checks if the export format is supported
+func IsValidFormat(format string) bool {
+	return format == FormatCSV || format == FormatXLSX
+}
+
+// ContentType returns the HTTP content type of the format
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
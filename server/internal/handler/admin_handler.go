@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"pinche/config"
+	"pinche/internal/logger"
+	"pinche/internal/middleware"
+	"pinche/internal/model"
+	"pinche/internal/service"
+)
+
+type AdminHandler struct {
+	service *service.AdminService
+	cfg     *config.Config
+}
+
+func NewAdminHandler(service *service.AdminService, cfg *config.Config) *AdminHandler {
+	return &AdminHandler{service: service, cfg: cfg}
+}
+
+// Login handles POST /api/admin/login
+func (h *AdminHandler) Login(c *gin.Context) {
+	var req model.AdminLoginReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误"))
+		return
+	}
+
+	admin, err := h.service.Login(c.Request.Context(), &req, c.ClientIP())
+	if err != nil {
+		// only credential, lock and captcha errors are meant for the client
+		var loginErr *service.LoginError
+		if !errors.As(err, &loginErr) && !errors.Is(err, service.ErrAdminDisabled) {
+			c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "登录失败"))
+			return
+		}
+		code := model.ErrCodeUnauthorized
+		if _, loginCode := loginErrorCode(c, err); loginCode != model.ErrCodeBadRequest {
+			code = loginCode
+		}
+		c.JSON(http.StatusOK, model.Error(code, err.Error()))
+		return
+	}
+
+	token, err := middleware.GenerateAdminToken(admin, h.cfg.JWT.Secret, h.cfg.JWT.ExpireHour)
+	if err != nil {
+		logger.Error("Admin login failed: token generation error", "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "登录失败"))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success(&model.AdminLoginResp{
+		Token:    token,
+		Username: admin.Username,
+		Admin:    admin,
+	}))
+}
+
+// GetMe handles GET /api/admin/me
+func (h *AdminHandler) GetMe(c *gin.Context) {
+	admin, err := h.service.GetByID(c.Request.Context(), middleware.GetAdminID(c))
+	if err != nil || admin == nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "获取管理员信息失败"))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(admin))
+}
+
+// List handles GET /api/admin/admins
+func (h *AdminHandler) List(c *gin.Context) {
+	var req model.AdminListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误"))
+		return
+	}
+
+	resp, err := h.service.List(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error("Admin list admins failed", "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "获取管理员列表失败"))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success(resp))
+}
+
+// Create handles POST /api/admin/admins
+func (h *AdminHandler) Create(c *gin.Context) {
+	var req model.AdminCreateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	admin, err := h.service.Create(c.Request.Context(), &req, middleware.GetAdminUsername(c))
+	if err != nil {
+		logger.Error("Admin create failed", "username", req.Username, "error", err)
+		middleware.SetAuditTarget(c, model.AuditActionAdminCreate, model.AuditTargetAdmin, "")
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, adminFailureMessage(err, "创建管理员失败")))
+		return
+	}
+	middleware.SetAuditTarget(c, model.AuditActionAdminCreate, model.AuditTargetAdmin, strconv.FormatUint(admin.ID, 10))
+	middleware.SetAuditState(c, nil, admin)
+
+	c.JSON(http.StatusOK, model.Success(admin))
+}
+
+// Disable handles POST /api/admin/admins/:id/disable
+func (h *AdminHandler) Disable(c *gin.Context) {
+	h.setDisabled(c, true)
+}
+
+// Enable handles POST /api/admin/admins/:id/enable
+func (h *AdminHandler) Enable(c *gin.Context) {
+	h.setDisabled(c, false)
+}
+
+func (h *AdminHandler) setDisabled(c *gin.Context, disabled bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的管理员ID"))
+		return
+	}
+
+	action := model.AuditActionAdminEnable
+	if disabled {
+		action = model.AuditActionAdminDisable
+	}
+	middleware.SetAuditTarget(c, action, model.AuditTargetAdmin, c.Param("id"))
+	h.setAuditBefore(c, id)
+
+	admin, err := h.service.SetDisabled(c.Request.Context(), id, disabled, middleware.GetAdminID(c))
+	if err != nil {
+		logger.Error("Admin set disabled failed", "admin_id", id, "disabled", disabled, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, adminFailureMessage(err, "更新管理员状态失败")))
+		return
+	}
+
+	middleware.SetAuditState(c, nil, admin)
+	c.JSON(http.StatusOK, model.Success(admin))
+}
+
+// UpdateRole handles PUT /api/admin/admins/:id/role
+func (h *AdminHandler) UpdateRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的管理员ID"))
+		return
+	}
+
+	var req model.AdminUpdateRoleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误"))
+		return
+	}
+
+	middleware.SetAuditTarget(c, model.AuditActionAdminUpdateRole, model.AuditTargetAdmin, c.Param("id"))
+	h.setAuditBefore(c, id)
+
+	admin, err := h.service.UpdateRole(c.Request.Context(), id, req.Role, middleware.GetAdminID(c))
+	if err != nil {
+		logger.Error("Admin update role failed", "admin_id", id, "role", req.Role, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, adminFailureMessage(err, "更新管理员角色失败")))
+		return
+	}
+
+	middleware.SetAuditState(c, nil, admin)
+	c.JSON(http.StatusOK, model.Success(admin))
+}
+
+// ResetPassword handles POST /api/admin/admins/:id/reset-password
+func (h *AdminHandler) ResetPassword(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的管理员ID"))
+		return
+	}
+
+	var req model.AdminResetPasswordReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误"))
+		return
+	}
+
+	// password hashes are never written to the audit log
+	middleware.SetAuditTarget(c, model.AuditActionAdminResetPassword, model.AuditTargetAdmin, c.Param("id"))
+
+	if err := h.service.ResetPassword(c.Request.Context(), id, req.Password, middleware.GetAdminID(c)); err != nil {
+		logger.Error("Admin reset password failed", "admin_id", id, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, adminFailureMessage(err, "重置密码失败")))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success(nil))
+}
+
+// setAuditBefore snapshots the target admin account before a mutation
+func (h *AdminHandler) setAuditBefore(c *gin.Context, id uint64) {
+	if admin, err := h.service.GetByID(c.Request.Context(), id); err == nil && admin != nil {
+		middleware.SetAuditState(c, admin, nil)
+	}
+}
+
+// adminFailureMessage keeps the validation errors of the admin service and hides the others behind fallback
+func adminFailureMessage(err error, fallback string) string {
+	switch {
+	case errors.Is(err, service.ErrInvalidAdminRole), errors.Is(err, service.ErrAdminUsernameTaken),
+		errors.Is(err, service.ErrAdminNotFound), errors.Is(err, service.ErrDisableSelf),
+		errors.Is(err, service.ErrLastSuperAdmin):
+		return err.Error()
+	}
+	return fallback
+}
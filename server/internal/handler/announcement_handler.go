@@ -3,9 +3,11 @@ package handler
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"pinche/internal/logger"
+	"pinche/internal/middleware"
 	"pinche/internal/model"
 	"pinche/internal/service"
 )
@@ -19,14 +21,22 @@ func NewAnnouncementHandler(s *service.AnnouncementService) *AnnouncementHandler
 }
 
 // GetActiveAnnouncements returns currently active announcements for public display
+// Logged in users get announcements targeted at them, minus the ones they dismissed
 func (h *AnnouncementHandler) GetActiveAnnouncements(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "3"))
 	if limit < 1 || limit > 10 {
 		limit = 5
 	}
 
-	announcements, err := h.service.GetActiveAnnouncements(limit)
+	platform := c.Query("platform")
+	if platform == "" {
+		platform = c.GetHeader("X-Platform")
+	}
+
+	userID := middleware.GetUserID(c)
+	announcements, err := h.service.GetActiveAnnouncements(c.Request.Context(), userID, strings.ToLower(platform), limit)
 	if err != nil {
+		logger.Error("Get active announcements failed", "user_id", userID, "error", err)
 		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "Failed to get announcements"))
 		return
 	}
@@ -36,12 +46,27 @@ func (h *AnnouncementHandler) GetActiveAnnouncements(c *gin.Context) {
 	c.JSON(http.StatusOK, model.Success(announcements))
 }
 
+// Dismiss hides an announcement for the current user
+func (h *AnnouncementHandler) Dismiss(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "Invalid ID"))
+		return
+	}
+
+	if err := h.service.Dismiss(c.Request.Context(), middleware.GetUserID(c), id); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(nil))
+}
+
 // ListAll returns all announcements for admin panel
 func (h *AnnouncementHandler) ListAll(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
 
-	list, total, err := h.service.ListAll(page, pageSize)
+	list, total, err := h.service.ListAll(c.Request.Context(), page, pageSize)
 	if err != nil {
 		logger.Error("Admin list announcements failed", "error", err)
 		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "Failed to get announcements"))
@@ -65,12 +90,14 @@ func (h *AnnouncementHandler) Create(c *gin.Context) {
 		return
 	}
 
-	ann, err := h.service.Create(&req)
+	ann, err := h.service.Create(c.Request.Context(), &req)
 	if err != nil {
 		logger.Error("Admin create announcement failed", "title", req.Title, "error", err)
-		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "Failed to create announcement"))
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "Failed to create announcement: "+err.Error()))
 		return
 	}
+	middleware.SetAuditTarget(c, model.AuditActionAnnouncementCreate, model.AuditTargetAnnouncement, strconv.FormatUint(ann.ID, 10))
+	middleware.SetAuditState(c, nil, ann)
 	logger.Info("Admin created announcement", "id", ann.ID, "title", ann.Title)
 	c.JSON(http.StatusOK, model.Success(ann))
 }
@@ -89,16 +116,22 @@ func (h *AnnouncementHandler) Update(c *gin.Context) {
 		return
 	}
 
-	ann, err := h.service.Update(id, &req)
+	middleware.SetAuditTarget(c, model.AuditActionAnnouncementUpdate, model.AuditTargetAnnouncement, c.Param("id"))
+	if before, err := h.service.GetByID(c.Request.Context(), id); err == nil && before != nil {
+		middleware.SetAuditState(c, before, nil)
+	}
+
+	ann, err := h.service.Update(c.Request.Context(), id, &req)
 	if err != nil {
 		logger.Error("Admin update announcement failed", "id", id, "error", err)
-		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "Failed to update announcement"))
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "Failed to update announcement: "+err.Error()))
 		return
 	}
 	if ann == nil {
 		c.JSON(http.StatusOK, model.Error(model.ErrCodeNotFound, "Announcement not found"))
 		return
 	}
+	middleware.SetAuditState(c, nil, ann)
 	logger.Info("Admin updated announcement", "id", id, "title", ann.Title)
 	c.JSON(http.StatusOK, model.Success(ann))
 }
@@ -111,7 +144,12 @@ func (h *AnnouncementHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Delete(id); err != nil {
+	middleware.SetAuditTarget(c, model.AuditActionAnnouncementDelete, model.AuditTargetAnnouncement, c.Param("id"))
+	if before, err := h.service.GetByID(c.Request.Context(), id); err == nil && before != nil {
+		middleware.SetAuditState(c, before, nil)
+	}
+
+	if err := h.service.Delete(c.Request.Context(), id); err != nil {
 		logger.Error("Admin delete announcement failed", "id", id, "error", err)
 		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "Failed to delete announcement"))
 		return
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"pinche/internal/logger"
+	"pinche/internal/model"
+	"pinche/internal/service"
+)
+
+type AuditHandler struct {
+	service *service.AuditService
+}
+
+func NewAuditHandler(s *service.AuditService) *AuditHandler {
+	return &AuditHandler{service: s}
+}
+
+// List handles GET /api/admin/audit-logs
+func (h *AuditHandler) List(c *gin.Context) {
+	var req model.AdminAuditLogListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误"))
+		return
+	}
+
+	resp, err := h.service.List(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error("Admin list audit logs failed", "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "获取审计日志失败"))
+		return
+	}
+	if resp.List == nil {
+		resp.List = []*model.AdminAuditLog{}
+	}
+
+	c.JSON(http.StatusOK, model.Success(resp))
+}
@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"pinche/internal/logger"
+	"pinche/internal/middleware"
+	"pinche/internal/model"
+	"pinche/internal/service"
+)
+
+type BanHandler struct {
+	service *service.BanService
+}
+
+func NewBanHandler(s *service.BanService) *BanHandler {
+	return &BanHandler{service: s}
+}
+
+// GetMyBans handles GET /api/bans, returns active bans of the current user
+func (h *BanHandler) GetMyBans(c *gin.Context) {
+	bans, err := h.service.GetMyBans(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取封禁记录失败"))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(bans))
+}
+
+// CreateAppeal handles POST /api/appeals
+func (h *BanHandler) CreateAppeal(c *gin.Context) {
+	var req model.AppealCreateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	appeal, err := h.service.CreateAppeal(c.Request.Context(), middleware.GetUserID(c), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(appeal))
+}
+
+// CreateBannedUserAppeal handles POST /api/user/appeal for banned users who cannot log in
+func (h *BanHandler) CreateBannedUserAppeal(c *gin.Context) {
+	var req model.BannedUserAppealReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	appeal, err := h.service.CreateAppealByCredentials(c.Request.Context(), &req, c.ClientIP())
+	if err != nil {
+		status, code := loginErrorCode(c, err)
+		c.JSON(status, model.Error(code, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(appeal))
+}
+
+// AdminBanUser handles POST /api/admin/users/:id/ban, id is the user open_id
+func (h *BanHandler) AdminBanUser(c *gin.Context) {
+	openID := c.Param("id")
+	if openID == "" {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的用户ID"))
+		return
+	}
+	req, ok := bindBanReq(c)
+	if !ok {
+		return
+	}
+
+	middleware.SetAuditTarget(c, model.AuditActionUserBan, model.AuditTargetUser, openID)
+	ban, err := h.service.BanUser(c.Request.Context(), openID, req, banOperator(c))
+	if err != nil {
+		logger.Error("Admin ban user failed", "open_id", openID, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, banFailureMessage(err, "封禁用户失败")))
+		return
+	}
+
+	middleware.SetAuditState(c, nil, ban)
+	c.JSON(http.StatusOK, model.Success(ban))
+}
+
+// AdminUnbanUser handles POST /api/admin/users/:id/unban
+func (h *BanHandler) AdminUnbanUser(c *gin.Context) {
+	openID := c.Param("id")
+	if openID == "" {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的用户ID"))
+		return
+	}
+
+	middleware.SetAuditTarget(c, model.AuditActionUserUnban, model.AuditTargetUser, openID)
+	ban, err := h.service.UnbanUser(c.Request.Context(), openID, banOperator(c))
+	if err != nil {
+		logger.Error("Admin unban user failed", "open_id", openID, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, banFailureMessage(err, "解封用户失败")))
+		return
+	}
+
+	setLiftAuditState(c, ban)
+	c.JSON(http.StatusOK, model.Success(ban))
+}
+
+// AdminBanTrip handles POST /api/admin/trips/:id/ban
+func (h *BanHandler) AdminBanTrip(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的行程ID"))
+		return
+	}
+	req, ok := bindBanReq(c)
+	if !ok {
+		return
+	}
+
+	middleware.SetAuditTarget(c, model.AuditActionTripBan, model.AuditTargetTrip, c.Param("id"))
+	ban, err := h.service.BanTrip(c.Request.Context(), id, req, banOperator(c))
+	if err != nil {
+		logger.Error("Admin ban trip failed", "trip_id", id, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, banFailureMessage(err, "封禁行程失败")))
+		return
+	}
+
+	middleware.SetAuditState(c, nil, ban)
+	c.JSON(http.StatusOK, model.Success(ban))
+}
+
+// AdminUnbanTrip handles POST /api/admin/trips/:id/unban
+func (h *BanHandler) AdminUnbanTrip(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的行程ID"))
+		return
+	}
+
+	middleware.SetAuditTarget(c, model.AuditActionTripUnban, model.AuditTargetTrip, c.Param("id"))
+	ban, err := h.service.UnbanTrip(c.Request.Context(), id, banOperator(c))
+	if err != nil {
+		logger.Error("Admin unban trip failed", "trip_id", id, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, banFailureMessage(err, "解封行程失败")))
+		return
+	}
+
+	setLiftAuditState(c, ban)
+	c.JSON(http.StatusOK, model.Success(ban))
+}
+
+// AdminListBans handles GET /api/admin/bans
+func (h *BanHandler) AdminListBans(c *gin.Context) {
+	var req model.BanListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误"))
+		return
+	}
+
+	resp, err := h.service.ListBans(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error("Admin list bans failed", "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "获取封禁列表失败"))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(resp))
+}
+
+// AdminListAppeals handles GET /api/admin/appeals
+func (h *BanHandler) AdminListAppeals(c *gin.Context) {
+	var req model.AppealListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误"))
+		return
+	}
+
+	resp, err := h.service.ListAppeals(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error("Admin list appeals failed", "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "获取申诉列表失败"))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(resp))
+}
+
+// AdminApproveAppeal handles POST /api/admin/appeals/:id/approve
+func (h *BanHandler) AdminApproveAppeal(c *gin.Context) {
+	h.reviewAppeal(c, true)
+}
+
+// AdminRejectAppeal handles POST /api/admin/appeals/:id/reject
+func (h *BanHandler) AdminRejectAppeal(c *gin.Context) {
+	h.reviewAppeal(c, false)
+}
+
+func (h *BanHandler) reviewAppeal(c *gin.Context, approve bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的申诉ID"))
+		return
+	}
+
+	var req model.AppealReviewReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+			return
+		}
+	}
+
+	action := model.AuditActionAppealReject
+	if approve {
+		action = model.AuditActionAppealApprove
+	}
+	middleware.SetAuditTarget(c, action, model.AuditTargetAppeal, c.Param("id"))
+	if before, err := h.service.GetAppeal(c.Request.Context(), id); err == nil && before != nil {
+		middleware.SetAuditState(c, before, nil)
+	}
+
+	var appeal *model.Appeal
+	if approve {
+		appeal, err = h.service.ApproveAppeal(c.Request.Context(), id, req.Note, banOperator(c))
+	} else {
+		appeal, err = h.service.RejectAppeal(c.Request.Context(), id, req.Note, banOperator(c))
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+
+	middleware.SetAuditState(c, nil, appeal)
+	c.JSON(http.StatusOK, model.Success(appeal))
+}
+
+// bindBanReq binds the optional ban body, an empty body bans permanently with the default reason
+func bindBanReq(c *gin.Context) (*model.AdminBanReq, bool) {
+	req := &model.AdminBanReq{}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(req); err != nil {
+			c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+			return nil, false
+		}
+	}
+	return req, true
+}
+
+func banOperator(c *gin.Context) model.BanOperator {
+	return model.BanOperator{
+		AdminID:  middleware.GetAdminID(c),
+		Username: middleware.GetAdminUsername(c),
+	}
+}
+
+// setLiftAuditState records an unban, ban is nil for legacy bans without a record
+func setLiftAuditState(c *gin.Context, ban *model.Ban) {
+	if ban == nil {
+		middleware.SetAuditState(c, gin.H{"status": "banned"}, gin.H{"status": "normal"})
+		return
+	}
+	middleware.SetAuditState(c, gin.H{"ban_id": ban.ID, "status": model.BanStatusActive}, ban)
+}
+
+// banFailureMessage keeps the validation errors of the ban service and hides the others behind fallback
+func banFailureMessage(err error, fallback string) string {
+	switch {
+	case errors.Is(err, service.ErrBanUserNotFound), errors.Is(err, service.ErrBanTripNotFound),
+		errors.Is(err, service.ErrUserAlreadyBanned), errors.Is(err, service.ErrTripAlreadyBanned):
+		return err.Error()
+	}
+	return fallback
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"pinche/internal/logger"
+	"pinche/internal/middleware"
+	"pinche/internal/model"
+	"pinche/internal/service"
+)
+
+type BroadcastHandler struct {
+	service *service.BroadcastService
+}
+
+func NewBroadcastHandler(s *service.BroadcastService) *BroadcastHandler {
+	return &BroadcastHandler{service: s}
+}
+
+// Create handles POST /api/admin/broadcasts
+func (h *BroadcastHandler) Create(c *gin.Context) {
+	var req model.BroadcastCreateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	b, err := h.service.Create(c.Request.Context(), &req, middleware.GetAdminID(c), middleware.GetAdminUsername(c))
+	if err != nil {
+		logger.Warn("Admin create broadcast failed", "audience_type", req.AudienceType, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "创建广播失败: "+err.Error()))
+		return
+	}
+
+	middleware.SetAuditTarget(c, model.AuditActionBroadcastCreate, model.AuditTargetBroadcast, strconv.FormatUint(b.ID, 10))
+	middleware.SetAuditState(c, nil, b)
+	c.JSON(http.StatusOK, model.Success(b))
+}
+
+// List handles GET /api/admin/broadcasts
+func (h *BroadcastHandler) List(c *gin.Context) {
+	var req model.BroadcastListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误"))
+		return
+	}
+
+	resp, err := h.service.List(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error("Admin list broadcasts failed", "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "获取广播列表失败"))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(resp))
+}
+
+// GetByID handles GET /api/admin/broadcasts/:id, used to poll delivery progress
+func (h *BroadcastHandler) GetByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的广播ID"))
+		return
+	}
+
+	b, err := h.service.GetByID(c.Request.Context(), id)
+	if err != nil {
+		logger.Error("Admin get broadcast failed", "id", id, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "获取广播失败"))
+		return
+	}
+	if b == nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeNotFound, "广播不存在"))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(b))
+}
+
+// Cancel handles POST /api/admin/broadcasts/:id/cancel
+func (h *BroadcastHandler) Cancel(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的广播ID"))
+		return
+	}
+
+	middleware.SetAuditTarget(c, model.AuditActionBroadcastCancel, model.AuditTargetBroadcast, c.Param("id"))
+	b, err := h.service.Cancel(c.Request.Context(), id)
+	if err != nil {
+		logger.Warn("Admin cancel broadcast failed", "id", id, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "取消广播失败: "+err.Error()))
+		return
+	}
+
+	middleware.SetAuditState(c, nil, b)
+	c.JSON(http.StatusOK, model.Success(b))
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"pinche/internal/model"
+	"pinche/internal/service"
+)
+
+type CaptchaHandler struct {
+	service *service.CaptchaService
+}
+
+func NewCaptchaHandler(service *service.CaptchaService) *CaptchaHandler {
+	return &CaptchaHandler{service: service}
+}
+
+// Create handles GET /api/captcha, the image is a PNG data URI
+func (h *CaptchaHandler) Create(c *gin.Context) {
+	captcha, err := h.service.Create(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取验证码失败"))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(captcha))
+}
+
+// loginErrorCode maps a login error to its HTTP status and error code: 429 with Retry-After while
+// the account or IP is locked, 428 when the next attempt needs a captcha, 400 otherwise
+func loginErrorCode(c *gin.Context, err error) (int, int) {
+	var loginErr *service.LoginError
+	if !errors.As(err, &loginErr) {
+		return http.StatusBadRequest, model.ErrCodeBadRequest
+	}
+	if loginErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(loginErr.RetryAfter.Seconds()+1)))
+		return http.StatusTooManyRequests, model.ErrCodeTooManyRequests
+	}
+	if loginErr.CaptchaRequired {
+		return http.StatusPreconditionRequired, model.ErrCodeCaptchaRequired
+	}
+	return http.StatusBadRequest, model.ErrCodeBadRequest
+}
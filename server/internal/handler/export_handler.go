@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"pinche/internal/export"
+	"pinche/internal/logger"
+	"pinche/internal/middleware"
+	"pinche/internal/model"
+	"pinche/internal/service"
+)
+
+type ExportHandler struct {
+	service *service.ExportService
+}
+
+func NewExportHandler(s *service.ExportService) *ExportHandler {
+	return &ExportHandler{service: s}
+}
+
+// ExportUsers handles GET /api/admin/export/users?format=csv|xlsx, takes the user list filters
+func (h *ExportHandler) ExportUsers(c *gin.Context) {
+	var req model.AdminUserListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误"))
+		return
+	}
+	h.stream(c, "users", "用户", func(w export.Writer, showSensitive bool) (int, error) {
+		return h.service.ExportUsers(c.Request.Context(), w, &req, showSensitive)
+	})
+}
+
+// ExportTrips handles GET /api/admin/export/trips?format=csv|xlsx, takes the trip list filters
+func (h *ExportHandler) ExportTrips(c *gin.Context) {
+	var req model.AdminTripListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误"))
+		return
+	}
+	h.stream(c, "trips", "行程", func(w export.Writer, showSensitive bool) (int, error) {
+		return h.service.ExportTrips(c.Request.Context(), w, &req, showSensitive)
+	})
+}
+
+// ExportMatches handles GET /api/admin/export/matches?format=csv|xlsx
+func (h *ExportHandler) ExportMatches(c *gin.Context) {
+	var req model.AdminMatchExportReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误"))
+		return
+	}
+	h.stream(c, "matches", "匹配", func(w export.Writer, showSensitive bool) (int, error) {
+		return h.service.ExportMatches(c.Request.Context(), w, &req, showSensitive)
+	})
+}
+
+// ExportReports handles GET /api/admin/export/reports?format=csv|xlsx, exports the content flag review queue
+func (h *ExportHandler) ExportReports(c *gin.Context) {
+	var req model.ContentFlagExportReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误"))
+		return
+	}
+	h.stream(c, "reports", "举报审核", func(w export.Writer, showSensitive bool) (int, error) {
+		return h.service.ExportReports(c.Request.Context(), w, &req, showSensitive)
+	})
+}
+
+// stream writes the export straight to the response
+// Once rows are being written the status can no longer change, so later errors only truncate the file
+func (h *ExportHandler) stream(c *gin.Context, entity, sheet string, write func(export.Writer, bool) (int, error)) {
+	format := c.DefaultQuery("format", export.FormatCSV)
+	if !export.IsValidFormat(format) {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "不支持的导出格式"))
+		return
+	}
+
+	showSensitive := model.AdminHasPermission(middleware.GetAdminRole(c), model.AdminPermSensitiveView)
+	filename := service.ExportFilename(entity, format)
+
+	c.Header("Content-Type", export.ContentType(format))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(format, c.Writer, sheet)
+	if err != nil {
+		logger.Error("Admin export init failed", "entity", entity, "format", format, "error", err)
+		return
+	}
+	rows, err := write(w, showSensitive)
+	if err != nil {
+		logger.Error("Admin export failed", "entity", entity, "format", format, "rows", rows, "error", err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		logger.Error("Admin export close failed", "entity", entity, "format", format, "error", err)
+		return
+	}
+
+	logger.Info("Admin exported data", "entity", entity, "format", format, "rows", rows,
+		"admin", middleware.GetAdminUsername(c), "sensitive", showSensitive)
+}
@@ -14,10 +14,8 @@ type FriendHandler struct {
 	service *service.FriendService
 }
 
-func NewFriendHandler() *FriendHandler {
-	return &FriendHandler{
-		service: service.NewFriendService(),
-	}
+func NewFriendHandler(service *service.FriendService) *FriendHandler {
+	return &FriendHandler{service: service}
 }
 
 // SendFriendRequest handles POST /api/friends/request
@@ -30,7 +28,7 @@ func (h *FriendHandler) SendFriendRequest(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.SendFriendRequest(userID, &req); err != nil {
+	if err := h.service.SendFriendRequest(c.Request.Context(), userID, &req); err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
@@ -42,7 +40,7 @@ func (h *FriendHandler) SendFriendRequest(c *gin.Context) {
 func (h *FriendHandler) GetFriendRequests(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
-	resp, err := h.service.GetFriendRequests(userID)
+	resp, err := h.service.GetFriendRequests(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取好友申请失败"))
 		return
@@ -61,7 +59,7 @@ func (h *FriendHandler) AcceptFriendRequest(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.AcceptFriendRequest(userID, requestID); err != nil {
+	if err := h.service.AcceptFriendRequest(c.Request.Context(), userID, requestID); err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
@@ -79,7 +77,7 @@ func (h *FriendHandler) RejectFriendRequest(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.RejectFriendRequest(userID, requestID); err != nil {
+	if err := h.service.RejectFriendRequest(c.Request.Context(), userID, requestID); err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
@@ -97,7 +95,7 @@ func (h *FriendHandler) CancelFriendRequest(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.CancelFriendRequest(userID, requestID); err != nil {
+	if err := h.service.CancelFriendRequest(c.Request.Context(), userID, requestID); err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
@@ -109,7 +107,7 @@ func (h *FriendHandler) CancelFriendRequest(c *gin.Context) {
 func (h *FriendHandler) GetFriends(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
-	resp, err := h.service.GetFriends(userID)
+	resp, err := h.service.GetFriends(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取好友列表失败"))
 		return
@@ -128,7 +126,7 @@ func (h *FriendHandler) DeleteFriend(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteFriend(userID, friendOpenID); err != nil {
+	if err := h.service.DeleteFriend(c.Request.Context(), userID, friendOpenID); err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
@@ -146,7 +144,7 @@ func (h *FriendHandler) GetUserProfile(c *gin.Context) {
 		return
 	}
 
-	profile, err := h.service.GetUserPublicProfile(userID, targetOpenID)
+	profile, err := h.service.GetUserPublicProfile(c.Request.Context(), userID, targetOpenID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
@@ -159,7 +157,7 @@ func (h *FriendHandler) GetUserProfile(c *gin.Context) {
 func (h *FriendHandler) GetFriendCount(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
-	resp, err := h.service.GetFriendCount(userID)
+	resp, err := h.service.GetFriendCount(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取好友数量失败"))
 		return
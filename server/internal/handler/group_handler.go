@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"pinche/internal/logger"
+	"pinche/internal/middleware"
+	"pinche/internal/model"
+	"pinche/internal/service"
+)
+
+type GroupHandler struct {
+	service *service.GroupService
+}
+
+func NewGroupHandler(service *service.GroupService) *GroupHandler {
+	return &GroupHandler{service: service}
+}
+
+// GetMyGroups handles GET /api/groups
+func (h *GroupHandler) GetMyGroups(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	resp, err := h.service.ListMyGroups(c.Request.Context(), userID)
+	if err != nil {
+		logger.Error("List trip groups failed", "user_id", userID, "error", err)
+		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取群聊列表失败"))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(resp))
+}
+
+// GetByID handles GET /api/groups/:id
+func (h *GroupHandler) GetByID(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "无效的群聊ID"))
+		return
+	}
+
+	group, err := h.service.GetGroup(c.Request.Context(), id, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(group))
+}
+
+// GetMessages handles GET /api/groups/:id/messages
+func (h *GroupHandler) GetMessages(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "无效的群聊ID"))
+		return
+	}
+
+	var req model.GroupMessageListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+	if req.BeforeID > 0 && req.AfterID > 0 {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "before_id和after_id不能同时使用"))
+		return
+	}
+
+	resp, err := h.service.GetMessages(c.Request.Context(), id, userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(resp))
+}
+
+// SendMessage handles POST /api/groups/:id/messages
+func (h *GroupHandler) SendMessage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "无效的群聊ID"))
+		return
+	}
+
+	var req model.GroupMessageSendReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	msg, err := h.service.SendMessage(c.Request.Context(), id, userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(msg))
+}
+
+// MarkAsRead handles PUT /api/groups/:id/read
+func (h *GroupHandler) MarkAsRead(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "无效的群聊ID"))
+		return
+	}
+
+	if err := h.service.MarkAsRead(c.Request.Context(), id, userID); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(nil))
+}
+
+// UpdateSetting handles PUT /api/groups/:id/settings
+func (h *GroupHandler) UpdateSetting(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "无效的群聊ID"))
+		return
+	}
+
+	var req model.GroupSettingReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	group, err := h.service.UpdateSetting(c.Request.Context(), id, userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(group))
+}
+
+// Leave handles POST /api/groups/:id/leave
+func (h *GroupHandler) Leave(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "无效的群聊ID"))
+		return
+	}
+
+	if err := h.service.Leave(c.Request.Context(), id, userID); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(nil))
+}
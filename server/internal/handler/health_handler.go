@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"pinche/internal/logger"
+	"pinche/internal/model"
+	"pinche/internal/service"
+)
+
+type HealthHandler struct {
+	service *service.HealthService
+}
+
+func NewHealthHandler(service *service.HealthService) *HealthHandler {
+	return &HealthHandler{service: service}
+}
+
+// Healthz handles GET /healthz, the process is alive as long as it can answer
+func (h *HealthHandler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": model.HealthStatusOK})
+}
+
+// Readyz handles GET /readyz, 503 when a dependency is down or the server is shutting down
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	readiness := h.service.Ready(c.Request.Context())
+	if readiness.Status != model.HealthStatusReady {
+		if readiness.Status == model.HealthStatusNotReady {
+			logger.Warn("Readiness check failed", "checks", readiness.Checks)
+		}
+		c.JSON(http.StatusServiceUnavailable, readiness)
+		return
+	}
+	c.JSON(http.StatusOK, readiness)
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"pinche/internal/logger"
+	"pinche/internal/middleware"
+	"pinche/internal/model"
+	"pinche/internal/service"
+	"pinche/internal/websocket"
+)
+
+type LocationHandler struct {
+	service *service.LocationService
+}
+
+func NewLocationHandler(service *service.LocationService) *LocationHandler {
+	return &LocationHandler{service: service}
+}
+
+// GetLiveLocationStatus handles GET /api/matches/:id/live-location
+func (h *LocationHandler) GetLiveLocationStatus(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "无效的匹配ID"))
+		return
+	}
+
+	status, err := h.service.GetLiveLocationStatus(c.Request.Context(), id, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success(status))
+}
+
+// HandleLiveLocationFrame handles live_location frames sent over websocket,
+// {"type":"live_location","data":{"match_id":1,"lat":30.5,"lng":114.3}}
+func (h *LocationHandler) HandleLiveLocationFrame(ctx context.Context, client *websocket.Client, data json.RawMessage) {
+	var frame model.LiveLocationFrame
+	if err := json.Unmarshal(data, &frame); err != nil || frame.MatchID == 0 {
+		logger.Debug("WebSocket: invalid live_location frame", "user_id", client.UserID)
+		return
+	}
+
+	if err := h.service.ShareLiveLocation(ctx, client.UserID, client.OpenID, &frame); err != nil {
+		logger.Debug("WebSocket: live location rejected", "user_id", client.UserID, "match_id", frame.MatchID, "error", err)
+	}
+}
+
+// HandleLiveLocationStopFrame handles live_location_stop frames sent over websocket,
+// {"type":"live_location_stop","data":{"match_id":1}}
+func (h *LocationHandler) HandleLiveLocationStopFrame(ctx context.Context, client *websocket.Client, data json.RawMessage) {
+	var frame model.LiveLocationStopFrame
+	if err := json.Unmarshal(data, &frame); err != nil || frame.MatchID == 0 {
+		logger.Debug("WebSocket: invalid live_location_stop frame", "user_id", client.UserID)
+		return
+	}
+
+	if err := h.service.StopLiveLocation(ctx, client.UserID, client.OpenID, frame.MatchID); err != nil {
+		logger.Debug("WebSocket: stop live location failed", "user_id", client.UserID, "match_id", frame.MatchID, "error", err)
+	}
+}
@@ -20,7 +20,7 @@ func NewMatchHandler(service *service.MatchService) *MatchHandler {
 
 func (h *MatchHandler) GetMyMatches(c *gin.Context) {
 	userID := middleware.GetUserID(c)
-	matches, err := h.service.GetMyMatches(userID)
+	matches, err := h.service.GetMyMatches(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取匹配列表失败"))
 		return
@@ -42,7 +42,7 @@ func (h *MatchHandler) Confirm(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Confirm(id, userID, req.Accept); err != nil {
+	if err := h.service.Confirm(c.Request.Context(), id, userID, req.Accept); err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
@@ -58,7 +58,7 @@ func (h *MatchHandler) GetContactInfo(c *gin.Context) {
 		return
 	}
 
-	info, err := h.service.GetContactInfo(id, userID)
+	info, err := h.service.GetContactInfo(c.Request.Context(), id, userID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
@@ -74,7 +74,7 @@ func (h *MatchHandler) GetByID(c *gin.Context) {
 		return
 	}
 
-	match, err := h.service.GetByID(id)
+	match, err := h.service.GetByID(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取匹配信息失败"))
 		return
@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"pinche/internal/logger"
 	"pinche/internal/middleware"
 	"pinche/internal/model"
 	"pinche/internal/service"
@@ -33,17 +38,15 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	msg, err := h.service.SendMessage(userID, &req)
+	msg, created, err := h.service.SendMessage(c.Request.Context(), userID, &req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
 
-	// get receiver internal ID for websocket
-	receiver, _ := h.userService.GetByOpenID(req.ReceiverID)
-	if receiver != nil {
-		// push message to receiver via websocket
-		h.wsHub.SendToUser(receiver.ID, websocket.Message{
+	// push message to receiver via websocket, retries were pushed the first time
+	if created {
+		h.wsHub.SendAlertContext(c.Request.Context(), msg.ReceiverID, msg.SenderID, websocket.Message{
 			Type: "new_message",
 			Data: msg,
 		})
@@ -52,6 +55,51 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 	c.JSON(http.StatusOK, model.Success(msg))
 }
 
+// HandleSendFrame handles send_message frames sent over websocket
+// The frame data has the same fields as POST /api/messages and client_msg_id is required,
+// the sender always gets a message_ack carrying either the stored message or the error
+func (h *MessageHandler) HandleSendFrame(ctx context.Context, client *websocket.Client, data json.RawMessage) {
+	ack := h.sendFrame(ctx, client, data)
+	h.wsHub.SendToUserContext(ctx, client.UserID, websocket.Message{
+		Type: "message_ack",
+		Data: ack,
+	})
+}
+
+// sendFrame stores the message of a send_message frame and returns the ack for the sender
+func (h *MessageHandler) sendFrame(ctx context.Context, client *websocket.Client, data json.RawMessage) model.MessageAck {
+	var req model.MessageSendReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		logger.Debug("WebSocket: invalid send_message frame", "user_id", client.UserID, "error", err)
+		return model.MessageAck{Error: "消息格式错误"}
+	}
+
+	ack := model.MessageAck{ClientMsgID: req.ClientMsgID}
+	if req.ClientMsgID == "" {
+		ack.Error = "缺少client_msg_id"
+		return ack
+	}
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		ack.Error = "参数错误: " + err.Error()
+		return ack
+	}
+
+	msg, created, err := h.service.SendMessage(ctx, client.UserID, &req)
+	if err != nil {
+		ack.Error = err.Error()
+		return ack
+	}
+	ack.MessageID = msg.ID
+	ack.CreatedAt = &msg.CreatedAt
+	if created {
+		h.wsHub.SendAlertContext(ctx, msg.ReceiverID, msg.SenderID, websocket.Message{
+			Type: "new_message",
+			Data: msg,
+		})
+	}
+	return ack
+}
+
 // GetConversationMessages handles GET /api/messages
 func (h *MessageHandler) GetConversationMessages(c *gin.Context) {
 	userID := middleware.GetUserID(c)
@@ -61,7 +109,12 @@ func (h *MessageHandler) GetConversationMessages(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.service.GetConversationMessages(userID, &req)
+	if req.BeforeID > 0 && req.AfterID > 0 {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "before_id和after_id不能同时使用"))
+		return
+	}
+
+	resp, err := h.service.GetConversationMessages(c.Request.Context(), userID, &req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取消息失败"))
 		return
@@ -70,12 +123,53 @@ func (h *MessageHandler) GetConversationMessages(c *gin.Context) {
 	c.JSON(http.StatusOK, model.Success(resp))
 }
 
+// GetConversationSetting handles GET /api/conversations/:peer_id/settings
+func (h *MessageHandler) GetConversationSetting(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	setting, err := h.service.GetConversationSetting(c.Request.Context(), userID, c.Param("peer_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success(setting))
+}
+
+// UpdateConversationSetting handles PUT /api/conversations/:peer_id/settings
+func (h *MessageHandler) UpdateConversationSetting(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	var req model.ConversationSettingReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	setting, err := h.service.UpdateConversationSetting(c.Request.Context(), userID, c.Param("peer_id"), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success(setting))
+}
+
 // GetConversations handles GET /api/conversations
 func (h *MessageHandler) GetConversations(c *gin.Context) {
 	userID := middleware.GetUserID(c)
+	var req model.ConversationListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+	if req.BeforeID > 0 && req.AfterID > 0 {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "before_id和after_id不能同时使用"))
+		return
+	}
 
-	resp, err := h.service.GetConversations(userID)
+	resp, err := h.service.GetConversations(c.Request.Context(), userID, &req)
 	if err != nil {
+		logger.Error("Get conversations failed", "user_id", userID, "error", err)
 		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取会话列表失败"))
 		return
 	}
@@ -83,6 +177,42 @@ func (h *MessageHandler) GetConversations(c *gin.Context) {
 	c.JSON(http.StatusOK, model.Success(resp))
 }
 
+// SearchMessages handles GET /api/messages/search
+func (h *MessageHandler) SearchMessages(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	var req model.MessageSearchReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	resp, err := h.service.SearchMessages(c.Request.Context(), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success(resp))
+}
+
+// Sync handles GET /api/sync, returning private and group messages and conversation settings changed since the cursor
+func (h *MessageHandler) Sync(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	var req model.SyncReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	resp, err := h.service.Sync(c.Request.Context(), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success(resp))
+}
+
 // MarkAsRead handles PUT /api/messages/read
 func (h *MessageHandler) MarkAsRead(c *gin.Context) {
 	userID := middleware.GetUserID(c)
@@ -92,19 +222,48 @@ func (h *MessageHandler) MarkAsRead(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.MarkAsRead(userID, peerOpenID); err != nil {
+	receipt, err := h.service.MarkAsRead(c.Request.Context(), userID, peerOpenID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "标记已读失败"))
 		return
 	}
+	h.pushReadReceipt(c.Request.Context(), receipt)
 
 	c.JSON(http.StatusOK, model.Success(nil))
 }
 
+// HandleReadFrame handles read frames sent over websocket, {"type":"read","data":{"peer_id":"..."}}
+func (h *MessageHandler) HandleReadFrame(ctx context.Context, client *websocket.Client, data json.RawMessage) {
+	var frame model.ReadFrameData
+	if err := json.Unmarshal(data, &frame); err != nil || frame.PeerID == "" {
+		logger.Debug("WebSocket: invalid read frame", "user_id", client.UserID)
+		return
+	}
+
+	receipt, err := h.service.MarkAsRead(ctx, client.UserID, frame.PeerID)
+	if err != nil {
+		logger.Warn("WebSocket: mark as read failed", "user_id", client.UserID, "peer_id", frame.PeerID, "error", err)
+		return
+	}
+	h.pushReadReceipt(ctx, receipt)
+}
+
+// pushReadReceipt tells the sender their messages were read, receipt is nil when nothing changed
+func (h *MessageHandler) pushReadReceipt(ctx context.Context, receipt *model.ReadReceipt) {
+	if receipt == nil {
+		return
+	}
+	h.wsHub.SendToUserContext(ctx, receipt.SenderID, websocket.Message{
+		Type: "messages_read",
+		Data: receipt,
+	})
+}
+
 // GetUnreadCount handles GET /api/messages/unread-count
 func (h *MessageHandler) GetUnreadCount(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
-	count, err := h.service.GetUnreadCount(userID)
+	count, err := h.service.GetUnreadCount(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取未读数失败"))
 		return
@@ -112,3 +271,75 @@ func (h *MessageHandler) GetUnreadCount(c *gin.Context) {
 
 	c.JSON(http.StatusOK, model.Success(gin.H{"count": count}))
 }
+
+// RecallMessage handles POST /api/messages/:id/recall
+func (h *MessageHandler) RecallMessage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	msgID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "无效的消息ID"))
+		return
+	}
+
+	msg, err := h.service.RecallMessage(c.Request.Context(), userID, msgID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+
+	// let the receiver replace the message in their chat view
+	h.wsHub.SendToUserContext(c.Request.Context(), msg.ReceiverID, websocket.Message{
+		Type: "message_recalled",
+		Data: msg,
+	})
+
+	c.JSON(http.StatusOK, model.Success(msg))
+}
+
+// ForwardMessage handles POST /api/messages/:id/forward
+func (h *MessageHandler) ForwardMessage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	msgID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "无效的消息ID"))
+		return
+	}
+
+	var req model.MessageForwardReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	msg, created, err := h.service.ForwardMessage(c.Request.Context(), userID, msgID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+
+	if created {
+		h.wsHub.SendAlertContext(c.Request.Context(), msg.ReceiverID, msg.SenderID, websocket.Message{
+			Type: "new_message",
+			Data: msg,
+		})
+	}
+
+	c.JSON(http.StatusOK, model.Success(msg))
+}
+
+// DeleteMessage handles DELETE /api/messages/:id, deleting for the current user only
+func (h *MessageHandler) DeleteMessage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	msgID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "无效的消息ID"))
+		return
+	}
+
+	if err := h.service.DeleteMessage(c.Request.Context(), userID, msgID); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success(nil))
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"pinche/internal/logger"
+	"pinche/internal/middleware"
+	"pinche/internal/model"
+	"pinche/internal/service"
+)
+
+type ModerationHandler struct {
+	service *service.ModerationService
+}
+
+func NewModerationHandler(s *service.ModerationService) *ModerationHandler {
+	return &ModerationHandler{service: s}
+}
+
+// ListWords handles GET /api/admin/sensitive-words
+func (h *ModerationHandler) ListWords(c *gin.Context) {
+	var req model.SensitiveWordListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误"))
+		return
+	}
+
+	resp, err := h.service.ListWords(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error("Admin list sensitive words failed", "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "获取敏感词列表失败"))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(resp))
+}
+
+// CreateWord handles POST /api/admin/sensitive-words
+func (h *ModerationHandler) CreateWord(c *gin.Context) {
+	var req model.SensitiveWordCreateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	w, err := h.service.CreateWord(c.Request.Context(), &req, middleware.GetAdminID(c), middleware.GetAdminUsername(c))
+	if err != nil {
+		logger.Warn("Admin create sensitive word failed", "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, wordFailureMessage(err, "添加敏感词失败")))
+		return
+	}
+
+	middleware.SetAuditTarget(c, model.AuditActionSensitiveWordCreate, model.AuditTargetSensitiveWord, strconv.FormatUint(w.ID, 10))
+	middleware.SetAuditState(c, nil, w)
+	c.JSON(http.StatusOK, model.Success(w))
+}
+
+// UpdateWord handles PUT /api/admin/sensitive-words/:id
+func (h *ModerationHandler) UpdateWord(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的敏感词ID"))
+		return
+	}
+	var req model.SensitiveWordUpdateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	middleware.SetAuditTarget(c, model.AuditActionSensitiveWordUpdate, model.AuditTargetSensitiveWord, c.Param("id"))
+	before, after, err := h.service.UpdateWord(c.Request.Context(), id, &req)
+	if err != nil {
+		logger.Warn("Admin update sensitive word failed", "id", id, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, wordFailureMessage(err, "修改敏感词失败")))
+		return
+	}
+
+	middleware.SetAuditState(c, before, after)
+	c.JSON(http.StatusOK, model.Success(after))
+}
+
+// DeleteWord handles DELETE /api/admin/sensitive-words/:id
+func (h *ModerationHandler) DeleteWord(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的敏感词ID"))
+		return
+	}
+
+	middleware.SetAuditTarget(c, model.AuditActionSensitiveWordDelete, model.AuditTargetSensitiveWord, c.Param("id"))
+	w, err := h.service.DeleteWord(c.Request.Context(), id)
+	if err != nil {
+		logger.Warn("Admin delete sensitive word failed", "id", id, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, wordFailureMessage(err, "删除敏感词失败")))
+		return
+	}
+
+	middleware.SetAuditState(c, w, nil)
+	c.JSON(http.StatusOK, model.Success(nil))
+}
+
+// ListFlags handles GET /api/admin/content-flags
+func (h *ModerationHandler) ListFlags(c *gin.Context) {
+	var req model.ContentFlagListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误"))
+		return
+	}
+
+	resp, err := h.service.ListFlags(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error("Admin list content flags failed", "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "获取审核列表失败"))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(resp))
+}
+
+// ApproveFlag handles POST /api/admin/content-flags/:id/approve
+func (h *ModerationHandler) ApproveFlag(c *gin.Context) {
+	h.reviewFlag(c, true)
+}
+
+// RejectFlag handles POST /api/admin/content-flags/:id/reject
+func (h *ModerationHandler) RejectFlag(c *gin.Context) {
+	h.reviewFlag(c, false)
+}
+
+func (h *ModerationHandler) reviewFlag(c *gin.Context, approve bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的审核记录ID"))
+		return
+	}
+
+	var req model.ContentFlagReviewReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+			return
+		}
+	}
+
+	action := model.AuditActionContentFlagReject
+	if approve {
+		action = model.AuditActionContentFlagApprove
+	}
+	middleware.SetAuditTarget(c, action, model.AuditTargetContentFlag, c.Param("id"))
+	flag, err := h.service.ReviewFlag(c.Request.Context(), id, approve, req.Note, middleware.GetAdminID(c), middleware.GetAdminUsername(c))
+	if err != nil {
+		logger.Warn("Admin review content flag failed", "id", id, "approve", approve, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "审核失败: "+err.Error()))
+		return
+	}
+
+	middleware.SetAuditState(c, nil, flag)
+	c.JSON(http.StatusOK, model.Success(flag))
+}
+
+// wordFailureMessage keeps the dictionary errors of the moderation service and hides the others behind fallback
+func wordFailureMessage(err error, fallback string) string {
+	switch {
+	case errors.Is(err, service.ErrSensitiveWordEmpty), errors.Is(err, service.ErrSensitiveWordExists),
+		errors.Is(err, service.ErrSensitiveWordNotFound):
+		return err.Error()
+	}
+	return fallback
+}
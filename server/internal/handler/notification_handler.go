@@ -23,7 +23,7 @@ func (h *NotificationHandler) GetList(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
 
-	resp, err := h.service.GetList(userID, page, pageSize)
+	resp, err := h.service.GetList(c.Request.Context(), userID, page, pageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取通知列表失败"))
 		return
@@ -40,7 +40,7 @@ func (h *NotificationHandler) MarkAsRead(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.MarkAsRead(id, userID); err != nil {
+	if err := h.service.MarkAsRead(c.Request.Context(), id, userID); err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
@@ -50,7 +50,7 @@ func (h *NotificationHandler) MarkAsRead(c *gin.Context) {
 
 func (h *NotificationHandler) MarkAllAsRead(c *gin.Context) {
 	userID := middleware.GetUserID(c)
-	if err := h.service.MarkAllAsRead(userID); err != nil {
+	if err := h.service.MarkAllAsRead(c.Request.Context(), userID); err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"pinche/internal/logger"
+	"pinche/internal/model"
+	"pinche/internal/service"
+)
+
+type StatsHandler struct {
+	service *service.StatsService
+}
+
+func NewStatsHandler(s *service.StatsService) *StatsHandler {
+	return &StatsHandler{service: s}
+}
+
+// Timeseries handles GET /api/admin/stats/timeseries
+func (h *StatsHandler) Timeseries(c *gin.Context) {
+	var req model.StatsTimeseriesReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	resp, err := h.service.Timeseries(c.Request.Context(), &req)
+	if err != nil {
+		logger.Warn("Admin get stats timeseries failed", "start_date", req.StartDate, "end_date", req.EndDate, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(resp))
+}
+
+// Funnel handles GET /api/admin/stats/funnel
+func (h *StatsHandler) Funnel(c *gin.Context) {
+	var req model.StatsFunnelReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	resp, err := h.service.Funnel(c.Request.Context(), &req)
+	if err != nil {
+		logger.Warn("Admin get stats funnel failed", "start_date", req.StartDate, "end_date", req.EndDate, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success(resp))
+}
@@ -27,7 +27,7 @@ func (h *TripHandler) Create(c *gin.Context) {
 		return
 	}
 
-	trip, err := h.service.Create(userID, &req)
+	trip, err := h.service.Create(c.Request.Context(), userID, &req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
@@ -46,7 +46,7 @@ func (h *TripHandler) GetByID(c *gin.Context) {
 	// get viewer ID if authenticated
 	viewerID := middleware.GetUserID(c)
 
-	trip, err := h.service.GetByIDAndIncrementView(id, viewerID)
+	trip, err := h.service.GetByIDAndIncrementView(c.Request.Context(), id, viewerID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取行程失败: "+err.Error()))
 		return
@@ -66,7 +66,7 @@ func (h *TripHandler) List(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.service.List(&req)
+	resp, err := h.service.List(c.Request.Context(), &req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取行程列表失败"))
 		return
@@ -75,9 +75,27 @@ func (h *TripHandler) List(c *gin.Context) {
 	c.JSON(http.StatusOK, model.Success(resp))
 }
 
+// GetHotRoutes handles GET /api/routes/hot
+func (h *TripHandler) GetHotRoutes(c *gin.Context) {
+	var req model.HotRoutesReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
+		return
+	}
+
+	routes, err := h.service.GetHotRoutes(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error("Get hot routes failed", "city", req.City, "error", err)
+		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取热门路线失败"))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success(routes))
+}
+
 func (h *TripHandler) GetMyTrips(c *gin.Context) {
 	userID := middleware.GetUserID(c)
-	trips, err := h.service.GetMyTrips(userID)
+	trips, err := h.service.GetMyTrips(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取行程列表失败"))
 		return
@@ -94,7 +112,7 @@ func (h *TripHandler) GetMyTripDetail(c *gin.Context) {
 		return
 	}
 
-	trip, err := h.service.GetMyTripDetail(id, userID)
+	trip, err := h.service.GetMyTripDetail(c.Request.Context(), id, userID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
@@ -118,7 +136,7 @@ func (h *TripHandler) UpdateTrip(c *gin.Context) {
 		return
 	}
 
-	needsReview, message, err := h.service.UpdateTrip(id, userID, &req)
+	needsReview, message, err := h.service.UpdateTrip(c.Request.Context(), id, userID, &req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
@@ -138,7 +156,7 @@ func (h *TripHandler) Cancel(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Cancel(id, userID); err != nil {
+	if err := h.service.Cancel(c.Request.Context(), id, userID); err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
@@ -154,7 +172,7 @@ func (h *TripHandler) Complete(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Complete(id, userID); err != nil {
+	if err := h.service.Complete(c.Request.Context(), id, userID); err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
@@ -170,7 +188,7 @@ func (h *TripHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Delete(id, userID); err != nil {
+	if err := h.service.Delete(c.Request.Context(), id, userID); err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
@@ -187,7 +205,7 @@ func (h *TripHandler) AdminListTrips(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.service.AdminListTrips(&req)
+	resp, err := h.service.AdminListTrips(c.Request.Context(), &req)
 	if err != nil {
 		logger.Error("Admin list trips failed", "error", err)
 		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "获取行程列表失败"))
@@ -198,41 +216,47 @@ func (h *TripHandler) AdminListTrips(c *gin.Context) {
 	c.JSON(http.StatusOK, model.Success(resp))
 }
 
-func (h *TripHandler) AdminBanTrip(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的行程ID"))
+// AdminRouteSupplyDemand handles GET /api/admin/stats/routes
+func (h *TripHandler) AdminRouteSupplyDemand(c *gin.Context) {
+	var req model.RouteSupplyDemandReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "参数错误: "+err.Error()))
 		return
 	}
 
-	if err := h.service.AdminBanTrip(id); err != nil {
-		logger.Error("Admin ban trip failed", "trip_id", id, "error", err)
-		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "封禁行程失败"))
+	routes, err := h.service.AdminRouteSupplyDemand(c.Request.Context(), &req)
+	if err != nil {
+		logger.Warn("Admin get route supply demand failed", "start_date", req.StartDate, "end_date", req.EndDate, "error", err)
+		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
 
-	logger.Info("Admin banned trip", "trip_id", id)
-	c.JSON(http.StatusOK, model.Success(nil))
+	c.JSON(http.StatusOK, model.Success(routes))
 }
 
-func (h *TripHandler) AdminUnbanTrip(c *gin.Context) {
+func (h *TripHandler) GrabTrip(c *gin.Context) {
+	userID := middleware.GetUserID(c)
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusOK, model.Error(model.ErrCodeBadRequest, "无效的行程ID"))
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "无效的行程ID"))
 		return
 	}
 
-	if err := h.service.AdminUnbanTrip(id); err != nil {
-		logger.Error("Admin unban trip failed", "trip_id", id, "error", err)
-		c.JSON(http.StatusOK, model.Error(model.ErrCodeInternal, "解封行程失败"))
+	var req model.GrabTripReq
+	// message is optional, so ignore bind errors
+	c.ShouldBindJSON(&req)
+
+	resp, err := h.service.GrabTrip(c.Request.Context(), id, userID, req.Message)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
 
-	logger.Info("Admin unbanned trip", "trip_id", id)
-	c.JSON(http.StatusOK, model.Success(nil))
+	c.JSON(http.StatusOK, model.Success(resp))
 }
 
-func (h *TripHandler) GrabTrip(c *gin.Context) {
+// AcceptGrab handles POST /api/trips/:id/grabs/:user_id/accept, the driver accepts a passenger's booking
+func (h *TripHandler) AcceptGrab(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
@@ -240,15 +264,10 @@ func (h *TripHandler) GrabTrip(c *gin.Context) {
 		return
 	}
 
-	var req model.GrabTripReq
-	// message is optional, so ignore bind errors
-	c.ShouldBindJSON(&req)
-
-	resp, err := h.service.GrabTrip(id, userID, req.Message)
-	if err != nil {
+	if err := h.service.AcceptGrab(c.Request.Context(), id, userID, c.Param("user_id")); err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
 	}
 
-	c.JSON(http.StatusOK, model.Success(resp))
+	c.JSON(http.StatusOK, model.Success(nil))
 }
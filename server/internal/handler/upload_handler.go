@@ -42,13 +42,13 @@ func (h *UploadHandler) Upload(c *gin.Context) {
 	// avatar: use UploadAvatar method with openID naming
 	if bizType == service.BizTypeAvatar {
 		userID := middleware.GetUserID(c)
-		user, err := h.userService.GetByID(userID)
+		user, err := h.userService.GetByID(c.Request.Context(), userID)
 		if err != nil || user == nil {
 			c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, "用户不存在"))
 			return
 		}
 
-		avatarURL, err := h.service.UploadAvatar(file, user.OpenID)
+		avatarURL, err := h.service.UploadAvatar(c.Request.Context(), file, user.OpenID)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 			return
@@ -62,7 +62,7 @@ func (h *UploadHandler) Upload(c *gin.Context) {
 
 	// trip: returns public URL
 	if bizType == service.BizTypeTrip {
-		publicURL, err := h.service.Upload(file, bizType)
+		publicURL, err := h.service.Upload(c.Request.Context(), file, bizType)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 			return
@@ -75,7 +75,7 @@ func (h *UploadHandler) Upload(c *gin.Context) {
 	}
 
 	// images/voices: returns object key
-	objectKey, err := h.service.Upload(file, bizType)
+	objectKey, err := h.service.Upload(c.Request.Context(), file, bizType)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.Error(model.ErrCodeBadRequest, err.Error()))
 		return
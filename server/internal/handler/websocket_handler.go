@@ -2,6 +2,7 @@ package handler
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -47,7 +48,7 @@ func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
 	}
 
 	// Get user's open_id for call signaling
-	user, err := h.userService.GetByID(userID)
+	user, err := h.userService.GetByID(c.Request.Context(), userID)
 	if err != nil {
 		logger.Error("WebSocket connection failed: user not found", "user_id", userID, "error", err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
@@ -62,12 +63,7 @@ func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
 
 	logger.Info("WebSocket connection established", "user_id", userID, "open_id", user.OpenID, "client_ip", c.ClientIP())
 
-	client := &ws.Client{
-		UserID: userID,
-		OpenID: user.OpenID,
-		Conn:   conn,
-		Send:   make(chan []byte, 256),
-	}
+	client := ws.NewClient(conn, userID, user.OpenID, strings.ToLower(c.Query("platform")))
 
 	h.hub.Register(client)
 
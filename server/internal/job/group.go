@@ -0,0 +1,76 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/codes"
+	"pinche/internal/logger"
+	"pinche/internal/tracing"
+)
+
+// Group runs fire-and-forget tasks, such as cache refreshes and match notifications after a request,
+// and lets shutdown wait for them before the database and Redis are closed
+type Group struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closed  bool
+	running atomic.Int64
+}
+
+func NewGroup() *Group {
+	return &Group{}
+}
+
+// Go runs fn in its own goroutine. Tasks started after Wait began are dropped,
+// the connections they would use are about to be closed.
+// fn gets ctx without its cancellation, as tasks usually outlive the request that started them,
+// and a span named after the task within the trace of ctx.
+func (g *Group) Go(ctx context.Context, name string, fn func(ctx context.Context)) {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		logger.Warn("Background task dropped during shutdown", "task", name)
+		return
+	}
+	g.wg.Add(1)
+	g.running.Add(1)
+	g.mu.Unlock()
+
+	ctx, span := tracing.StartChild(tracing.Detach(ctx), "task "+name)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("Background task panicked", "task", name, "panic", r)
+				span.SetStatus(codes.Error, fmt.Sprint(r))
+			}
+			span.End()
+			g.running.Add(-1)
+			g.wg.Done()
+		}()
+		fn(ctx)
+	}()
+}
+
+// Wait stops accepting tasks and waits for the running ones until ctx is done
+func (g *Group) Wait(ctx context.Context) error {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Info("Background tasks drained")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d background tasks still running: %w", g.running.Load(), ctx.Err())
+	}
+}
@@ -0,0 +1,110 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"pinche/internal/logger"
+)
+
+// Func is a unit of periodic background work, ctx is cancelled when the scheduler stops
+// so long running jobs can stop between steps
+type Func func(ctx context.Context) error
+
+type entry struct {
+	name     string
+	interval time.Duration
+	fn       Func
+}
+
+// Scheduler runs registered jobs at fixed intervals, each in its own goroutine
+type Scheduler struct {
+	entries []*entry
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	started bool
+}
+
+func NewScheduler() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Every registers a job to run once on start and then every interval
+// Must be called before Start
+func (s *Scheduler) Every(name string, interval time.Duration, fn Func) {
+	s.entries = append(s.entries, &entry{name: name, interval: interval, fn: fn})
+}
+
+// Start launches all registered jobs
+func (s *Scheduler) Start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	for _, e := range s.entries {
+		s.wg.Add(1)
+		go s.loop(e)
+	}
+	logger.Info("Job scheduler started", "jobs", len(s.entries))
+}
+
+// Stop signals all jobs to exit and waits for running ones to finish until ctx is done
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if !s.started {
+		return nil
+	}
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Info("Job scheduler stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs still running: %w", ctx.Err())
+	}
+}
+
+func (s *Scheduler) loop(e *entry) {
+	defer s.wg.Done()
+
+	s.run(e)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.run(e)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// run executes a job once, a panic in one job must not bring down the server
+func (s *Scheduler) run(e *entry) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Job panicked", "job", e.name, "panic", r)
+		}
+	}()
+
+	start := time.Now()
+	if err := e.fn(s.ctx); err != nil {
+		logger.Error("Job failed", "job", e.name, "error", err, "latency", time.Since(start))
+		return
+	}
+	logger.Debug("Job finished", "job", e.name, "latency", time.Since(start))
+}
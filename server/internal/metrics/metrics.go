@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "pinche"
+
+// HTTP
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route template.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"method", "route"})
+)
+
+// cache results
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+)
+
+// Cache
+var CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "cache_requests_total",
+	Help:      "Cache lookups by cache and result (hit, miss, error).",
+}, []string{"cache", "result"})
+
+// websocket drop reasons
+const (
+	DropOffline     = "offline"
+	DropChannelFull = "channel_full"
+)
+
+// WebSocket
+var (
+	WebSocketMessagesSent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "messages_sent_total",
+		Help:      "Messages queued for connected websocket clients.",
+	})
+
+	WebSocketMessagesDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "messages_dropped_total",
+		Help:      "Messages not delivered over websocket, by reason (offline, channel_full).",
+	}, []string{"reason"})
+
+	WebSocketEvictions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "evictions_total",
+		Help:      "Websocket clients disconnected because their send channel was full.",
+	})
+)
+
+// match score results
+const (
+	MatchScoreBelowThreshold = "below_threshold"
+	MatchScoreExists         = "exists"
+	MatchScoreCreated        = "created"
+)
+
+// Matching
+var (
+	MatchesCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "matches_created_total",
+		Help:      "Matches created by automatic matching.",
+	})
+
+	MatchesSucceeded = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "matches_succeeded_total",
+		Help:      "Matches accepted by both driver and passenger.",
+	})
+
+	MatchScore = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "match_score",
+		Help:      "Scores of candidate trips by result (below_threshold, exists, created).",
+		Buckets:   prometheus.LinearBuckets(10, 10, 10),
+	}, []string{"result"})
+)
+
+// RegisterDB exports the connection pool stats of db
+func RegisterDB(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterGauge exports a gauge read from fn on every scrape, e.g. connected websocket clients
+func RegisterGauge(subsystem, name, help string, fn func() float64) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
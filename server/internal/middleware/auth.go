@@ -1,9 +1,6 @@
 package middleware
 
 import (
-	"crypto/md5"
-	"crypto/subtle"
-	"encoding/hex"
 	"net/http"
 	"strings"
 	"time"
@@ -46,6 +43,21 @@ func AuthMiddleware(userService *service.UserService) gin.HandlerFunc {
 	}
 }
 
+// OptionalAuthMiddleware sets the user ID when a valid token is present,
+// letting public routes personalise responses without requiring login
+func OptionalAuthMiddleware(userService *service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader != "" {
+			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+			if userID, err := userService.ParseToken(tokenString); err == nil {
+				c.Set("user_id", userID)
+			}
+		}
+		c.Next()
+	}
+}
+
 func GetUserID(c *gin.Context) uint64 {
 	userID, exists := c.Get("user_id")
 	if !exists || userID == nil {
@@ -57,8 +69,9 @@ func GetUserID(c *gin.Context) uint64 {
 	return 0
 }
 
-// AdminAuthMiddleware validates admin JWT token
-func AdminAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// AdminAuthMiddleware validates admin JWT token and loads the admin account
+// Disabled accounts are rejected even if their token has not expired
+func AdminAuthMiddleware(cfg *config.Config, adminService *service.AdminService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -82,21 +95,85 @@ func AdminAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		c.Set("admin_username", claims.Username)
+		// role and status are read from DB so changes take effect immediately
+		admin, err := adminService.GetByID(c.Request.Context(), claims.AdminID)
+		if err != nil {
+			logger.Error("Admin auth failed: get admin error",
+				"admin_id", claims.AdminID,
+				"error", err)
+			c.JSON(http.StatusInternalServerError, model.Error(model.ErrCodeInternal, "获取管理员信息失败"))
+			c.Abort()
+			return
+		}
+		if admin == nil || admin.Status != model.AdminStatusActive {
+			logger.Warn("Admin auth failed: admin not found or disabled",
+				"admin_id", claims.AdminID,
+				"username", claims.Username,
+				"client_ip", c.ClientIP())
+			c.JSON(http.StatusUnauthorized, model.Error(model.ErrCodeUnauthorized, "账号不可用，请重新登录"))
+			c.Abort()
+			return
+		}
+
+		c.Set("admin_id", admin.ID)
+		c.Set("admin_username", admin.Username)
+		c.Set("admin_role", admin.Role)
 		c.Next()
 	}
 }
 
+// RequireAdminPermission checks the current admin's role grants the permission
+// Must be used after AdminAuthMiddleware
+func RequireAdminPermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := GetAdminRole(c)
+		if !model.AdminHasPermission(role, perm) {
+			logger.Warn("Admin permission denied",
+				"path", c.Request.URL.Path,
+				"username", GetAdminUsername(c),
+				"role", role,
+				"permission", perm)
+			c.JSON(http.StatusForbidden, model.Error(model.ErrCodeForbidden, "无权执行此操作"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func GetAdminID(c *gin.Context) uint64 {
+	adminID, exists := c.Get("admin_id")
+	if !exists || adminID == nil {
+		return 0
+	}
+	if id, ok := adminID.(uint64); ok {
+		return id
+	}
+	return 0
+}
+
+func GetAdminUsername(c *gin.Context) string {
+	return c.GetString("admin_username")
+}
+
+func GetAdminRole(c *gin.Context) string {
+	return c.GetString("admin_role")
+}
+
 // AdminClaims represents admin JWT claims
 type AdminClaims struct {
+	AdminID  uint64 `json:"admin_id"`
 	Username string `json:"username"`
+	Role     string `json:"role"`
 	jwt.RegisteredClaims
 }
 
 // GenerateAdminToken creates a JWT token for admin
-func GenerateAdminToken(username string, secret string, expireHours int) (string, error) {
+func GenerateAdminToken(admin *model.Admin, secret string, expireHours int) (string, error) {
 	claims := AdminClaims{
-		Username: username,
+		AdminID:  admin.ID,
+		Username: admin.Username,
+		Role:     admin.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expireHours) * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -123,26 +200,3 @@ func ParseAdminToken(tokenString string, secret string) (*AdminClaims, error) {
 
 	return nil, jwt.ErrSignatureInvalid
 }
-
-// md5Hash calculates MD5 hash of password
-func md5Hash(password string) string {
-	hash := md5.Sum([]byte(password))
-	return hex.EncodeToString(hash[:])
-}
-
-// ValidateAdminCredentials validates admin username and password
-// Password from request is already MD5 hashed by frontend
-func ValidateAdminCredentials(cfg *config.Config, username, hashedPassword string) bool {
-	if cfg.Admin.Password == "" {
-		logger.Warn("Admin login attempted but ADMIN_PASSWORD is not configured")
-		return false
-	}
-
-	// MD5 hash the configured password to compare with frontend-hashed password
-	expectedHash := md5Hash(cfg.Admin.Password)
-
-	usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(cfg.Admin.Username)) == 1
-	passwordMatch := subtle.ConstantTimeCompare([]byte(hashedPassword), []byte(expectedHash)) == 1
-
-	return usernameMatch && passwordMatch
-}
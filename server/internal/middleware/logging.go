@@ -7,8 +7,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"pinche/internal/logger"
+	"pinche/internal/tracing"
 )
 
+// maxCapturedBody caps the captured response body so streamed downloads are not held in memory
+const maxCapturedBody = 64 << 10
+
 // responseWriter wraps gin.ResponseWriter to capture response body
 type responseWriter struct {
 	gin.ResponseWriter
@@ -16,7 +20,13 @@ type responseWriter struct {
 }
 
 func (w *responseWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
+	if remaining := maxCapturedBody - w.body.Len(); remaining > 0 {
+		if len(b) > remaining {
+			w.body.Write(b[:remaining])
+		} else {
+			w.body.Write(b)
+		}
+	}
 	return w.ResponseWriter.Write(b)
 }
 
@@ -78,6 +88,9 @@ func LoggingMiddleware() gin.HandlerFunc {
 		if userID != nil {
 			fields = append(fields, "user_id", userID)
 		}
+		if traceID := tracing.TraceID(c.Request.Context()); traceID != "" {
+			fields = append(fields, "trace_id", traceID)
+		}
 		if requestBody != "" && requestBody != "{}" {
 			fields = append(fields, "request_body", requestBody)
 		}
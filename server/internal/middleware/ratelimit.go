@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pinche/config"
+	"pinche/internal/cache"
+	"pinche/internal/logger"
+	"pinche/internal/model"
+)
+
+// rateLimitFallbackPeriod is how long requests are limited locally after Redis failed,
+// instead of waiting on an unreachable Redis for every request
+const rateLimitFallbackPeriod = 10 * time.Second
+
+// RateLimitKeyFunc returns what a request is limited by, an empty key skips the limit
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// RateLimitByIP limits by client IP, for public routes
+func RateLimitByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// RateLimitByUser limits by the logged in user, falling back to the client IP
+func RateLimitByUser(c *gin.Context) string {
+	if userID := GetUserID(c); userID > 0 {
+		return "user:" + strconv.FormatUint(userID, 10)
+	}
+	return RateLimitByIP(c)
+}
+
+// RateLimitByPhone limits by the phone field of the JSON body, e.g. login attempts on one account.
+// The body is restored for the handler and the phone number is hashed before it is used as a key.
+func RateLimitByPhone(c *gin.Context) string {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 64<<10))
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+	if err != nil {
+		return ""
+	}
+
+	var req struct {
+		Phone string `json:"phone"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || req.Phone == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(req.Phone))
+	return "phone:" + hex.EncodeToString(sum[:8])
+}
+
+// RateLimiter builds per-route rate limit middlewares. Counters live in Redis so limits hold across
+// instances, when Redis is unavailable each instance falls back to its own in-memory counters.
+type RateLimiter struct {
+	enabled bool
+	redis   *cache.RateLimiter
+	local   *localRateLimiter
+
+	mu            sync.Mutex
+	fallbackUntil time.Time
+}
+
+func NewRateLimiter(cfg *config.RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		enabled: cfg.Enabled,
+		redis:   cache.NewRateLimiter(),
+		local:   newLocalRateLimiter(),
+	}
+}
+
+// Limit allows rule.Limit requests per key within a sliding rule.Window on the routes it is added to.
+// name separates the counters of different policies. Responses carry X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset (unix seconds), rejected ones also Retry-After.
+func (l *RateLimiter) Limit(name string, rule config.RateLimitRule, keyFn RateLimitKeyFunc) gin.HandlerFunc {
+	if !l.enabled || rule.Limit <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		key := keyFn(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		result := l.allow(c.Request.Context(), name+":"+key, rule)
+		remaining := result.Remaining
+		if remaining < 0 {
+			remaining = 0
+		}
+		header := c.Writer.Header()
+		header.Set("X-RateLimit-Limit", strconv.Itoa(rule.Limit))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		header.Set("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+
+		if !result.Allowed {
+			retryAfter := int(time.Until(result.ResetAt).Seconds() + 1)
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			header.Set("Retry-After", strconv.Itoa(retryAfter))
+			logger.Warn("Rate limit exceeded",
+				"policy", name,
+				"path", c.Request.URL.Path,
+				"client_ip", c.ClientIP(),
+				"user_id", GetUserID(c),
+				"retry_after", retryAfter)
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, model.Error(model.ErrCodeTooManyRequests, "请求过于频繁，请稍后再试"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// allow checks the key against Redis, or the local limiter while Redis is considered down
+func (l *RateLimiter) allow(ctx context.Context, key string, rule config.RateLimitRule) *cache.RateLimitResult {
+	now := time.Now()
+	l.mu.Lock()
+	useLocal := now.Before(l.fallbackUntil)
+	l.mu.Unlock()
+
+	if !useLocal {
+		result, err := l.redis.Allow(ctx, key, rule.Limit, rule.Window)
+		if err == nil {
+			return result
+		}
+		logger.Warn("Rate limit: Redis unavailable, using local limiter", "key", key, "error", err)
+		l.mu.Lock()
+		l.fallbackUntil = now.Add(rateLimitFallbackPeriod)
+		l.mu.Unlock()
+	}
+	return l.local.allow(key, rule.Limit, rule.Window, now)
+}
+
+// localRateLimiter is an in-memory sliding window limiter used while Redis is unavailable
+type localRateLimiter struct {
+	mu        sync.Mutex
+	windows   map[string]*localWindow
+	lastSweep time.Time
+}
+
+type localWindow struct {
+	hits   []time.Time // request times within the window, oldest first
+	window time.Duration
+}
+
+func newLocalRateLimiter() *localRateLimiter {
+	return &localRateLimiter{windows: make(map[string]*localWindow)}
+}
+
+func (l *localRateLimiter) allow(key string, limit int, window time.Duration, now time.Time) *cache.RateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// drop windows without recent requests once a minute so the map does not grow forever
+	if now.Sub(l.lastSweep) > time.Minute {
+		for k, w := range l.windows {
+			if len(w.hits) == 0 || now.Sub(w.hits[len(w.hits)-1]) > w.window {
+				delete(l.windows, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w, ok := l.windows[key]
+	if !ok {
+		w = &localWindow{window: window}
+		l.windows[key] = w
+	}
+	expired := 0
+	for expired < len(w.hits) && now.Sub(w.hits[expired]) >= window {
+		expired++
+	}
+	w.hits = w.hits[expired:]
+
+	allowed := len(w.hits) < limit
+	if allowed {
+		w.hits = append(w.hits, now)
+	}
+	resetAt := now.Add(window)
+	if len(w.hits) > 0 {
+		resetAt = w.hits[0].Add(window)
+	}
+	return &cache.RateLimitResult{
+		Allowed:   allowed,
+		Remaining: limit - len(w.hits),
+		ResetAt:   resetAt,
+	}
+}
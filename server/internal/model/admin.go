@@ -0,0 +1,135 @@
+package model
+
+import "time"
+
+const (
+	AdminStatusActive   = 0
+	AdminStatusDisabled = 1
+
+	// admin roles
+	AdminRoleSuperAdmin    = "super_admin"    // full access, manages admin accounts
+	AdminRoleModerator     = "moderator"      // bans users and trips
+	AdminRoleContentEditor = "content_editor" // manages announcements
+	AdminRoleViewer        = "viewer"         // read-only access
+)
+
+// admin permissions, checked per route
+const (
+	AdminPermStatsView          = "stats:view"
+	AdminPermUserView           = "user:view"
+	AdminPermUserBan            = "user:ban"
+	AdminPermTripView           = "trip:view"
+	AdminPermTripBan            = "trip:ban"
+	AdminPermAppealReview       = "appeal:review"
+	AdminPermAnnouncementView   = "announcement:view"
+	AdminPermAnnouncementManage = "announcement:manage"
+	AdminPermAdminManage        = "admin:manage"
+	AdminPermAuditView          = "audit:view"
+	AdminPermDataExport         = "data:export"    // bulk CSV/XLSX exports
+	AdminPermSensitiveView      = "sensitive:view" // unmasked phone and contact fields in exports
+	AdminPermBroadcastManage    = "broadcast:manage"
+	AdminPermModeration         = "moderation:manage" // sensitive words and the content review list
+)
+
+// adminRolePermissions maps each role to its granted permissions
+var adminRolePermissions = map[string][]string{
+	AdminRoleModerator: {
+		AdminPermStatsView,
+		AdminPermUserView, AdminPermUserBan,
+		AdminPermTripView, AdminPermTripBan,
+		AdminPermAppealReview,
+		AdminPermAnnouncementView,
+		AdminPermDataExport,
+		AdminPermModeration,
+	},
+	AdminRoleContentEditor: {
+		AdminPermStatsView,
+		AdminPermUserView,
+		AdminPermTripView,
+		AdminPermAnnouncementView, AdminPermAnnouncementManage,
+		AdminPermBroadcastManage,
+	},
+	AdminRoleViewer: {
+		AdminPermStatsView,
+		AdminPermUserView,
+		AdminPermTripView,
+		AdminPermAnnouncementView,
+	},
+}
+
+// IsValidAdminRole checks if the role is a known admin role
+func IsValidAdminRole(role string) bool {
+	if role == AdminRoleSuperAdmin {
+		return true
+	}
+	_, ok := adminRolePermissions[role]
+	return ok
+}
+
+// AdminHasPermission checks if the role is granted the permission
+// Super admins are granted every permission
+func AdminHasPermission(role, perm string) bool {
+	if role == AdminRoleSuperAdmin {
+		return true
+	}
+	for _, p := range adminRolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Admin represents an operations backend account
+type Admin struct {
+	ID          uint64     `json:"id"`
+	Username    string     `json:"username"`
+	Password    string     `json:"-"`
+	Role        string     `json:"role"`
+	Status      int8       `json:"status"` // 0-active 1-disabled
+	LastLoginAt *time.Time `json:"last_login_at"`
+	LastLoginIP string     `json:"last_login_ip"`
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type AdminLoginReq struct {
+	Username    string `json:"username" binding:"required"`
+	Password    string `json:"password" binding:"required"` // MD5 hashed by frontend
+	CaptchaID   string `json:"captcha_id" binding:"max=64"` // required after repeated failures
+	CaptchaCode string `json:"captcha_code" binding:"max=16"`
+}
+
+type AdminLoginResp struct {
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	Admin    *Admin `json:"admin"`
+}
+
+type AdminCreateReq struct {
+	Username string `json:"username" binding:"required,min=3,max=32"`
+	Password string `json:"password" binding:"required"` // MD5 hashed by frontend
+	Role     string `json:"role" binding:"required"`
+}
+
+type AdminUpdateRoleReq struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type AdminResetPasswordReq struct {
+	Password string `json:"password" binding:"required"` // MD5 hashed by frontend
+}
+
+type AdminListReq struct {
+	Search   string `form:"search"`
+	Role     string `form:"role"`
+	Status   *int8  `form:"status"`
+	Page     int    `form:"page,default=1"`
+	PageSize int    `form:"page_size,default=20"`
+}
+
+type AdminListResp struct {
+	List  []*Admin `json:"list"`
+	Total int64    `json:"total"`
+}
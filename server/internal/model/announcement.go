@@ -2,6 +2,24 @@ package model
 
 import "time"
 
+// AnnouncementTypeUrgent announcements are pushed to online users once they become active
+const AnnouncementTypeUrgent = 3
+
+// announcement target roles, derived from the trips a user published recently
+const (
+	AnnouncementRoleAll       = 0
+	AnnouncementRoleDriver    = 1
+	AnnouncementRolePassenger = 2
+)
+
+// client platforms an announcement can be targeted at
+const (
+	PlatformIOS         = "ios"
+	PlatformAndroid     = "android"
+	PlatformWeb         = "web"
+	PlatformMiniProgram = "miniprogram"
+)
+
 type Announcement struct {
 	ID        uint64    `json:"id"`
 	Title     string    `json:"title"`
@@ -11,8 +29,24 @@ type Announcement struct {
 	SortOrder int       `json:"sort_order"` // higher = more priority
 	StartTime time.Time `json:"start_time"`
 	EndTime   time.Time `json:"end_time"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	// targeting, comma separated lists where empty means everyone
+	TargetProvinces string     `json:"target_provinces"`
+	TargetCities    string     `json:"target_cities"`
+	TargetRole      int8       `json:"target_role"` // 0-all 1-driver 2-passenger
+	TargetPlatforms string     `json:"target_platforms"`
+	PushedAt        *time.Time `json:"pushed_at"` // when an urgent announcement was pushed over websocket
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// AnnouncementAudience describes the viewer announcements are matched against
+type AnnouncementAudience struct {
+	UserID      uint64
+	Province    string
+	City        string
+	IsDriver    bool
+	IsPassenger bool
+	Platform    string
 }
 
 type AnnouncementCreateReq struct {
@@ -23,6 +57,11 @@ type AnnouncementCreateReq struct {
 	SortOrder int    `json:"sort_order"`
 	StartTime string `json:"start_time"`
 	EndTime   string `json:"end_time"`
+
+	TargetProvinces string `json:"target_provinces" binding:"max=500"`
+	TargetCities    string `json:"target_cities" binding:"max=500"`
+	TargetRole      int8   `json:"target_role" binding:"oneof=0 1 2"`
+	TargetPlatforms string `json:"target_platforms" binding:"max=100"`
 }
 
 type AnnouncementUpdateReq struct {
@@ -33,4 +72,10 @@ type AnnouncementUpdateReq struct {
 	SortOrder int    `json:"sort_order"`
 	StartTime string `json:"start_time"`
 	EndTime   string `json:"end_time"`
+
+	// targeting fields are left unchanged when omitted
+	TargetProvinces *string `json:"target_provinces" binding:"omitempty,max=500"`
+	TargetCities    *string `json:"target_cities" binding:"omitempty,max=500"`
+	TargetRole      *int8   `json:"target_role" binding:"omitempty,oneof=0 1 2"`
+	TargetPlatforms *string `json:"target_platforms" binding:"omitempty,max=100"`
 }
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// audit target types
+const (
+	AuditTargetUser          = "user"
+	AuditTargetTrip          = "trip"
+	AuditTargetAnnouncement  = "announcement"
+	AuditTargetAdmin         = "admin"
+	AuditTargetAppeal        = "appeal"
+	AuditTargetBroadcast     = "broadcast"
+	AuditTargetSensitiveWord = "sensitive_word"
+	AuditTargetContentFlag   = "content_flag"
+)
+
+// audit actions
+const (
+	AuditActionUserBan             = "user.ban"
+	AuditActionUserUnban           = "user.unban"
+	AuditActionTripBan             = "trip.ban"
+	AuditActionTripUnban           = "trip.unban"
+	AuditActionAnnouncementCreate  = "announcement.create"
+	AuditActionAnnouncementUpdate  = "announcement.update"
+	AuditActionAnnouncementDelete  = "announcement.delete"
+	AuditActionAdminCreate         = "admin.create"
+	AuditActionAdminDisable        = "admin.disable"
+	AuditActionAdminEnable         = "admin.enable"
+	AuditActionAdminUpdateRole     = "admin.update_role"
+	AuditActionAdminResetPassword  = "admin.reset_password"
+	AuditActionAppealApprove       = "appeal.approve"
+	AuditActionAppealReject        = "appeal.reject"
+	AuditActionBroadcastCreate     = "broadcast.create"
+	AuditActionBroadcastCancel     = "broadcast.cancel"
+	AuditActionSensitiveWordCreate = "sensitive_word.create"
+	AuditActionSensitiveWordUpdate = "sensitive_word.update"
+	AuditActionSensitiveWordDelete = "sensitive_word.delete"
+	AuditActionContentFlagApprove  = "content_flag.approve"
+	AuditActionContentFlagReject   = "content_flag.reject"
+)
+
+// AdminAuditLog is a persistent record of an admin mutation
+type AdminAuditLog struct {
+	ID            uint64          `json:"id"`
+	AdminID       uint64          `json:"admin_id"`
+	AdminUsername string          `json:"admin_username"`
+	Action        string          `json:"action"`
+	TargetType    string          `json:"target_type"`
+	TargetID      string          `json:"target_id"` // numeric ID or open_id
+	BeforeState   json.RawMessage `json:"before_state"`
+	AfterState    json.RawMessage `json:"after_state"`
+	Method        string          `json:"method"`
+	Path          string          `json:"path"`
+	ResultCode    int             `json:"result_code"` // response code, 0 means success
+	IP            string          `json:"ip"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+type AdminAuditLogListReq struct {
+	AdminUsername string `form:"admin"`
+	Action        string `form:"action"`
+	TargetType    string `form:"target_type"`
+	TargetID      string `form:"target_id"`
+	StartDate     string `form:"start_date"` // YYYY-MM-DD, inclusive
+	EndDate       string `form:"end_date"`   // YYYY-MM-DD, inclusive
+	Page          int    `form:"page,default=1"`
+	PageSize      int    `form:"page_size,default=20"`
+}
+
+type AdminAuditLogListResp struct {
+	List  []*AdminAuditLog `json:"list"`
+	Total int64            `json:"total"`
+}
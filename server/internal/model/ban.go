@@ -0,0 +1,109 @@
+package model
+
+import "time"
+
+const (
+	BanTargetUser = "user"
+	BanTargetTrip = "trip"
+
+	BanStatusActive  = 0
+	BanStatusLifted  = 1 // lifted by admin or approved appeal
+	BanStatusExpired = 2 // lifted automatically on expiry
+
+	AppealStatusPending  = 0
+	AppealStatusApproved = 1
+	AppealStatusRejected = 2
+
+	// DefaultBanReason is used when the admin does not give one
+	DefaultBanReason = "违反平台使用规范"
+)
+
+// Ban records a ban on a user account or a trip
+type Ban struct {
+	ID            uint64     `json:"id"`
+	TargetType    string     `json:"target_type"` // user or trip
+	UserID        uint64     `json:"-"`           // affected user (trip owner for trip bans)
+	UserOpenID    string     `json:"user_id"`
+	TripID        uint64     `json:"trip_id"`     // 0 for user bans
+	TripStatus    int8       `json:"trip_status"` // status of the trip before the ban, restored when lifted
+	Reason        string     `json:"reason"`
+	ExpiresAt     *time.Time `json:"expires_at"` // nil means permanent
+	AdminID       uint64     `json:"admin_id"`
+	AdminUsername string     `json:"admin_username"`
+	Status        int8       `json:"status"` // 0-active 1-lifted 2-expired
+	LiftedAt      *time.Time `json:"lifted_at"`
+	LiftedBy      string     `json:"lifted_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Appeal is a user's request to lift a ban
+type Appeal struct {
+	ID           uint64     `json:"id"`
+	BanID        uint64     `json:"ban_id"`
+	UserID       uint64     `json:"-"`
+	UserOpenID   string     `json:"user_id"`
+	Content      string     `json:"content"`
+	Status       int8       `json:"status"` // 0-pending 1-approved 2-rejected
+	ReviewerID   uint64     `json:"reviewer_id"`
+	ReviewerName string     `json:"reviewer_name"`
+	ReviewNote   string     `json:"review_note"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	Ban          *Ban       `json:"ban,omitempty"`
+}
+
+// AdminBanReq is the optional body of admin ban requests
+type AdminBanReq struct {
+	Reason        string `json:"reason" binding:"max=255"`
+	DurationHours int    `json:"duration_hours" binding:"min=0"` // 0 means permanent
+}
+
+// BanOperator identifies the admin issuing or lifting a ban
+type BanOperator struct {
+	AdminID  uint64
+	Username string
+}
+
+type BanListReq struct {
+	TargetType string `form:"target_type"`
+	UserID     string `form:"user_id"` // open_id
+	Status     *int8  `form:"status"`
+	Page       int    `form:"page,default=1"`
+	PageSize   int    `form:"page_size,default=20"`
+}
+
+type BanListResp struct {
+	List  []*Ban `json:"list"`
+	Total int64  `json:"total"`
+}
+
+type AppealCreateReq struct {
+	BanID   uint64 `json:"ban_id" binding:"required"`
+	Content string `json:"content" binding:"required,max=500"`
+}
+
+// BannedUserAppealReq lets a banned user, who can no longer log in, appeal with credentials
+type BannedUserAppealReq struct {
+	Phone       string `json:"phone" binding:"required"`
+	Password    string `json:"password" binding:"required"` // MD5 hashed by frontend
+	Content     string `json:"content" binding:"required,max=500"`
+	CaptchaID   string `json:"captcha_id" binding:"max=64"` // required after repeated failures, as for login
+	CaptchaCode string `json:"captcha_code" binding:"max=16"`
+}
+
+type AppealReviewReq struct {
+	Note string `json:"note" binding:"max=255"`
+}
+
+type AppealListReq struct {
+	Status   *int8 `form:"status"`
+	Page     int   `form:"page,default=1"`
+	PageSize int   `form:"page_size,default=20"`
+}
+
+type AppealListResp struct {
+	List  []*Appeal `json:"list"`
+	Total int64     `json:"total"`
+}
@@ -0,0 +1,86 @@
+package model
+
+import "time"
+
+// SystemUserID is the "系统通知" user seeded by init.sql, sender of system chat messages
+const SystemUserID uint64 = 1
+
+// broadcast delivery channels
+const (
+	BroadcastChannelMessage      = "message"      // system chat message in the user's inbox
+	BroadcastChannelNotification = "notification" // entry in the notification list
+)
+
+// broadcast audiences
+const (
+	BroadcastAudienceAll   = "all"
+	BroadcastAudienceCity  = "city"  // users whose profile city matches
+	BroadcastAudienceRoute = "route" // users with trips on a route departing on a date
+	BroadcastAudienceUsers = "users" // explicit list of open IDs
+)
+
+// broadcast status
+const (
+	BroadcastStatusPending   = 0
+	BroadcastStatusRunning   = 1
+	BroadcastStatusCompleted = 2
+	BroadcastStatusCancelled = 3
+)
+
+// MaxBroadcastOpenIDs caps the explicit recipient list of a broadcast
+const MaxBroadcastOpenIDs = 1000
+
+// Broadcast is an admin message delivered to many users by a background job
+type Broadcast struct {
+	ID              uint64 `json:"id"`
+	Channel         string `json:"channel"`
+	Title           string `json:"title"` // notification title, unused for chat messages
+	Content         string `json:"content"`
+	AudienceType    string `json:"audience_type"`
+	City            string `json:"city"`
+	DepartureCity   string `json:"departure_city"`
+	DestinationCity string `json:"destination_city"`
+	TripDate        string `json:"trip_date"` // YYYY-MM-DD
+	OpenIDs         string `json:"open_ids"`  // comma separated
+	Status          int8   `json:"status"`    // 0-pending 1-running 2-completed 3-cancelled
+	// progress, LastUserID is the checkpoint delivery resumes from
+	TotalCount    int        `json:"total_count"`
+	SentCount     int        `json:"sent_count"`
+	FailedCount   int        `json:"failed_count"`
+	LastUserID    uint64     `json:"-"`
+	AdminID       uint64     `json:"admin_id"`
+	AdminUsername string     `json:"admin_username"`
+	StartedAt     *time.Time `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// BroadcastRecipient is a user a broadcast is delivered to
+type BroadcastRecipient struct {
+	UserID uint64
+	OpenID string
+}
+
+type BroadcastCreateReq struct {
+	Channel         string   `json:"channel" binding:"required,oneof=message notification"`
+	Title           string   `json:"title" binding:"max=100"`
+	Content         string   `json:"content" binding:"required,max=2000"`
+	AudienceType    string   `json:"audience_type" binding:"required,oneof=all city route users"`
+	City            string   `json:"city" binding:"max=50"`
+	DepartureCity   string   `json:"departure_city" binding:"max=50"`
+	DestinationCity string   `json:"destination_city" binding:"max=50"`
+	TripDate        string   `json:"trip_date"`
+	OpenIDs         []string `json:"open_ids"`
+}
+
+type BroadcastListReq struct {
+	Status   *int8 `form:"status"`
+	Page     int   `form:"page,default=1"`
+	PageSize int   `form:"page_size,default=20"`
+}
+
+type BroadcastListResp struct {
+	List  []*Broadcast `json:"list"`
+	Total int64        `json:"total"`
+}
@@ -0,0 +1,95 @@
+package model
+
+import "time"
+
+// trip group status
+const (
+	TripGroupStatusActive = 0
+	TripGroupStatusClosed = 1 // driver trip ended, cancelled, deleted or banned, no new messages
+)
+
+// trip group member roles and status
+const (
+	GroupMemberRoleDriver    = 1
+	GroupMemberRolePassenger = 2
+
+	GroupMemberStatusActive = 0
+	GroupMemberStatusLeft   = 1
+)
+
+// TripGroup is the group conversation of a driver trip and its matched or booked passengers
+type TripGroup struct {
+	ID          uint64    `json:"id"`
+	TripID      uint64    `json:"trip_id"` // driver trip
+	OwnerID     uint64    `json:"-"`       // driver internal ID
+	OwnerOpenID string    `json:"owner_id"`
+	Name        string    `json:"name"`
+	Status      int8      `json:"status"` // 0-active 1-closed
+	Muted       bool      `json:"muted"`  // the viewer muted the group
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// joined fields
+	MemberCount   int                `json:"member_count"`
+	UnreadCount   int                `json:"unread_count"`
+	LastMessage   *GroupMessage      `json:"last_message,omitempty"`
+	LastMessageAt *time.Time         `json:"last_message_at,omitempty"`
+	Members       []*TripGroupMember `json:"members,omitempty"`
+}
+
+// TripGroupMember is a user in a trip group
+type TripGroupMember struct {
+	ID                uint64     `json:"-"`
+	GroupID           uint64     `json:"group_id"`
+	UserID            uint64     `json:"-"`
+	Role              int8       `json:"role"`    // 1-driver 2-passenger
+	TripID            uint64     `json:"trip_id"` // passenger trip the member joined through, 0 for bookings
+	Status            int8       `json:"status"`  // 0-active 1-left
+	JoinedMessageID   uint64     `json:"-"`       // newest group message when the member joined, only later ones are visible
+	LastReadMessageID uint64     `json:"last_read_message_id"`
+	Muted             bool       `json:"-"` // messages are pushed without alerts, only shown to the member as TripGroup.Muted
+	JoinedAt          time.Time  `json:"joined_at"`
+	LeftAt            *time.Time `json:"left_at,omitempty"`
+	User              *User      `json:"user,omitempty"`
+}
+
+// GroupMessage is a message sent to a trip group
+type GroupMessage struct {
+	ID           uint64    `json:"id"`
+	GroupID      uint64    `json:"group_id"`
+	SenderID     uint64    `json:"-"`
+	SenderOpenID string    `json:"sender_id"`
+	Content      string    `json:"content"`
+	MsgType      int8      `json:"msg_type"` // same types as private messages, calls excluded
+	Duration     int       `json:"duration"`
+	CreatedAt    time.Time `json:"created_at"`
+	Sender       *User     `json:"sender,omitempty"`
+}
+
+// GroupSettingReq updates the caller's settings of a group
+type GroupSettingReq struct {
+	Muted *bool `json:"muted" binding:"required"`
+}
+
+type GroupMessageSendReq struct {
+	Content  string `json:"content" binding:"required"`
+	MsgType  int8   `json:"msg_type" binding:"required,oneof=1 2 3 4 6 8"`
+	Duration int    `json:"duration"`
+}
+
+// GroupMessageListReq pages through the group history by message ID, like private messages
+type GroupMessageListReq struct {
+	BeforeID uint64 `form:"before_id"`
+	AfterID  uint64 `form:"after_id"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+// GroupMessageListResp is the response for listing group messages, newest first
+type GroupMessageListResp struct {
+	List    []*GroupMessage `json:"list"`
+	HasMore bool            `json:"has_more"` // more messages exist in the requested direction
+}
+
+type TripGroupListResp struct {
+	List []*TripGroup `json:"list"`
+}
@@ -0,0 +1,22 @@
+package model
+
+// health and dependency statuses
+const (
+	HealthStatusOK           = "ok"
+	HealthStatusReady        = "ready"
+	HealthStatusNotReady     = "not_ready"
+	HealthStatusShuttingDown = "shutting_down"
+	HealthStatusDown         = "down"
+)
+
+// Readiness is the body of GET /readyz
+type Readiness struct {
+	Status string                  `json:"status"`
+	Checks map[string]*HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck is the result of checking one dependency
+type HealthCheck struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+}
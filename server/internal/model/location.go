@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// Reasons a live location share stopped
+const (
+	LiveLocationStopUser    = "stopped"     // the sharer stopped sharing
+	LiveLocationStopExpired = "expired"     // the share window after departure has passed
+	LiveLocationStopEnded   = "match_ended" // the match or one of its trips is no longer active
+)
+
+// LiveLocationFrame is the payload of a live_location frame sent by client over websocket
+type LiveLocationFrame struct {
+	MatchID  uint64  `json:"match_id"`
+	Lat      float64 `json:"lat"`
+	Lng      float64 `json:"lng"`
+	Accuracy float64 `json:"accuracy"` // meters, optional
+	Heading  float64 `json:"heading"`  // degrees clockwise from north, optional
+}
+
+// LiveLocationStopFrame is the payload of a live_location_stop frame sent by client over websocket
+type LiveLocationStopFrame struct {
+	MatchID uint64 `json:"match_id"`
+}
+
+// LiveLocation is a position pushed to the matched counterpart, never persisted
+type LiveLocation struct {
+	MatchID   uint64    `json:"match_id"`
+	UserID    string    `json:"user_id"` // open_id of the sharer
+	Lat       float64   `json:"lat"`
+	Lng       float64   `json:"lng"`
+	Accuracy  float64   `json:"accuracy"`
+	Heading   float64   `json:"heading"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LiveLocationStopped tells the counterpart (and the sharer, unless they stopped it) that sharing ended
+type LiveLocationStopped struct {
+	MatchID uint64 `json:"match_id"`
+	UserID  string `json:"user_id"` // open_id of the sharer
+	Reason  string `json:"reason"`
+}
+
+// LiveLocationStatus tells whether live location can currently be shared for a match
+type LiveLocationStatus struct {
+	MatchID   uint64     `json:"match_id"`
+	Available bool       `json:"available"`
+	Reason    string     `json:"reason,omitempty"`     // why it is unavailable
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // departure time plus the share window
+}
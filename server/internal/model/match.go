@@ -49,3 +49,10 @@ type ContactInfo struct {
 	PassengerPhone    string `json:"passenger_phone"`
 	PassengerNickname string `json:"passenger_nickname"`
 }
+
+// AdminMatchExportReq filters matches for admin export
+type AdminMatchExportReq struct {
+	Status    *int8  `form:"status"`
+	StartDate string `form:"start_date"` // match created date, YYYY-MM-DD
+	EndDate   string `form:"end_date"`
+}
@@ -10,15 +10,41 @@ type Message struct {
 	SenderOpenID   string    `json:"sender_id"`    // open_id for external
 	ReceiverOpenID string    `json:"receiver_id"`  // open_id for external
 	Content        string    `json:"content"`
-	MsgType        int8      `json:"msg_type"`  // 1: text, 2: image, 3: voice, 4: emoji
+	MsgType        int8      `json:"msg_type"`  // 1: text, 2: image, 3: voice, 4: emoji, 5: call, 6: video, 7: recalled, 8: location
 	Duration       int       `json:"duration"`  // voice duration in seconds (for voice messages)
 	IsRead         int8      `json:"is_read"`   // 0: unread, 1: read
+	RecalledAt     *time.Time `json:"recalled_at,omitempty"` // set when the sender recalled the message
+	ClientMsgID    string    `json:"client_msg_id,omitempty"` // client generated idempotency ID
+	ReplyTo        *MessageReply `json:"reply_to,omitempty"` // the quoted message when this is a reply
 	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Deleted        bool      `json:"-"` // deleted by the viewer for themselves, only loaded when syncing
 	// joined fields
 	Sender   *User `json:"sender,omitempty"`
 	Receiver *User `json:"receiver,omitempty"`
 }
 
+// MessageReply is the quoted message of a reply, denormalised when the reply is sent
+type MessageReply struct {
+	ID           uint64 `json:"id"`
+	SenderID     uint64 `json:"-"`         // internal ID, always one of the two participants
+	SenderOpenID string `json:"sender_id"` // open_id for external
+	Snippet      string `json:"snippet"`   // text excerpt or a placeholder such as [图片], replaced once the quoted message is recalled
+}
+
+// SetParticipants fills the open_ids of the sender, the receiver and the quoted message's sender
+func (m *Message) SetParticipants(senderOpenID, receiverOpenID string) {
+	m.SenderOpenID = senderOpenID
+	m.ReceiverOpenID = receiverOpenID
+	if m.ReplyTo != nil {
+		if m.ReplyTo.SenderID == m.SenderID {
+			m.ReplyTo.SenderOpenID = senderOpenID
+		} else {
+			m.ReplyTo.SenderOpenID = receiverOpenID
+		}
+	}
+}
+
 // MsgType constants
 const (
 	MsgTypeText  int8 = 1
@@ -27,8 +53,13 @@ const (
 	MsgTypeEmoji int8 = 4 // emoji message, content is emoji code
 	MsgTypeCall  int8 = 5 // call record, content is JSON with call info
 	MsgTypeVideo int8 = 6 // video message, content is JSON with video info
+	MsgTypeRecalled int8 = 7 // recalled by sender, original content is wiped
+	MsgTypeLocation int8 = 8 // static location, content is JSON with location info
 )
 
+// RecalledMessageContent replaces the content of a recalled message
+const RecalledMessageContent = "此消息已撤回"
+
 // CallRecord represents the call record data stored in message content
 type CallRecord struct {
 	CallType string `json:"call_type"` // "audio" or "video"
@@ -45,25 +76,52 @@ type VideoContent struct {
 	Height    int    `json:"height"`    // video height in pixels
 }
 
+// LocationContent represents the location message data stored in message content
+type LocationContent struct {
+	Lat     float64 `json:"lat"`     // latitude, GCJ-02 as returned by the map SDK
+	Lng     float64 `json:"lng"`     // longitude
+	Address string  `json:"address"` // formatted address, max 200 characters
+	Name    string  `json:"name"`    // optional POI name, max 100 characters
+}
+
 // MessageSendReq is the request body for sending a message
 type MessageSendReq struct {
 	ReceiverID string `json:"receiver_id" binding:"required"`              // open_id
-	Content    string `json:"content" binding:"required"`                  // text max 2000, image/voice is COS key, emoji is emoji code
-	MsgType    int8   `json:"msg_type" binding:"required,oneof=1 2 3 4 5 6"`
+	Content    string `json:"content" binding:"required"`                  // text max 2000, image/voice is COS key, emoji is emoji code, location is LocationContent JSON
+	MsgType    int8   `json:"msg_type" binding:"required,oneof=1 2 3 4 5 6 8"`
 	Duration   int    `json:"duration"`                                    // voice/video duration in seconds
+	ClientMsgID string `json:"client_msg_id" binding:"max=64"`             // optional idempotency ID, retries with the same ID are not posted twice
+	ReplyToID  uint64 `json:"reply_to_id"`                                 // optional ID of a message in the same conversation to quote
+}
+
+// MessageForwardReq is the request body for forwarding a message to another conversation
+type MessageForwardReq struct {
+	ReceiverID  string `json:"receiver_id" binding:"required"` // open_id
+	ClientMsgID string `json:"client_msg_id" binding:"max=64"`
 }
 
-// MessageListReq is the request params for listing messages
+// MessageAck answers a send_message websocket frame
+type MessageAck struct {
+	ClientMsgID string     `json:"client_msg_id"`
+	MessageID   uint64     `json:"message_id,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// MessageListReq is the request params for listing messages.
+// Without a cursor the newest messages are returned, before_id pages back into history
+// and after_id fetches messages newer than the ones the client has.
 type MessageListReq struct {
 	PeerID   string `form:"peer_id" binding:"required"` // open_id
-	Page     int    `form:"page" binding:"min=1"`
-	PageSize int    `form:"page_size" binding:"min=1,max=100"`
+	BeforeID uint64 `form:"before_id"`
+	AfterID  uint64 `form:"after_id"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
 }
 
-// MessageListResp is the response for listing messages
+// MessageListResp is the response for listing messages, newest first
 type MessageListResp struct {
-	List  []*Message `json:"list"`
-	Total int64      `json:"total"`
+	List    []*Message `json:"list"`
+	HasMore bool       `json:"has_more"` // more messages exist in the requested direction
 }
 
 // Conversation represents a chat conversation with another user
@@ -74,9 +132,109 @@ type Conversation struct {
 	LastMessage   *Message  `json:"last_message"`
 	UnreadCount   int       `json:"unread_count"`
 	LastMessageAt time.Time `json:"last_message_at"`
+	// the user's own settings for the conversation
+	Pinned   bool   `json:"pinned"`
+	Muted    bool   `json:"muted"`
+	Archived bool   `json:"archived"`
+	Remark   string `json:"remark"` // custom display name for the peer, empty if not set
 }
 
-// ConversationListResp is the response for listing conversations
+// ConversationListReq is the request params for listing conversations,
+// the cursors are the last_message.id of a conversation already loaded
+type ConversationListReq struct {
+	BeforeID uint64 `form:"before_id"`
+	AfterID  uint64 `form:"after_id"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Archived bool   `form:"archived"` // list archived conversations instead
+}
+
+// ConversationListResp is the response for listing conversations, most recent first.
+// Pinned conversations are returned in pinned on the first page only and never appear in list,
+// archived conversations only appear when listing with archived=true.
 type ConversationListResp struct {
-	List []*Conversation `json:"list"`
+	Pinned  []*Conversation `json:"pinned,omitempty"`
+	List    []*Conversation `json:"list"`
+	HasMore bool            `json:"has_more"`
+}
+
+// Conversation folders for listing, a conversation is in exactly one of them
+const (
+	ConversationFolderInbox    = "inbox"
+	ConversationFolderPinned   = "pinned"
+	ConversationFolderArchived = "archived"
+)
+
+// ConversationSetting holds a user's settings for the conversation with a peer
+type ConversationSetting struct {
+	UserID     uint64 `json:"-"`
+	PeerID     uint64 `json:"-"`
+	PeerOpenID string `json:"peer_id"`
+	Pinned     bool   `json:"pinned"`
+	Muted      bool   `json:"muted"` // new messages are delivered without alerts
+	Archived   bool   `json:"archived"`
+	Remark     string `json:"remark"`
+
+	UpdatedAt time.Time `json:"-"` // sync cursor
+}
+
+// ConversationSettingReq updates conversation settings, omitted fields are left unchanged
+type ConversationSettingReq struct {
+	Pinned   *bool   `json:"pinned"`
+	Muted    *bool   `json:"muted"`
+	Archived *bool   `json:"archived"`
+	Remark   *string `json:"remark" binding:"omitempty,max=50"`
+}
+
+// SyncReq is the request params for incremental sync after reconnecting
+type SyncReq struct {
+	Cursor string `form:"cursor"`                                  // next_cursor of the previous sync, empty for a full sync
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=500"` // per kind of change
+}
+
+// SyncResp lists what changed since the cursor: private messages created or changed (read, recalled),
+// new messages of the groups the user is in and conversation settings changed on any device.
+// Messages the user deleted for themselves are returned in deleted_ids only.
+type SyncResp struct {
+	Messages      []*Message             `json:"messages"`
+	DeletedIDs    []uint64               `json:"deleted_ids"`
+	GroupMessages []*GroupMessage        `json:"group_messages"`
+	Settings      []*ConversationSetting `json:"settings"`
+	NextCursor    string                 `json:"next_cursor"`
+	HasMore       bool                   `json:"has_more"` // call again with next_cursor right away
+}
+
+// ReadReceipt tells a sender how far the peer has read their messages
+type ReadReceipt struct {
+	ReaderID          uint64 `json:"-"`
+	ReaderOpenID      string `json:"reader_id"`
+	SenderID          uint64 `json:"-"`
+	LastReadMessageID uint64 `json:"last_read_message_id"`
+}
+
+// ReadFrameData is the payload of a read frame sent by client over websocket
+type ReadFrameData struct {
+	PeerID string `json:"peer_id"` // open_id of the user whose messages were read
+}
+
+// MessageSearchReq is the request params for searching text messages in the user's own conversations
+type MessageSearchReq struct {
+	Keyword   string `form:"keyword" binding:"required,max=50"`
+	PeerID    string `form:"peer_id"`    // open_id, limit to one conversation
+	StartDate string `form:"start_date"` // YYYY-MM-DD, inclusive
+	EndDate   string `form:"end_date"`   // YYYY-MM-DD, inclusive
+	BeforeID  uint64 `form:"before_id"`  // id of the last result already loaded
+	PageSize  int    `form:"page_size" binding:"omitempty,min=1,max=50"`
+}
+
+// MessageSearchResult is a matched message with the peer of its conversation
+type MessageSearchResult struct {
+	Message   *Message `json:"message"`
+	Peer      *User    `json:"peer"`
+	Highlight string   `json:"highlight"` // HTML escaped snippet, matches wrapped in <em></em>
+}
+
+// MessageSearchResp is the response for searching messages, newest first
+type MessageSearchResp struct {
+	List    []*MessageSearchResult `json:"list"`
+	HasMore bool                   `json:"has_more"`
 }
@@ -0,0 +1,121 @@
+package model
+
+import "time"
+
+// sensitive word actions
+const (
+	ModerationActionBlock = "block" // reject the content
+	ModerationActionMask  = "mask"  // replace the word with '*'
+	ModerationActionFlag  = "flag"  // keep the content and add it to the review list
+)
+
+// scenes content is moderated in
+const (
+	ModerationSceneTripRemark    = "trip_remark"
+	ModerationSceneMessage       = "message"
+	ModerationSceneGroupMessage  = "group_message"
+	ModerationSceneFriendRequest = "friend_request"
+	ModerationSceneNickname      = "nickname"
+	ModerationSceneGrabMessage   = "grab_message"
+	ModerationSceneChatSpam      = "chat_spam" // repeatedly hitting the chat rate limits, flagged with the user as target
+)
+
+// content flag review status
+const (
+	ContentFlagStatusPending  = 0
+	ContentFlagStatusApproved = 1 // reviewed, content is fine
+	ContentFlagStatusRejected = 2 // reviewed, content violates the rules
+)
+
+// ContactWordPhone and ContactWordWechat stand for detected contact details in ContentFlag.MatchedWords
+const (
+	ContactWordPhone  = "[手机号]"
+	ContactWordWechat = "[微信号]"
+)
+
+// IsValidModerationAction checks if the action is a known sensitive word action
+func IsValidModerationAction(action string) bool {
+	return action == ModerationActionBlock || action == ModerationActionMask || action == ModerationActionFlag
+}
+
+// SensitiveWord is an admin managed dictionary entry
+type SensitiveWord struct {
+	ID            uint64    `json:"id"`
+	Word          string    `json:"word"`
+	Action        string    `json:"action"` // block, mask or flag
+	AdminID       uint64    `json:"admin_id"`
+	AdminUsername string    `json:"admin_username"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type SensitiveWordCreateReq struct {
+	Word   string `json:"word" binding:"required,max=50"`
+	Action string `json:"action" binding:"required,oneof=block mask flag"`
+}
+
+type SensitiveWordUpdateReq struct {
+	Action string `json:"action" binding:"required,oneof=block mask flag"`
+}
+
+type SensitiveWordListReq struct {
+	Keyword  string `form:"keyword"`
+	Action   string `form:"action"`
+	Page     int    `form:"page,default=1"`
+	PageSize int    `form:"page_size,default=20"`
+}
+
+type SensitiveWordListResp struct {
+	List  []*SensitiveWord `json:"list"`
+	Total int64            `json:"total"`
+}
+
+// ModerationResult is the outcome of checking a piece of content that was not blocked
+type ModerationResult struct {
+	Scene   string
+	UserID  uint64
+	Text    string   // content to store, with masked words replaced
+	Flagged []string // words of flag rules found, the content goes to the review list
+}
+
+// ContentFlag is user content waiting for or having had admin review
+type ContentFlag struct {
+	ID           uint64     `json:"id"`
+	UserID       uint64     `json:"-"`
+	UserOpenID   string     `json:"user_id"`
+	UserNickname string     `json:"user_nickname"`
+	Scene        string     `json:"scene"`
+	TargetID     uint64     `json:"target_id"` // trip, message, friend request or user ID depending on the scene
+	Content      string     `json:"content"`
+	MatchedWords string     `json:"matched_words"` // comma separated
+	Status       int8       `json:"status"`        // 0-pending 1-approved 2-rejected
+	ReviewerID   uint64     `json:"reviewer_id"`
+	ReviewerName string     `json:"reviewer_name"`
+	ReviewNote   string     `json:"review_note"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type ContentFlagListReq struct {
+	Status   *int8  `form:"status"`
+	Scene    string `form:"scene"`
+	Page     int    `form:"page,default=1"`
+	PageSize int    `form:"page_size,default=20"`
+}
+
+// ContentFlagExportReq filters content flags for the reports export
+type ContentFlagExportReq struct {
+	Status    *int8  `form:"status"`
+	Scene     string `form:"scene"`
+	StartDate string `form:"start_date"` // flag created date, YYYY-MM-DD
+	EndDate   string `form:"end_date"`
+}
+
+type ContentFlagListResp struct {
+	List  []*ContentFlag `json:"list"`
+	Total int64          `json:"total"`
+}
+
+type ContentFlagReviewReq struct {
+	Note string `json:"note" binding:"max=500"`
+}
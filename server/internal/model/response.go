@@ -23,9 +23,11 @@ func Error(code int, message string) *Response {
 
 // error codes
 const (
-	ErrCodeInternal     = 500
-	ErrCodeBadRequest   = 400
-	ErrCodeUnauthorized = 401
-	ErrCodeForbidden    = 403
-	ErrCodeNotFound     = 404
+	ErrCodeInternal        = 500
+	ErrCodeBadRequest      = 400
+	ErrCodeUnauthorized    = 401
+	ErrCodeForbidden       = 403
+	ErrCodeNotFound        = 404
+	ErrCodeCaptchaRequired = 428 // login needs a captcha, fetch one from GET /api/captcha
+	ErrCodeTooManyRequests = 429
 )
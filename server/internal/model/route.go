@@ -0,0 +1,40 @@
+package model
+
+// RouteSupplyDemand compares drivers (supply) and passengers (demand) on a city pair
+type RouteSupplyDemand struct {
+	DepartureCity   string  `json:"departure_city"`
+	DestinationCity string  `json:"destination_city"`
+	Date            string  `json:"date,omitempty"` // departure date, empty when aggregated over the range
+	Supply          int64   `json:"supply"`         // driver trips
+	SupplySeats     int64   `json:"supply_seats"`   // seats offered by drivers
+	Demand          int64   `json:"demand"`         // passenger trips
+	DemandSeats     int64   `json:"demand_seats"`   // seats needed by passengers
+	Ratio           float64 `json:"ratio"`          // demand / supply, supply of 0 counts as 1
+}
+
+const (
+	RouteSortShortage = "shortage" // most passengers per driver first
+	RouteSortSurplus  = "surplus"  // most drivers per passenger first
+	RouteSortVolume   = "volume"   // most trips first
+)
+
+// RouteSupplyDemandReq queries supply and demand by route and departure date for admins
+type RouteSupplyDemandReq struct {
+	StartDate       string `form:"start_date" binding:"required"` // departure date, YYYY-MM-DD
+	EndDate         string `form:"end_date" binding:"required"`
+	DepartureCity   string `form:"departure_city"`
+	DestinationCity string `form:"destination_city"`
+	City            string `form:"city"`                  // routes departing from or arriving at this city
+	Sort            string `form:"sort,default=shortage"` // shortage, surplus or volume
+	MinTrips        int    `form:"min_trips,default=1"`   // ignore routes with fewer trips
+	Limit           int    `form:"limit,default=100"`
+	GroupByDate     bool   `form:"-"` // set by service
+	Statuses        []int8 `form:"-"` // trip statuses counted, set by service
+}
+
+// HotRoutesReq queries popular routes among upcoming open trips
+type HotRoutesReq struct {
+	City  string `form:"city" binding:"max=50"` // only routes departing from or arriving at this city
+	Days  int    `form:"days,default=7"`
+	Limit int    `form:"limit,default=10"`
+}
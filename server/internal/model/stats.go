@@ -0,0 +1,82 @@
+package model
+
+// time-series metrics maintained in the stats_hourly rollup table
+const (
+	StatsMetricNewUsers         = "new_users"
+	StatsMetricDriverTrips      = "driver_trips"
+	StatsMetricPassengerTrips   = "passenger_trips"
+	StatsMetricGrabs            = "grabs"
+	StatsMetricMatchesCreated   = "matches_created"
+	StatsMetricMatchesSucceeded = "matches_succeeded"
+	StatsMetricMessagesSent     = "messages_sent"
+
+	StatsGranularityDay  = "day"
+	StatsGranularityHour = "hour"
+)
+
+// StatsMetrics lists all time-series metrics in display order
+var StatsMetrics = []string{
+	StatsMetricNewUsers,
+	StatsMetricDriverTrips,
+	StatsMetricPassengerTrips,
+	StatsMetricGrabs,
+	StatsMetricMatchesCreated,
+	StatsMetricMatchesSucceeded,
+	StatsMetricMessagesSent,
+}
+
+// IsValidStatsMetric checks if the metric is a known time-series metric
+func IsValidStatsMetric(metric string) bool {
+	for _, m := range StatsMetrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+type StatsTimeseriesReq struct {
+	StartDate   string `form:"start_date" binding:"required"` // YYYY-MM-DD, inclusive
+	EndDate     string `form:"end_date" binding:"required"`   // YYYY-MM-DD, inclusive
+	Granularity string `form:"granularity,default=day"`       // day or hour
+	Metrics     string `form:"metrics"`                       // comma separated, empty means all
+}
+
+// StatsPoint is one bucket value of a metric
+type StatsPoint struct {
+	Bucket string `json:"bucket"` // "2006-01-02" or "2006-01-02 15:00"
+	Value  int64  `json:"value"`
+}
+
+type StatsTimeseriesResp struct {
+	Granularity string             `json:"granularity"`
+	Buckets     []string           `json:"buckets"`
+	Series      map[string][]int64 `json:"series"` // metric -> values aligned with buckets
+	Totals      map[string]int64   `json:"totals"`
+}
+
+type StatsFunnelReq struct {
+	StartDate       string `form:"start_date" binding:"required"` // YYYY-MM-DD, by trip publish date
+	EndDate         string `form:"end_date" binding:"required"`
+	DepartureCity   string `form:"departure_city"`
+	DestinationCity string `form:"destination_city"`
+	Limit           int    `form:"limit,default=50"` // max routes returned
+}
+
+// RouteFunnel counts trips of a route at each funnel step
+type RouteFunnel struct {
+	DepartureCity   string  `json:"departure_city"`
+	DestinationCity string  `json:"destination_city"`
+	Published       int64   `json:"published"`
+	Engaged         int64   `json:"engaged"`   // grabbed or matched
+	Confirmed       int64   `json:"confirmed"` // match confirmed by both sides
+	Completed       int64   `json:"completed"`
+	EngagedRate     float64 `json:"engaged_rate"`   // engaged / published
+	ConfirmedRate   float64 `json:"confirmed_rate"` // confirmed / published
+	CompletedRate   float64 `json:"completed_rate"` // completed / published
+}
+
+type StatsFunnelResp struct {
+	Total  *RouteFunnel   `json:"total"`
+	Routes []*RouteFunnel `json:"routes"`
+}
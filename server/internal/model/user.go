@@ -36,8 +36,10 @@ type UserRegisterReq struct {
 }
 
 type UserLoginReq struct {
-	Phone    string `json:"phone" binding:"required,len=11"`
-	Password string `json:"password" binding:"required"`
+	Phone       string `json:"phone" binding:"required,len=11"`
+	Password    string `json:"password" binding:"required"`
+	CaptchaID   string `json:"captcha_id" binding:"max=64"` // required after repeated failures
+	CaptchaCode string `json:"captcha_code" binding:"max=16"`
 }
 
 type UserLoginResp struct {
@@ -45,6 +47,12 @@ type UserLoginResp struct {
 	User  *User  `json:"user"`
 }
 
+// CaptchaResp is an image captcha, the code is sent back with the captcha_id on login
+type CaptchaResp struct {
+	CaptchaID string `json:"captcha_id"`
+	Image     string `json:"image"` // PNG data URI
+}
+
 type UserUpdateReq struct {
 	Nickname string `json:"nickname" binding:"omitempty,min=2,max=20"`
 	Avatar   string `json:"avatar"`
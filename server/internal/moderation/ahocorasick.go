@@ -0,0 +1,121 @@
+// Package moderation finds sensitive words and contact details in user content.
+// Words are matched with an Aho–Corasick automaton so a text is scanned once
+// regardless of the dictionary size.
+package moderation
+
+import "unicode"
+
+// Match is an occurrence of a dictionary pattern, Start and End are rune offsets [Start, End)
+type Match struct {
+	Pattern int // index into the patterns the matcher was built with
+	Start   int
+	End     int
+}
+
+type node struct {
+	next   map[rune]int
+	fail   int
+	output []int // patterns ending at this node, including those reached via fail links
+}
+
+// Matcher is an immutable Aho–Corasick automaton, safe for concurrent use
+type Matcher struct {
+	nodes    []node
+	patterns [][]rune
+}
+
+// NewMatcher builds a matcher for the patterns, matching ignores case and full-width forms
+// Empty patterns are ignored
+func NewMatcher(patterns []string) *Matcher {
+	m := &Matcher{
+		nodes:    []node{{next: map[rune]int{}}},
+		patterns: make([][]rune, len(patterns)),
+	}
+
+	// build the trie
+	for i, p := range patterns {
+		runes := fold([]rune(p))
+		m.patterns[i] = runes
+		if len(runes) == 0 {
+			continue
+		}
+		cur := 0
+		for _, r := range runes {
+			nxt, ok := m.nodes[cur].next[r]
+			if !ok {
+				nxt = len(m.nodes)
+				m.nodes = append(m.nodes, node{next: map[rune]int{}})
+				m.nodes[cur].next[r] = nxt
+			}
+			cur = nxt
+		}
+		m.nodes[cur].output = append(m.nodes[cur].output, i)
+	}
+
+	// breadth first to set fail links, children of the root fail to the root
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[cur].next {
+			f := m.nodes[cur].fail
+			for f > 0 {
+				if _, ok := m.nodes[f].next[r]; ok {
+					break
+				}
+				f = m.nodes[f].fail
+			}
+			if target, ok := m.nodes[f].next[r]; ok && target != child {
+				m.nodes[child].fail = target
+			}
+			fail := m.nodes[child].fail
+			m.nodes[child].output = append(m.nodes[child].output, m.nodes[fail].output...)
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+// FindAll returns every occurrence of every pattern in text, overlapping matches included,
+// ordered by end offset
+func (m *Matcher) FindAll(text string) []Match {
+	if m == nil || len(m.nodes) == 1 {
+		return nil
+	}
+	var matches []Match
+	cur := 0
+	for i, r := range fold([]rune(text)) {
+		for cur > 0 {
+			if _, ok := m.nodes[cur].next[r]; ok {
+				break
+			}
+			cur = m.nodes[cur].fail
+		}
+		if nxt, ok := m.nodes[cur].next[r]; ok {
+			cur = nxt
+		}
+		for _, p := range m.nodes[cur].output {
+			matches = append(matches, Match{Pattern: p, Start: i + 1 - len(m.patterns[p]), End: i + 1})
+		}
+	}
+	return matches
+}
+
+// fold lowercases runes and maps full-width ASCII to half-width, one to one so offsets
+// in the folded text match the original
+func fold(runes []rune) []rune {
+	out := make([]rune, len(runes))
+	for i, r := range runes {
+		switch {
+		case r >= '！' && r <= '～':
+			r -= '！' - '!'
+		case r == '\u3000': // ideographic space
+			r = ' '
+		}
+		out[i] = unicode.ToLower(r)
+	}
+	return out
+}
@@ -0,0 +1,139 @@
+package moderation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatcherFindAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		text     string
+		want     []Match
+	}{
+		{
+			name:     "no patterns",
+			patterns: nil,
+			text:     "任何内容",
+			want:     nil,
+		},
+		{
+			name:     "no match",
+			patterns: []string{"赌博"},
+			text:     "明天去上海",
+			want:     nil,
+		},
+		{
+			name:     "single match",
+			patterns: []string{"赌博"},
+			text:     "这里有赌博网站",
+			want:     []Match{{Pattern: 0, Start: 3, End: 5}},
+		},
+		{
+			name:     "repeated",
+			patterns: []string{"ab"},
+			text:     "abxab",
+			want:     []Match{{0, 0, 2}, {0, 3, 5}},
+		},
+		{
+			name:     "overlapping",
+			patterns: []string{"aba"},
+			text:     "ababa",
+			want:     []Match{{0, 0, 3}, {0, 2, 5}},
+		},
+		{
+			name:     "overlapping different words",
+			patterns: []string{"he", "she", "his", "hers"},
+			text:     "ushers",
+			want:     []Match{{1, 1, 4}, {0, 2, 4}, {3, 2, 6}},
+		},
+		{
+			name:     "nested",
+			patterns: []string{"发票", "代开发票"},
+			text:     "可代开发票",
+			want:     []Match{{1, 1, 5}, {0, 3, 5}},
+		},
+		{
+			name:     "nested prefix",
+			patterns: []string{"微信", "微信号"},
+			text:     "加微信号",
+			want:     []Match{{0, 1, 3}, {1, 1, 4}},
+		},
+		{
+			name:     "fail link to a shorter word",
+			patterns: []string{"abcd", "bc"},
+			text:     "abce",
+			want:     []Match{{1, 1, 3}},
+		},
+		{
+			name:     "case insensitive",
+			patterns: []string{"VPN"},
+			text:     "免费vpn和Vpn",
+			want:     []Match{{0, 2, 5}, {0, 6, 9}},
+		},
+		{
+			name:     "full-width text",
+			patterns: []string{"vpn"},
+			text:     "免费ＶＰＮ",
+			want:     []Match{{0, 2, 5}},
+		},
+		{
+			name:     "full-width pattern",
+			patterns: []string{"ｑｑ"},
+			text:     "加QQ",
+			want:     []Match{{0, 1, 3}},
+		},
+		{
+			name:     "ideographic space",
+			patterns: []string{"a b"},
+			text:     "a　b",
+			want:     []Match{{0, 0, 3}},
+		},
+		{
+			name:     "empty pattern ignored",
+			patterns: []string{"", "x"},
+			text:     "x",
+			want:     []Match{{1, 0, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewMatcher(tt.patterns).FindAll(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindAll(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilMatcher(t *testing.T) {
+	var m *Matcher
+	if got := m.FindAll("text"); got != nil {
+		t.Errorf("FindAll on nil matcher = %v, want nil", got)
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		text     string
+		want     string
+	}{
+		{"no match", []string{"赌博"}, "你好", "你好"},
+		{"single", []string{"赌博"}, "不要赌博哦", "不要**哦"},
+		{"overlapping", []string{"aba"}, "ababa", "*****"},
+		{"nested", []string{"发票", "代开发票"}, "可代开发票吗", "可****吗"},
+		{"keeps the original case", []string{"vpn"}, "买VPN或vpn", "买***或***"},
+		{"full-width", []string{"vpn"}, "ＶＰＮ好用", "***好用"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMatcher(tt.patterns)
+			if got := Mask(tt.text, m.FindAll(tt.text)); got != tt.want {
+				t.Errorf("Mask(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package moderation
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+var (
+	// mainland mobile numbers, allowing spaces or dashes between digit groups
+	phonePattern = regexp.MustCompile(`(?:\+?86[\s-]?)?1[3-9]\d(?:[\s-]?\d){8}`)
+	// WeChat IDs announced with a keyword, e.g. "微信: abc_123" or "vx abc123"
+	wechatPattern = regexp.MustCompile(`(?i)(?:微信|威信|薇信|v信|wx|vx|weixin|wechat)(?:号)?[\s:：]*[a-z][-_a-z0-9]{5,19}`)
+)
+
+// FindContacts returns the rune offsets [Start, End) of phone numbers and WeChat IDs in text.
+// Pattern is 0 for phone numbers and 1 for WeChat IDs.
+func FindContacts(text string) []Match {
+	var matches []Match
+	for p, re := range []*regexp.Regexp{phonePattern, wechatPattern} {
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			start := utf8.RuneCountInString(text[:loc[0]])
+			matches = append(matches, Match{
+				Pattern: p,
+				Start:   start,
+				End:     start + utf8.RuneCountInString(text[loc[0]:loc[1]]),
+			})
+		}
+	}
+	return matches
+}
+
+// Mask replaces every rune covered by the matches with '*'
+func Mask(text string, matches []Match) string {
+	if len(matches) == 0 {
+		return text
+	}
+	runes := []rune(text)
+	for _, m := range matches {
+		for i := m.Start; i < m.End && i < len(runes); i++ {
+			runes[i] = '*'
+		}
+	}
+	return string(runes)
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"pinche/internal/database"
+	"pinche/internal/model"
+	"pinche/internal/tracing"
+)
+
+type AdminRepository struct{}
+
+func NewAdminRepository() *AdminRepository {
+	return &AdminRepository{}
+}
+
+const adminColumns = `id, username, password, role, status, last_login_at, COALESCE(last_login_ip, ''),
+	COALESCE(created_by, ''), created_at, updated_at`
+
+func scanAdmin(scanner interface{ Scan(...interface{}) error }) (*model.Admin, error) {
+	admin := &model.Admin{}
+	var lastLoginAt sql.NullTime
+	err := scanner.Scan(&admin.ID, &admin.Username, &admin.Password, &admin.Role, &admin.Status,
+		&lastLoginAt, &admin.LastLoginIP, &admin.CreatedBy, &admin.CreatedAt, &admin.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastLoginAt.Valid {
+		admin.LastLoginAt = &lastLoginAt.Time
+	}
+	return admin, nil
+}
+
+func (r *AdminRepository) Create(ctx context.Context, admin *model.Admin) (err error) {
+	query := `INSERT INTO admins (username, password, role, status, created_by) VALUES (?, ?, ?, ?, ?)`
+	ctx, span := database.StartSpan(ctx, "AdminRepository.Create", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, admin.Username, admin.Password, admin.Role, admin.Status, admin.CreatedBy)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	admin.ID = uint64(id)
+	return nil
+}
+
+func (r *AdminRepository) GetByID(ctx context.Context, id uint64) (_ *model.Admin, err error) {
+	query := `SELECT ` + adminColumns + ` FROM admins WHERE id = ?`
+	ctx, span := database.StartSpan(ctx, "AdminRepository.GetByID", query)
+	defer tracing.End(span, &err)
+	admin, err := scanAdmin(database.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return admin, nil
+}
+
+func (r *AdminRepository) GetByUsername(ctx context.Context, username string) (_ *model.Admin, err error) {
+	query := `SELECT ` + adminColumns + ` FROM admins WHERE username = ?`
+	ctx, span := database.StartSpan(ctx, "AdminRepository.GetByUsername", query)
+	defer tracing.End(span, &err)
+	admin, err := scanAdmin(database.DB.QueryRowContext(ctx, query, username))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return admin, nil
+}
+
+// Count returns the total number of admin accounts
+func (r *AdminRepository) Count(ctx context.Context) (_ int64, err error) {
+	query := `SELECT COUNT(*) FROM admins`
+	ctx, span := database.StartSpan(ctx, "AdminRepository.Count", query)
+	defer tracing.End(span, &err)
+	var count int64
+	err = database.DB.QueryRowContext(ctx, query).Scan(&count)
+	return count, err
+}
+
+// CountActiveByRole returns the number of enabled admins with the given role
+func (r *AdminRepository) CountActiveByRole(ctx context.Context, role string) (_ int64, err error) {
+	query := `SELECT COUNT(*) FROM admins WHERE role = ? AND status = ?`
+	ctx, span := database.StartSpan(ctx, "AdminRepository.CountActiveByRole", query)
+	defer tracing.End(span, &err)
+	var count int64
+	err = database.DB.QueryRowContext(ctx, query, role, model.AdminStatusActive).Scan(&count)
+	return count, err
+}
+
+func (r *AdminRepository) List(ctx context.Context, req *model.AdminListReq) (_ []*model.Admin, _ int64, err error) {
+	var conditions []string
+	var args []interface{}
+
+	if req.Search != "" {
+		conditions = append(conditions, "username LIKE ?")
+		args = append(args, "%"+req.Search+"%")
+	}
+	if req.Role != "" {
+		conditions = append(conditions, "role = ?")
+		args = append(args, req.Role)
+	}
+	if req.Status != nil {
+		conditions = append(conditions, "status = ?")
+		args = append(args, *req.Status)
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// count
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM admins %s", whereClause)
+	var total int64
+	ctx, span := database.StartSpan(ctx, "AdminRepository.List", countQuery)
+	defer tracing.End(span, &err)
+	if err = database.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// list
+	offset := (req.Page - 1) * req.PageSize
+	listQuery := fmt.Sprintf(`SELECT %s FROM admins %s ORDER BY created_at DESC LIMIT ? OFFSET ?`, adminColumns, whereClause)
+	args = append(args, req.PageSize, offset)
+
+	rows, err := database.DB.QueryContext(ctx, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var admins []*model.Admin
+	for rows.Next() {
+		admin, err := scanAdmin(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		admins = append(admins, admin)
+	}
+	return admins, total, nil
+}
+
+func (r *AdminRepository) UpdateStatus(ctx context.Context, id uint64, status int8) (err error) {
+	query := `UPDATE admins SET status = ? WHERE id = ?`
+	ctx, span := database.StartSpan(ctx, "AdminRepository.UpdateStatus", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, status, id)
+	return err
+}
+
+func (r *AdminRepository) UpdateRole(ctx context.Context, id uint64, role string) (err error) {
+	query := `UPDATE admins SET role = ? WHERE id = ?`
+	ctx, span := database.StartSpan(ctx, "AdminRepository.UpdateRole", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, role, id)
+	return err
+}
+
+func (r *AdminRepository) UpdatePassword(ctx context.Context, id uint64, password string) (err error) {
+	query := `UPDATE admins SET password = ? WHERE id = ?`
+	ctx, span := database.StartSpan(ctx, "AdminRepository.UpdatePassword", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, password, id)
+	return err
+}
+
+// UpdateLastLogin records the time and IP of a successful login
+func (r *AdminRepository) UpdateLastLogin(ctx context.Context, id uint64, ip string) (err error) {
+	query := `UPDATE admins SET last_login_at = ?, last_login_ip = ? WHERE id = ?`
+	ctx, span := database.StartSpan(ctx, "AdminRepository.UpdateLastLogin", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, time.Now(), ip, id)
+	return err
+}
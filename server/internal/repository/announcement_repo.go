@@ -1,11 +1,15 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	"pinche/internal/database"
 	"pinche/internal/model"
+	"pinche/internal/tracing"
 )
 
 type AnnouncementRepository struct{}
@@ -14,10 +18,31 @@ func NewAnnouncementRepository() *AnnouncementRepository {
 	return &AnnouncementRepository{}
 }
 
-func (r *AnnouncementRepository) Create(ann *model.Announcement) error {
-	query := `INSERT INTO announcements (title, content, type, is_active, sort_order, start_time, end_time) 
-		VALUES (?, ?, ?, ?, ?, ?, ?)`
-	result, err := database.DB.Exec(query, ann.Title, ann.Content, ann.Type, ann.IsActive, ann.SortOrder, ann.StartTime, ann.EndTime)
+const announcementColumns = `id, title, content, type, is_active, sort_order, start_time, end_time,
+	target_provinces, target_cities, target_role, target_platforms, pushed_at, created_at, updated_at`
+
+func scanAnnouncement(scanner interface{ Scan(...interface{}) error }) (*model.Announcement, error) {
+	ann := &model.Announcement{}
+	var pushedAt sql.NullTime
+	err := scanner.Scan(&ann.ID, &ann.Title, &ann.Content, &ann.Type, &ann.IsActive, &ann.SortOrder, &ann.StartTime, &ann.EndTime,
+		&ann.TargetProvinces, &ann.TargetCities, &ann.TargetRole, &ann.TargetPlatforms, &pushedAt, &ann.CreatedAt, &ann.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if pushedAt.Valid {
+		ann.PushedAt = &pushedAt.Time
+	}
+	return ann, nil
+}
+
+func (r *AnnouncementRepository) Create(ctx context.Context, ann *model.Announcement) (err error) {
+	query := `INSERT INTO announcements (title, content, type, is_active, sort_order, start_time, end_time,
+		target_provinces, target_cities, target_role, target_platforms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	ctx, span := database.StartSpan(ctx, "AnnouncementRepository.Create", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, ann.Title, ann.Content, ann.Type, ann.IsActive, ann.SortOrder, ann.StartTime, ann.EndTime,
+		ann.TargetProvinces, ann.TargetCities, ann.TargetRole, ann.TargetPlatforms)
 	if err != nil {
 		return err
 	}
@@ -29,13 +54,11 @@ func (r *AnnouncementRepository) Create(ann *model.Announcement) error {
 	return nil
 }
 
-func (r *AnnouncementRepository) GetByID(id uint64) (*model.Announcement, error) {
-	query := `SELECT id, title, content, type, is_active, sort_order, start_time, end_time, created_at, updated_at 
-		FROM announcements WHERE id = ?`
-	ann := &model.Announcement{}
-	err := database.DB.QueryRow(query, id).Scan(
-		&ann.ID, &ann.Title, &ann.Content, &ann.Type, &ann.IsActive, &ann.SortOrder, &ann.StartTime, &ann.EndTime, &ann.CreatedAt, &ann.UpdatedAt,
-	)
+func (r *AnnouncementRepository) GetByID(ctx context.Context, id uint64) (_ *model.Announcement, err error) {
+	query := `SELECT ` + announcementColumns + ` FROM announcements WHERE id = ?`
+	ctx, span := database.StartSpan(ctx, "AnnouncementRepository.GetByID", query)
+	defer tracing.End(span, &err)
+	ann, err := scanAnnouncement(database.DB.QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -45,16 +68,20 @@ func (r *AnnouncementRepository) GetByID(id uint64) (*model.Announcement, error)
 	return ann, nil
 }
 
-// GetActiveAnnouncements returns currently active announcements for public display
-func (r *AnnouncementRepository) GetActiveAnnouncements(limit int) ([]*model.Announcement, error) {
+// ListActive returns currently active announcements, excluding those the user dismissed
+// Targeting is applied by the caller, userID 0 skips the dismissal filter
+func (r *AnnouncementRepository) ListActive(ctx context.Context, userID uint64, limit int) (_ []*model.Announcement, err error) {
 	now := time.Now()
-	query := `SELECT id, title, content, type, is_active, sort_order, start_time, end_time, created_at, updated_at 
-		FROM announcements 
+	query := `SELECT ` + announcementColumns + ` 
+		FROM announcements a
 		WHERE is_active = 1 AND start_time <= ? AND end_time >= ?
+		AND NOT EXISTS (SELECT 1 FROM announcement_dismissals d WHERE d.announcement_id = a.id AND d.user_id = ?)
 		ORDER BY sort_order DESC, created_at DESC
 		LIMIT ?`
 
-	rows, err := database.DB.Query(query, now, now, limit)
+	ctx, span := database.StartSpan(ctx, "AnnouncementRepository.ListActive", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, now, now, userID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -62,31 +89,122 @@ func (r *AnnouncementRepository) GetActiveAnnouncements(limit int) ([]*model.Ann
 
 	var announcements []*model.Announcement
 	for rows.Next() {
-		ann := &model.Announcement{}
-		err := rows.Scan(&ann.ID, &ann.Title, &ann.Content, &ann.Type, &ann.IsActive, &ann.SortOrder, &ann.StartTime, &ann.EndTime, &ann.CreatedAt, &ann.UpdatedAt)
+		ann, err := scanAnnouncement(rows)
 		if err != nil {
 			return nil, err
 		}
 		announcements = append(announcements, ann)
 	}
-	return announcements, nil
+	return announcements, rows.Err()
+}
+
+// ListPendingUrgent returns active urgent announcements that have not been pushed yet
+func (r *AnnouncementRepository) ListPendingUrgent(ctx context.Context) (_ []*model.Announcement, err error) {
+	now := time.Now()
+	query := `SELECT ` + announcementColumns + ` 
+		FROM announcements
+		WHERE type = ? AND is_active = 1 AND pushed_at IS NULL AND start_time <= ? AND end_time >= ?
+		ORDER BY start_time`
+
+	ctx, span := database.StartSpan(ctx, "AnnouncementRepository.ListPendingUrgent", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, model.AnnouncementTypeUrgent, now, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var announcements []*model.Announcement
+	for rows.Next() {
+		ann, err := scanAnnouncement(rows)
+		if err != nil {
+			return nil, err
+		}
+		announcements = append(announcements, ann)
+	}
+	return announcements, rows.Err()
+}
+
+// MarkPushed records the push time, returning false if another run already claimed it
+func (r *AnnouncementRepository) MarkPushed(ctx context.Context, id uint64, pushedAt time.Time) (_ bool, err error) {
+	query := `UPDATE announcements SET pushed_at = ? WHERE id = ? AND pushed_at IS NULL`
+	ctx, span := database.StartSpan(ctx, "AnnouncementRepository.MarkPushed", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, pushedAt, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// GetAudiences loads the targeting attributes of the given users
+// A user counts as driver or passenger if they published such a trip since the given time
+func (r *AnnouncementRepository) GetAudiences(ctx context.Context, userIDs []uint64, since time.Time) (_ []*model.AnnouncementAudience, err error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	placeholders := make([]string, len(userIDs))
+	args := []interface{}{model.TripTypeDriver, since, model.TripTypePassenger, since}
+	for i, id := range userIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+
+	query := fmt.Sprintf(`SELECT u.id, u.province, u.city,
+		EXISTS (SELECT 1 FROM trips t WHERE t.user_id = u.id AND t.trip_type = ? AND t.created_at >= ?),
+		EXISTS (SELECT 1 FROM trips t WHERE t.user_id = u.id AND t.trip_type = ? AND t.created_at >= ?)
+		FROM users u WHERE u.id IN (%s)`, strings.Join(placeholders, ","))
+
+	ctx, span := database.StartSpan(ctx, "AnnouncementRepository.GetAudiences", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var audiences []*model.AnnouncementAudience
+	for rows.Next() {
+		aud := &model.AnnouncementAudience{}
+		if err = rows.Scan(&aud.UserID, &aud.Province, &aud.City, &aud.IsDriver, &aud.IsPassenger); err != nil {
+			return nil, err
+		}
+		audiences = append(audiences, aud)
+	}
+	return audiences, rows.Err()
+}
+
+// Dismiss hides an announcement for the user, dismissing twice is a no-op
+func (r *AnnouncementRepository) Dismiss(ctx context.Context, userID, announcementID uint64) (err error) {
+	query := `INSERT IGNORE INTO announcement_dismissals (user_id, announcement_id) VALUES (?, ?)`
+	ctx, span := database.StartSpan(ctx, "AnnouncementRepository.Dismiss", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, userID, announcementID)
+	return err
 }
 
 // ListAll returns all announcements for admin panel
-func (r *AnnouncementRepository) ListAll(page, pageSize int) ([]*model.Announcement, int64, error) {
+func (r *AnnouncementRepository) ListAll(ctx context.Context, page, pageSize int) (_ []*model.Announcement, _ int64, err error) {
 	// count
 	countQuery := `SELECT COUNT(*) FROM announcements`
 	var total int64
-	if err := database.DB.QueryRow(countQuery).Scan(&total); err != nil {
+	ctx, span := database.StartSpan(ctx, "AnnouncementRepository.ListAll", countQuery)
+	defer tracing.End(span, &err)
+	if err = database.DB.QueryRowContext(ctx, countQuery).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
 	// list
 	offset := (page - 1) * pageSize
-	query := `SELECT id, title, content, type, is_active, sort_order, start_time, end_time, created_at, updated_at 
+	query := `SELECT ` + announcementColumns + ` 
 		FROM announcements ORDER BY created_at DESC LIMIT ? OFFSET ?`
 
-	rows, err := database.DB.Query(query, pageSize, offset)
+	rows, err := database.DB.QueryContext(ctx, query, pageSize, offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -94,8 +212,7 @@ func (r *AnnouncementRepository) ListAll(page, pageSize int) ([]*model.Announcem
 
 	var announcements []*model.Announcement
 	for rows.Next() {
-		ann := &model.Announcement{}
-		err := rows.Scan(&ann.ID, &ann.Title, &ann.Content, &ann.Type, &ann.IsActive, &ann.SortOrder, &ann.StartTime, &ann.EndTime, &ann.CreatedAt, &ann.UpdatedAt)
+		ann, err := scanAnnouncement(rows)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -104,14 +221,23 @@ func (r *AnnouncementRepository) ListAll(page, pageSize int) ([]*model.Announcem
 	return announcements, total, nil
 }
 
-func (r *AnnouncementRepository) Update(ann *model.Announcement) error {
-	query := `UPDATE announcements SET title = ?, content = ?, type = ?, is_active = ?, sort_order = ?, start_time = ?, end_time = ? WHERE id = ?`
-	_, err := database.DB.Exec(query, ann.Title, ann.Content, ann.Type, ann.IsActive, ann.SortOrder, ann.StartTime, ann.EndTime, ann.ID)
+func (r *AnnouncementRepository) Update(ctx context.Context, ann *model.Announcement) (err error) {
+	query := `UPDATE announcements SET title = ?, content = ?, type = ?, is_active = ?, sort_order = ?, start_time = ?, end_time = ?,
+		target_provinces = ?, target_cities = ?, target_role = ?, target_platforms = ? WHERE id = ?`
+	ctx, span := database.StartSpan(ctx, "AnnouncementRepository.Update", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, ann.Title, ann.Content, ann.Type, ann.IsActive, ann.SortOrder, ann.StartTime, ann.EndTime,
+		ann.TargetProvinces, ann.TargetCities, ann.TargetRole, ann.TargetPlatforms, ann.ID)
 	return err
 }
 
-func (r *AnnouncementRepository) Delete(id uint64) error {
+func (r *AnnouncementRepository) Delete(ctx context.Context, id uint64) (err error) {
 	query := `DELETE FROM announcements WHERE id = ?`
-	_, err := database.DB.Exec(query, id)
+	ctx, span := database.StartSpan(ctx, "AnnouncementRepository.Delete", query)
+	defer tracing.End(span, &err)
+	if _, err = database.DB.ExecContext(ctx, `DELETE FROM announcement_dismissals WHERE announcement_id = ?`, id); err != nil {
+		return err
+	}
+	_, err = database.DB.ExecContext(ctx, query, id)
 	return err
 }
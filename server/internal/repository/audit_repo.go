@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"pinche/internal/database"
+	"pinche/internal/model"
+	"pinche/internal/tracing"
+)
+
+type AuditRepository struct{}
+
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{}
+}
+
+func (r *AuditRepository) Create(ctx context.Context, log *model.AdminAuditLog) (err error) {
+	query := `INSERT INTO admin_audit_logs (admin_id, admin_username, action, target_type, target_id,
+		before_state, after_state, method, path, result_code, ip) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	ctx, span := database.StartSpan(ctx, "AuditRepository.Create", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, log.AdminID, log.AdminUsername, log.Action, log.TargetType, log.TargetID,
+		nullableJSON(log.BeforeState), nullableJSON(log.AfterState), log.Method, log.Path, log.ResultCode, log.IP)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	log.ID = uint64(id)
+	return nil
+}
+
+// List returns audit logs matching the filters, newest first
+func (r *AuditRepository) List(ctx context.Context, req *model.AdminAuditLogListReq) (_ []*model.AdminAuditLog, _ int64, err error) {
+	var conditions []string
+	var args []interface{}
+
+	if req.AdminUsername != "" {
+		conditions = append(conditions, "admin_username = ?")
+		args = append(args, req.AdminUsername)
+	}
+	if req.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, req.Action)
+	}
+	if req.TargetType != "" {
+		conditions = append(conditions, "target_type = ?")
+		args = append(args, req.TargetType)
+	}
+	if req.TargetID != "" {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, req.TargetID)
+	}
+	if req.StartDate != "" {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, req.StartDate+" 00:00:00")
+	}
+	if req.EndDate != "" {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, req.EndDate+" 23:59:59")
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// count
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM admin_audit_logs %s", whereClause)
+	var total int64
+	ctx, span := database.StartSpan(ctx, "AuditRepository.List", countQuery)
+	defer tracing.End(span, &err)
+	if err = database.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// list
+	offset := (req.Page - 1) * req.PageSize
+	listQuery := fmt.Sprintf(`SELECT id, admin_id, admin_username, action, target_type, target_id,
+		before_state, after_state, method, path, result_code, ip, created_at
+		FROM admin_audit_logs %s ORDER BY id DESC LIMIT ? OFFSET ?`, whereClause)
+	args = append(args, req.PageSize, offset)
+
+	rows, err := database.DB.QueryContext(ctx, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var logs []*model.AdminAuditLog
+	for rows.Next() {
+		l := &model.AdminAuditLog{}
+		var before, after []byte
+		err := rows.Scan(&l.ID, &l.AdminID, &l.AdminUsername, &l.Action, &l.TargetType, &l.TargetID,
+			&before, &after, &l.Method, &l.Path, &l.ResultCode, &l.IP, &l.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(before) > 0 {
+			l.BeforeState = before
+		}
+		if len(after) > 0 {
+			l.AfterState = after
+		}
+		logs = append(logs, l)
+	}
+	return logs, total, nil
+}
+
+// nullableJSON converts empty JSON to NULL for storage
+func nullableJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
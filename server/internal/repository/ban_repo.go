@@ -0,0 +1,385 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"pinche/internal/database"
+	"pinche/internal/model"
+	"pinche/internal/tracing"
+)
+
+type BanRepository struct{}
+
+func NewBanRepository() *BanRepository {
+	return &BanRepository{}
+}
+
+const banColumns = `b.id, b.target_type, b.user_id, COALESCE(u.open_id, ''), b.trip_id, b.trip_status, b.reason,
+	b.expires_at, b.admin_id, b.admin_username, b.status, b.lifted_at, b.lifted_by, b.created_at, b.updated_at`
+
+func scanBan(scanner interface{ Scan(...interface{}) error }) (*model.Ban, error) {
+	ban := &model.Ban{}
+	var expiresAt, liftedAt sql.NullTime
+	err := scanner.Scan(&ban.ID, &ban.TargetType, &ban.UserID, &ban.UserOpenID, &ban.TripID, &ban.TripStatus, &ban.Reason,
+		&expiresAt, &ban.AdminID, &ban.AdminUsername, &ban.Status, &liftedAt, &ban.LiftedBy, &ban.CreatedAt, &ban.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		ban.ExpiresAt = &expiresAt.Time
+	}
+	if liftedAt.Valid {
+		ban.LiftedAt = &liftedAt.Time
+	}
+	return ban, nil
+}
+
+// CreateUserBan creates the ban of a user account and marks the user banned in one transaction
+func (r *BanRepository) CreateUserBan(ctx context.Context, ban *model.Ban) (err error) {
+	query := `INSERT INTO bans (target_type, user_id, trip_id, reason, expires_at, admin_id, admin_username, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	ctx, span := database.StartSpan(ctx, "BanRepository.CreateUserBan", query)
+	defer tracing.End(span, &err)
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, ban.TargetType, ban.UserID, ban.TripID, ban.Reason, ban.ExpiresAt,
+		ban.AdminID, ban.AdminUsername, model.BanStatusActive)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE users SET status = 1 WHERE id = ?`, ban.UserID); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	ban.ID = uint64(id)
+	return nil
+}
+
+// CreateTripBan creates the ban of a trip and marks the trip banned in one transaction,
+// the status of the trip before the ban is kept on the record to be restored when the ban is lifted
+func (r *BanRepository) CreateTripBan(ctx context.Context, ban *model.Ban) (err error) {
+	query := `INSERT INTO bans (target_type, user_id, trip_id, trip_status, reason, expires_at, admin_id, admin_username, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	ctx, span := database.StartSpan(ctx, "BanRepository.CreateTripBan", query)
+	defer tracing.End(span, &err)
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var tripStatus int8
+	if err = tx.QueryRowContext(ctx, `SELECT status FROM trips WHERE id = ? FOR UPDATE`, ban.TripID).Scan(&tripStatus); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, query, ban.TargetType, ban.UserID, ban.TripID, tripStatus, ban.Reason, ban.ExpiresAt,
+		ban.AdminID, ban.AdminUsername, model.BanStatusActive)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE trips SET status = ? WHERE id = ?`, model.TripStatusBanned, ban.TripID); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	ban.ID = uint64(id)
+	ban.TripStatus = tripStatus
+	return nil
+}
+
+func (r *BanRepository) GetByID(ctx context.Context, id uint64) (_ *model.Ban, err error) {
+	query := `SELECT ` + banColumns + ` FROM bans b LEFT JOIN users u ON b.user_id = u.id WHERE b.id = ?`
+	ctx, span := database.StartSpan(ctx, "BanRepository.GetByID", query)
+	defer tracing.End(span, &err)
+	ban, err := scanBan(database.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ban, nil
+}
+
+// GetActiveUserBan returns the active account ban of a user
+func (r *BanRepository) GetActiveUserBan(ctx context.Context, userID uint64) (_ *model.Ban, err error) {
+	query := `SELECT ` + banColumns + ` FROM bans b LEFT JOIN users u ON b.user_id = u.id
+		WHERE b.target_type = ? AND b.user_id = ? AND b.status = ? ORDER BY b.id DESC LIMIT 1`
+	ctx, span := database.StartSpan(ctx, "BanRepository.GetActiveUserBan", query)
+	defer tracing.End(span, &err)
+	ban, err := scanBan(database.DB.QueryRowContext(ctx, query, model.BanTargetUser, userID, model.BanStatusActive))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ban, nil
+}
+
+// GetActiveTripBan returns the active ban of a trip
+func (r *BanRepository) GetActiveTripBan(ctx context.Context, tripID uint64) (_ *model.Ban, err error) {
+	query := `SELECT ` + banColumns + ` FROM bans b LEFT JOIN users u ON b.user_id = u.id
+		WHERE b.target_type = ? AND b.trip_id = ? AND b.status = ? ORDER BY b.id DESC LIMIT 1`
+	ctx, span := database.StartSpan(ctx, "BanRepository.GetActiveTripBan", query)
+	defer tracing.End(span, &err)
+	ban, err := scanBan(database.DB.QueryRowContext(ctx, query, model.BanTargetTrip, tripID, model.BanStatusActive))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ban, nil
+}
+
+// ListActiveByUserID returns all active bans affecting a user, account and trip bans
+func (r *BanRepository) ListActiveByUserID(ctx context.Context, userID uint64) (_ []*model.Ban, err error) {
+	query := `SELECT ` + banColumns + ` FROM bans b LEFT JOIN users u ON b.user_id = u.id
+		WHERE b.user_id = ? AND b.status = ? ORDER BY b.id DESC`
+	ctx, span := database.StartSpan(ctx, "BanRepository.ListActiveByUserID", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, userID, model.BanStatusActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bans []*model.Ban
+	for rows.Next() {
+		ban, err := scanBan(rows)
+		if err != nil {
+			return nil, err
+		}
+		bans = append(bans, ban)
+	}
+	return bans, nil
+}
+
+// ListExpired returns active bans whose expiry has passed
+func (r *BanRepository) ListExpired(ctx context.Context, now time.Time, limit int) (_ []*model.Ban, err error) {
+	query := `SELECT ` + banColumns + ` FROM bans b LEFT JOIN users u ON b.user_id = u.id
+		WHERE b.status = ? AND b.expires_at IS NOT NULL AND b.expires_at <= ? ORDER BY b.expires_at LIMIT ?`
+	ctx, span := database.StartSpan(ctx, "BanRepository.ListExpired", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, model.BanStatusActive, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bans []*model.Ban
+	for rows.Next() {
+		ban, err := scanBan(rows)
+		if err != nil {
+			return nil, err
+		}
+		bans = append(bans, ban)
+	}
+	return bans, nil
+}
+
+// Lift marks an active ban as lifted or expired, returns false if it was no longer active
+func (r *BanRepository) Lift(ctx context.Context, id uint64, status int8, liftedBy string) (_ bool, err error) {
+	query := `UPDATE bans SET status = ?, lifted_at = ?, lifted_by = ? WHERE id = ? AND status = ?`
+	ctx, span := database.StartSpan(ctx, "BanRepository.Lift", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, status, time.Now(), liftedBy, id, model.BanStatusActive)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// List returns bans for admin panel
+func (r *BanRepository) List(ctx context.Context, req *model.BanListReq) (_ []*model.Ban, _ int64, err error) {
+	var conditions []string
+	var args []interface{}
+
+	if req.TargetType != "" {
+		conditions = append(conditions, "b.target_type = ?")
+		args = append(args, req.TargetType)
+	}
+	if req.UserID != "" {
+		conditions = append(conditions, "u.open_id = ?")
+		args = append(args, req.UserID)
+	}
+	if req.Status != nil {
+		conditions = append(conditions, "b.status = ?")
+		args = append(args, *req.Status)
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// count
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM bans b LEFT JOIN users u ON b.user_id = u.id %s", whereClause)
+	var total int64
+	ctx, span := database.StartSpan(ctx, "BanRepository.List", countQuery)
+	defer tracing.End(span, &err)
+	if err = database.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// list
+	offset := (req.Page - 1) * req.PageSize
+	listQuery := fmt.Sprintf(`SELECT %s FROM bans b LEFT JOIN users u ON b.user_id = u.id %s
+		ORDER BY b.id DESC LIMIT ? OFFSET ?`, banColumns, whereClause)
+	args = append(args, req.PageSize, offset)
+
+	rows, err := database.DB.QueryContext(ctx, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var bans []*model.Ban
+	for rows.Next() {
+		ban, err := scanBan(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		bans = append(bans, ban)
+	}
+	return bans, total, nil
+}
+
+const appealColumns = `a.id, a.ban_id, a.user_id, COALESCE(u.open_id, ''), a.content, a.status,
+	a.reviewer_id, a.reviewer_name, a.review_note, a.reviewed_at, a.created_at, a.updated_at`
+
+func scanAppeal(scanner interface{ Scan(...interface{}) error }) (*model.Appeal, error) {
+	appeal := &model.Appeal{}
+	var reviewedAt sql.NullTime
+	err := scanner.Scan(&appeal.ID, &appeal.BanID, &appeal.UserID, &appeal.UserOpenID, &appeal.Content, &appeal.Status,
+		&appeal.ReviewerID, &appeal.ReviewerName, &appeal.ReviewNote, &reviewedAt, &appeal.CreatedAt, &appeal.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if reviewedAt.Valid {
+		appeal.ReviewedAt = &reviewedAt.Time
+	}
+	return appeal, nil
+}
+
+func (r *BanRepository) CreateAppeal(ctx context.Context, appeal *model.Appeal) (err error) {
+	query := `INSERT INTO ban_appeals (ban_id, user_id, content, status) VALUES (?, ?, ?, ?)`
+	ctx, span := database.StartSpan(ctx, "BanRepository.CreateAppeal", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, appeal.BanID, appeal.UserID, appeal.Content, model.AppealStatusPending)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	appeal.ID = uint64(id)
+	return nil
+}
+
+func (r *BanRepository) GetAppealByID(ctx context.Context, id uint64) (_ *model.Appeal, err error) {
+	query := `SELECT ` + appealColumns + ` FROM ban_appeals a LEFT JOIN users u ON a.user_id = u.id WHERE a.id = ?`
+	ctx, span := database.StartSpan(ctx, "BanRepository.GetAppealByID", query)
+	defer tracing.End(span, &err)
+	appeal, err := scanAppeal(database.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return appeal, nil
+}
+
+// HasPendingAppeal checks if a ban already has an appeal waiting for review
+func (r *BanRepository) HasPendingAppeal(ctx context.Context, banID uint64) (_ bool, err error) {
+	query := `SELECT COUNT(*) FROM ban_appeals WHERE ban_id = ? AND status = ?`
+	ctx, span := database.StartSpan(ctx, "BanRepository.HasPendingAppeal", query)
+	defer tracing.End(span, &err)
+	var count int
+	err = database.DB.QueryRowContext(ctx, query, banID, model.AppealStatusPending).Scan(&count)
+	return count > 0, err
+}
+
+// ListAppeals returns appeals for the admin review queue, oldest first
+func (r *BanRepository) ListAppeals(ctx context.Context, req *model.AppealListReq) (_ []*model.Appeal, _ int64, err error) {
+	whereClause := ""
+	var args []interface{}
+	if req.Status != nil {
+		whereClause = "WHERE a.status = ?"
+		args = append(args, *req.Status)
+	}
+
+	// count
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM ban_appeals a %s", whereClause)
+	var total int64
+	ctx, span := database.StartSpan(ctx, "BanRepository.ListAppeals", countQuery)
+	defer tracing.End(span, &err)
+	if err = database.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// list
+	offset := (req.Page - 1) * req.PageSize
+	listQuery := fmt.Sprintf(`SELECT %s FROM ban_appeals a LEFT JOIN users u ON a.user_id = u.id %s
+		ORDER BY a.id ASC LIMIT ? OFFSET ?`, appealColumns, whereClause)
+	args = append(args, req.PageSize, offset)
+
+	rows, err := database.DB.QueryContext(ctx, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var appeals []*model.Appeal
+	for rows.Next() {
+		appeal, err := scanAppeal(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		appeals = append(appeals, appeal)
+	}
+	return appeals, total, nil
+}
+
+// ReviewAppeal sets the review result of a pending appeal, returns false if already reviewed
+func (r *BanRepository) ReviewAppeal(ctx context.Context, id uint64, status int8, reviewerID uint64, reviewerName, note string) (_ bool, err error) {
+	query := `UPDATE ban_appeals SET status = ?, reviewer_id = ?, reviewer_name = ?, review_note = ?, reviewed_at = ?
+		WHERE id = ? AND status = ?`
+	ctx, span := database.StartSpan(ctx, "BanRepository.ReviewAppeal", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, status, reviewerID, reviewerName, note, time.Now(), id, model.AppealStatusPending)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
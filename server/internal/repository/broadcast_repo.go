@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"pinche/internal/database"
+	"pinche/internal/model"
+	"pinche/internal/tracing"
+)
+
+type BroadcastRepository struct{}
+
+func NewBroadcastRepository() *BroadcastRepository {
+	return &BroadcastRepository{}
+}
+
+const broadcastColumns = `id, channel, title, content, audience_type, city, departure_city, destination_city, trip_date, open_ids,
+	status, total_count, sent_count, failed_count, last_user_id, admin_id, admin_username, started_at, finished_at, created_at, updated_at`
+
+func scanBroadcast(scanner interface{ Scan(...interface{}) error }) (*model.Broadcast, error) {
+	b := &model.Broadcast{}
+	var startedAt, finishedAt sql.NullTime
+	err := scanner.Scan(&b.ID, &b.Channel, &b.Title, &b.Content, &b.AudienceType, &b.City, &b.DepartureCity, &b.DestinationCity, &b.TripDate, &b.OpenIDs,
+		&b.Status, &b.TotalCount, &b.SentCount, &b.FailedCount, &b.LastUserID, &b.AdminID, &b.AdminUsername, &startedAt, &finishedAt, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if startedAt.Valid {
+		b.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		b.FinishedAt = &finishedAt.Time
+	}
+	return b, nil
+}
+
+func (r *BroadcastRepository) Create(ctx context.Context, b *model.Broadcast) (err error) {
+	query := `INSERT INTO broadcasts (channel, title, content, audience_type, city, departure_city, destination_city, trip_date, open_ids,
+		status, total_count, admin_id, admin_username)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	ctx, span := database.StartSpan(ctx, "BroadcastRepository.Create", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, b.Channel, b.Title, b.Content, b.AudienceType, b.City, b.DepartureCity, b.DestinationCity, b.TripDate, b.OpenIDs,
+		model.BroadcastStatusPending, b.TotalCount, b.AdminID, b.AdminUsername)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	b.ID = uint64(id)
+	return nil
+}
+
+func (r *BroadcastRepository) GetByID(ctx context.Context, id uint64) (_ *model.Broadcast, err error) {
+	query := `SELECT ` + broadcastColumns + ` FROM broadcasts WHERE id = ?`
+	ctx, span := database.StartSpan(ctx, "BroadcastRepository.GetByID", query)
+	defer tracing.End(span, &err)
+	b, err := scanBroadcast(database.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// GetNextUnfinished returns the oldest pending or running broadcast, nil if there is none
+func (r *BroadcastRepository) GetNextUnfinished(ctx context.Context) (_ *model.Broadcast, err error) {
+	query := `SELECT ` + broadcastColumns + ` FROM broadcasts WHERE status IN (?, ?) ORDER BY id LIMIT 1`
+	ctx, span := database.StartSpan(ctx, "BroadcastRepository.GetNextUnfinished", query)
+	defer tracing.End(span, &err)
+	b, err := scanBroadcast(database.DB.QueryRowContext(ctx, query, model.BroadcastStatusPending, model.BroadcastStatusRunning))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (r *BroadcastRepository) List(ctx context.Context, req *model.BroadcastListReq) (_ []*model.Broadcast, _ int64, err error) {
+	var conditions []string
+	var args []interface{}
+
+	if req.Status != nil {
+		conditions = append(conditions, "status = ?")
+		args = append(args, *req.Status)
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// count
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM broadcasts %s", whereClause)
+	var total int64
+	ctx, span := database.StartSpan(ctx, "BroadcastRepository.List", countQuery)
+	defer tracing.End(span, &err)
+	if err = database.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// list
+	offset := (req.Page - 1) * req.PageSize
+	listQuery := fmt.Sprintf(`SELECT %s FROM broadcasts %s ORDER BY id DESC LIMIT ? OFFSET ?`, broadcastColumns, whereClause)
+	args = append(args, req.PageSize, offset)
+
+	rows, err := database.DB.QueryContext(ctx, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var broadcasts []*model.Broadcast
+	for rows.Next() {
+		b, err := scanBroadcast(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		broadcasts = append(broadcasts, b)
+	}
+	return broadcasts, total, rows.Err()
+}
+
+// MarkRunning moves a pending broadcast to running
+func (r *BroadcastRepository) MarkRunning(ctx context.Context, id uint64, startedAt time.Time) (err error) {
+	query := `UPDATE broadcasts SET status = ?, started_at = ? WHERE id = ? AND status = ?`
+	ctx, span := database.StartSpan(ctx, "BroadcastRepository.MarkRunning", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, model.BroadcastStatusRunning, startedAt, id, model.BroadcastStatusPending)
+	return err
+}
+
+// SaveProgress adds the counts of a delivered batch and moves the checkpoint forward
+func (r *BroadcastRepository) SaveProgress(ctx context.Context, id uint64, lastUserID uint64, sent, failed int) (err error) {
+	query := `UPDATE broadcasts SET last_user_id = ?, sent_count = sent_count + ?, failed_count = failed_count + ? WHERE id = ?`
+	ctx, span := database.StartSpan(ctx, "BroadcastRepository.SaveProgress", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, lastUserID, sent, failed, id)
+	return err
+}
+
+// Finish moves an unfinished broadcast to a final status, returning false if it was already finished
+func (r *BroadcastRepository) Finish(ctx context.Context, id uint64, status int8, finishedAt time.Time) (_ bool, err error) {
+	query := `UPDATE broadcasts SET status = ?, finished_at = ? WHERE id = ? AND status IN (?, ?)`
+	ctx, span := database.StartSpan(ctx, "BroadcastRepository.Finish", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, status, finishedAt, id, model.BroadcastStatusPending, model.BroadcastStatusRunning)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// broadcastAudienceFilter builds the users filter for a broadcast audience.
+// The system user and banned users never receive broadcasts.
+func broadcastAudienceFilter(b *model.Broadcast) (string, []interface{}) {
+	conditions := []string{"u.id <> ?", "u.status = 0"}
+	args := []interface{}{model.SystemUserID}
+
+	switch b.AudienceType {
+	case model.BroadcastAudienceCity:
+		conditions = append(conditions, "u.city = ?")
+		args = append(args, b.City)
+	case model.BroadcastAudienceRoute:
+		conditions = append(conditions, `u.id IN (SELECT t.user_id FROM trips t
+			WHERE t.departure_city = ? AND t.destination_city = ? AND t.departure_time >= ? AND t.departure_time < ? AND t.status <> ?)`)
+		day, _ := time.ParseInLocation("2006-01-02", b.TripDate, time.Local)
+		args = append(args, b.DepartureCity, b.DestinationCity, day, day.AddDate(0, 0, 1), model.TripStatusCancelled)
+	case model.BroadcastAudienceUsers:
+		openIDs := strings.Split(b.OpenIDs, ",")
+		placeholders := make([]string, len(openIDs))
+		for i, openID := range openIDs {
+			placeholders[i] = "?"
+			args = append(args, openID)
+		}
+		conditions = append(conditions, fmt.Sprintf("u.open_id IN (%s)", strings.Join(placeholders, ",")))
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// CountRecipients counts the users a broadcast will be delivered to
+func (r *BroadcastRepository) CountRecipients(ctx context.Context, b *model.Broadcast) (_ int, err error) {
+	whereClause, args := broadcastAudienceFilter(b)
+	query := "SELECT COUNT(*) FROM users u " + whereClause
+	ctx, span := database.StartSpan(ctx, "BroadcastRepository.CountRecipients", query)
+	defer tracing.End(span, &err)
+	var count int
+	err = database.DB.QueryRowContext(ctx, query, args...).Scan(&count)
+	return count, err
+}
+
+// ListRecipients returns the next batch of recipients after the checkpoint, ordered by user ID
+func (r *BroadcastRepository) ListRecipients(ctx context.Context, b *model.Broadcast, afterUserID uint64, limit int) (_ []*model.BroadcastRecipient, err error) {
+	whereClause, args := broadcastAudienceFilter(b)
+	query := fmt.Sprintf(`SELECT u.id, u.open_id FROM users u %s AND u.id > ? ORDER BY u.id LIMIT ?`, whereClause)
+	args = append(args, afterUserID, limit)
+
+	ctx, span := database.StartSpan(ctx, "BroadcastRepository.ListRecipients", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []*model.BroadcastRecipient
+	for rows.Next() {
+		rcpt := &model.BroadcastRecipient{}
+		if err = rows.Scan(&rcpt.UserID, &rcpt.OpenID); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, rcpt)
+	}
+	return recipients, rows.Err()
+}
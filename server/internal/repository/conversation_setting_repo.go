@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"pinche/internal/database"
+	"pinche/internal/model"
+	"pinche/internal/tracing"
+)
+
+type ConversationSettingRepository struct{}
+
+func NewConversationSettingRepository() *ConversationSettingRepository {
+	return &ConversationSettingRepository{}
+}
+
+// Get returns the user's settings for the conversation with peer, nil if never set
+func (r *ConversationSettingRepository) Get(ctx context.Context, userID, peerID uint64) (_ *model.ConversationSetting, err error) {
+	query := `SELECT user_id, peer_id, pinned, muted, archived, remark FROM conversation_settings WHERE user_id = ? AND peer_id = ?`
+	setting := &model.ConversationSetting{}
+	ctx, span := database.StartSpan(ctx, "ConversationSettingRepository.Get", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, userID, peerID).Scan(
+		&setting.UserID, &setting.PeerID, &setting.Pinned, &setting.Muted, &setting.Archived, &setting.Remark,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return setting, nil
+}
+
+// Save creates or replaces the settings of a conversation
+func (r *ConversationSettingRepository) Save(ctx context.Context, setting *model.ConversationSetting) (err error) {
+	query := `INSERT INTO conversation_settings (user_id, peer_id, pinned, muted, archived, remark) VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE pinned = VALUES(pinned), muted = VALUES(muted), archived = VALUES(archived), remark = VALUES(remark)`
+	ctx, span := database.StartSpan(ctx, "ConversationSettingRepository.Save", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, setting.UserID, setting.PeerID, setting.Pinned, setting.Muted, setting.Archived, setting.Remark)
+	return err
+}
+
+// CountPinned returns how many conversations the user pinned
+func (r *ConversationSettingRepository) CountPinned(ctx context.Context, userID uint64) (_ int, err error) {
+	query := `SELECT COUNT(*) FROM conversation_settings WHERE user_id = ? AND pinned = 1`
+	var count int
+	ctx, span := database.StartSpan(ctx, "ConversationSettingRepository.CountPinned", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+// IsMuted reports whether the user muted the conversation with peer
+func (r *ConversationSettingRepository) IsMuted(ctx context.Context, userID, peerID uint64) (_ bool, err error) {
+	query := `SELECT muted FROM conversation_settings WHERE user_id = ? AND peer_id = ?`
+	var muted bool
+	ctx, span := database.StartSpan(ctx, "ConversationSettingRepository.IsMuted", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, userID, peerID).Scan(&muted)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return muted, err
+}
+
+// UnarchiveUnmuted moves an archived conversation back to the inbox unless it is muted,
+// called when the peer sends a new message
+func (r *ConversationSettingRepository) UnarchiveUnmuted(ctx context.Context, userID, peerID uint64) (err error) {
+	query := `UPDATE conversation_settings SET archived = 0 WHERE user_id = ? AND peer_id = ? AND archived = 1 AND muted = 0`
+	ctx, span := database.StartSpan(ctx, "ConversationSettingRepository.UnarchiveUnmuted", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, userID, peerID)
+	return err
+}
+
+// ListChangedSince returns up to limit settings of the user changed after the (updatedAfter, afterPeerID)
+// cursor, oldest change first
+func (r *ConversationSettingRepository) ListChangedSince(ctx context.Context, userID uint64, updatedAfter time.Time, afterPeerID uint64, limit int) (_ []*model.ConversationSetting, _ bool, err error) {
+	query := `SELECT cs.user_id, cs.peer_id, COALESCE(u.open_id, ''), cs.pinned, cs.muted, cs.archived, cs.remark, cs.updated_at
+		FROM conversation_settings cs
+		LEFT JOIN users u ON u.id = cs.peer_id
+		WHERE cs.user_id = ? AND (cs.updated_at > ? OR (cs.updated_at = ? AND cs.peer_id > ?))
+		ORDER BY cs.updated_at, cs.peer_id
+		LIMIT ?`
+	ctx, span := database.StartSpan(ctx, "ConversationSettingRepository.ListChangedSince", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, userID, updatedAfter, updatedAfter, afterPeerID, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var settings []*model.ConversationSetting
+	for rows.Next() {
+		setting := &model.ConversationSetting{}
+		err := rows.Scan(&setting.UserID, &setting.PeerID, &setting.PeerOpenID, &setting.Pinned, &setting.Muted,
+			&setting.Archived, &setting.Remark, &setting.UpdatedAt)
+		if err != nil {
+			return nil, false, err
+		}
+		settings = append(settings, setting)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(settings) > limit
+	if hasMore {
+		settings = settings[:limit]
+	}
+	return settings, hasMore, nil
+}
@@ -1,11 +1,13 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
 	"pinche/internal/database"
 	"pinche/internal/model"
+	"pinche/internal/tracing"
 )
 
 type FriendRepository struct{}
@@ -15,9 +17,11 @@ func NewFriendRepository() *FriendRepository {
 }
 
 // Create creates a new friend request
-func (r *FriendRepository) Create(friend *model.Friend) error {
+func (r *FriendRepository) Create(ctx context.Context, friend *model.Friend) (err error) {
 	query := `INSERT INTO friends (user_id, friend_id, status, message) VALUES (?, ?, ?, ?)`
-	result, err := database.DB.Exec(query, friend.UserID, friend.FriendID, friend.Status, friend.Message)
+	ctx, span := database.StartSpan(ctx, "FriendRepository.Create", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, friend.UserID, friend.FriendID, friend.Status, friend.Message)
 	if err != nil {
 		return err
 	}
@@ -30,7 +34,7 @@ func (r *FriendRepository) Create(friend *model.Friend) error {
 }
 
 // GetByID gets a friend record by ID
-func (r *FriendRepository) GetByID(id uint64) (*model.Friend, error) {
+func (r *FriendRepository) GetByID(ctx context.Context, id uint64) (_ *model.Friend, err error) {
 	query := `SELECT f.id, f.user_id, f.friend_id, f.status, f.message, f.created_at, f.updated_at,
 		u1.open_id, u2.open_id
 		FROM friends f
@@ -38,7 +42,9 @@ func (r *FriendRepository) GetByID(id uint64) (*model.Friend, error) {
 		JOIN users u2 ON f.friend_id = u2.id
 		WHERE f.id = ?`
 	friend := &model.Friend{}
-	err := database.DB.QueryRow(query, id).Scan(
+	ctx, span := database.StartSpan(ctx, "FriendRepository.GetByID", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, id).Scan(
 		&friend.ID, &friend.UserID, &friend.FriendID, &friend.Status, &friend.Message,
 		&friend.CreatedAt, &friend.UpdatedAt, &friend.UserOpenID, &friend.FriendOpenID,
 	)
@@ -52,7 +58,7 @@ func (r *FriendRepository) GetByID(id uint64) (*model.Friend, error) {
 }
 
 // GetByUserAndFriend gets a friend record by user_id and friend_id
-func (r *FriendRepository) GetByUserAndFriend(userID, friendID uint64) (*model.Friend, error) {
+func (r *FriendRepository) GetByUserAndFriend(ctx context.Context, userID, friendID uint64) (_ *model.Friend, err error) {
 	query := `SELECT f.id, f.user_id, f.friend_id, f.status, f.message, f.created_at, f.updated_at,
 		u1.open_id, u2.open_id
 		FROM friends f
@@ -60,7 +66,9 @@ func (r *FriendRepository) GetByUserAndFriend(userID, friendID uint64) (*model.F
 		JOIN users u2 ON f.friend_id = u2.id
 		WHERE f.user_id = ? AND f.friend_id = ?`
 	friend := &model.Friend{}
-	err := database.DB.QueryRow(query, userID, friendID).Scan(
+	ctx, span := database.StartSpan(ctx, "FriendRepository.GetByUserAndFriend", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, userID, friendID).Scan(
 		&friend.ID, &friend.UserID, &friend.FriendID, &friend.Status, &friend.Message,
 		&friend.CreatedAt, &friend.UpdatedAt, &friend.UserOpenID, &friend.FriendOpenID,
 	)
@@ -74,32 +82,40 @@ func (r *FriendRepository) GetByUserAndFriend(userID, friendID uint64) (*model.F
 }
 
 // UpdateStatus updates the status of a friend record
-func (r *FriendRepository) UpdateStatus(id uint64, status int8) error {
+func (r *FriendRepository) UpdateStatus(ctx context.Context, id uint64, status int8) (err error) {
 	query := `UPDATE friends SET status = ? WHERE id = ?`
-	_, err := database.DB.Exec(query, status, id)
+	ctx, span := database.StartSpan(ctx, "FriendRepository.UpdateStatus", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, status, id)
 	return err
 }
 
 // Delete deletes a friend record
-func (r *FriendRepository) Delete(id uint64) error {
+func (r *FriendRepository) Delete(ctx context.Context, id uint64) (err error) {
 	query := `DELETE FROM friends WHERE id = ?`
-	_, err := database.DB.Exec(query, id)
+	ctx, span := database.StartSpan(ctx, "FriendRepository.Delete", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, id)
 	return err
 }
 
 // DeleteByUserAndFriend deletes friend records between two users (both directions)
-func (r *FriendRepository) DeleteByUserAndFriend(userID, friendID uint64) error {
+func (r *FriendRepository) DeleteByUserAndFriend(ctx context.Context, userID, friendID uint64) (err error) {
 	query := `DELETE FROM friends WHERE (user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)`
-	_, err := database.DB.Exec(query, userID, friendID, friendID, userID)
+	ctx, span := database.StartSpan(ctx, "FriendRepository.DeleteByUserAndFriend", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, userID, friendID, friendID, userID)
 	return err
 }
 
 // GetPendingRequests gets pending friend requests received by a user
-func (r *FriendRepository) GetPendingRequests(userID uint64) ([]*model.Friend, int64, error) {
+func (r *FriendRepository) GetPendingRequests(ctx context.Context, userID uint64) (_ []*model.Friend, _ int64, err error) {
 	// count
 	var total int64
 	countQuery := `SELECT COUNT(*) FROM friends WHERE friend_id = ? AND status = ?`
-	if err := database.DB.QueryRow(countQuery, userID, model.FriendStatusPending).Scan(&total); err != nil {
+	ctx, span := database.StartSpan(ctx, "FriendRepository.GetPendingRequests", countQuery)
+	defer tracing.End(span, &err)
+	if err = database.DB.QueryRowContext(ctx, countQuery, userID, model.FriendStatusPending).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
@@ -113,7 +129,7 @@ func (r *FriendRepository) GetPendingRequests(userID uint64) ([]*model.Friend, i
 		WHERE f.friend_id = ? AND f.status = ?
 		ORDER BY f.created_at DESC`
 
-	rows, err := database.DB.Query(query, userID, model.FriendStatusPending)
+	rows, err := database.DB.QueryContext(ctx, query, userID, model.FriendStatusPending)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -137,12 +153,14 @@ func (r *FriendRepository) GetPendingRequests(userID uint64) ([]*model.Friend, i
 }
 
 // GetFriends gets all friends of a user (accepted status, both directions)
-func (r *FriendRepository) GetFriends(userID uint64) ([]*model.Friend, int64, error) {
+func (r *FriendRepository) GetFriends(ctx context.Context, userID uint64) (_ []*model.Friend, _ int64, err error) {
 	// count: user sent request and accepted OR user received request and accepted
 	countQuery := `SELECT COUNT(*) FROM friends 
 		WHERE ((user_id = ? OR friend_id = ?) AND status = ?)`
 	var total int64
-	if err := database.DB.QueryRow(countQuery, userID, userID, model.FriendStatusAccepted).Scan(&total); err != nil {
+	ctx, span := database.StartSpan(ctx, "FriendRepository.GetFriends", countQuery)
+	defer tracing.End(span, &err)
+	if err = database.DB.QueryRowContext(ctx, countQuery, userID, userID, model.FriendStatusAccepted).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
@@ -162,7 +180,7 @@ func (r *FriendRepository) GetFriends(userID uint64) ([]*model.Friend, int64, er
 		WHERE (f.user_id = ? OR f.friend_id = ?) AND f.status = ?
 		ORDER BY f.updated_at DESC`
 
-	rows, err := database.DB.Query(query, userID, userID, userID, userID, userID, userID, userID, userID, userID, model.FriendStatusAccepted)
+	rows, err := database.DB.QueryContext(ctx, query, userID, userID, userID, userID, userID, userID, userID, userID, userID, model.FriendStatusAccepted)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -186,11 +204,13 @@ func (r *FriendRepository) GetFriends(userID uint64) ([]*model.Friend, int64, er
 }
 
 // CheckFriendship checks if two users are friends (accepted status)
-func (r *FriendRepository) CheckFriendship(userID, friendID uint64) (bool, error) {
+func (r *FriendRepository) CheckFriendship(ctx context.Context, userID, friendID uint64) (_ bool, err error) {
 	query := `SELECT COUNT(*) FROM friends 
 		WHERE ((user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)) AND status = ?`
 	var count int64
-	err := database.DB.QueryRow(query, userID, friendID, friendID, userID, model.FriendStatusAccepted).Scan(&count)
+	ctx, span := database.StartSpan(ctx, "FriendRepository.CheckFriendship", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, userID, friendID, friendID, userID, model.FriendStatusAccepted).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -198,7 +218,7 @@ func (r *FriendRepository) CheckFriendship(userID, friendID uint64) (bool, error
 }
 
 // GetFriendshipRecord gets the friendship record between two users (any status)
-func (r *FriendRepository) GetFriendshipRecord(userID, friendID uint64) (*model.Friend, error) {
+func (r *FriendRepository) GetFriendshipRecord(ctx context.Context, userID, friendID uint64) (_ *model.Friend, err error) {
 	query := `SELECT f.id, f.user_id, f.friend_id, f.status, f.message, f.created_at, f.updated_at,
 		u1.open_id, u2.open_id
 		FROM friends f
@@ -206,7 +226,9 @@ func (r *FriendRepository) GetFriendshipRecord(userID, friendID uint64) (*model.
 		JOIN users u2 ON f.friend_id = u2.id
 		WHERE (f.user_id = ? AND f.friend_id = ?) OR (f.user_id = ? AND f.friend_id = ?)`
 	friend := &model.Friend{}
-	err := database.DB.QueryRow(query, userID, friendID, friendID, userID).Scan(
+	ctx, span := database.StartSpan(ctx, "FriendRepository.GetFriendshipRecord", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, userID, friendID, friendID, userID).Scan(
 		&friend.ID, &friend.UserID, &friend.FriendID, &friend.Status, &friend.Message,
 		&friend.CreatedAt, &friend.UpdatedAt, &friend.UserOpenID, &friend.FriendOpenID,
 	)
@@ -220,30 +242,36 @@ func (r *FriendRepository) GetFriendshipRecord(userID, friendID uint64) (*model.
 }
 
 // GetPendingRequestCount gets the count of pending friend requests received by a user
-func (r *FriendRepository) GetPendingRequestCount(userID uint64) (int64, error) {
+func (r *FriendRepository) GetPendingRequestCount(ctx context.Context, userID uint64) (_ int64, err error) {
 	query := `SELECT COUNT(*) FROM friends WHERE friend_id = ? AND status = ?`
 	var count int64
-	err := database.DB.QueryRow(query, userID, model.FriendStatusPending).Scan(&count)
+	ctx, span := database.StartSpan(ctx, "FriendRepository.GetPendingRequestCount", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, userID, model.FriendStatusPending).Scan(&count)
 	return count, err
 }
 
 // GetFriendCount gets the count of friends for a user
-func (r *FriendRepository) GetFriendCount(userID uint64) (int64, error) {
+func (r *FriendRepository) GetFriendCount(ctx context.Context, userID uint64) (_ int64, err error) {
 	query := `SELECT COUNT(*) FROM friends WHERE (user_id = ? OR friend_id = ?) AND status = ?`
 	var count int64
-	err := database.DB.QueryRow(query, userID, userID, model.FriendStatusAccepted).Scan(&count)
+	ctx, span := database.StartSpan(ctx, "FriendRepository.GetFriendCount", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, userID, userID, model.FriendStatusAccepted).Scan(&count)
 	return count, err
 }
 
 // ResetRejectedRequest resets a rejected request to pending (for re-sending)
-func (r *FriendRepository) ResetRejectedRequest(id uint64, message string) error {
+func (r *FriendRepository) ResetRejectedRequest(ctx context.Context, id uint64, message string) (err error) {
 	query := `UPDATE friends SET status = ?, message = ? WHERE id = ?`
-	_, err := database.DB.Exec(query, model.FriendStatusPending, message, id)
+	ctx, span := database.StartSpan(ctx, "FriendRepository.ResetRejectedRequest", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, model.FriendStatusPending, message, id)
 	return err
 }
 
 // GetSentPendingRequests gets pending friend requests sent by a user
-func (r *FriendRepository) GetSentPendingRequests(userID uint64) ([]*model.Friend, error) {
+func (r *FriendRepository) GetSentPendingRequests(ctx context.Context, userID uint64) (_ []*model.Friend, err error) {
 	query := `SELECT f.id, f.user_id, f.friend_id, f.status, f.message, f.created_at, f.updated_at,
 		u1.open_id, u2.open_id,
 		u2.id, u2.open_id, u2.nickname, u2.avatar, u2.gender, COALESCE(u2.city, ''), COALESCE(u2.province, '')
@@ -253,7 +281,9 @@ func (r *FriendRepository) GetSentPendingRequests(userID uint64) ([]*model.Frien
 		WHERE f.user_id = ? AND f.status = ?
 		ORDER BY f.created_at DESC`
 
-	rows, err := database.DB.Query(query, userID, model.FriendStatusPending)
+	ctx, span := database.StartSpan(ctx, "FriendRepository.GetSentPendingRequests", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, userID, model.FriendStatusPending)
 	if err != nil {
 		return nil, err
 	}
@@ -277,7 +307,7 @@ func (r *FriendRepository) GetSentPendingRequests(userID uint64) ([]*model.Frien
 }
 
 // GetRecentTripsByUserID gets trips from the last 3 days for a user
-func (r *FriendRepository) GetRecentTripsByUserID(userID uint64, days int) ([]*model.Trip, error) {
+func (r *FriendRepository) GetRecentTripsByUserID(ctx context.Context, userID uint64, days int) (_ []*model.Trip, err error) {
 	query := fmt.Sprintf(`SELECT t.id, t.user_id, u.open_id, t.trip_type, 
 		t.departure_city, COALESCE(t.departure_province, ''), t.departure_address, 
 		COALESCE(t.departure_lat, 0), COALESCE(t.departure_lng, 0),
@@ -290,7 +320,9 @@ func (r *FriendRepository) GetRecentTripsByUserID(userID uint64, days int) ([]*m
 		WHERE t.user_id = ? AND t.departure_time >= NOW() - INTERVAL %d DAY AND t.status IN (1, 2)
 		ORDER BY t.departure_time ASC`, days)
 
-	rows, err := database.DB.Query(query, userID)
+	ctx, span := database.StartSpan(ctx, "FriendRepository.GetRecentTripsByUserID", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,340 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"pinche/internal/database"
+	"pinche/internal/model"
+	"pinche/internal/tracing"
+)
+
+type GroupRepository struct{}
+
+func NewGroupRepository() *GroupRepository {
+	return &GroupRepository{}
+}
+
+func (r *GroupRepository) Create(ctx context.Context, g *model.TripGroup) (err error) {
+	query := `INSERT INTO trip_groups (trip_id, owner_id, name, status) VALUES (?, ?, ?, ?)`
+	ctx, span := database.StartSpan(ctx, "GroupRepository.Create", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, g.TripID, g.OwnerID, g.Name, model.TripGroupStatusActive)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	g.ID = uint64(id)
+	return nil
+}
+
+func (r *GroupRepository) GetByID(ctx context.Context, id uint64) (_ *model.TripGroup, err error) {
+	query := `SELECT g.id, g.trip_id, g.owner_id, COALESCE(u.open_id, ''), g.name, g.status, g.created_at, g.updated_at
+		FROM trip_groups g LEFT JOIN users u ON u.id = g.owner_id WHERE g.id = ?`
+	ctx, span := database.StartSpan(ctx, "GroupRepository.GetByID", query)
+	defer tracing.End(span, &err)
+	return r.scanGroup(database.DB.QueryRowContext(ctx, query, id))
+}
+
+// GetByTripID returns the group of a driver trip, nil if none was created yet
+func (r *GroupRepository) GetByTripID(ctx context.Context, tripID uint64) (_ *model.TripGroup, err error) {
+	query := `SELECT g.id, g.trip_id, g.owner_id, COALESCE(u.open_id, ''), g.name, g.status, g.created_at, g.updated_at
+		FROM trip_groups g LEFT JOIN users u ON u.id = g.owner_id WHERE g.trip_id = ?`
+	ctx, span := database.StartSpan(ctx, "GroupRepository.GetByTripID", query)
+	defer tracing.End(span, &err)
+	return r.scanGroup(database.DB.QueryRowContext(ctx, query, tripID))
+}
+
+func (r *GroupRepository) scanGroup(row *sql.Row) (*model.TripGroup, error) {
+	g := &model.TripGroup{}
+	err := row.Scan(&g.ID, &g.TripID, &g.OwnerID, &g.OwnerOpenID, &g.Name, &g.Status, &g.CreatedAt, &g.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (r *GroupRepository) UpdateStatus(ctx context.Context, id uint64, status int8) (err error) {
+	query := `UPDATE trip_groups SET status = ? WHERE id = ?`
+	ctx, span := database.StartSpan(ctx, "GroupRepository.UpdateStatus", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, status, id)
+	return err
+}
+
+// ListByUser returns the groups the user is an active member of, with unread count and last message
+func (r *GroupRepository) ListByUser(ctx context.Context, userID uint64) (_ []*model.TripGroup, err error) {
+	query := `
+		SELECT g.id, g.trip_id, g.owner_id, COALESCE(u.open_id, ''), g.name, g.status, m.muted, g.created_at, g.updated_at,
+			(SELECT COUNT(*) FROM trip_group_members m2 WHERE m2.group_id = g.id AND m2.status = ?) AS member_count,
+			(SELECT COUNT(*) FROM group_messages gm WHERE gm.group_id = g.id AND gm.id > m.last_read_message_id AND gm.sender_id <> ?) AS unread_count,
+			lm.id, lm.sender_id, COALESCE(su.open_id, ''), lm.content, lm.msg_type, lm.duration, lm.created_at
+		FROM trip_group_members m
+		JOIN trip_groups g ON g.id = m.group_id
+		LEFT JOIN users u ON u.id = g.owner_id
+		LEFT JOIN group_messages lm ON lm.id = (SELECT MAX(id) FROM group_messages WHERE group_id = g.id AND id > m.joined_message_id)
+		LEFT JOIN users su ON su.id = lm.sender_id
+		WHERE m.user_id = ? AND m.status = ?
+		ORDER BY COALESCE(lm.created_at, g.created_at) DESC`
+
+	ctx, span := database.StartSpan(ctx, "GroupRepository.ListByUser", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, model.GroupMemberStatusActive, userID, userID, model.GroupMemberStatusActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []*model.TripGroup
+	for rows.Next() {
+		g := &model.TripGroup{}
+		var lastID, lastSenderID sql.NullInt64
+		var lastContent sql.NullString
+		var lastType sql.NullInt16
+		var lastDuration sql.NullInt64
+		var lastAt sql.NullTime
+		var lastSenderOpenID string
+		err := rows.Scan(&g.ID, &g.TripID, &g.OwnerID, &g.OwnerOpenID, &g.Name, &g.Status, &g.Muted, &g.CreatedAt, &g.UpdatedAt,
+			&g.MemberCount, &g.UnreadCount,
+			&lastID, &lastSenderID, &lastSenderOpenID, &lastContent, &lastType, &lastDuration, &lastAt)
+		if err != nil {
+			return nil, err
+		}
+		if lastID.Valid {
+			g.LastMessage = &model.GroupMessage{
+				ID:           uint64(lastID.Int64),
+				GroupID:      g.ID,
+				SenderID:     uint64(lastSenderID.Int64),
+				SenderOpenID: lastSenderOpenID,
+				Content:      lastContent.String,
+				MsgType:      int8(lastType.Int16),
+				Duration:     int(lastDuration.Int64),
+				CreatedAt:    lastAt.Time,
+			}
+			g.LastMessageAt = &lastAt.Time
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// AddMember adds the user to the group, or reactivates them if they left before.
+// lastMessageID is the newest message of the group, the member's history and unread count start after it.
+// A member who is still active keeps the history they joined with.
+func (r *GroupRepository) AddMember(ctx context.Context, m *model.TripGroupMember, lastMessageID uint64) (err error) {
+	query := `INSERT INTO trip_group_members (group_id, user_id, role, trip_id, status, joined_message_id, last_read_message_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE joined_message_id = IF(status = ?, joined_message_id, VALUES(joined_message_id)),
+			trip_id = VALUES(trip_id), status = VALUES(status), left_at = NULL,
+			last_read_message_id = GREATEST(last_read_message_id, VALUES(last_read_message_id))`
+	ctx, span := database.StartSpan(ctx, "GroupRepository.AddMember", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, m.GroupID, m.UserID, m.Role, m.TripID, model.GroupMemberStatusActive,
+		lastMessageID, lastMessageID, model.GroupMemberStatusActive)
+	return err
+}
+
+// RemoveMember marks an active member as left, returning false if they were not active
+func (r *GroupRepository) RemoveMember(ctx context.Context, groupID, userID uint64, leftAt time.Time) (_ bool, err error) {
+	query := `UPDATE trip_group_members SET status = ?, left_at = ? WHERE group_id = ? AND user_id = ? AND status = ?`
+	ctx, span := database.StartSpan(ctx, "GroupRepository.RemoveMember", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, model.GroupMemberStatusLeft, leftAt, groupID, userID, model.GroupMemberStatusActive)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+const groupMemberColumns = `id, group_id, user_id, role, trip_id, status, joined_message_id, last_read_message_id, muted, created_at, left_at`
+
+func scanGroupMember(scanner interface{ Scan(...interface{}) error }) (*model.TripGroupMember, error) {
+	m := &model.TripGroupMember{}
+	var leftAt sql.NullTime
+	err := scanner.Scan(&m.ID, &m.GroupID, &m.UserID, &m.Role, &m.TripID, &m.Status, &m.JoinedMessageID, &m.LastReadMessageID,
+		&m.Muted, &m.JoinedAt, &leftAt)
+	if err != nil {
+		return nil, err
+	}
+	if leftAt.Valid {
+		m.LeftAt = &leftAt.Time
+	}
+	return m, nil
+}
+
+func (r *GroupRepository) GetMember(ctx context.Context, groupID, userID uint64) (_ *model.TripGroupMember, err error) {
+	query := `SELECT ` + groupMemberColumns + ` FROM trip_group_members WHERE group_id = ? AND user_id = ?`
+	ctx, span := database.StartSpan(ctx, "GroupRepository.GetMember", query)
+	defer tracing.End(span, &err)
+	m, err := scanGroupMember(database.DB.QueryRowContext(ctx, query, groupID, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ListActiveMembers returns the current members of a group, driver first
+func (r *GroupRepository) ListActiveMembers(ctx context.Context, groupID uint64) ([]*model.TripGroupMember, error) {
+	query := `SELECT ` + groupMemberColumns + ` FROM trip_group_members WHERE group_id = ? AND status = ? ORDER BY role, id`
+	return r.queryMembers(ctx, "GroupRepository.ListActiveMembers", query, groupID, model.GroupMemberStatusActive)
+}
+
+// ListActiveMembershipsByTrip returns active memberships a passenger gained through the given trip
+func (r *GroupRepository) ListActiveMembershipsByTrip(ctx context.Context, tripID uint64) ([]*model.TripGroupMember, error) {
+	query := `SELECT ` + groupMemberColumns + ` FROM trip_group_members WHERE trip_id = ? AND role = ? AND status = ?`
+	return r.queryMembers(ctx, "GroupRepository.ListActiveMembershipsByTrip", query, tripID, model.GroupMemberRolePassenger, model.GroupMemberStatusActive)
+}
+
+func (r *GroupRepository) queryMembers(ctx context.Context, name, query string, args ...interface{}) (_ []*model.TripGroupMember, err error) {
+	ctx, span := database.StartSpan(ctx, name, query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*model.TripGroupMember
+	for rows.Next() {
+		m, err := scanGroupMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// SetMuted mutes or unmutes the group for the member
+func (r *GroupRepository) SetMuted(ctx context.Context, groupID, userID uint64, muted bool) (err error) {
+	query := `UPDATE trip_group_members SET muted = ? WHERE group_id = ? AND user_id = ?`
+	ctx, span := database.StartSpan(ctx, "GroupRepository.SetMuted", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, muted, groupID, userID)
+	return err
+}
+
+// MarkRead moves the member's read position forward to the given message
+func (r *GroupRepository) MarkRead(ctx context.Context, groupID, userID, messageID uint64) (err error) {
+	query := `UPDATE trip_group_members SET last_read_message_id = GREATEST(last_read_message_id, ?) WHERE group_id = ? AND user_id = ?`
+	ctx, span := database.StartSpan(ctx, "GroupRepository.MarkRead", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, messageID, groupID, userID)
+	return err
+}
+
+func (r *GroupRepository) CreateMessage(ctx context.Context, msg *model.GroupMessage) (err error) {
+	query := `INSERT INTO group_messages (group_id, sender_id, content, msg_type, duration) VALUES (?, ?, ?, ?, ?)`
+	ctx, span := database.StartSpan(ctx, "GroupRepository.CreateMessage", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, msg.GroupID, msg.SenderID, msg.Content, msg.MsgType, msg.Duration)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	msg.ID = uint64(id)
+	return nil
+}
+
+// GetLastMessageID returns the newest message ID of a group, 0 if it has none
+func (r *GroupRepository) GetLastMessageID(ctx context.Context, groupID uint64) (_ uint64, err error) {
+	query := `SELECT COALESCE(MAX(id), 0) FROM group_messages WHERE group_id = ?`
+	var id uint64
+	ctx, span := database.StartSpan(ctx, "GroupRepository.GetLastMessageID", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, groupID).Scan(&id)
+	return id, err
+}
+
+const groupMessageSelect = `SELECT gm.id, gm.group_id, gm.sender_id, COALESCE(u.open_id, ''), gm.content, gm.msg_type, gm.duration, gm.created_at
+	FROM group_messages gm
+	LEFT JOIN users u ON u.id = gm.sender_id`
+
+// ListMessages retrieves up to limit messages of a group newer than joinedID, newest first.
+// beforeID pages back to older messages, afterID returns the ones newer than it
+func (r *GroupRepository) ListMessages(ctx context.Context, groupID, joinedID, beforeID, afterID uint64, limit int) ([]*model.GroupMessage, bool, error) {
+	cursor, order := "", "DESC"
+	args := []interface{}{groupID, joinedID}
+	if afterID > 0 {
+		cursor, order = " AND gm.id > ?", "ASC"
+		args = append(args, afterID)
+	} else if beforeID > 0 {
+		cursor = " AND gm.id < ?"
+		args = append(args, beforeID)
+	}
+	// fetch one extra row to know whether there are more
+	query := groupMessageSelect + ` WHERE gm.group_id = ? AND gm.id > ?` + cursor + ` ORDER BY gm.id ` + order + ` LIMIT ?`
+	args = append(args, limit+1)
+
+	messages, err := r.queryMessages(ctx, "GroupRepository.ListMessages", query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	if order == "ASC" {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	return messages, hasMore, nil
+}
+
+// ListMessagesSince returns up to limit messages newer than afterID of the groups the user is in, oldest first,
+// leaving out the ones sent before the user joined
+func (r *GroupRepository) ListMessagesSince(ctx context.Context, userID, afterID uint64, limit int) ([]*model.GroupMessage, bool, error) {
+	query := groupMessageSelect + `
+		JOIN trip_group_members m ON m.group_id = gm.group_id
+		WHERE m.user_id = ? AND m.status = ? AND gm.id > ? AND gm.id > m.joined_message_id
+		ORDER BY gm.id
+		LIMIT ?`
+	messages, err := r.queryMessages(ctx, "GroupRepository.ListMessagesSince", query, userID, model.GroupMemberStatusActive, afterID, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	return messages, hasMore, nil
+}
+
+func (r *GroupRepository) queryMessages(ctx context.Context, name, query string, args ...interface{}) (_ []*model.GroupMessage, err error) {
+	ctx, span := database.StartSpan(ctx, name, query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*model.GroupMessage
+	for rows.Next() {
+		msg := &model.GroupMessage{}
+		err := rows.Scan(&msg.ID, &msg.GroupID, &msg.SenderID, &msg.SenderOpenID, &msg.Content, &msg.MsgType, &msg.Duration, &msg.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
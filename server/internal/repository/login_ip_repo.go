@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"pinche/internal/database"
+	"pinche/internal/tracing"
+)
+
+// LoginIPRepository keeps the IPs each user logged in from, to spot logins from new places
+type LoginIPRepository struct{}
+
+func NewLoginIPRepository() *LoginIPRepository {
+	return &LoginIPRepository{}
+}
+
+// IsKnown reports whether the user logged in from ip before, and whether any IP was recorded at all
+func (r *LoginIPRepository) IsKnown(ctx context.Context, userID uint64, ip string) (known bool, hasHistory bool, err error) {
+	query := `SELECT COUNT(*), COALESCE(MAX(ip = ?), 0) FROM user_login_ips WHERE user_id = ?`
+	var count int
+	ctx, span := database.StartSpan(ctx, "LoginIPRepository.IsKnown", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, ip, userID).Scan(&count, &known)
+	if err == sql.ErrNoRows {
+		return false, false, nil
+	}
+	return known, count > 0, err
+}
+
+// Record stores a successful login from ip
+func (r *LoginIPRepository) Record(ctx context.Context, userID uint64, ip string) (err error) {
+	query := `INSERT INTO user_login_ips (user_id, ip) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE login_count = login_count + 1, last_login_at = CURRENT_TIMESTAMP`
+	ctx, span := database.StartSpan(ctx, "LoginIPRepository.Record", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, userID, ip)
+	return err
+}
@@ -1,9 +1,14 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"strings"
+
 	"pinche/internal/database"
 	"pinche/internal/model"
+	"pinche/internal/tracing"
 )
 
 type MatchRepository struct{}
@@ -12,10 +17,12 @@ func NewMatchRepository() *MatchRepository {
 	return &MatchRepository{}
 }
 
-func (r *MatchRepository) Create(match *model.Match) error {
+func (r *MatchRepository) Create(ctx context.Context, match *model.Match) (err error) {
 	query := `INSERT INTO matches (driver_trip_id, passenger_trip_id, driver_id, passenger_id, match_score, driver_status, passenger_status, status) 
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
-	result, err := database.DB.Exec(query,
+	ctx, span := database.StartSpan(ctx, "MatchRepository.Create", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query,
 		match.DriverTripID, match.PassengerTripID, match.DriverID, match.PassengerID,
 		match.MatchScore, match.DriverStatus, match.PassengerStatus, match.Status,
 	)
@@ -30,7 +37,7 @@ func (r *MatchRepository) Create(match *model.Match) error {
 	return nil
 }
 
-func (r *MatchRepository) GetByID(id uint64) (*model.Match, error) {
+func (r *MatchRepository) GetByID(ctx context.Context, id uint64) (_ *model.Match, err error) {
 	query := `SELECT m.id, m.driver_trip_id, m.passenger_trip_id, m.driver_id, m.passenger_id, m.match_score, 
 		m.driver_status, m.passenger_status, m.status, m.created_at, m.updated_at,
 		COALESCE(d.open_id, ''), COALESCE(p.open_id, '')
@@ -39,7 +46,9 @@ func (r *MatchRepository) GetByID(id uint64) (*model.Match, error) {
 		LEFT JOIN users p ON m.passenger_id = p.id
 		WHERE m.id = ?`
 	match := &model.Match{}
-	err := database.DB.QueryRow(query, id).Scan(
+	ctx, span := database.StartSpan(ctx, "MatchRepository.GetByID", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, id).Scan(
 		&match.ID, &match.DriverTripID, &match.PassengerTripID, &match.DriverID, &match.PassengerID,
 		&match.MatchScore, &match.DriverStatus, &match.PassengerStatus, &match.Status, &match.CreatedAt, &match.UpdatedAt,
 		&match.DriverOpenID, &match.PassengerOpenID,
@@ -53,7 +62,7 @@ func (r *MatchRepository) GetByID(id uint64) (*model.Match, error) {
 	return match, nil
 }
 
-func (r *MatchRepository) GetByTrips(driverTripID, passengerTripID uint64) (*model.Match, error) {
+func (r *MatchRepository) GetByTrips(ctx context.Context, driverTripID, passengerTripID uint64) (_ *model.Match, err error) {
 	query := `SELECT m.id, m.driver_trip_id, m.passenger_trip_id, m.driver_id, m.passenger_id, m.match_score, 
 		m.driver_status, m.passenger_status, m.status, m.created_at, m.updated_at,
 		COALESCE(d.open_id, ''), COALESCE(p.open_id, '')
@@ -61,8 +70,10 @@ func (r *MatchRepository) GetByTrips(driverTripID, passengerTripID uint64) (*mod
 		LEFT JOIN users d ON m.driver_id = d.id
 		LEFT JOIN users p ON m.passenger_id = p.id
 		WHERE m.driver_trip_id = ? AND m.passenger_trip_id = ?`
+	ctx, span := database.StartSpan(ctx, "MatchRepository.GetByTrips", query)
+	defer tracing.End(span, &err)
 	match := &model.Match{}
-	err := database.DB.QueryRow(query, driverTripID, passengerTripID).Scan(
+	err = database.DB.QueryRowContext(ctx, query, driverTripID, passengerTripID).Scan(
 		&match.ID, &match.DriverTripID, &match.PassengerTripID, &match.DriverID, &match.PassengerID,
 		&match.MatchScore, &match.DriverStatus, &match.PassengerStatus, &match.Status, &match.CreatedAt, &match.UpdatedAt,
 		&match.DriverOpenID, &match.PassengerOpenID,
@@ -76,7 +87,7 @@ func (r *MatchRepository) GetByTrips(driverTripID, passengerTripID uint64) (*mod
 	return match, nil
 }
 
-func (r *MatchRepository) GetByUserID(userID uint64) ([]*model.Match, error) {
+func (r *MatchRepository) GetByUserID(ctx context.Context, userID uint64) (_ []*model.Match, err error) {
 	query := `
 		SELECT m.id, m.driver_trip_id, m.passenger_trip_id, m.driver_id, m.passenger_id, m.match_score,
 			m.driver_status, m.passenger_status, m.status, m.created_at, m.updated_at,
@@ -92,7 +103,9 @@ func (r *MatchRepository) GetByUserID(userID uint64) ([]*model.Match, error) {
 		WHERE m.driver_id = ? OR m.passenger_id = ?
 		ORDER BY m.created_at DESC
 	`
-	rows, err := database.DB.Query(query, userID, userID)
+	ctx, span := database.StartSpan(ctx, "MatchRepository.GetByUserID", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, userID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -127,25 +140,35 @@ func (r *MatchRepository) GetByUserID(userID uint64) ([]*model.Match, error) {
 	return matches, nil
 }
 
-func (r *MatchRepository) UpdateDriverStatus(id uint64, status int8) error {
+func (r *MatchRepository) UpdateDriverStatus(ctx context.Context, id uint64, status int8) (err error) {
 	query := `UPDATE matches SET driver_status = ? WHERE id = ?`
-	_, err := database.DB.Exec(query, status, id)
+	ctx, span := database.StartSpan(ctx, "MatchRepository.UpdateDriverStatus", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, status, id)
 	return err
 }
 
-func (r *MatchRepository) UpdatePassengerStatus(id uint64, status int8) error {
+func (r *MatchRepository) UpdatePassengerStatus(ctx context.Context, id uint64, status int8) (err error) {
 	query := `UPDATE matches SET passenger_status = ? WHERE id = ?`
-	_, err := database.DB.Exec(query, status, id)
+	ctx, span := database.StartSpan(ctx, "MatchRepository.UpdatePassengerStatus", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, status, id)
 	return err
 }
 
-func (r *MatchRepository) UpdateStatus(id uint64, status int8) error {
+// UpdateStatus sets the match status, the first switch to success records the time for the stats rollup
+func (r *MatchRepository) UpdateStatus(ctx context.Context, id uint64, status int8) (err error) {
 	query := `UPDATE matches SET status = ? WHERE id = ?`
-	_, err := database.DB.Exec(query, status, id)
+	if status == model.MatchStatusSuccess {
+		query = `UPDATE matches SET status = ?, succeeded_at = COALESCE(succeeded_at, NOW()) WHERE id = ?`
+	}
+	ctx, span := database.StartSpan(ctx, "MatchRepository.UpdateStatus", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, status, id)
 	return err
 }
 
-func (r *MatchRepository) GetContactInfo(matchID uint64) (*model.ContactInfo, error) {
+func (r *MatchRepository) GetContactInfo(ctx context.Context, matchID uint64) (_ *model.ContactInfo, err error) {
 	query := `
 		SELECT d.phone, d.nickname, p.phone, p.nickname
 		FROM matches m
@@ -154,7 +177,9 @@ func (r *MatchRepository) GetContactInfo(matchID uint64) (*model.ContactInfo, er
 		WHERE m.id = ? AND m.status = ?
 	`
 	info := &model.ContactInfo{}
-	err := database.DB.QueryRow(query, matchID, model.MatchStatusSuccess).Scan(
+	ctx, span := database.StartSpan(ctx, "MatchRepository.GetContactInfo", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, matchID, model.MatchStatusSuccess).Scan(
 		&info.DriverPhone, &info.DriverNickname, &info.PassengerPhone, &info.PassengerNickname,
 	)
 	if err == sql.ErrNoRows {
@@ -165,3 +190,70 @@ func (r *MatchRepository) GetContactInfo(matchID uint64) (*model.ContactInfo, er
 	}
 	return info, nil
 }
+
+// IterateAdmin streams all matches matching the admin filters to fn, newest first,
+// with both users and the driver trip route attached
+func (r *MatchRepository) IterateAdmin(ctx context.Context, req *model.AdminMatchExportReq, fn func(*model.Match) error) (err error) {
+	var conditions []string
+	var args []interface{}
+
+	if req.Status != nil {
+		conditions = append(conditions, "m.status = ?")
+		args = append(args, *req.Status)
+	}
+	if req.StartDate != "" {
+		conditions = append(conditions, "m.created_at >= ?")
+		args = append(args, req.StartDate+" 00:00:00")
+	}
+	if req.EndDate != "" {
+		conditions = append(conditions, "m.created_at <= ?")
+		args = append(args, req.EndDate+" 23:59:59")
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf(`SELECT m.id, m.driver_trip_id, m.passenger_trip_id, m.match_score,
+		m.driver_status, m.passenger_status, m.status, m.created_at, m.updated_at,
+		COALESCE(d.open_id, ''), COALESCE(d.phone, ''), COALESCE(d.nickname, ''),
+		COALESCE(p.open_id, ''), COALESCE(p.phone, ''), COALESCE(p.nickname, ''),
+		COALESCE(t.departure_city, ''), COALESCE(t.destination_city, ''), t.departure_time
+		FROM matches m
+		LEFT JOIN users d ON m.driver_id = d.id
+		LEFT JOIN users p ON m.passenger_id = p.id
+		LEFT JOIN trips t ON m.driver_trip_id = t.id
+		%s
+		ORDER BY m.id DESC`, whereClause)
+
+	ctx, span := database.StartSpan(ctx, "MatchRepository.IterateAdmin", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		match := &model.Match{Driver: &model.User{}, Passenger: &model.User{}, DriverTrip: &model.Trip{}}
+		var departureTime sql.NullTime
+		err := rows.Scan(&match.ID, &match.DriverTripID, &match.PassengerTripID, &match.MatchScore,
+			&match.DriverStatus, &match.PassengerStatus, &match.Status, &match.CreatedAt, &match.UpdatedAt,
+			&match.Driver.OpenID, &match.Driver.Phone, &match.Driver.Nickname,
+			&match.Passenger.OpenID, &match.Passenger.Phone, &match.Passenger.Nickname,
+			&match.DriverTrip.DepartureCity, &match.DriverTrip.DestinationCity, &departureTime)
+		if err != nil {
+			return err
+		}
+		match.DriverOpenID = match.Driver.OpenID
+		match.PassengerOpenID = match.Passenger.OpenID
+		if departureTime.Valid {
+			match.DriverTrip.DepartureTime = departureTime.Time
+		}
+		if err = fn(match); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -1,9 +1,14 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"strings"
+	"time"
+
 	"pinche/internal/database"
 	"pinche/internal/model"
+	"pinche/internal/tracing"
 )
 
 type MessageRepository struct{}
@@ -12,10 +17,31 @@ func NewMessageRepository() *MessageRepository {
 	return &MessageRepository{}
 }
 
-// Create inserts a new message into the database
-func (r *MessageRepository) Create(msg *model.Message) error {
-	query := `INSERT INTO messages (sender_id, receiver_id, content, msg_type, duration, is_read) VALUES (?, ?, ?, ?, ?, ?)`
-	result, err := database.DB.Exec(query, msg.SenderID, msg.ReceiverID, msg.Content, msg.MsgType, msg.Duration, msg.IsRead)
+// Create inserts a new message and moves the conversation of both users to it in one transaction
+func (r *MessageRepository) Create(ctx context.Context, msg *model.Message) (err error) {
+	query := `INSERT INTO messages (sender_id, receiver_id, content, msg_type, duration, is_read, client_msg_id, reply_to_id, reply_sender_id, reply_snippet)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	var clientMsgID sql.NullString
+	if msg.ClientMsgID != "" {
+		clientMsgID = sql.NullString{String: msg.ClientMsgID, Valid: true}
+	}
+	var replyToID, replySenderID sql.NullInt64
+	var replySnippet string
+	if msg.ReplyTo != nil {
+		replyToID = sql.NullInt64{Int64: int64(msg.ReplyTo.ID), Valid: true}
+		replySenderID = sql.NullInt64{Int64: int64(msg.ReplyTo.SenderID), Valid: true}
+		replySnippet = msg.ReplyTo.Snippet
+	}
+	ctx, span := database.StartSpan(ctx, "MessageRepository.Create", query)
+	defer tracing.End(span, &err)
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, msg.SenderID, msg.ReceiverID, msg.Content, msg.MsgType, msg.Duration, msg.IsRead, clientMsgID,
+		replyToID, replySenderID, replySnippet)
 	if err != nil {
 		return err
 	}
@@ -23,102 +49,201 @@ func (r *MessageRepository) Create(msg *model.Message) error {
 	if err != nil {
 		return err
 	}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO conversations (user_id, peer_id, last_message_id) VALUES (?, ?, ?), (?, ?, ?)
+		ON DUPLICATE KEY UPDATE last_message_id = GREATEST(last_message_id, VALUES(last_message_id))`,
+		msg.SenderID, msg.ReceiverID, id, msg.ReceiverID, msg.SenderID, id); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
 	msg.ID = uint64(id)
 	return nil
 }
 
 // GetByID retrieves a message by its ID
-func (r *MessageRepository) GetByID(id uint64) (*model.Message, error) {
-	query := `SELECT id, sender_id, receiver_id, content, msg_type, duration, is_read, created_at FROM messages WHERE id = ?`
-	msg := &model.Message{}
-	err := database.DB.QueryRow(query, id).Scan(
-		&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.MsgType, &msg.Duration, &msg.IsRead, &msg.CreatedAt,
-	)
+func (r *MessageRepository) GetByID(ctx context.Context, id uint64) (*model.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE id = ?`
+	return r.getOne(ctx, "MessageRepository.GetByID", query, id)
+}
+
+// GetByClientMsgID finds a message by the sender's idempotency ID, used to deduplicate retries
+func (r *MessageRepository) GetByClientMsgID(ctx context.Context, senderID uint64, clientMsgID string) (*model.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE sender_id = ? AND client_msg_id = ?`
+	return r.getOne(ctx, "MessageRepository.GetByClientMsgID", query, senderID, clientMsgID)
+}
+
+// GetVisibleByID retrieves a message of the user's conversations they have not deleted for themselves
+func (r *MessageRepository) GetVisibleByID(ctx context.Context, id, userID uint64) (*model.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages
+		WHERE id = ? AND ((sender_id = ? AND sender_deleted = 0) OR (receiver_id = ? AND receiver_deleted = 0))`
+	return r.getOne(ctx, "MessageRepository.GetVisibleByID", query, id, userID, userID)
+}
+
+func (r *MessageRepository) getOne(ctx context.Context, name, query string, args ...interface{}) (_ *model.Message, err error) {
+	ctx, span := database.StartSpan(ctx, name, query)
+	defer tracing.End(span, &err)
+	msg, err := scanMessage(database.DB.QueryRowContext(ctx, query, args...))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
-	if err != nil {
+	return msg, err
+}
+
+const messageColumns = `id, sender_id, receiver_id, content, msg_type, duration, is_read, recalled_at, client_msg_id,
+	reply_to_id, reply_sender_id, reply_snippet, created_at, updated_at`
+
+// scanMessage scans messageColumns followed by any extra selected columns into extra
+func scanMessage(scanner interface{ Scan(...interface{}) error }, extra ...interface{}) (*model.Message, error) {
+	msg := &model.Message{}
+	var recalledAt sql.NullTime
+	var clientMsgID sql.NullString
+	var replyToID, replySenderID sql.NullInt64
+	var replySnippet string
+	dest := []interface{}{
+		&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.MsgType, &msg.Duration, &msg.IsRead, &recalledAt, &clientMsgID,
+		&replyToID, &replySenderID, &replySnippet, &msg.CreatedAt, &msg.UpdatedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
 		return nil, err
 	}
+	if recalledAt.Valid {
+		msg.RecalledAt = &recalledAt.Time
+	}
+	msg.ClientMsgID = clientMsgID.String
+	if replyToID.Valid {
+		msg.ReplyTo = &model.MessageReply{
+			ID:       uint64(replyToID.Int64),
+			SenderID: uint64(replySenderID.Int64),
+			Snippet:  replySnippet,
+		}
+	}
 	return msg, nil
 }
 
-// GetConversationMessages retrieves messages between two users with pagination
-func (r *MessageRepository) GetConversationMessages(userID, peerID uint64, page, pageSize int) ([]*model.Message, int64, error) {
-	// count total messages in conversation
-	countQuery := `SELECT COUNT(*) FROM messages WHERE (sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)`
-	var total int64
-	err := database.DB.QueryRow(countQuery, userID, peerID, peerID, userID).Scan(&total)
-	if err != nil {
-		return nil, 0, err
+// GetConversationMessages retrieves up to limit messages between two users, newest first,
+// skipping messages the user deleted for themselves. A non-zero beforeID returns messages older than it,
+// a non-zero afterID the ones right after it. hasMore reports whether the page was cut at limit.
+func (r *MessageRepository) GetConversationMessages(ctx context.Context, userID, peerID, beforeID, afterID uint64, limit int) (_ []*model.Message, _ bool, err error) {
+	cursor, order := "", "DESC"
+	var cursorArgs []interface{}
+	if afterID > 0 {
+		cursor, order = " AND id > ?", "ASC"
+		cursorArgs = append(cursorArgs, afterID)
+	} else if beforeID > 0 {
+		cursor = " AND id < ?"
+		cursorArgs = append(cursorArgs, beforeID)
 	}
 
-	// get messages with pagination, ordered by created_at desc
-	offset := (page - 1) * pageSize
+	// one branch per direction so each is served by idx_conversation (sender_id, receiver_id, id)
 	query := `
-		SELECT m.id, m.sender_id, m.receiver_id, m.content, m.msg_type, m.duration, m.is_read, m.created_at,
-		       s.open_id as sender_open_id, r.open_id as receiver_open_id
-		FROM messages m
-		LEFT JOIN users s ON s.id = m.sender_id
-		LEFT JOIN users r ON r.id = m.receiver_id
-		WHERE (m.sender_id = ? AND m.receiver_id = ?) OR (m.sender_id = ? AND m.receiver_id = ?)
-		ORDER BY m.created_at DESC
-		LIMIT ? OFFSET ?
+		SELECT t.*, COALESCE(s.open_id, ''), COALESCE(rc.open_id, '')
+		FROM (
+			(SELECT ` + messageColumns + ` FROM messages
+				WHERE sender_id = ? AND receiver_id = ? AND sender_deleted = 0` + cursor + `
+				ORDER BY id ` + order + ` LIMIT ?)
+			UNION ALL
+			(SELECT ` + messageColumns + ` FROM messages
+				WHERE sender_id = ? AND receiver_id = ? AND receiver_deleted = 0` + cursor + `
+				ORDER BY id ` + order + ` LIMIT ?)
+		) AS t
+		LEFT JOIN users s ON s.id = t.sender_id
+		LEFT JOIN users rc ON rc.id = t.receiver_id
+		ORDER BY t.id ` + order + `
+		LIMIT ?
 	`
-	rows, err := database.DB.Query(query, userID, peerID, peerID, userID, pageSize, offset)
+	// fetch one extra row to know whether there are more
+	args := append([]interface{}{userID, peerID}, cursorArgs...)
+	args = append(args, limit+1, peerID, userID)
+	args = append(args, cursorArgs...)
+	args = append(args, limit+1, limit+1)
+
+	ctx, span := database.StartSpan(ctx, "MessageRepository.GetConversationMessages", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, 0, err
+		return nil, false, err
 	}
 	defer rows.Close()
 
 	var messages []*model.Message
 	for rows.Next() {
-		msg := &model.Message{}
-		err := rows.Scan(&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.MsgType, &msg.Duration, &msg.IsRead, &msg.CreatedAt,
-			&msg.SenderOpenID, &msg.ReceiverOpenID)
+		var senderOpenID, receiverOpenID string
+		msg, err := scanMessage(rows, &senderOpenID, &receiverOpenID)
 		if err != nil {
-			return nil, 0, err
+			return nil, false, err
 		}
+		msg.SetParticipants(senderOpenID, receiverOpenID)
 		messages = append(messages, msg)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, false, err
+	}
 
-	return messages, total, nil
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	if order == "ASC" {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	return messages, hasMore, nil
 }
 
-// GetConversations retrieves all conversations for a user
-func (r *MessageRepository) GetConversations(userID uint64) ([]*model.Conversation, error) {
-	// use subquery to get latest message for each conversation
+// GetConversations retrieves up to limit conversations of a user in one folder (see model.ConversationFolder*)
+// ordered by their latest message, ignoring messages they deleted. The cursors are the ID of a conversation's
+// latest message: beforeID pages back to older conversations, afterID returns the ones with newer messages.
+// Pages are read from the conversations table through its (user_id, last_message_id) index.
+func (r *MessageRepository) GetConversations(ctx context.Context, userID uint64, folder string, beforeID, afterID uint64, limit int) (_ []*model.Conversation, _ bool, err error) {
+	// a conversation without settings is in the inbox
+	var folderFilter string
+	switch folder {
+	case model.ConversationFolderPinned:
+		folderFilter = "c.peer_id IN (SELECT peer_id FROM conversation_settings WHERE user_id = ? AND pinned = 1 AND archived = 0)"
+	case model.ConversationFolderArchived:
+		folderFilter = "c.peer_id IN (SELECT peer_id FROM conversation_settings WHERE user_id = ? AND archived = 1)"
+	default:
+		folderFilter = "c.peer_id NOT IN (SELECT peer_id FROM conversation_settings WHERE user_id = ? AND (pinned = 1 OR archived = 1))"
+	}
+
+	cursor, order := "", "DESC"
+	var cursorArgs []interface{}
+	if afterID > 0 {
+		cursor, order = " AND c.last_message_id > ?", "ASC"
+		cursorArgs = append(cursorArgs, afterID)
+	} else if beforeID > 0 {
+		cursor = " AND c.last_message_id < ?"
+		cursorArgs = append(cursorArgs, beforeID)
+	}
+
 	query := `
-		SELECT 
-			peer_id,
-			COALESCE((SELECT open_id FROM users WHERE id = peer_id), '') as peer_open_id,
-			COALESCE((SELECT content FROM messages m2 WHERE 
-				((m2.sender_id = ? AND m2.receiver_id = peer_id) OR (m2.sender_id = peer_id AND m2.receiver_id = ?))
-				ORDER BY m2.created_at DESC LIMIT 1), '') as last_content,
-			COALESCE((SELECT msg_type FROM messages m3 WHERE 
-				((m3.sender_id = ? AND m3.receiver_id = peer_id) OR (m3.sender_id = peer_id AND m3.receiver_id = ?))
-				ORDER BY m3.created_at DESC LIMIT 1), 1) as last_msg_type,
-			COALESCE((SELECT duration FROM messages m3b WHERE 
-				((m3b.sender_id = ? AND m3b.receiver_id = peer_id) OR (m3b.sender_id = peer_id AND m3b.receiver_id = ?))
-				ORDER BY m3b.created_at DESC LIMIT 1), 0) as last_duration,
-			(SELECT created_at FROM messages m4 WHERE 
-				((m4.sender_id = ? AND m4.receiver_id = peer_id) OR (m4.sender_id = peer_id AND m4.receiver_id = ?))
-				ORDER BY m4.created_at DESC LIMIT 1) as last_message_at,
-			(SELECT COUNT(*) FROM messages m5 WHERE m5.sender_id = peer_id AND m5.receiver_id = ? AND m5.is_read = 0) as unread_count
+		SELECT c.peer_id, COALESCE(p.open_id, ''),
+			lm.id, lm.content, lm.msg_type, lm.duration, lm.recalled_at, lm.created_at,
+			(SELECT COUNT(*) FROM messages u
+				WHERE u.sender_id = c.peer_id AND u.receiver_id = ? AND u.is_read = 0 AND u.receiver_deleted = 0) AS unread_count,
+			COALESCE(cs.pinned, 0), COALESCE(cs.muted, 0), COALESCE(cs.archived, 0), COALESCE(cs.remark, '')
 		FROM (
-			SELECT DISTINCT CASE 
-				WHEN sender_id = ? THEN receiver_id 
-				ELSE sender_id 
-			END as peer_id
-			FROM messages 
-			WHERE sender_id = ? OR receiver_id = ?
-		) AS peers
-		ORDER BY last_message_at DESC
+			SELECT c.peer_id, c.last_message_id
+			FROM conversations c
+			WHERE c.user_id = ? AND ` + folderFilter + cursor + `
+			ORDER BY c.last_message_id ` + order + `
+			LIMIT ?
+		) AS c
+		JOIN messages lm ON lm.id = c.last_message_id
+		LEFT JOIN users p ON p.id = c.peer_id
+		LEFT JOIN conversation_settings cs ON cs.user_id = ? AND cs.peer_id = c.peer_id
+		ORDER BY c.last_message_id DESC
 	`
-	rows, err := database.DB.Query(query,
-		userID, userID, userID, userID, userID, userID, userID, userID, userID, userID, userID, userID)
+	args := append([]interface{}{userID, userID, userID}, cursorArgs...)
+	args = append(args, limit+1, userID)
+
+	ctx, span := database.StartSpan(ctx, "MessageRepository.GetConversations", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer rows.Close()
 
@@ -127,42 +252,292 @@ func (r *MessageRepository) GetConversations(userID uint64) ([]*model.Conversati
 		conv := &model.Conversation{
 			LastMessage: &model.Message{},
 		}
-		var lastMessageAt sql.NullTime
+		var recalledAt sql.NullTime
 		err := rows.Scan(
 			&conv.PeerID,
 			&conv.PeerOpenID,
+			&conv.LastMessage.ID,
 			&conv.LastMessage.Content,
 			&conv.LastMessage.MsgType,
 			&conv.LastMessage.Duration,
-			&lastMessageAt,
+			&recalledAt,
+			&conv.LastMessage.CreatedAt,
 			&conv.UnreadCount,
+			&conv.Pinned,
+			&conv.Muted,
+			&conv.Archived,
+			&conv.Remark,
 		)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
-		if lastMessageAt.Valid {
-			conv.LastMessageAt = lastMessageAt.Time
+		if recalledAt.Valid {
+			conv.LastMessage.RecalledAt = &recalledAt.Time
 		}
+		conv.LastMessageAt = conv.LastMessage.CreatedAt
 		conversations = append(conversations, conv)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, false, err
+	}
 
-	return conversations, nil
+	// with after_id the page closest to the cursor was fetched, drop the extra row from the newest end
+	hasMore := len(conversations) > limit
+	if hasMore {
+		if order == "ASC" {
+			conversations = conversations[1:]
+		} else {
+			conversations = conversations[:limit]
+		}
+	}
+	return conversations, hasMore, nil
 }
 
-// MarkAsRead marks all messages from a sender to a receiver as read
-func (r *MessageRepository) MarkAsRead(receiverID, senderID uint64) error {
+// ListChangedSince returns up to limit messages of a user created or updated after the
+// (updatedAfter, afterID) position, ordered by updated_at then id. Messages the user deleted
+// for themselves are included with Deleted set, so clients can drop them.
+func (r *MessageRepository) ListChangedSince(ctx context.Context, userID uint64, updatedAfter time.Time, afterID uint64, limit int) (_ []*model.Message, _ bool, err error) {
+	cursor := ` AND (updated_at > ? OR (updated_at = ? AND id > ?))`
+	query := `
+		SELECT t.*, COALESCE(s.open_id, ''), COALESCE(rc.open_id, '')
+		FROM (
+			(SELECT ` + messageColumns + `, sender_deleted AS deleted FROM messages
+				WHERE sender_id = ?` + cursor + `
+				ORDER BY updated_at, id LIMIT ?)
+			UNION ALL
+			(SELECT ` + messageColumns + `, receiver_deleted AS deleted FROM messages
+				WHERE receiver_id = ?` + cursor + `
+				ORDER BY updated_at, id LIMIT ?)
+		) AS t
+		LEFT JOIN users s ON s.id = t.sender_id
+		LEFT JOIN users rc ON rc.id = t.receiver_id
+		ORDER BY t.updated_at, t.id
+		LIMIT ?
+	`
+	args := []interface{}{
+		userID, updatedAfter, updatedAfter, afterID, limit + 1,
+		userID, updatedAfter, updatedAfter, afterID, limit + 1,
+		limit + 1,
+	}
+
+	ctx, span := database.StartSpan(ctx, "MessageRepository.ListChangedSince", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var messages []*model.Message
+	for rows.Next() {
+		// the deleted flag is selected right after messageColumns
+		var deleted bool
+		var senderOpenID, receiverOpenID string
+		msg, err := scanMessage(rows, &deleted, &senderOpenID, &receiverOpenID)
+		if err != nil {
+			return nil, false, err
+		}
+		msg.Deleted = deleted
+		msg.SetParticipants(senderOpenID, receiverOpenID)
+		messages = append(messages, msg)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	return messages, hasMore, nil
+}
+
+// Search finds the user's visible text messages matching a boolean mode query against the
+// ngram FULLTEXT index ft_content, newest first. peerID 0 searches all conversations and
+// from/to (exclusive) are optional. Recalled messages are no longer text and never match.
+func (r *MessageRepository) Search(ctx context.Context, userID, peerID uint64, query string, from, to *time.Time, beforeID uint64, limit int) (_ []*model.Message, _ bool, err error) {
+	conditions := []string{
+		"MATCH(m.content) AGAINST(? IN BOOLEAN MODE)",
+		"m.msg_type = ?",
+		"m.recalled_at IS NULL",
+		"((m.sender_id = ? AND m.sender_deleted = 0) OR (m.receiver_id = ? AND m.receiver_deleted = 0))",
+	}
+	args := []interface{}{query, model.MsgTypeText, userID, userID}
+
+	if peerID > 0 {
+		conditions = append(conditions, "(m.sender_id = ? OR m.receiver_id = ?)")
+		args = append(args, peerID, peerID)
+	}
+	if from != nil {
+		conditions = append(conditions, "m.created_at >= ?")
+		args = append(args, *from)
+	}
+	if to != nil {
+		conditions = append(conditions, "m.created_at < ?")
+		args = append(args, *to)
+	}
+	if beforeID > 0 {
+		conditions = append(conditions, "m.id < ?")
+		args = append(args, beforeID)
+	}
+
+	sqlQuery := `
+		SELECT m.id, m.sender_id, m.receiver_id, m.content, m.msg_type, m.duration, m.is_read, m.recalled_at,
+			m.client_msg_id, m.reply_to_id, m.reply_sender_id, m.reply_snippet, m.created_at, m.updated_at,
+			COALESCE(s.open_id, ''), COALESCE(rc.open_id, '')
+		FROM messages m
+		LEFT JOIN users s ON s.id = m.sender_id
+		LEFT JOIN users rc ON rc.id = m.receiver_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY m.id DESC
+		LIMIT ?`
+	args = append(args, limit+1)
+
+	ctx, span := database.StartSpan(ctx, "MessageRepository.Search", sqlQuery)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var messages []*model.Message
+	for rows.Next() {
+		var senderOpenID, receiverOpenID string
+		msg, err := scanMessage(rows, &senderOpenID, &receiverOpenID)
+		if err != nil {
+			return nil, false, err
+		}
+		msg.SetParticipants(senderOpenID, receiverOpenID)
+		messages = append(messages, msg)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	return messages, hasMore, nil
+}
+
+// MarkAsRead marks all messages from a sender to a receiver as read, returning how many changed
+func (r *MessageRepository) MarkAsRead(ctx context.Context, receiverID, senderID uint64) (_ int64, err error) {
 	query := `UPDATE messages SET is_read = 1 WHERE sender_id = ? AND receiver_id = ? AND is_read = 0`
-	_, err := database.DB.Exec(query, senderID, receiverID)
-	return err
+	ctx, span := database.StartSpan(ctx, "MessageRepository.MarkAsRead", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, senderID, receiverID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetLastReadMessageID returns the newest message from sender the receiver has read, 0 if none
+func (r *MessageRepository) GetLastReadMessageID(ctx context.Context, receiverID, senderID uint64) (_ uint64, err error) {
+	query := `SELECT COALESCE(MAX(id), 0) FROM messages WHERE sender_id = ? AND receiver_id = ? AND is_read = 1`
+	var id uint64
+	ctx, span := database.StartSpan(ctx, "MessageRepository.GetLastReadMessageID", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, senderID, receiverID).Scan(&id)
+	return id, err
+}
+
+// HasSentTo reports whether sender ever sent a message to receiver
+func (r *MessageRepository) HasSentTo(ctx context.Context, senderID, receiverID uint64) (_ bool, err error) {
+	query := `SELECT EXISTS(SELECT 1 FROM messages WHERE sender_id = ? AND receiver_id = ?)`
+	var exists bool
+	ctx, span := database.StartSpan(ctx, "MessageRepository.HasSentTo", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, senderID, receiverID).Scan(&exists)
+	return exists, err
 }
 
 // GetUnreadCount returns the count of unread messages for a user
-func (r *MessageRepository) GetUnreadCount(userID uint64) (int, error) {
-	query := `SELECT COUNT(*) FROM messages WHERE receiver_id = ? AND is_read = 0`
+func (r *MessageRepository) GetUnreadCount(ctx context.Context, userID uint64) (_ int, err error) {
+	query := `SELECT COUNT(*) FROM messages WHERE receiver_id = ? AND is_read = 0 AND receiver_deleted = 0`
 	var count int
-	err := database.DB.QueryRow(query, userID).Scan(&count)
+	ctx, span := database.StartSpan(ctx, "MessageRepository.GetUnreadCount", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, userID).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
 	return count, nil
 }
+
+// Recall wipes the content of a message sent after the given time,
+// returning false if it is not the sender's, too old or already recalled.
+// The recalled message stays in place as a recall notice, so the conversations pointing at it keep it as their latest message.
+func (r *MessageRepository) Recall(ctx context.Context, id, senderID uint64, sentAfter, recalledAt time.Time) (_ bool, err error) {
+	query := `UPDATE messages SET content = ?, msg_type = ?, duration = 0, recalled_at = ?,
+		reply_to_id = NULL, reply_sender_id = NULL, reply_snippet = ''
+		WHERE id = ? AND sender_id = ? AND recalled_at IS NULL AND created_at >= ?`
+	ctx, span := database.StartSpan(ctx, "MessageRepository.Recall", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, model.RecalledMessageContent, model.MsgTypeRecalled, recalledAt, id, senderID, sentAfter)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// UpdateReplySnippets replaces the quoted snippet of every reply to a message, used once it is recalled
+func (r *MessageRepository) UpdateReplySnippets(ctx context.Context, replyToID uint64, snippet string) (err error) {
+	query := `UPDATE messages SET reply_snippet = ? WHERE reply_to_id = ?`
+	ctx, span := database.StartSpan(ctx, "MessageRepository.UpdateReplySnippets", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, snippet, replyToID)
+	return err
+}
+
+// DeleteForUser hides a message from one side of the conversation only, the user's conversation
+// falls back to the latest message they can still see and is dropped once there is none
+func (r *MessageRepository) DeleteForUser(ctx context.Context, id, userID uint64) (err error) {
+	query := `UPDATE messages SET
+		sender_deleted = IF(sender_id = ?, 1, sender_deleted),
+		receiver_deleted = IF(receiver_id = ?, 1, receiver_deleted)
+		WHERE id = ? AND (sender_id = ? OR receiver_id = ?)`
+	ctx, span := database.StartSpan(ctx, "MessageRepository.DeleteForUser", query)
+	defer tracing.End(span, &err)
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, query, userID, userID, id, userID, userID); err != nil {
+		return err
+	}
+	var peerID uint64
+	err = tx.QueryRowContext(ctx, `SELECT IF(sender_id = ?, receiver_id, sender_id) FROM messages WHERE id = ? AND (sender_id = ? OR receiver_id = ?)`,
+		userID, id, userID, userID).Scan(&peerID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var lastID uint64
+	if err = tx.QueryRowContext(ctx, `SELECT GREATEST(
+			COALESCE((SELECT MAX(id) FROM messages WHERE sender_id = ? AND receiver_id = ? AND sender_deleted = 0), 0),
+			COALESCE((SELECT MAX(id) FROM messages WHERE sender_id = ? AND receiver_id = ? AND receiver_deleted = 0), 0))`,
+		userID, peerID, peerID, userID).Scan(&lastID); err != nil {
+		return err
+	}
+	if lastID == 0 {
+		_, err = tx.ExecContext(ctx, `DELETE FROM conversations WHERE user_id = ? AND peer_id = ?`, userID, peerID)
+	} else {
+		_, err = tx.ExecContext(ctx, `UPDATE conversations SET last_message_id = ? WHERE user_id = ? AND peer_id = ?`, lastID, userID, peerID)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,324 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"pinche/internal/database"
+	"pinche/internal/model"
+	"pinche/internal/tracing"
+)
+
+type ModerationRepository struct{}
+
+func NewModerationRepository() *ModerationRepository {
+	return &ModerationRepository{}
+}
+
+const sensitiveWordColumns = `id, word, action, admin_id, admin_username, created_at, updated_at`
+
+func scanSensitiveWord(scanner interface{ Scan(...interface{}) error }) (*model.SensitiveWord, error) {
+	w := &model.SensitiveWord{}
+	err := scanner.Scan(&w.ID, &w.Word, &w.Action, &w.AdminID, &w.AdminUsername, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (r *ModerationRepository) CreateWord(ctx context.Context, w *model.SensitiveWord) (err error) {
+	query := `INSERT INTO sensitive_words (word, action, admin_id, admin_username) VALUES (?, ?, ?, ?)`
+	ctx, span := database.StartSpan(ctx, "ModerationRepository.CreateWord", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, w.Word, w.Action, w.AdminID, w.AdminUsername)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	w.ID = uint64(id)
+	return nil
+}
+
+func (r *ModerationRepository) GetWordByID(ctx context.Context, id uint64) (_ *model.SensitiveWord, err error) {
+	query := `SELECT ` + sensitiveWordColumns + ` FROM sensitive_words WHERE id = ?`
+	ctx, span := database.StartSpan(ctx, "ModerationRepository.GetWordByID", query)
+	defer tracing.End(span, &err)
+	w, err := scanSensitiveWord(database.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (r *ModerationRepository) GetWordByWord(ctx context.Context, word string) (_ *model.SensitiveWord, err error) {
+	query := `SELECT ` + sensitiveWordColumns + ` FROM sensitive_words WHERE word = ?`
+	ctx, span := database.StartSpan(ctx, "ModerationRepository.GetWordByWord", query)
+	defer tracing.End(span, &err)
+	w, err := scanSensitiveWord(database.DB.QueryRowContext(ctx, query, word))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (r *ModerationRepository) UpdateWordAction(ctx context.Context, id uint64, action string) (err error) {
+	query := `UPDATE sensitive_words SET action = ? WHERE id = ?`
+	ctx, span := database.StartSpan(ctx, "ModerationRepository.UpdateWordAction", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, action, id)
+	return err
+}
+
+func (r *ModerationRepository) DeleteWord(ctx context.Context, id uint64) (err error) {
+	query := `DELETE FROM sensitive_words WHERE id = ?`
+	ctx, span := database.StartSpan(ctx, "ModerationRepository.DeleteWord", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+// ListAllWords returns the whole dictionary, used to build the matcher
+func (r *ModerationRepository) ListAllWords(ctx context.Context) (_ []*model.SensitiveWord, err error) {
+	query := `SELECT ` + sensitiveWordColumns + ` FROM sensitive_words`
+	ctx, span := database.StartSpan(ctx, "ModerationRepository.ListAllWords", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var words []*model.SensitiveWord
+	for rows.Next() {
+		w, err := scanSensitiveWord(rows)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, w)
+	}
+	return words, rows.Err()
+}
+
+func (r *ModerationRepository) ListWords(ctx context.Context, req *model.SensitiveWordListReq) (_ []*model.SensitiveWord, _ int64, err error) {
+	var conditions []string
+	var args []interface{}
+
+	if req.Keyword != "" {
+		conditions = append(conditions, "word LIKE ?")
+		args = append(args, "%"+req.Keyword+"%")
+	}
+	if req.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, req.Action)
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// count
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM sensitive_words %s", whereClause)
+	var total int64
+	ctx, span := database.StartSpan(ctx, "ModerationRepository.ListWords", countQuery)
+	defer tracing.End(span, &err)
+	if err = database.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// list
+	offset := (req.Page - 1) * req.PageSize
+	listQuery := fmt.Sprintf(`SELECT %s FROM sensitive_words %s ORDER BY id DESC LIMIT ? OFFSET ?`, sensitiveWordColumns, whereClause)
+	args = append(args, req.PageSize, offset)
+
+	rows, err := database.DB.QueryContext(ctx, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var words []*model.SensitiveWord
+	for rows.Next() {
+		w, err := scanSensitiveWord(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		words = append(words, w)
+	}
+	return words, total, rows.Err()
+}
+
+const contentFlagColumns = `f.id, f.user_id, COALESCE(u.open_id, ''), COALESCE(u.nickname, ''), f.scene, f.target_id, f.content, f.matched_words,
+	f.status, f.reviewer_id, f.reviewer_name, f.review_note, f.reviewed_at, f.created_at`
+
+func scanContentFlag(scanner interface{ Scan(...interface{}) error }) (*model.ContentFlag, error) {
+	f := &model.ContentFlag{}
+	var reviewedAt sql.NullTime
+	err := scanner.Scan(&f.ID, &f.UserID, &f.UserOpenID, &f.UserNickname, &f.Scene, &f.TargetID, &f.Content, &f.MatchedWords,
+		&f.Status, &f.ReviewerID, &f.ReviewerName, &f.ReviewNote, &reviewedAt, &f.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if reviewedAt.Valid {
+		f.ReviewedAt = &reviewedAt.Time
+	}
+	return f, nil
+}
+
+func (r *ModerationRepository) CreateFlag(ctx context.Context, f *model.ContentFlag) (err error) {
+	query := `INSERT INTO content_flags (user_id, scene, target_id, content, matched_words, status) VALUES (?, ?, ?, ?, ?, ?)`
+	ctx, span := database.StartSpan(ctx, "ModerationRepository.CreateFlag", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, f.UserID, f.Scene, f.TargetID, f.Content, f.MatchedWords, model.ContentFlagStatusPending)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	f.ID = uint64(id)
+	return nil
+}
+
+func (r *ModerationRepository) GetFlagByID(ctx context.Context, id uint64) (_ *model.ContentFlag, err error) {
+	query := `SELECT ` + contentFlagColumns + ` FROM content_flags f LEFT JOIN users u ON u.id = f.user_id WHERE f.id = ?`
+	ctx, span := database.StartSpan(ctx, "ModerationRepository.GetFlagByID", query)
+	defer tracing.End(span, &err)
+	f, err := scanContentFlag(database.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (r *ModerationRepository) ListFlags(ctx context.Context, req *model.ContentFlagListReq) (_ []*model.ContentFlag, _ int64, err error) {
+	var conditions []string
+	var args []interface{}
+
+	if req.Status != nil {
+		conditions = append(conditions, "f.status = ?")
+		args = append(args, *req.Status)
+	}
+	if req.Scene != "" {
+		conditions = append(conditions, "f.scene = ?")
+		args = append(args, req.Scene)
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// count
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM content_flags f %s", whereClause)
+	var total int64
+	ctx, span := database.StartSpan(ctx, "ModerationRepository.ListFlags", countQuery)
+	defer tracing.End(span, &err)
+	if err = database.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// list
+	offset := (req.Page - 1) * req.PageSize
+	listQuery := fmt.Sprintf(`SELECT %s FROM content_flags f LEFT JOIN users u ON u.id = f.user_id %s ORDER BY f.id DESC LIMIT ? OFFSET ?`,
+		contentFlagColumns, whereClause)
+	args = append(args, req.PageSize, offset)
+
+	rows, err := database.DB.QueryContext(ctx, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var flags []*model.ContentFlag
+	for rows.Next() {
+		f, err := scanContentFlag(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		flags = append(flags, f)
+	}
+	return flags, total, rows.Err()
+}
+
+// IterateFlags streams all content flags matching the export filters to fn, newest first
+func (r *ModerationRepository) IterateFlags(ctx context.Context, req *model.ContentFlagExportReq, fn func(*model.ContentFlag) error) (err error) {
+	var conditions []string
+	var args []interface{}
+
+	if req.Status != nil {
+		conditions = append(conditions, "f.status = ?")
+		args = append(args, *req.Status)
+	}
+	if req.Scene != "" {
+		conditions = append(conditions, "f.scene = ?")
+		args = append(args, req.Scene)
+	}
+	if req.StartDate != "" {
+		conditions = append(conditions, "f.created_at >= ?")
+		args = append(args, req.StartDate+" 00:00:00")
+	}
+	if req.EndDate != "" {
+		conditions = append(conditions, "f.created_at <= ?")
+		args = append(args, req.EndDate+" 23:59:59")
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM content_flags f LEFT JOIN users u ON u.id = f.user_id %s ORDER BY f.id DESC`,
+		contentFlagColumns, whereClause)
+	ctx, span := database.StartSpan(ctx, "ModerationRepository.IterateFlags", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		f, err := scanContentFlag(rows)
+		if err != nil {
+			return err
+		}
+		if err = fn(f); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ReviewFlag records the review of a pending flag, returning false if it was already reviewed
+func (r *ModerationRepository) ReviewFlag(ctx context.Context, id uint64, status int8, reviewerID uint64, reviewerName, note string, reviewedAt time.Time) (_ bool, err error) {
+	query := `UPDATE content_flags SET status = ?, reviewer_id = ?, reviewer_name = ?, review_note = ?, reviewed_at = ?
+		WHERE id = ? AND status = ?`
+	ctx, span := database.StartSpan(ctx, "ModerationRepository.ReviewFlag", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, status, reviewerID, reviewerName, note, reviewedAt, id, model.ContentFlagStatusPending)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
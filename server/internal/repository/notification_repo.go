@@ -1,8 +1,11 @@
 package repository
 
 import (
+	"context"
+
 	"pinche/internal/database"
 	"pinche/internal/model"
+	"pinche/internal/tracing"
 )
 
 type NotificationRepository struct{}
@@ -11,9 +14,11 @@ func NewNotificationRepository() *NotificationRepository {
 	return &NotificationRepository{}
 }
 
-func (r *NotificationRepository) Create(n *model.Notification) error {
+func (r *NotificationRepository) Create(ctx context.Context, n *model.Notification) (err error) {
 	query := `INSERT INTO notifications (user_id, match_id, trip_id, title, content) VALUES (?, ?, ?, ?, ?)`
-	result, err := database.DB.Exec(query, n.UserID, n.MatchID, n.TripID, n.Title, n.Content)
+	ctx, span := database.StartSpan(ctx, "NotificationRepository.Create", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, n.UserID, n.MatchID, n.TripID, n.Title, n.Content)
 	if err != nil {
 		return err
 	}
@@ -25,23 +30,26 @@ func (r *NotificationRepository) Create(n *model.Notification) error {
 	return nil
 }
 
-func (r *NotificationRepository) GetByUserID(userID uint64, page, pageSize int) ([]*model.Notification, int64, int64, error) {
+func (r *NotificationRepository) GetByUserID(ctx context.Context, userID uint64, page, pageSize int) (_ []*model.Notification, _ int64, _ int64, err error) {
+	query := `SELECT id, user_id, match_id, COALESCE(trip_id, 0), title, content, is_read, created_at FROM notifications WHERE user_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?`
+	ctx, span := database.StartSpan(ctx, "NotificationRepository.GetByUserID", query)
+	defer tracing.End(span, &err)
+
 	// count total
 	var total int64
-	if err := database.DB.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ?", userID).Scan(&total); err != nil {
+	if err = database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM notifications WHERE user_id = ?", userID).Scan(&total); err != nil {
 		return nil, 0, 0, err
 	}
 
 	// count unread
 	var unread int64
-	if err := database.DB.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ? AND is_read = 0", userID).Scan(&unread); err != nil {
+	if err = database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM notifications WHERE user_id = ? AND is_read = 0", userID).Scan(&unread); err != nil {
 		return nil, 0, 0, err
 	}
 
 	// list
 	offset := (page - 1) * pageSize
-	query := `SELECT id, user_id, match_id, COALESCE(trip_id, 0), title, content, is_read, created_at FROM notifications WHERE user_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?`
-	rows, err := database.DB.Query(query, userID, pageSize, offset)
+	rows, err := database.DB.QueryContext(ctx, query, userID, pageSize, offset)
 	if err != nil {
 		return nil, 0, 0, err
 	}
@@ -50,7 +58,7 @@ func (r *NotificationRepository) GetByUserID(userID uint64, page, pageSize int)
 	var notifications []*model.Notification
 	for rows.Next() {
 		n := &model.Notification{}
-		if err := rows.Scan(&n.ID, &n.UserID, &n.MatchID, &n.TripID, &n.Title, &n.Content, &n.IsRead, &n.CreatedAt); err != nil {
+		if err = rows.Scan(&n.ID, &n.UserID, &n.MatchID, &n.TripID, &n.Title, &n.Content, &n.IsRead, &n.CreatedAt); err != nil {
 			return nil, 0, 0, err
 		}
 		notifications = append(notifications, n)
@@ -58,14 +66,18 @@ func (r *NotificationRepository) GetByUserID(userID uint64, page, pageSize int)
 	return notifications, total, unread, nil
 }
 
-func (r *NotificationRepository) MarkAsRead(id, userID uint64) error {
+func (r *NotificationRepository) MarkAsRead(ctx context.Context, id, userID uint64) (err error) {
 	query := `UPDATE notifications SET is_read = 1 WHERE id = ? AND user_id = ?`
-	_, err := database.DB.Exec(query, id, userID)
+	ctx, span := database.StartSpan(ctx, "NotificationRepository.MarkAsRead", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, id, userID)
 	return err
 }
 
-func (r *NotificationRepository) MarkAllAsRead(userID uint64) error {
+func (r *NotificationRepository) MarkAllAsRead(ctx context.Context, userID uint64) (err error) {
 	query := `UPDATE notifications SET is_read = 1 WHERE user_id = ?`
-	_, err := database.DB.Exec(query, userID)
+	ctx, span := database.StartSpan(ctx, "NotificationRepository.MarkAllAsRead", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, userID)
 	return err
 }
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"pinche/internal/database"
+	"pinche/internal/model"
+	"pinche/internal/tracing"
+)
+
+type StatsRepository struct{}
+
+func NewStatsRepository() *StatsRepository {
+	return &StatsRepository{}
+}
+
+// hourlyMetricSources maps each metric to a query producing (bucket_hour, value) rows
+// for records created in [?, ?), a query over several tables repeats the window for each
+var hourlyMetricSources = map[string]string{
+	model.StatsMetricNewUsers: `SELECT DATE_FORMAT(created_at, '%Y-%m-%d %H:00:00'), COUNT(*)
+		FROM users WHERE created_at >= ? AND created_at < ? GROUP BY 1`,
+	model.StatsMetricDriverTrips: `SELECT DATE_FORMAT(created_at, '%Y-%m-%d %H:00:00'), COUNT(*)
+		FROM trips WHERE created_at >= ? AND created_at < ? AND trip_type = 1 GROUP BY 1`,
+	model.StatsMetricPassengerTrips: `SELECT DATE_FORMAT(created_at, '%Y-%m-%d %H:00:00'), COUNT(*)
+		FROM trips WHERE created_at >= ? AND created_at < ? AND trip_type = 2 GROUP BY 1`,
+	model.StatsMetricGrabs: `SELECT DATE_FORMAT(created_at, '%Y-%m-%d %H:00:00'), COUNT(*)
+		FROM trip_grabs WHERE created_at >= ? AND created_at < ? GROUP BY 1`,
+	model.StatsMetricMatchesCreated: `SELECT DATE_FORMAT(created_at, '%Y-%m-%d %H:00:00'), COUNT(*)
+		FROM matches WHERE created_at >= ? AND created_at < ? GROUP BY 1`,
+	model.StatsMetricMatchesSucceeded: `SELECT DATE_FORMAT(succeeded_at, '%Y-%m-%d %H:00:00'), COUNT(*)
+		FROM matches WHERE succeeded_at >= ? AND succeeded_at < ? GROUP BY 1`,
+	model.StatsMetricMessagesSent: `SELECT DATE_FORMAT(created_at, '%Y-%m-%d %H:00:00'), COUNT(*) FROM (
+			SELECT created_at FROM messages WHERE created_at >= ? AND created_at < ?
+			UNION ALL
+			SELECT created_at FROM group_messages WHERE created_at >= ? AND created_at < ?
+		) m GROUP BY 1`,
+}
+
+// RollupHourly recomputes the hourly buckets of a metric in [from, to)
+func (r *StatsRepository) RollupHourly(ctx context.Context, metric string, from, to time.Time) (err error) {
+	source, ok := hourlyMetricSources[metric]
+	if !ok {
+		return fmt.Errorf("unknown stats metric: %s", metric)
+	}
+
+	query := `INSERT INTO stats_hourly (metric, bucket_hour, value) SELECT ?, t.* FROM (` + source + `) t`
+	ctx, span := database.StartSpan(ctx, "StatsRepository.RollupHourly", query)
+	defer tracing.End(span, &err)
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM stats_hourly WHERE metric = ? AND bucket_hour >= ? AND bucket_hour < ?`,
+		metric, from, to); err != nil {
+		return err
+	}
+	args := []interface{}{metric}
+	for i := 0; i < strings.Count(source, "?"); i += 2 {
+		args = append(args, from, to)
+	}
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RollupRouteFunnel recomputes the daily route funnel for trips published in [from, to)
+func (r *StatsRepository) RollupRouteFunnel(ctx context.Context, from, to time.Time) (err error) {
+	query := `INSERT INTO stats_route_funnel_daily
+		(stat_date, departure_city, destination_city, published, engaged, confirmed, completed)
+		SELECT DATE(t.created_at), t.departure_city, t.destination_city,
+			COUNT(*),
+			SUM(EXISTS(SELECT 1 FROM trip_grabs g WHERE g.trip_id = t.id)
+				OR EXISTS(SELECT 1 FROM matches m WHERE m.driver_trip_id = t.id)
+				OR EXISTS(SELECT 1 FROM matches m WHERE m.passenger_trip_id = t.id)),
+			SUM(t.status IN (?, ?)
+				OR EXISTS(SELECT 1 FROM matches m WHERE m.driver_trip_id = t.id AND m.status = ?)
+				OR EXISTS(SELECT 1 FROM matches m WHERE m.passenger_trip_id = t.id AND m.status = ?)),
+			SUM(t.status = ?)
+		FROM trips t
+		WHERE t.created_at >= ? AND t.created_at < ?
+		GROUP BY DATE(t.created_at), t.departure_city, t.destination_city`
+	ctx, span := database.StartSpan(ctx, "StatsRepository.RollupRouteFunnel", query)
+	defer tracing.End(span, &err)
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM stats_route_funnel_daily WHERE stat_date >= ? AND stat_date < ?`,
+		from.Format("2006-01-02"), to.Format("2006-01-02")); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, query,
+		model.TripStatusMatched, model.TripStatusCompleted,
+		model.MatchStatusSuccess, model.MatchStatusSuccess,
+		model.TripStatusCompleted,
+		from, to); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// HasHourlyData reports whether the hourly rollup has been populated
+func (r *StatsRepository) HasHourlyData(ctx context.Context) (_ bool, err error) {
+	query := `SELECT 1 FROM stats_hourly LIMIT 1`
+	ctx, span := database.StartSpan(ctx, "StatsRepository.HasHourlyData", query)
+	defer tracing.End(span, &err)
+	var id int
+	err = database.DB.QueryRowContext(ctx, query).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// QueryTimeseries returns metric values in [from, to) grouped by hour or day
+func (r *StatsRepository) QueryTimeseries(ctx context.Context, metrics []string, granularity string, from, to time.Time) (_ map[string][]*model.StatsPoint, err error) {
+	bucketExpr := "DATE_FORMAT(bucket_hour, '%Y-%m-%d %H:00')"
+	if granularity == model.StatsGranularityDay {
+		bucketExpr = "DATE_FORMAT(bucket_hour, '%Y-%m-%d')"
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(metrics)), ",")
+	query := fmt.Sprintf(`SELECT metric, %s AS bucket, SUM(value) FROM stats_hourly
+		WHERE metric IN (%s) AND bucket_hour >= ? AND bucket_hour < ?
+		GROUP BY metric, bucket ORDER BY bucket`, bucketExpr, placeholders)
+
+	args := make([]interface{}, 0, len(metrics)+2)
+	for _, m := range metrics {
+		args = append(args, m)
+	}
+	args = append(args, from, to)
+
+	ctx, span := database.StartSpan(ctx, "StatsRepository.QueryTimeseries", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string][]*model.StatsPoint)
+	for rows.Next() {
+		var metric string
+		p := &model.StatsPoint{}
+		if err = rows.Scan(&metric, &p.Bucket, &p.Value); err != nil {
+			return nil, err
+		}
+		result[metric] = append(result[metric], p)
+	}
+	return result, nil
+}
+
+// QueryRouteFunnel sums the route funnel over [from, to) dates, busiest routes first
+func (r *StatsRepository) QueryRouteFunnel(ctx context.Context, req *model.StatsFunnelReq, from, to time.Time) (_ []*model.RouteFunnel, err error) {
+	whereClause, args := funnelWhere(req, from, to)
+	query := fmt.Sprintf(`SELECT departure_city, destination_city,
+		SUM(published), SUM(engaged), SUM(confirmed), SUM(completed)
+		FROM stats_route_funnel_daily %s
+		GROUP BY departure_city, destination_city
+		ORDER BY SUM(published) DESC LIMIT ?`, whereClause)
+	args = append(args, req.Limit)
+
+	ctx, span := database.StartSpan(ctx, "StatsRepository.QueryRouteFunnel", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var routes []*model.RouteFunnel
+	for rows.Next() {
+		f := &model.RouteFunnel{}
+		if err = rows.Scan(&f.DepartureCity, &f.DestinationCity,
+			&f.Published, &f.Engaged, &f.Confirmed, &f.Completed); err != nil {
+			return nil, err
+		}
+		routes = append(routes, f)
+	}
+	return routes, nil
+}
+
+// QueryFunnelTotal sums the funnel over all matching routes
+func (r *StatsRepository) QueryFunnelTotal(ctx context.Context, req *model.StatsFunnelReq, from, to time.Time) (_ *model.RouteFunnel, err error) {
+	whereClause, args := funnelWhere(req, from, to)
+	query := fmt.Sprintf(`SELECT COALESCE(SUM(published), 0), COALESCE(SUM(engaged), 0),
+		COALESCE(SUM(confirmed), 0), COALESCE(SUM(completed), 0)
+		FROM stats_route_funnel_daily %s`, whereClause)
+
+	f := &model.RouteFunnel{
+		DepartureCity:   req.DepartureCity,
+		DestinationCity: req.DestinationCity,
+	}
+	ctx, span := database.StartSpan(ctx, "StatsRepository.QueryFunnelTotal", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, args...).Scan(&f.Published, &f.Engaged, &f.Confirmed, &f.Completed)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func funnelWhere(req *model.StatsFunnelReq, from, to time.Time) (string, []interface{}) {
+	conditions := []string{"stat_date >= ?", "stat_date < ?"}
+	args := []interface{}{from.Format("2006-01-02"), to.Format("2006-01-02")}
+
+	if req.DepartureCity != "" {
+		conditions = append(conditions, "departure_city = ?")
+		args = append(args, req.DepartureCity)
+	}
+	if req.DestinationCity != "" {
+		conditions = append(conditions, "destination_city = ?")
+		args = append(args, req.DestinationCity)
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -8,6 +9,7 @@ import (
 
 	"pinche/internal/database"
 	"pinche/internal/model"
+	"pinche/internal/tracing"
 )
 
 type TripRepository struct{}
@@ -17,26 +19,30 @@ func NewTripRepository() *TripRepository {
 }
 
 // CountActiveByUserID returns count of active trips (pending/matched) for a user
-func (r *TripRepository) CountActiveByUserID(userID uint64) (int, error) {
+func (r *TripRepository) CountActiveByUserID(ctx context.Context, userID uint64) (count int, err error) {
 	query := `SELECT COUNT(*) FROM trips WHERE user_id = ? AND status IN (?, ?)`
-	var count int
-	err := database.DB.QueryRow(query, userID, model.TripStatusPending, model.TripStatusMatched).Scan(&count)
+	ctx, span := database.StartSpan(ctx, "TripRepository.CountActiveByUserID", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, userID, model.TripStatusPending, model.TripStatusMatched).Scan(&count)
 	return count, err
 }
 
 // CountTodayByUserID returns count of trips created today by a user
-func (r *TripRepository) CountTodayByUserID(userID uint64) (int, error) {
+func (r *TripRepository) CountTodayByUserID(ctx context.Context, userID uint64) (count int, err error) {
 	query := `SELECT COUNT(*) FROM trips WHERE user_id = ? AND DATE(created_at) = CURDATE()`
-	var count int
-	err := database.DB.QueryRow(query, userID).Scan(&count)
+	ctx, span := database.StartSpan(ctx, "TripRepository.CountTodayByUserID", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, userID).Scan(&count)
 	return count, err
 }
 
-func (r *TripRepository) Create(trip *model.Trip) error {
+func (r *TripRepository) Create(ctx context.Context, trip *model.Trip) (err error) {
 	query := `INSERT INTO trips (user_id, trip_type, departure_city, departure_province, departure_address, departure_lat, departure_lng, 
 		destination_city, destination_province, destination_address, destination_lat, destination_lng, departure_time, seats, price, remark, images, status) 
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	result, err := database.DB.Exec(query,
+	ctx, span := database.StartSpan(ctx, "TripRepository.Create", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query,
 		trip.UserID, trip.TripType, trip.DepartureCity, trip.DepartureProvince, trip.DepartureAddress, trip.DepartureLat, trip.DepartureLng,
 		trip.DestinationCity, trip.DestinationProvince, trip.DestinationAddress, trip.DestinationLat, trip.DestinationLng,
 		trip.DepartureTime, trip.Seats, trip.Price, trip.Remark, trip.Images, trip.Status,
@@ -52,7 +58,7 @@ func (r *TripRepository) Create(trip *model.Trip) error {
 	return nil
 }
 
-func (r *TripRepository) GetByID(id uint64) (*model.Trip, error) {
+func (r *TripRepository) GetByID(ctx context.Context, id uint64) (_ *model.Trip, err error) {
 	query := `SELECT t.id, t.user_id, t.trip_type, t.departure_city, COALESCE(t.departure_province, ''), t.departure_address, t.departure_lat, t.departure_lng,
 		t.destination_city, COALESCE(t.destination_province, ''), t.destination_address, t.destination_lat, t.destination_lng, t.departure_time, 
 		t.seats, t.price, t.remark, COALESCE(t.images, ''), t.status, COALESCE(t.view_count, 0), t.created_at, t.updated_at,
@@ -61,7 +67,9 @@ func (r *TripRepository) GetByID(id uint64) (*model.Trip, error) {
 		LEFT JOIN users u ON t.user_id = u.id
 		WHERE t.id = ?`
 	trip := &model.Trip{User: &model.User{}}
-	err := database.DB.QueryRow(query, id).Scan(
+	ctx, span := database.StartSpan(ctx, "TripRepository.GetByID", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, id).Scan(
 		&trip.ID, &trip.UserID, &trip.TripType, &trip.DepartureCity, &trip.DepartureProvince, &trip.DepartureAddress, &trip.DepartureLat, &trip.DepartureLng,
 		&trip.DestinationCity, &trip.DestinationProvince, &trip.DestinationAddress, &trip.DestinationLat, &trip.DestinationLng,
 		&trip.DepartureTime, &trip.Seats, &trip.Price, &trip.Remark, &trip.Images, &trip.Status, &trip.ViewCount, &trip.CreatedAt, &trip.UpdatedAt,
@@ -80,7 +88,7 @@ func (r *TripRepository) GetByID(id uint64) (*model.Trip, error) {
 	return trip, nil
 }
 
-func (r *TripRepository) List(req *model.TripListReq) ([]*model.Trip, int64, error) {
+func (r *TripRepository) List(ctx context.Context, req *model.TripListReq) (_ []*model.Trip, _ int64, err error) {
 	var conditions []string
 	var args []interface{}
 
@@ -136,7 +144,9 @@ func (r *TripRepository) List(req *model.TripListReq) ([]*model.Trip, int64, err
 	// count (need to join users table for open_id filter)
 	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM trips t LEFT JOIN users u ON t.user_id = u.id WHERE %s", whereClause)
 	var total int64
-	if err := database.DB.QueryRow(countQuery, args...).Scan(&total); err != nil {
+	ctx, span := database.StartSpan(ctx, "TripRepository.List", countQuery)
+	defer tracing.End(span, &err)
+	if err = database.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
@@ -167,7 +177,7 @@ func (r *TripRepository) List(req *model.TripListReq) ([]*model.Trip, int64, err
 	`, whereClause, orderClause)
 	args = append(args, req.PageSize, offset)
 
-	rows, err := database.DB.Query(listQuery, args...)
+	rows, err := database.DB.QueryContext(ctx, listQuery, args...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -195,7 +205,7 @@ func (r *TripRepository) List(req *model.TripListReq) ([]*model.Trip, int64, err
 	return trips, total, nil
 }
 
-func (r *TripRepository) GetByUserID(userID uint64) ([]*model.Trip, error) {
+func (r *TripRepository) GetByUserID(ctx context.Context, userID uint64) (_ []*model.Trip, err error) {
 	query := `SELECT t.id, t.user_id, t.trip_type, t.departure_city, COALESCE(t.departure_province, ''), t.departure_address, t.departure_lat, t.departure_lng,
 		t.destination_city, COALESCE(t.destination_province, ''), t.destination_address, t.destination_lat, t.destination_lng, t.departure_time, t.seats, t.price, t.remark, COALESCE(t.images, ''), t.status, COALESCE(t.view_count, 0), t.created_at, t.updated_at,
 		COALESCE(u.open_id, '')
@@ -203,7 +213,9 @@ func (r *TripRepository) GetByUserID(userID uint64) ([]*model.Trip, error) {
 		LEFT JOIN users u ON t.user_id = u.id
 		WHERE t.user_id = ? ORDER BY t.created_at DESC`
 
-	rows, err := database.DB.Query(query, userID)
+	ctx, span := database.StartSpan(ctx, "TripRepository.GetByUserID", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -226,13 +238,15 @@ func (r *TripRepository) GetByUserID(userID uint64) ([]*model.Trip, error) {
 	return trips, nil
 }
 
-func (r *TripRepository) UpdateStatus(id uint64, status int8) error {
+func (r *TripRepository) UpdateStatus(ctx context.Context, id uint64, status int8) (err error) {
 	query := `UPDATE trips SET status = ? WHERE id = ?`
-	_, err := database.DB.Exec(query, status, id)
+	ctx, span := database.StartSpan(ctx, "TripRepository.UpdateStatus", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, status, id)
 	return err
 }
 
-func (r *TripRepository) FindMatchingTrips(trip *model.Trip) ([]*model.Trip, error) {
+func (r *TripRepository) FindMatchingTrips(ctx context.Context, trip *model.Trip) (_ []*model.Trip, err error) {
 	// find opposite type trips with similar route and time
 	oppositeType := model.TripTypePassenger
 	if trip.TripType == model.TripTypePassenger {
@@ -259,8 +273,10 @@ func (r *TripRepository) FindMatchingTrips(trip *model.Trip) ([]*model.Trip, err
 		ORDER BY ABS(TIMESTAMPDIFF(MINUTE, t.departure_time, ?)) ASC
 		LIMIT 20
 	`
+	ctx, span := database.StartSpan(ctx, "TripRepository.FindMatchingTrips", query)
+	defer tracing.End(span, &err)
 
-	rows, err := database.DB.Query(query, oppositeType, model.TripStatusPending,
+	rows, err := database.DB.QueryContext(ctx, query, oppositeType, model.TripStatusPending,
 		trip.DepartureCity, trip.DestinationCity, startTime, endTime, trip.UserID, trip.DepartureTime)
 	if err != nil {
 		return nil, err
@@ -286,14 +302,69 @@ func (r *TripRepository) FindMatchingTrips(trip *model.Trip) ([]*model.Trip, err
 	return trips, nil
 }
 
-func (r *TripRepository) Delete(id uint64, userID uint64) error {
+func (r *TripRepository) Delete(ctx context.Context, id uint64, userID uint64) (err error) {
 	query := `DELETE FROM trips WHERE id = ? AND user_id = ?`
-	_, err := database.DB.Exec(query, id, userID)
+	ctx, span := database.StartSpan(ctx, "TripRepository.Delete", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, id, userID)
 	return err
 }
 
 // AdminListAll returns all trips for admin panel
-func (r *TripRepository) AdminListAll(req *model.AdminTripListReq) ([]*model.Trip, int64, error) {
+func (r *TripRepository) AdminListAll(ctx context.Context, req *model.AdminTripListReq) (_ []*model.Trip, _ int64, err error) {
+	whereClause, args := adminTripFilter(req)
+
+	// count
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM trips t LEFT JOIN users u ON t.user_id = u.id %s", whereClause)
+	var total int64
+	ctx, span := database.StartSpan(ctx, "TripRepository.AdminListAll", countQuery)
+	defer tracing.End(span, &err)
+	if err = database.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// list
+	offset := (req.Page - 1) * req.PageSize
+	listQuery := fmt.Sprintf(`
+		SELECT t.id, t.user_id, t.trip_type, t.departure_city, COALESCE(t.departure_province, ''), t.departure_address, t.departure_lat, t.departure_lng,
+			t.destination_city, COALESCE(t.destination_province, ''), t.destination_address, t.destination_lat, t.destination_lng, t.departure_time, 
+			t.seats, t.price, t.remark, COALESCE(t.images, ''), t.status, COALESCE(t.view_count, 0), t.created_at, t.updated_at,
+			COALESCE(u.id, 0), COALESCE(u.open_id, ''), COALESCE(u.phone, ''), COALESCE(u.nickname, ''), COALESCE(u.avatar, ''), COALESCE(u.gender, 0)
+		FROM trips t
+		LEFT JOIN users u ON t.user_id = u.id
+		%s
+		ORDER BY t.created_at DESC
+		LIMIT ? OFFSET ?
+	`, whereClause)
+	args = append(args, req.PageSize, offset)
+
+	rows, err := database.DB.QueryContext(ctx, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var trips []*model.Trip
+	for rows.Next() {
+		trip := &model.Trip{User: &model.User{}}
+		err := rows.Scan(
+			&trip.ID, &trip.UserID, &trip.TripType, &trip.DepartureCity, &trip.DepartureProvince, &trip.DepartureAddress, &trip.DepartureLat, &trip.DepartureLng,
+			&trip.DestinationCity, &trip.DestinationProvince, &trip.DestinationAddress, &trip.DestinationLat, &trip.DestinationLng,
+			&trip.DepartureTime, &trip.Seats, &trip.Price, &trip.Remark, &trip.Images, &trip.Status, &trip.ViewCount, &trip.CreatedAt, &trip.UpdatedAt,
+			&trip.User.ID, &trip.User.OpenID, &trip.User.Phone, &trip.User.Nickname, &trip.User.Avatar, &trip.User.Gender,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		trip.UserOpenID = trip.User.OpenID
+		trips = append(trips, trip)
+	}
+
+	return trips, total, nil
+}
+
+// adminTripFilter builds the WHERE clause shared by the admin trip list and export
+func adminTripFilter(req *model.AdminTripListReq) (string, []interface{}) {
 	var conditions []string
 	var args []interface{}
 
@@ -326,66 +397,67 @@ func (r *TripRepository) AdminListAll(req *model.AdminTripListReq) ([]*model.Tri
 	if len(conditions) > 0 {
 		whereClause = "WHERE " + strings.Join(conditions, " AND ")
 	}
+	return whereClause, args
+}
 
-	// count
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM trips t LEFT JOIN users u ON t.user_id = u.id %s", whereClause)
-	var total int64
-	if err := database.DB.QueryRow(countQuery, args...).Scan(&total); err != nil {
-		return nil, 0, err
-	}
-
-	// list
-	offset := (req.Page - 1) * req.PageSize
-	listQuery := fmt.Sprintf(`
-		SELECT t.id, t.user_id, t.trip_type, t.departure_city, COALESCE(t.departure_province, ''), t.departure_address, t.departure_lat, t.departure_lng,
-			t.destination_city, COALESCE(t.destination_province, ''), t.destination_address, t.destination_lat, t.destination_lng, t.departure_time, 
-			t.seats, t.price, t.remark, COALESCE(t.images, ''), t.status, COALESCE(t.view_count, 0), t.created_at, t.updated_at,
-			COALESCE(u.id, 0), COALESCE(u.open_id, ''), COALESCE(u.phone, ''), COALESCE(u.nickname, ''), COALESCE(u.avatar, ''), COALESCE(u.gender, 0)
+// IterateAdmin streams all trips matching the admin filters to fn, newest first
+// Rows are not buffered so exports run in constant memory
+func (r *TripRepository) IterateAdmin(ctx context.Context, req *model.AdminTripListReq, fn func(*model.Trip) error) (err error) {
+	whereClause, args := adminTripFilter(req)
+	query := fmt.Sprintf(`
+		SELECT t.id, t.user_id, t.trip_type, t.departure_city, COALESCE(t.departure_province, ''), t.departure_address,
+			t.destination_city, COALESCE(t.destination_province, ''), t.destination_address, t.departure_time,
+			t.seats, t.price, t.remark, t.status, COALESCE(t.view_count, 0), t.created_at, t.updated_at,
+			COALESCE(u.open_id, ''), COALESCE(u.phone, ''), COALESCE(u.nickname, '')
 		FROM trips t
 		LEFT JOIN users u ON t.user_id = u.id
 		%s
 		ORDER BY t.created_at DESC
-		LIMIT ? OFFSET ?
 	`, whereClause)
-	args = append(args, req.PageSize, offset)
 
-	rows, err := database.DB.Query(listQuery, args...)
+	ctx, span := database.StartSpan(ctx, "TripRepository.IterateAdmin", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, 0, err
+		return err
 	}
 	defer rows.Close()
 
-	var trips []*model.Trip
 	for rows.Next() {
 		trip := &model.Trip{User: &model.User{}}
 		err := rows.Scan(
-			&trip.ID, &trip.UserID, &trip.TripType, &trip.DepartureCity, &trip.DepartureProvince, &trip.DepartureAddress, &trip.DepartureLat, &trip.DepartureLng,
-			&trip.DestinationCity, &trip.DestinationProvince, &trip.DestinationAddress, &trip.DestinationLat, &trip.DestinationLng,
-			&trip.DepartureTime, &trip.Seats, &trip.Price, &trip.Remark, &trip.Images, &trip.Status, &trip.ViewCount, &trip.CreatedAt, &trip.UpdatedAt,
-			&trip.User.ID, &trip.User.OpenID, &trip.User.Phone, &trip.User.Nickname, &trip.User.Avatar, &trip.User.Gender,
+			&trip.ID, &trip.UserID, &trip.TripType, &trip.DepartureCity, &trip.DepartureProvince, &trip.DepartureAddress,
+			&trip.DestinationCity, &trip.DestinationProvince, &trip.DestinationAddress, &trip.DepartureTime,
+			&trip.Seats, &trip.Price, &trip.Remark, &trip.Status, &trip.ViewCount, &trip.CreatedAt, &trip.UpdatedAt,
+			&trip.User.OpenID, &trip.User.Phone, &trip.User.Nickname,
 		)
 		if err != nil {
-			return nil, 0, err
+			return err
 		}
 		trip.UserOpenID = trip.User.OpenID
-		trips = append(trips, trip)
+		if err = fn(trip); err != nil {
+			return err
+		}
 	}
-
-	return trips, total, nil
+	return rows.Err()
 }
 
 // IncrementViewCount increases the view count of a trip
-func (r *TripRepository) IncrementViewCount(id uint64) error {
+func (r *TripRepository) IncrementViewCount(ctx context.Context, id uint64) (err error) {
 	query := `UPDATE trips SET view_count = COALESCE(view_count, 0) + 1 WHERE id = ?`
-	_, err := database.DB.Exec(query, id)
+	ctx, span := database.StartSpan(ctx, "TripRepository.IncrementViewCount", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, id)
 	return err
 }
 
 // CreateGrab creates a grab record for a trip
-func (r *TripRepository) CreateGrab(grab *model.TripGrab) error {
+func (r *TripRepository) CreateGrab(ctx context.Context, grab *model.TripGrab) (err error) {
 	query := `INSERT INTO trip_grabs (trip_id, user_id, message) VALUES (?, ?, ?)
 		ON DUPLICATE KEY UPDATE message = VALUES(message)`
-	result, err := database.DB.Exec(query, grab.TripID, grab.UserID, grab.Message)
+	ctx, span := database.StartSpan(ctx, "TripRepository.CreateGrab", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, grab.TripID, grab.UserID, grab.Message)
 	if err != nil {
 		return err
 	}
@@ -394,15 +466,27 @@ func (r *TripRepository) CreateGrab(grab *model.TripGrab) error {
 	return nil
 }
 
+// HasGrab checks if the user grabbed the trip
+func (r *TripRepository) HasGrab(ctx context.Context, tripID, userID uint64) (_ bool, err error) {
+	query := `SELECT COUNT(*) FROM trip_grabs WHERE trip_id = ? AND user_id = ?`
+	ctx, span := database.StartSpan(ctx, "TripRepository.HasGrab", query)
+	defer tracing.End(span, &err)
+	var count int
+	err = database.DB.QueryRowContext(ctx, query, tripID, userID).Scan(&count)
+	return count > 0, err
+}
+
 // GetGrabsByTripID returns all grab records for a trip
-func (r *TripRepository) GetGrabsByTripID(tripID uint64) ([]*model.TripGrab, error) {
+func (r *TripRepository) GetGrabsByTripID(ctx context.Context, tripID uint64) (_ []*model.TripGrab, err error) {
 	query := `SELECT g.id, g.trip_id, g.user_id, g.message, g.created_at,
 		COALESCE(u.id, 0), COALESCE(u.open_id, ''), COALESCE(u.nickname, ''), COALESCE(u.avatar, ''), COALESCE(u.gender, 0)
 		FROM trip_grabs g
 		LEFT JOIN users u ON g.user_id = u.id
 		WHERE g.trip_id = ?
 		ORDER BY g.created_at DESC`
-	rows, err := database.DB.Query(query, tripID)
+	ctx, span := database.StartSpan(ctx, "TripRepository.GetGrabsByTripID", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, tripID)
 	if err != nil {
 		return nil, err
 	}
@@ -424,7 +508,7 @@ func (r *TripRepository) GetGrabsByTripID(tripID uint64) ([]*model.TripGrab, err
 }
 
 // UpdateTrip updates trip fields that can be changed directly (images, remark, seats, price)
-func (r *TripRepository) UpdateTrip(id uint64, userID uint64, images string, remark string, seats *int, price *float64) error {
+func (r *TripRepository) UpdateTrip(ctx context.Context, id uint64, userID uint64, images string, remark string, seats *int, price *float64) (err error) {
 	query := `UPDATE trips SET images = ?, remark = ?`
 	args := []interface{}{images, remark}
 
@@ -439,7 +523,9 @@ func (r *TripRepository) UpdateTrip(id uint64, userID uint64, images string, rem
 	query += ` WHERE id = ? AND user_id = ?`
 	args = append(args, id, userID)
 
-	result, err := database.DB.Exec(query, args...)
+	ctx, span := database.StartSpan(ctx, "TripRepository.UpdateTrip", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, args...)
 	if err != nil {
 		return err
 	}
@@ -451,9 +537,11 @@ func (r *TripRepository) UpdateTrip(id uint64, userID uint64, images string, rem
 }
 
 // CreateTripUpdate creates a pending update request
-func (r *TripRepository) CreateTripUpdate(update *model.TripUpdate) error {
+func (r *TripRepository) CreateTripUpdate(ctx context.Context, update *model.TripUpdate) (err error) {
 	query := `INSERT INTO trip_updates (trip_id, user_id, update_type, old_value, new_value, status) VALUES (?, ?, ?, ?, ?, ?)`
-	result, err := database.DB.Exec(query, update.TripID, update.UserID, update.UpdateType, update.OldValue, update.NewValue, update.Status)
+	ctx, span := database.StartSpan(ctx, "TripRepository.CreateTripUpdate", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, update.TripID, update.UserID, update.UpdateType, update.OldValue, update.NewValue, update.Status)
 	if err != nil {
 		return err
 	}
@@ -463,10 +551,12 @@ func (r *TripRepository) CreateTripUpdate(update *model.TripUpdate) error {
 }
 
 // GetPendingUpdatesByTripID returns pending update requests for a trip
-func (r *TripRepository) GetPendingUpdatesByTripID(tripID uint64) ([]*model.TripUpdate, error) {
+func (r *TripRepository) GetPendingUpdatesByTripID(ctx context.Context, tripID uint64) (_ []*model.TripUpdate, err error) {
 	query := `SELECT id, trip_id, user_id, update_type, old_value, new_value, status, reject_reason, created_at, updated_at
 		FROM trip_updates WHERE trip_id = ? AND status = 0 ORDER BY created_at DESC`
-	rows, err := database.DB.Query(query, tripID)
+	ctx, span := database.StartSpan(ctx, "TripRepository.GetPendingUpdatesByTripID", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, tripID)
 	if err != nil {
 		return nil, err
 	}
@@ -483,3 +573,80 @@ func (r *TripRepository) GetPendingUpdatesByTripID(tripID uint64) ([]*model.Trip
 	}
 	return updates, nil
 }
+
+// AggregateRouteSupplyDemand counts driver and passenger trips per route departing in [from, to)
+func (r *TripRepository) AggregateRouteSupplyDemand(ctx context.Context, req *model.RouteSupplyDemandReq, from, to time.Time) (_ []*model.RouteSupplyDemand, err error) {
+	conditions := []string{"departure_time >= ?", "departure_time < ?"}
+	args := []interface{}{from, to}
+
+	if len(req.Statuses) > 0 {
+		conditions = append(conditions, "status IN ("+strings.TrimSuffix(strings.Repeat("?,", len(req.Statuses)), ",")+")")
+		for _, status := range req.Statuses {
+			args = append(args, status)
+		}
+	}
+	if req.DepartureCity != "" {
+		conditions = append(conditions, "departure_city = ?")
+		args = append(args, req.DepartureCity)
+	}
+	if req.DestinationCity != "" {
+		conditions = append(conditions, "destination_city = ?")
+		args = append(args, req.DestinationCity)
+	}
+	if req.City != "" {
+		conditions = append(conditions, "(departure_city = ? OR destination_city = ?)")
+		args = append(args, req.City, req.City)
+	}
+
+	groupColumns := "departure_city, destination_city"
+	dateColumn := "''"
+	if req.GroupByDate {
+		dateColumn = "DATE_FORMAT(departure_time, '%Y-%m-%d')"
+		groupColumns += ", " + dateColumn
+	}
+
+	var orderClause string
+	switch req.Sort {
+	case model.RouteSortSurplus:
+		orderClause = "SUM(trip_type = 1) / GREATEST(SUM(trip_type = 2), 1) DESC, COUNT(*) DESC"
+	case model.RouteSortVolume:
+		orderClause = "COUNT(*) DESC"
+	default:
+		orderClause = "SUM(trip_type = 2) / GREATEST(SUM(trip_type = 1), 1) DESC, COUNT(*) DESC"
+	}
+
+	query := fmt.Sprintf(`SELECT departure_city, destination_city, %s,
+		SUM(trip_type = ?), SUM(CASE WHEN trip_type = ? THEN seats ELSE 0 END),
+		SUM(trip_type = ?), SUM(CASE WHEN trip_type = ? THEN seats ELSE 0 END)
+		FROM trips WHERE %s
+		GROUP BY %s
+		HAVING COUNT(*) >= ?
+		ORDER BY %s
+		LIMIT ?`, dateColumn, strings.Join(conditions, " AND "), groupColumns, orderClause)
+	args = append([]interface{}{model.TripTypeDriver, model.TripTypeDriver, model.TripTypePassenger, model.TripTypePassenger}, args...)
+	args = append(args, req.MinTrips, req.Limit)
+
+	ctx, span := database.StartSpan(ctx, "TripRepository.AggregateRouteSupplyDemand", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var routes []*model.RouteSupplyDemand
+	for rows.Next() {
+		route := &model.RouteSupplyDemand{}
+		if err = rows.Scan(&route.DepartureCity, &route.DestinationCity, &route.Date,
+			&route.Supply, &route.SupplySeats, &route.Demand, &route.DemandSeats); err != nil {
+			return nil, err
+		}
+		supply := route.Supply
+		if supply == 0 {
+			supply = 1
+		}
+		route.Ratio = float64(route.Demand) / float64(supply)
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
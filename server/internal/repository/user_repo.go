@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
@@ -9,6 +10,7 @@ import (
 
 	"pinche/internal/database"
 	"pinche/internal/model"
+	"pinche/internal/tracing"
 )
 
 type UserRepository struct{}
@@ -24,12 +26,14 @@ func generateOpenID() string {
 	return hex.EncodeToString(bytes)
 }
 
-func (r *UserRepository) Create(user *model.User) error {
+func (r *UserRepository) Create(ctx context.Context, user *model.User) (err error) {
 	// generate unique open_id
 	user.OpenID = generateOpenID()
 
 	query := `INSERT INTO users (open_id, phone, password, nickname, avatar, gender, status, city, province) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	result, err := database.DB.Exec(query, user.OpenID, user.Phone, user.Password, user.Nickname, user.Avatar, user.Gender, 0, user.City, user.Province)
+	ctx, span := database.StartSpan(ctx, "UserRepository.Create", query)
+	defer tracing.End(span, &err)
+	result, err := database.DB.ExecContext(ctx, query, user.OpenID, user.Phone, user.Password, user.Nickname, user.Avatar, user.Gender, 0, user.City, user.Province)
 	if err != nil {
 		return err
 	}
@@ -41,7 +45,7 @@ func (r *UserRepository) Create(user *model.User) error {
 	return nil
 }
 
-func (r *UserRepository) GetByID(id uint64) (*model.User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, id uint64) (_ *model.User, err error) {
 	query := `SELECT id, open_id, phone, password, nickname, avatar, gender, 
 		COALESCE(status, 0), COALESCE(city, ''), COALESCE(province, ''),
 		COALESCE(contact_phone, ''), COALESCE(contact_wechat, ''),
@@ -49,7 +53,9 @@ func (r *UserRepository) GetByID(id uint64) (*model.User, error) {
 		COALESCE(car_number, ''), COALESCE(car_brand, ''), COALESCE(car_model, ''), COALESCE(car_color, ''),
 		created_at, updated_at FROM users WHERE id = ?`
 	user := &model.User{}
-	err := database.DB.QueryRow(query, id).Scan(
+	ctx, span := database.StartSpan(ctx, "UserRepository.GetByID", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, id).Scan(
 		&user.ID, &user.OpenID, &user.Phone, &user.Password, &user.Nickname,
 		&user.Avatar, &user.Gender, &user.Status, &user.City, &user.Province,
 		&user.ContactPhone, &user.ContactWechat,
@@ -66,7 +72,7 @@ func (r *UserRepository) GetByID(id uint64) (*model.User, error) {
 	return user, nil
 }
 
-func (r *UserRepository) GetByOpenID(openID string) (*model.User, error) {
+func (r *UserRepository) GetByOpenID(ctx context.Context, openID string) (_ *model.User, err error) {
 	query := `SELECT id, open_id, phone, password, nickname, avatar, gender, 
 		COALESCE(status, 0), COALESCE(city, ''), COALESCE(province, ''),
 		COALESCE(contact_phone, ''), COALESCE(contact_wechat, ''),
@@ -74,7 +80,9 @@ func (r *UserRepository) GetByOpenID(openID string) (*model.User, error) {
 		COALESCE(car_number, ''), COALESCE(car_brand, ''), COALESCE(car_model, ''), COALESCE(car_color, ''),
 		created_at, updated_at FROM users WHERE open_id = ?`
 	user := &model.User{}
-	err := database.DB.QueryRow(query, openID).Scan(
+	ctx, span := database.StartSpan(ctx, "UserRepository.GetByOpenID", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, openID).Scan(
 		&user.ID, &user.OpenID, &user.Phone, &user.Password, &user.Nickname,
 		&user.Avatar, &user.Gender, &user.Status, &user.City, &user.Province,
 		&user.ContactPhone, &user.ContactWechat,
@@ -91,7 +99,7 @@ func (r *UserRepository) GetByOpenID(openID string) (*model.User, error) {
 	return user, nil
 }
 
-func (r *UserRepository) GetByPhone(phone string) (*model.User, error) {
+func (r *UserRepository) GetByPhone(ctx context.Context, phone string) (_ *model.User, err error) {
 	query := `SELECT id, open_id, phone, password, nickname, avatar, gender, 
 		COALESCE(status, 0), COALESCE(city, ''), COALESCE(province, ''),
 		COALESCE(contact_phone, ''), COALESCE(contact_wechat, ''),
@@ -99,7 +107,9 @@ func (r *UserRepository) GetByPhone(phone string) (*model.User, error) {
 		COALESCE(car_number, ''), COALESCE(car_brand, ''), COALESCE(car_model, ''), COALESCE(car_color, ''),
 		created_at, updated_at FROM users WHERE phone = ?`
 	user := &model.User{}
-	err := database.DB.QueryRow(query, phone).Scan(
+	ctx, span := database.StartSpan(ctx, "UserRepository.GetByPhone", query)
+	defer tracing.End(span, &err)
+	err = database.DB.QueryRowContext(ctx, query, phone).Scan(
 		&user.ID, &user.OpenID, &user.Phone, &user.Password, &user.Nickname,
 		&user.Avatar, &user.Gender, &user.Status, &user.City, &user.Province,
 		&user.ContactPhone, &user.ContactWechat,
@@ -116,13 +126,15 @@ func (r *UserRepository) GetByPhone(phone string) (*model.User, error) {
 	return user, nil
 }
 
-func (r *UserRepository) Update(user *model.User) error {
+func (r *UserRepository) Update(ctx context.Context, user *model.User) (err error) {
 	query := `UPDATE users SET nickname = ?, avatar = ?, gender = ?, city = ?, province = ?,
 		contact_phone = ?, contact_wechat = ?,
 		emergency_contact_name = ?, emergency_contact_phone = ?, emergency_contact_relation = ?,
 		car_number = ?, car_brand = ?, car_model = ?, car_color = ?
 		WHERE id = ?`
-	_, err := database.DB.Exec(query, user.Nickname, user.Avatar, user.Gender, user.City, user.Province,
+	ctx, span := database.StartSpan(ctx, "UserRepository.Update", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, user.Nickname, user.Avatar, user.Gender, user.City, user.Province,
 		user.ContactPhone, user.ContactWechat,
 		user.EmergencyContactName, user.EmergencyContactPhone, user.EmergencyContactRelation,
 		user.CarNumber, user.CarBrand, user.CarModel, user.CarColor,
@@ -130,20 +142,71 @@ func (r *UserRepository) Update(user *model.User) error {
 	return err
 }
 
-func (r *UserRepository) UpdateStatus(id uint64, status int8) error {
+func (r *UserRepository) UpdateStatus(ctx context.Context, id uint64, status int8) (err error) {
 	query := `UPDATE users SET status = ? WHERE id = ?`
-	_, err := database.DB.Exec(query, status, id)
+	ctx, span := database.StartSpan(ctx, "UserRepository.UpdateStatus", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, status, id)
 	return err
 }
 
-func (r *UserRepository) UpdateStatusByOpenID(openID string, status int8) error {
+func (r *UserRepository) UpdateStatusByOpenID(ctx context.Context, openID string, status int8) (err error) {
 	query := `UPDATE users SET status = ? WHERE open_id = ?`
-	_, err := database.DB.Exec(query, status, openID)
+	ctx, span := database.StartSpan(ctx, "UserRepository.UpdateStatusByOpenID", query)
+	defer tracing.End(span, &err)
+	_, err = database.DB.ExecContext(ctx, query, status, openID)
 	return err
 }
 
 // ListAll returns all users for admin panel
-func (r *UserRepository) ListAll(req *model.AdminUserListReq) ([]*model.User, int64, error) {
+func (r *UserRepository) ListAll(ctx context.Context, req *model.AdminUserListReq) (_ []*model.User, _ int64, err error) {
+	whereClause, args := adminUserFilter(req)
+
+	// count
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM users %s", whereClause)
+	var total int64
+	ctx, span := database.StartSpan(ctx, "UserRepository.ListAll", countQuery)
+	defer tracing.End(span, &err)
+	if err = database.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// list
+	offset := (req.Page - 1) * req.PageSize
+	listQuery := fmt.Sprintf(`SELECT id, open_id, phone, password, nickname, avatar, gender, 
+		COALESCE(status, 0), COALESCE(city, ''), COALESCE(province, ''),
+		COALESCE(contact_phone, ''), COALESCE(contact_wechat, ''),
+		COALESCE(emergency_contact_name, ''), COALESCE(emergency_contact_phone, ''), COALESCE(emergency_contact_relation, ''),
+		COALESCE(car_number, ''), COALESCE(car_brand, ''), COALESCE(car_model, ''), COALESCE(car_color, ''),
+		created_at, updated_at 
+		FROM users %s ORDER BY created_at DESC LIMIT ? OFFSET ?`, whereClause)
+	args = append(args, req.PageSize, offset)
+
+	rows, err := database.DB.QueryContext(ctx, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []*model.User
+	for rows.Next() {
+		user := &model.User{}
+		err := rows.Scan(&user.ID, &user.OpenID, &user.Phone, &user.Password, &user.Nickname, &user.Avatar, &user.Gender, &user.Status, &user.City, &user.Province,
+			&user.ContactPhone, &user.ContactWechat,
+			&user.EmergencyContactName, &user.EmergencyContactPhone, &user.EmergencyContactRelation,
+			&user.CarNumber, &user.CarBrand, &user.CarModel, &user.CarColor,
+			&user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		user.Password = ""
+		users = append(users, user)
+	}
+	return users, total, nil
+}
+
+// adminUserFilter builds the WHERE clause shared by the admin user list and export
+func adminUserFilter(req *model.AdminUserListReq) (string, []interface{}) {
 	var conditions []string
 	var args []interface{}
 
@@ -168,111 +231,109 @@ func (r *UserRepository) ListAll(req *model.AdminUserListReq) ([]*model.User, in
 	if len(conditions) > 0 {
 		whereClause = "WHERE " + strings.Join(conditions, " AND ")
 	}
+	return whereClause, args
+}
 
-	// count
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM users %s", whereClause)
-	var total int64
-	if err := database.DB.QueryRow(countQuery, args...).Scan(&total); err != nil {
-		return nil, 0, err
-	}
-
-	// list
-	offset := (req.Page - 1) * req.PageSize
-	listQuery := fmt.Sprintf(`SELECT id, open_id, phone, password, nickname, avatar, gender, 
+// IterateAdmin streams all users matching the admin filters to fn, newest first
+// Rows are not buffered so exports run in constant memory
+func (r *UserRepository) IterateAdmin(ctx context.Context, req *model.AdminUserListReq, fn func(*model.User) error) (err error) {
+	whereClause, args := adminUserFilter(req)
+	query := fmt.Sprintf(`SELECT id, open_id, phone, nickname, gender,
 		COALESCE(status, 0), COALESCE(city, ''), COALESCE(province, ''),
 		COALESCE(contact_phone, ''), COALESCE(contact_wechat, ''),
-		COALESCE(emergency_contact_name, ''), COALESCE(emergency_contact_phone, ''), COALESCE(emergency_contact_relation, ''),
 		COALESCE(car_number, ''), COALESCE(car_brand, ''), COALESCE(car_model, ''), COALESCE(car_color, ''),
-		created_at, updated_at 
-		FROM users %s ORDER BY created_at DESC LIMIT ? OFFSET ?`, whereClause)
-	args = append(args, req.PageSize, offset)
+		created_at, updated_at
+		FROM users %s ORDER BY created_at DESC`, whereClause)
 
-	rows, err := database.DB.Query(listQuery, args...)
+	ctx, span := database.StartSpan(ctx, "UserRepository.IterateAdmin", query)
+	defer tracing.End(span, &err)
+	rows, err := database.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, 0, err
+		return err
 	}
 	defer rows.Close()
 
-	var users []*model.User
 	for rows.Next() {
 		user := &model.User{}
-		err := rows.Scan(&user.ID, &user.OpenID, &user.Phone, &user.Password, &user.Nickname, &user.Avatar, &user.Gender, &user.Status, &user.City, &user.Province,
+		err := rows.Scan(&user.ID, &user.OpenID, &user.Phone, &user.Nickname, &user.Gender, &user.Status, &user.City, &user.Province,
 			&user.ContactPhone, &user.ContactWechat,
-			&user.EmergencyContactName, &user.EmergencyContactPhone, &user.EmergencyContactRelation,
 			&user.CarNumber, &user.CarBrand, &user.CarModel, &user.CarColor,
 			&user.CreatedAt, &user.UpdatedAt)
 		if err != nil {
-			return nil, 0, err
+			return err
+		}
+		if err = fn(user); err != nil {
+			return err
 		}
-		user.Password = ""
-		users = append(users, user)
 	}
-	return users, total, nil
+	return rows.Err()
 }
 
 // GetStats returns statistics for admin dashboard
-func (r *UserRepository) GetStats() (map[string]interface{}, error) {
+func (r *UserRepository) GetStats(ctx context.Context) (_ map[string]interface{}, err error) {
+	ctx, span := database.StartSpan(ctx, "UserRepository.GetStats", "")
+	defer tracing.End(span, &err)
 	stats := make(map[string]interface{})
 
 	// total users
 	var totalUsers int64
-	if err := database.DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&totalUsers); err != nil {
+	if err = database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&totalUsers); err != nil {
 		return nil, err
 	}
 	stats["total_users"] = totalUsers
 
 	// banned users
 	var bannedUsers int64
-	if err := database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE status = 1").Scan(&bannedUsers); err != nil {
+	if err = database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE status = 1").Scan(&bannedUsers); err != nil {
 		return nil, err
 	}
 	stats["banned_users"] = bannedUsers
 
 	// total trips
 	var totalTrips int64
-	if err := database.DB.QueryRow("SELECT COUNT(*) FROM trips").Scan(&totalTrips); err != nil {
+	if err = database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM trips").Scan(&totalTrips); err != nil {
 		return nil, err
 	}
 	stats["total_trips"] = totalTrips
 
 	// active trips (pending)
 	var activeTrips int64
-	if err := database.DB.QueryRow("SELECT COUNT(*) FROM trips WHERE status = 1").Scan(&activeTrips); err != nil {
+	if err = database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM trips WHERE status = 1").Scan(&activeTrips); err != nil {
 		return nil, err
 	}
 	stats["active_trips"] = activeTrips
 
 	// banned trips
 	var bannedTrips int64
-	if err := database.DB.QueryRow("SELECT COUNT(*) FROM trips WHERE status = 5").Scan(&bannedTrips); err != nil {
+	if err = database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM trips WHERE status = 5").Scan(&bannedTrips); err != nil {
 		return nil, err
 	}
 	stats["banned_trips"] = bannedTrips
 
 	// total matches
 	var totalMatches int64
-	if err := database.DB.QueryRow("SELECT COUNT(*) FROM matches").Scan(&totalMatches); err != nil {
+	if err = database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM matches").Scan(&totalMatches); err != nil {
 		return nil, err
 	}
 	stats["total_matches"] = totalMatches
 
 	// successful matches
 	var successMatches int64
-	if err := database.DB.QueryRow("SELECT COUNT(*) FROM matches WHERE status = 1").Scan(&successMatches); err != nil {
+	if err = database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM matches WHERE status = 1").Scan(&successMatches); err != nil {
 		return nil, err
 	}
 	stats["success_matches"] = successMatches
 
 	// today new users
 	var todayUsers int64
-	if err := database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE DATE(created_at) = CURDATE()").Scan(&todayUsers); err != nil {
+	if err = database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE DATE(created_at) = CURDATE()").Scan(&todayUsers); err != nil {
 		return nil, err
 	}
 	stats["today_users"] = todayUsers
 
 	// today new trips
 	var todayTrips int64
-	if err := database.DB.QueryRow("SELECT COUNT(*) FROM trips WHERE DATE(created_at) = CURDATE()").Scan(&todayTrips); err != nil {
+	if err = database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM trips WHERE DATE(created_at) = CURDATE()").Scan(&todayTrips); err != nil {
 		return nil, err
 	}
 	stats["today_trips"] = todayTrips
@@ -1,33 +1,76 @@
 package router
 
 import (
+	"context"
+	"time"
+
 	"pinche/config"
 	"pinche/internal/handler"
+	"pinche/internal/job"
+	"pinche/internal/logger"
 	"pinche/internal/middleware"
+	"pinche/internal/model"
 	"pinche/internal/service"
 	"pinche/internal/websocket"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func Setup(cfg *config.Config, wsHub *websocket.Hub) *gin.Engine {
+func Setup(cfg *config.Config, wsHub *websocket.Hub, scheduler *job.Scheduler, workers *job.Group, healthService *service.HealthService) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
 	r.Use(gin.Recovery())
+
+	// probes and metrics scrapes, registered before the tracing, logging and metrics middlewares so they do not flood them
+	healthHandler := handler.NewHealthHandler(healthService)
+	r.GET("/healthz", healthHandler.Healthz)
+	r.GET("/readyz", healthHandler.Readyz)
+	if cfg.Metrics.Enabled && cfg.Metrics.Token == "" {
+		logger.Warn("Metrics enabled without METRICS_TOKEN, /metrics is not served")
+	} else if cfg.Metrics.Enabled {
+		r.GET("/metrics", middleware.MetricsAuthMiddleware(cfg.Metrics.Token), gin.WrapH(promhttp.Handler()))
+	}
+
+	r.Use(middleware.TracingMiddleware())
 	r.Use(middleware.CorsMiddleware())
 	r.Use(middleware.LoggingMiddleware())
+	r.Use(middleware.MetricsMiddleware())
 
 	// serve uploaded files as static
 	r.Static("/api/uploads", "./uploads")
 
 	// services
-	userService := service.NewUserService(cfg)
-	matchService := service.NewMatchService(wsHub)
-	tripService := service.NewTripService(matchService, wsHub)
+	moderationService := service.NewModerationService(cfg)
+	userService := service.NewUserService(cfg, moderationService)
+	groupService := service.NewGroupService(moderationService, wsHub)
+	matchService := service.NewMatchService(groupService, wsHub)
+	tripService := service.NewTripService(matchService, groupService, moderationService, wsHub, workers)
 	notificationService := service.NewNotificationService()
-	messageService := service.NewMessageService()
-	announcementService := service.NewAnnouncementService()
+	messageService := service.NewMessageService(cfg, moderationService)
+	friendService := service.NewFriendService(moderationService)
+	announcementService := service.NewAnnouncementService(wsHub)
 	uploadService := service.NewUploadService(cfg)
+	adminService := service.NewAdminService(cfg)
+	auditService := service.NewAuditService()
+	banService := service.NewBanService(userService, tripService, wsHub)
+	statsService := service.NewStatsService(cfg)
+	exportService := service.NewExportService()
+	broadcastService := service.NewBroadcastService(cfg, wsHub)
+	locationService := service.NewLocationService(cfg, wsHub)
+	captchaService := service.NewCaptchaService()
+
+	// create initial super admin from config if there is none
+	if err := adminService.EnsureBootstrapAdmin(context.Background()); err != nil {
+		logger.Error("Ensure bootstrap admin failed", "error", err)
+	}
+
+	// background jobs
+	scheduler.Every("ban_expiry", time.Duration(cfg.Job.BanExpiryInterval)*time.Second, banService.ExpireBans)
+	scheduler.Every("stats_rollup", time.Duration(cfg.Job.StatsRollupInterval)*time.Second, statsService.Rollup)
+	scheduler.Every("announcement_push", time.Duration(cfg.Job.AnnouncementPushInterval)*time.Second, announcementService.PushUrgent)
+	scheduler.Every("broadcast_delivery", time.Duration(cfg.Job.BroadcastInterval)*time.Second, broadcastService.Deliver)
+	scheduler.Every("sensitive_word_reload", time.Duration(cfg.Job.SensitiveWordInterval)*time.Second, moderationService.ReloadWords)
 
 	// handlers
 	userHandler := handler.NewUserHandler(userService, cfg)
@@ -38,14 +81,42 @@ func Setup(cfg *config.Config, wsHub *websocket.Hub) *gin.Engine {
 	wsHandler := handler.NewWebSocketHandler(wsHub, userService)
 	announcementHandler := handler.NewAnnouncementHandler(announcementService)
 	uploadHandler := handler.NewUploadHandler(uploadService, userService)
-	friendHandler := handler.NewFriendHandler()
+	friendHandler := handler.NewFriendHandler(friendService)
+	adminHandler := handler.NewAdminHandler(adminService, cfg)
+	auditHandler := handler.NewAuditHandler(auditService)
+	banHandler := handler.NewBanHandler(banService)
+	statsHandler := handler.NewStatsHandler(statsService)
+	exportHandler := handler.NewExportHandler(exportService)
+	broadcastHandler := handler.NewBroadcastHandler(broadcastService)
+	groupHandler := handler.NewGroupHandler(groupService)
+	moderationHandler := handler.NewModerationHandler(moderationService)
+	locationHandler := handler.NewLocationHandler(locationService)
+	captchaHandler := handler.NewCaptchaHandler(captchaService)
+
+	// muted conversations still get new messages, without alerts
+	wsHub.SetMuteChecker(messageService.IsConversationMuted)
+
+	// websocket frames from clients
+	wsHub.Handle("read", messageHandler.HandleReadFrame)
+	wsHub.Handle("send_message", messageHandler.HandleSendFrame)
+	wsHub.Handle("live_location", locationHandler.HandleLiveLocationFrame)
+	wsHub.Handle("live_location_stop", locationHandler.HandleLiveLocationStopFrame)
+
+	// rate limits of abuse-prone routes
+	limiter := middleware.NewRateLimiter(&cfg.RateLimit)
 
 	// public routes
-	r.POST("/api/user/register", userHandler.Register)
-	r.POST("/api/user/login", userHandler.Login)
+	r.POST("/api/user/register", limiter.Limit("register", cfg.RateLimit.Register, middleware.RateLimitByIP), userHandler.Register)
+	r.POST("/api/user/login",
+		limiter.Limit("login_ip", cfg.RateLimit.LoginIP, middleware.RateLimitByIP),
+		limiter.Limit("login_phone", cfg.RateLimit.LoginPhone, middleware.RateLimitByPhone),
+		userHandler.Login)
+	r.POST("/api/user/appeal", limiter.Limit("appeal", cfg.RateLimit.Appeal, middleware.RateLimitByIP), banHandler.CreateBannedUserAppeal)
+	r.GET("/api/captcha", limiter.Limit("captcha", cfg.RateLimit.Captcha, middleware.RateLimitByIP), captchaHandler.Create)
 	r.GET("/api/trips", tripHandler.List)
 	r.GET("/api/trips/:id", tripHandler.GetByID)
-	r.GET("/api/announcements", announcementHandler.GetActiveAnnouncements)
+	r.GET("/api/routes/hot", tripHandler.GetHotRoutes)
+	r.GET("/api/announcements", middleware.OptionalAuthMiddleware(userService), announcementHandler.GetActiveAnnouncements)
 
 	// websocket
 	r.GET("/ws", wsHandler.HandleConnection)
@@ -66,28 +137,53 @@ func Setup(cfg *config.Config, wsHub *websocket.Hub) *gin.Engine {
 		auth.PUT("/trips/:id/cancel", tripHandler.Cancel)
 		auth.PUT("/trips/:id/complete", tripHandler.Complete)
 		auth.DELETE("/trips/:id", tripHandler.Delete)
-		auth.POST("/trips/:id/grab", tripHandler.GrabTrip)
+		auth.POST("/trips/:id/grab", limiter.Limit("grab", cfg.RateLimit.Grab, middleware.RateLimitByUser), tripHandler.GrabTrip)
+		auth.POST("/trips/:id/grabs/:user_id/accept", tripHandler.AcceptGrab)
 
 		// matches
 		auth.GET("/matches", matchHandler.GetMyMatches)
 		auth.GET("/matches/:id", matchHandler.GetByID)
 		auth.POST("/matches/:id/confirm", matchHandler.Confirm)
 		auth.GET("/matches/:id/contact", matchHandler.GetContactInfo)
+		auth.GET("/matches/:id/live-location", locationHandler.GetLiveLocationStatus)
 
 		// notifications
 		auth.GET("/notifications", notificationHandler.GetList)
 		auth.PUT("/notifications/:id/read", notificationHandler.MarkAsRead)
 		auth.PUT("/notifications/read-all", notificationHandler.MarkAllAsRead)
 
+		// announcements
+		auth.POST("/announcements/:id/dismiss", announcementHandler.Dismiss)
+
+		// bans and appeals
+		auth.GET("/bans", banHandler.GetMyBans)
+		auth.POST("/appeals", banHandler.CreateAppeal)
+
 		// messages
 		auth.POST("/messages", messageHandler.SendMessage)
 		auth.GET("/messages", messageHandler.GetConversationMessages)
 		auth.GET("/conversations", messageHandler.GetConversations)
+		auth.GET("/conversations/:peer_id/settings", messageHandler.GetConversationSetting)
+		auth.PUT("/conversations/:peer_id/settings", messageHandler.UpdateConversationSetting)
 		auth.PUT("/messages/read", messageHandler.MarkAsRead)
 		auth.GET("/messages/unread-count", messageHandler.GetUnreadCount)
+		auth.GET("/messages/search", messageHandler.SearchMessages)
+		auth.POST("/messages/:id/recall", messageHandler.RecallMessage)
+		auth.POST("/messages/:id/forward", messageHandler.ForwardMessage)
+		auth.DELETE("/messages/:id", messageHandler.DeleteMessage)
+		auth.GET("/sync", messageHandler.Sync)
+
+		// trip group chats
+		auth.GET("/groups", groupHandler.GetMyGroups)
+		auth.GET("/groups/:id", groupHandler.GetByID)
+		auth.GET("/groups/:id/messages", groupHandler.GetMessages)
+		auth.POST("/groups/:id/messages", groupHandler.SendMessage)
+		auth.PUT("/groups/:id/read", groupHandler.MarkAsRead)
+		auth.PUT("/groups/:id/settings", groupHandler.UpdateSetting)
+		auth.POST("/groups/:id/leave", groupHandler.Leave)
 
 		// upload
-		auth.POST("/upload", uploadHandler.Upload)
+		auth.POST("/upload", limiter.Limit("upload", cfg.RateLimit.Upload, middleware.RateLimitByUser), uploadHandler.Upload)
 		auth.GET("/resource/url", uploadHandler.GetSignedURL)
 
 		// friends
@@ -103,25 +199,64 @@ func Setup(cfg *config.Config, wsHub *websocket.Hub) *gin.Engine {
 	}
 
 	// admin routes
-	r.POST("/api/admin/login", userHandler.AdminLogin)
+	r.POST("/api/admin/login", limiter.Limit("admin_login", cfg.RateLimit.AdminLogin, middleware.RateLimitByIP), adminHandler.Login)
 
 	admin := r.Group("/api/admin")
-	admin.Use(middleware.AdminAuthMiddleware(cfg))
+	admin.Use(middleware.AdminAuthMiddleware(cfg, adminService))
+	admin.Use(middleware.AdminAuditMiddleware(auditService))
 	{
-		admin.GET("/announcements", announcementHandler.ListAll)
-		admin.POST("/announcements", announcementHandler.Create)
-		admin.PUT("/announcements/:id", announcementHandler.Update)
-		admin.DELETE("/announcements/:id", announcementHandler.Delete)
+		admin.GET("/me", adminHandler.GetMe)
+
+		admin.GET("/announcements", middleware.RequireAdminPermission(model.AdminPermAnnouncementView), announcementHandler.ListAll)
+		admin.POST("/announcements", middleware.RequireAdminPermission(model.AdminPermAnnouncementManage), announcementHandler.Create)
+		admin.PUT("/announcements/:id", middleware.RequireAdminPermission(model.AdminPermAnnouncementManage), announcementHandler.Update)
+		admin.DELETE("/announcements/:id", middleware.RequireAdminPermission(model.AdminPermAnnouncementManage), announcementHandler.Delete)
+
+		admin.GET("/broadcasts", middleware.RequireAdminPermission(model.AdminPermBroadcastManage), broadcastHandler.List)
+		admin.POST("/broadcasts", middleware.RequireAdminPermission(model.AdminPermBroadcastManage), broadcastHandler.Create)
+		admin.GET("/broadcasts/:id", middleware.RequireAdminPermission(model.AdminPermBroadcastManage), broadcastHandler.GetByID)
+		admin.POST("/broadcasts/:id/cancel", middleware.RequireAdminPermission(model.AdminPermBroadcastManage), broadcastHandler.Cancel)
+
+		admin.GET("/sensitive-words", middleware.RequireAdminPermission(model.AdminPermModeration), moderationHandler.ListWords)
+		admin.POST("/sensitive-words", middleware.RequireAdminPermission(model.AdminPermModeration), moderationHandler.CreateWord)
+		admin.PUT("/sensitive-words/:id", middleware.RequireAdminPermission(model.AdminPermModeration), moderationHandler.UpdateWord)
+		admin.DELETE("/sensitive-words/:id", middleware.RequireAdminPermission(model.AdminPermModeration), moderationHandler.DeleteWord)
+		admin.GET("/content-flags", middleware.RequireAdminPermission(model.AdminPermModeration), moderationHandler.ListFlags)
+		admin.POST("/content-flags/:id/approve", middleware.RequireAdminPermission(model.AdminPermModeration), moderationHandler.ApproveFlag)
+		admin.POST("/content-flags/:id/reject", middleware.RequireAdminPermission(model.AdminPermModeration), moderationHandler.RejectFlag)
+
+		admin.GET("/users", middleware.RequireAdminPermission(model.AdminPermUserView), userHandler.AdminListUsers)
+		admin.POST("/users/:id/ban", middleware.RequireAdminPermission(model.AdminPermUserBan), banHandler.AdminBanUser)
+		admin.POST("/users/:id/unban", middleware.RequireAdminPermission(model.AdminPermUserBan), banHandler.AdminUnbanUser)
+
+		admin.GET("/trips", middleware.RequireAdminPermission(model.AdminPermTripView), tripHandler.AdminListTrips)
+		admin.POST("/trips/:id/ban", middleware.RequireAdminPermission(model.AdminPermTripBan), banHandler.AdminBanTrip)
+		admin.POST("/trips/:id/unban", middleware.RequireAdminPermission(model.AdminPermTripBan), banHandler.AdminUnbanTrip)
+
+		admin.GET("/bans", middleware.RequireAdminPermission(model.AdminPermUserView), banHandler.AdminListBans)
+		admin.GET("/appeals", middleware.RequireAdminPermission(model.AdminPermAppealReview), banHandler.AdminListAppeals)
+		admin.POST("/appeals/:id/approve", middleware.RequireAdminPermission(model.AdminPermAppealReview), banHandler.AdminApproveAppeal)
+		admin.POST("/appeals/:id/reject", middleware.RequireAdminPermission(model.AdminPermAppealReview), banHandler.AdminRejectAppeal)
+
+		admin.GET("/stats", middleware.RequireAdminPermission(model.AdminPermStatsView), userHandler.AdminGetStats)
+		admin.GET("/stats/timeseries", middleware.RequireAdminPermission(model.AdminPermStatsView), statsHandler.Timeseries)
+		admin.GET("/stats/funnel", middleware.RequireAdminPermission(model.AdminPermStatsView), statsHandler.Funnel)
+		admin.GET("/stats/routes", middleware.RequireAdminPermission(model.AdminPermStatsView), tripHandler.AdminRouteSupplyDemand)
 
-		admin.GET("/users", userHandler.AdminListUsers)
-		admin.POST("/users/:id/ban", userHandler.AdminBanUser)
-		admin.POST("/users/:id/unban", userHandler.AdminUnbanUser)
+		admin.GET("/export/users", middleware.RequireAdminPermission(model.AdminPermDataExport), exportHandler.ExportUsers)
+		admin.GET("/export/trips", middleware.RequireAdminPermission(model.AdminPermDataExport), exportHandler.ExportTrips)
+		admin.GET("/export/matches", middleware.RequireAdminPermission(model.AdminPermDataExport), exportHandler.ExportMatches)
+		admin.GET("/export/reports", middleware.RequireAdminPermission(model.AdminPermDataExport), exportHandler.ExportReports)
 
-		admin.GET("/trips", tripHandler.AdminListTrips)
-		admin.POST("/trips/:id/ban", tripHandler.AdminBanTrip)
-		admin.POST("/trips/:id/unban", tripHandler.AdminUnbanTrip)
+		// admin account management (super admin only)
+		admin.GET("/admins", middleware.RequireAdminPermission(model.AdminPermAdminManage), adminHandler.List)
+		admin.POST("/admins", middleware.RequireAdminPermission(model.AdminPermAdminManage), adminHandler.Create)
+		admin.PUT("/admins/:id/role", middleware.RequireAdminPermission(model.AdminPermAdminManage), adminHandler.UpdateRole)
+		admin.POST("/admins/:id/disable", middleware.RequireAdminPermission(model.AdminPermAdminManage), adminHandler.Disable)
+		admin.POST("/admins/:id/enable", middleware.RequireAdminPermission(model.AdminPermAdminManage), adminHandler.Enable)
+		admin.POST("/admins/:id/reset-password", middleware.RequireAdminPermission(model.AdminPermAdminManage), adminHandler.ResetPassword)
 
-		admin.GET("/stats", userHandler.AdminGetStats)
+		admin.GET("/audit-logs", middleware.RequireAdminPermission(model.AdminPermAuditView), auditHandler.List)
 	}
 
 	return r
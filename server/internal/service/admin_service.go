@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+
+	"pinche/config"
+	"pinche/internal/logger"
+	"pinche/internal/model"
+	"pinche/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type AdminService struct {
+	repo   *repository.AdminRepository
+	guard  *LoginGuard
+	config *config.Config
+}
+
+func NewAdminService(cfg *config.Config) *AdminService {
+	return &AdminService{
+		repo:   repository.NewAdminRepository(),
+		guard:  NewLoginGuard(cfg, "admin"),
+		config: cfg,
+	}
+}
+
+// md5Hash calculates MD5 hash of password, matching the frontend hashing
+func md5Hash(password string) string {
+	hash := md5.Sum([]byte(password))
+	return hex.EncodeToString(hash[:])
+}
+
+// EnsureBootstrapAdmin creates the initial super admin from ADMIN_USERNAME/ADMIN_PASSWORD
+// when no admin account exists yet
+func (s *AdminService) EnsureBootstrapAdmin(ctx context.Context) error {
+	count, err := s.repo.Count(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	if s.config.Admin.Password == "" {
+		logger.Warn("No admin account exists and ADMIN_PASSWORD is not configured, admin login is unavailable")
+		return nil
+	}
+
+	// frontend sends MD5 hashed password, bcrypt it for storage
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(md5Hash(s.config.Admin.Password)), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	admin := &model.Admin{
+		Username:  s.config.Admin.Username,
+		Password:  string(hashedPassword),
+		Role:      model.AdminRoleSuperAdmin,
+		Status:    model.AdminStatusActive,
+		CreatedBy: "bootstrap",
+	}
+	if err := s.repo.Create(ctx, admin); err != nil {
+		return err
+	}
+	logger.Info("Bootstrap super admin created", "admin_id", admin.ID, "username", admin.Username)
+	return nil
+}
+
+// ErrAdminDisabled rejects the login of a disabled admin whose password is correct
+var ErrAdminDisabled = errors.New("账号已被禁用，请联系超级管理员")
+
+// admin management failures shown to the operator as they are, any other failure is logged and reported as a fixed message
+var (
+	ErrInvalidAdminRole   = errors.New("无效的角色")
+	ErrAdminUsernameTaken = errors.New("用户名已存在")
+	ErrAdminNotFound      = errors.New("管理员不存在")
+	ErrDisableSelf        = errors.New("不能禁用自己的账号")
+	ErrLastSuperAdmin     = errors.New("至少需要保留一个可用的超级管理员")
+)
+
+// Login validates admin credentials, password is already MD5 hashed by frontend
+func (s *AdminService) Login(ctx context.Context, req *model.AdminLoginReq, clientIP string) (*model.Admin, error) {
+	username := req.Username
+	if err := s.guard.Check(ctx, username, clientIP, req.CaptchaID, req.CaptchaCode); err != nil {
+		logger.Warn("Admin login rejected by guard", "username", username, "client_ip", clientIP, "reason", err.Error())
+		return nil, err
+	}
+
+	admin, err := s.repo.GetByUsername(ctx, username)
+	if err != nil {
+		logger.Error("Get admin by username failed", "username", username, "error", err)
+		return nil, errors.New("登录失败")
+	}
+	if admin == nil {
+		logger.Warn("Admin login failed: admin not found", "username", username, "client_ip", clientIP)
+		loginErr, _ := s.guard.Fail(ctx, username, clientIP, "用户名或密码错误")
+		return nil, loginErr
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(req.Password)); err != nil {
+		logger.Warn("Admin login failed: wrong password", "admin_id", admin.ID, "username", username, "client_ip", clientIP)
+		loginErr, _ := s.guard.Fail(ctx, username, clientIP, "用户名或密码错误")
+		return nil, loginErr
+	}
+
+	if admin.Status != model.AdminStatusActive {
+		logger.Warn("Admin login failed: admin disabled", "admin_id", admin.ID, "username", username)
+		return nil, ErrAdminDisabled
+	}
+
+	s.guard.Succeed(ctx, username)
+	if err := s.repo.UpdateLastLogin(ctx, admin.ID, clientIP); err != nil {
+		logger.Error("Update admin last login failed", "admin_id", admin.ID, "error", err)
+	}
+
+	logger.Info("Admin logged in", "admin_id", admin.ID, "username", username, "role", admin.Role, "client_ip", clientIP)
+	return admin, nil
+}
+
+func (s *AdminService) GetByID(ctx context.Context, id uint64) (*model.Admin, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *AdminService) List(ctx context.Context, req *model.AdminListReq) (*model.AdminListResp, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 || req.PageSize > 100 {
+		req.PageSize = 20
+	}
+
+	admins, total, err := s.repo.List(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AdminListResp{
+		List:  admins,
+		Total: total,
+	}, nil
+}
+
+// Create creates a new admin account (super admin only)
+func (s *AdminService) Create(ctx context.Context, req *model.AdminCreateReq, operator string) (*model.Admin, error) {
+	if !model.IsValidAdminRole(req.Role) {
+		return nil, ErrInvalidAdminRole
+	}
+
+	existing, err := s.repo.GetByUsername(ctx, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrAdminUsernameTaken
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Error("Hash admin password failed", "error", err)
+		return nil, err
+	}
+
+	admin := &model.Admin{
+		Username:  req.Username,
+		Password:  string(hashedPassword),
+		Role:      req.Role,
+		Status:    model.AdminStatusActive,
+		CreatedBy: operator,
+	}
+	if err := s.repo.Create(ctx, admin); err != nil {
+		logger.Error("Create admin failed", "username", req.Username, "error", err)
+		return nil, err
+	}
+
+	logger.Info("Admin created", "admin_id", admin.ID, "username", admin.Username, "role", admin.Role, "operator", operator)
+	return s.repo.GetByID(ctx, admin.ID)
+}
+
+// SetDisabled disables or re-enables an admin account
+func (s *AdminService) SetDisabled(ctx context.Context, id uint64, disabled bool, operatorID uint64) (*model.Admin, error) {
+	admin, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if admin == nil {
+		return nil, ErrAdminNotFound
+	}
+
+	status := int8(model.AdminStatusActive)
+	if disabled {
+		if admin.ID == operatorID {
+			return nil, ErrDisableSelf
+		}
+		if err := s.ensureNotLastSuperAdmin(ctx, admin); err != nil {
+			return nil, err
+		}
+		status = model.AdminStatusDisabled
+	}
+
+	if err := s.repo.UpdateStatus(ctx, id, status); err != nil {
+		return nil, err
+	}
+	admin.Status = status
+	logger.Info("Admin status updated", "admin_id", id, "status", status, "operator_id", operatorID)
+	return admin, nil
+}
+
+// UpdateRole changes the role of an admin account
+func (s *AdminService) UpdateRole(ctx context.Context, id uint64, role string, operatorID uint64) (*model.Admin, error) {
+	if !model.IsValidAdminRole(role) {
+		return nil, ErrInvalidAdminRole
+	}
+
+	admin, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if admin == nil {
+		return nil, ErrAdminNotFound
+	}
+	if admin.Role == role {
+		return admin, nil
+	}
+	if role != model.AdminRoleSuperAdmin {
+		if err := s.ensureNotLastSuperAdmin(ctx, admin); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.UpdateRole(ctx, id, role); err != nil {
+		return nil, err
+	}
+	admin.Role = role
+	logger.Info("Admin role updated", "admin_id", id, "role", role, "operator_id", operatorID)
+	return admin, nil
+}
+
+// ResetPassword sets a new password for an admin account
+func (s *AdminService) ResetPassword(ctx context.Context, id uint64, hashedPassword string, operatorID uint64) error {
+	admin, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if admin == nil {
+		return ErrAdminNotFound
+	}
+
+	bcrypted, err := bcrypt.GenerateFromPassword([]byte(hashedPassword), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Error("Hash admin password failed", "error", err)
+		return err
+	}
+
+	if err := s.repo.UpdatePassword(ctx, id, string(bcrypted)); err != nil {
+		return err
+	}
+	logger.Info("Admin password reset", "admin_id", id, "operator_id", operatorID)
+	return nil
+}
+
+// ensureNotLastSuperAdmin prevents locking everyone out of admin management
+func (s *AdminService) ensureNotLastSuperAdmin(ctx context.Context, admin *model.Admin) error {
+	if admin.Role != model.AdminRoleSuperAdmin || admin.Status != model.AdminStatusActive {
+		return nil
+	}
+	count, err := s.repo.CountActiveByRole(ctx, model.AdminRoleSuperAdmin)
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return ErrLastSuperAdmin
+	}
+	return nil
+}
@@ -1,21 +1,43 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"slices"
 	"strings"
 	"time"
 
 	"pinche/internal/logger"
 	"pinche/internal/model"
 	"pinche/internal/repository"
+	"pinche/internal/websocket"
 )
 
+const (
+	// trips published within this window decide whether a user is a driver or passenger
+	announcementRoleWindow = 90 * 24 * time.Hour
+	// active announcements fetched before targeting is applied
+	maxActiveAnnouncements = 100
+	// online users whose targeting attributes are loaded per query when pushing urgent announcements
+	urgentPushBatchSize = 300
+)
+
+var validPlatforms = map[string]bool{
+	model.PlatformIOS:         true,
+	model.PlatformAndroid:     true,
+	model.PlatformWeb:         true,
+	model.PlatformMiniProgram: true,
+}
+
 type AnnouncementService struct {
-	repo *repository.AnnouncementRepository
+	repo  *repository.AnnouncementRepository
+	wsHub *websocket.Hub
 }
 
-func NewAnnouncementService() *AnnouncementService {
+func NewAnnouncementService(wsHub *websocket.Hub) *AnnouncementService {
 	return &AnnouncementService{
-		repo: repository.NewAnnouncementRepository(),
+		repo:  repository.NewAnnouncementRepository(),
+		wsHub: wsHub,
 	}
 }
 
@@ -47,7 +69,80 @@ func parseTimeString(s string) time.Time {
 	return time.Time{}
 }
 
-func (s *AnnouncementService) Create(req *model.AnnouncementCreateReq) (*model.Announcement, error) {
+// normalizeTargetList cleans a comma separated target list, accepting full-width commas
+func normalizeTargetList(s string) []string {
+	s = strings.ReplaceAll(s, "，", ",")
+	seen := make(map[string]bool)
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		items = append(items, item)
+	}
+	return items
+}
+
+// normalizePlatforms validates and cleans the target platform list
+func normalizePlatforms(s string) (string, error) {
+	platforms := normalizeTargetList(strings.ToLower(s))
+	for _, p := range platforms {
+		if !validPlatforms[p] {
+			return "", errors.New("不支持的平台: " + p)
+		}
+	}
+	return strings.Join(platforms, ","), nil
+}
+
+func containsTarget(list, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, item := range strings.Split(list, ",") {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// announcementMatches reports whether the announcement targets the audience
+// Province and city targets are alternatives, so a user matches if either does
+func announcementMatches(ann *model.Announcement, aud *model.AnnouncementAudience) bool {
+	if ann.TargetProvinces != "" || ann.TargetCities != "" {
+		if !containsTarget(ann.TargetProvinces, aud.Province) && !containsTarget(ann.TargetCities, aud.City) {
+			return false
+		}
+	}
+	switch ann.TargetRole {
+	case model.AnnouncementRoleDriver:
+		if !aud.IsDriver {
+			return false
+		}
+	case model.AnnouncementRolePassenger:
+		if !aud.IsPassenger {
+			return false
+		}
+	}
+	if ann.TargetPlatforms != "" && !containsTarget(ann.TargetPlatforms, aud.Platform) {
+		return false
+	}
+	return true
+}
+
+// needsAudience reports whether matching the announcement requires user attributes
+func needsAudience(ann *model.Announcement) bool {
+	return ann.TargetProvinces != "" || ann.TargetCities != "" || ann.TargetRole != model.AnnouncementRoleAll
+}
+
+func (s *AnnouncementService) Create(ctx context.Context, req *model.AnnouncementCreateReq) (*model.Announcement, error) {
+	platforms, err := normalizePlatforms(req.TargetPlatforms)
+	if err != nil {
+		return nil, err
+	}
+
 	startTime := parseTimeString(req.StartTime)
 	endTime := parseTimeString(req.EndTime)
 
@@ -67,6 +162,11 @@ func (s *AnnouncementService) Create(req *model.AnnouncementCreateReq) (*model.A
 		SortOrder: req.SortOrder,
 		StartTime: startTime,
 		EndTime:   endTime,
+
+		TargetProvinces: strings.Join(normalizeTargetList(req.TargetProvinces), ","),
+		TargetCities:    strings.Join(normalizeTargetList(req.TargetCities), ","),
+		TargetRole:      req.TargetRole,
+		TargetPlatforms: platforms,
 	}
 
 	if ann.Type == 0 {
@@ -76,7 +176,7 @@ func (s *AnnouncementService) Create(req *model.AnnouncementCreateReq) (*model.A
 		ann.IsActive = 1
 	}
 
-	if err := s.repo.Create(ann); err != nil {
+	if err := s.repo.Create(ctx, ann); err != nil {
 		logger.Error("Create announcement failed", "title", req.Title, "error", err)
 		return nil, err
 	}
@@ -84,26 +184,138 @@ func (s *AnnouncementService) Create(req *model.AnnouncementCreateReq) (*model.A
 	return ann, nil
 }
 
-func (s *AnnouncementService) GetByID(id uint64) (*model.Announcement, error) {
-	return s.repo.GetByID(id)
+func (s *AnnouncementService) GetByID(ctx context.Context, id uint64) (*model.Announcement, error) {
+	return s.repo.GetByID(ctx, id)
 }
 
-func (s *AnnouncementService) GetActiveAnnouncements(limit int) ([]*model.Announcement, error) {
-	return s.repo.GetActiveAnnouncements(limit)
+// GetActiveAnnouncements returns the active announcements targeted at the viewer
+// Anonymous viewers (userID 0) only see announcements without region or role targeting
+func (s *AnnouncementService) GetActiveAnnouncements(ctx context.Context, userID uint64, platform string, limit int) ([]*model.Announcement, error) {
+	announcements, err := s.repo.ListActive(ctx, userID, maxActiveAnnouncements)
+	if err != nil {
+		return nil, err
+	}
+
+	aud := &model.AnnouncementAudience{UserID: userID}
+	if userID > 0 && slices.ContainsFunc(announcements, needsAudience) {
+		audiences, err := s.repo.GetAudiences(ctx, []uint64{userID}, time.Now().Add(-announcementRoleWindow))
+		if err != nil {
+			return nil, err
+		}
+		if len(audiences) > 0 {
+			aud = audiences[0]
+		}
+	}
+	aud.Platform = platform
+
+	result := make([]*model.Announcement, 0, limit)
+	for _, ann := range announcements {
+		if !announcementMatches(ann, aud) {
+			continue
+		}
+		result = append(result, ann)
+		if len(result) >= limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// Dismiss hides an announcement for the user so it is no longer returned
+func (s *AnnouncementService) Dismiss(ctx context.Context, userID, announcementID uint64) error {
+	ann, err := s.repo.GetByID(ctx, announcementID)
+	if err != nil {
+		return err
+	}
+	if ann == nil {
+		return errors.New("公告不存在")
+	}
+	if err := s.repo.Dismiss(ctx, userID, announcementID); err != nil {
+		logger.Error("Dismiss announcement failed", "user_id", userID, "announcement_id", announcementID, "error", err)
+		return err
+	}
+	logger.Debug("Announcement dismissed", "user_id", userID, "announcement_id", announcementID)
+	return nil
+}
+
+// PushUrgent pushes urgent announcements that became active to the online users they target
+// Run periodically by the scheduler, each announcement is pushed once
+func (s *AnnouncementService) PushUrgent(ctx context.Context) error {
+	announcements, err := s.repo.ListPendingUrgent(ctx)
+	if err != nil {
+		return err
+	}
+	if len(announcements) == 0 {
+		return nil
+	}
+
+	claimed := make([]*model.Announcement, 0, len(announcements))
+	for _, ann := range announcements {
+		ok, err := s.repo.MarkPushed(ctx, ann.ID, time.Now())
+		if err != nil {
+			logger.Error("Mark announcement pushed failed", "id", ann.ID, "error", err)
+			continue
+		}
+		if ok {
+			claimed = append(claimed, ann)
+		}
+	}
+	if len(claimed) == 0 {
+		return nil
+	}
+
+	// targeting attributes are loaded in batches, an IN list of every online user would outgrow the query limits
+	online := s.wsHub.OnlineUsers()
+	sent := make([]int, len(claimed))
+	since := time.Now().Add(-announcementRoleWindow)
+	for start := 0; start < len(online); start += urgentPushBatchSize {
+		batch := online[start:min(start+urgentPushBatchSize, len(online))]
+		platforms := make(map[uint64]string, len(batch))
+		userIDs := make([]uint64, 0, len(batch))
+		for _, u := range batch {
+			platforms[u.UserID] = u.Platform
+			userIDs = append(userIDs, u.UserID)
+		}
+
+		// the announcements are already claimed, a failed batch is skipped rather than retried
+		audiences, err := s.repo.GetAudiences(ctx, userIDs, since)
+		if err != nil {
+			logger.Error("Get urgent announcement audiences failed", "batch_start", start, "batch_size", len(batch), "error", err)
+			continue
+		}
+		for _, aud := range audiences {
+			aud.Platform = platforms[aud.UserID]
+			for i, ann := range claimed {
+				if !announcementMatches(ann, aud) {
+					continue
+				}
+				s.wsHub.SendToUserContext(ctx, aud.UserID, websocket.Message{
+					Type: "announcement",
+					Data: ann,
+				})
+				sent[i]++
+			}
+		}
+	}
+
+	for i, ann := range claimed {
+		logger.Info("Urgent announcement pushed", "id", ann.ID, "title", ann.Title, "online_users", len(online), "sent", sent[i])
+	}
+	return nil
 }
 
-func (s *AnnouncementService) ListAll(page, pageSize int) ([]*model.Announcement, int64, error) {
+func (s *AnnouncementService) ListAll(ctx context.Context, page, pageSize int) ([]*model.Announcement, int64, error) {
 	if page < 1 {
 		page = 1
 	}
 	if pageSize < 1 || pageSize > 100 {
 		pageSize = 20
 	}
-	return s.repo.ListAll(page, pageSize)
+	return s.repo.ListAll(ctx, page, pageSize)
 }
 
-func (s *AnnouncementService) Update(id uint64, req *model.AnnouncementUpdateReq) (*model.Announcement, error) {
-	ann, err := s.repo.GetByID(id)
+func (s *AnnouncementService) Update(ctx context.Context, id uint64, req *model.AnnouncementUpdateReq) (*model.Announcement, error) {
+	ann, err := s.repo.GetByID(ctx, id)
 	if err != nil || ann == nil {
 		return nil, err
 	}
@@ -133,8 +345,24 @@ func (s *AnnouncementService) Update(id uint64, req *model.AnnouncementUpdateReq
 			ann.EndTime = endTime
 		}
 	}
+	if req.TargetProvinces != nil {
+		ann.TargetProvinces = strings.Join(normalizeTargetList(*req.TargetProvinces), ",")
+	}
+	if req.TargetCities != nil {
+		ann.TargetCities = strings.Join(normalizeTargetList(*req.TargetCities), ",")
+	}
+	if req.TargetRole != nil {
+		ann.TargetRole = *req.TargetRole
+	}
+	if req.TargetPlatforms != nil {
+		platforms, err := normalizePlatforms(*req.TargetPlatforms)
+		if err != nil {
+			return nil, err
+		}
+		ann.TargetPlatforms = platforms
+	}
 
-	if err := s.repo.Update(ann); err != nil {
+	if err := s.repo.Update(ctx, ann); err != nil {
 		logger.Error("Update announcement failed", "id", id, "error", err)
 		return nil, err
 	}
@@ -142,8 +370,8 @@ func (s *AnnouncementService) Update(id uint64, req *model.AnnouncementUpdateReq
 	return ann, nil
 }
 
-func (s *AnnouncementService) Delete(id uint64) error {
-	if err := s.repo.Delete(id); err != nil {
+func (s *AnnouncementService) Delete(ctx context.Context, id uint64) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
 		logger.Error("Delete announcement failed", "id", id, "error", err)
 		return err
 	}
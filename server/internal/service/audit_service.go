@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"pinche/internal/logger"
+	"pinche/internal/model"
+	"pinche/internal/repository"
+)
+
+type AuditService struct {
+	repo *repository.AuditRepository
+}
+
+func NewAuditService() *AuditService {
+	return &AuditService{
+		repo: repository.NewAuditRepository(),
+	}
+}
+
+// Record persists an audit log entry, failures are logged but never block the request
+func (s *AuditService) Record(ctx context.Context, log *model.AdminAuditLog) {
+	if err := s.repo.Create(ctx, log); err != nil {
+		logger.Error("Write admin audit log failed",
+			"admin_id", log.AdminID, "action", log.Action,
+			"target_type", log.TargetType, "target_id", log.TargetID, "error", err)
+	}
+}
+
+func (s *AuditService) List(ctx context.Context, req *model.AdminAuditLogListReq) (*model.AdminAuditLogListResp, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 || req.PageSize > 100 {
+		req.PageSize = 20
+	}
+
+	logs, total, err := s.repo.List(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AdminAuditLogListResp{
+		List:  logs,
+		Total: total,
+	}, nil
+}
@@ -0,0 +1,459 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"pinche/internal/logger"
+	"pinche/internal/model"
+	"pinche/internal/repository"
+	"pinche/internal/websocket"
+)
+
+// expired bans lifted per job run
+const banExpiryBatchSize = 100
+
+type BanService struct {
+	repo        *repository.BanRepository
+	userRepo    *repository.UserRepository
+	tripRepo    *repository.TripRepository
+	notifyRepo  *repository.NotificationRepository
+	userService *UserService
+	tripService *TripService
+	wsHub       *websocket.Hub
+}
+
+func NewBanService(userService *UserService, tripService *TripService, wsHub *websocket.Hub) *BanService {
+	return &BanService{
+		repo:        repository.NewBanRepository(),
+		userRepo:    repository.NewUserRepository(),
+		tripRepo:    repository.NewTripRepository(),
+		notifyRepo:  repository.NewNotificationRepository(),
+		userService: userService,
+		tripService: tripService,
+		wsHub:       wsHub,
+	}
+}
+
+// ban failures shown to the admin as they are, any other failure is logged and reported as a fixed message
+var (
+	ErrBanUserNotFound   = errors.New("用户不存在")
+	ErrBanTripNotFound   = errors.New("行程不存在")
+	ErrUserAlreadyBanned = errors.New("该用户已处于封禁状态")
+	ErrTripAlreadyBanned = errors.New("该行程已处于封禁状态")
+)
+
+// BanUser bans a user account with a reason and optional duration
+func (s *BanService) BanUser(ctx context.Context, openID string, req *model.AdminBanReq, op model.BanOperator) (*model.Ban, error) {
+	user, err := s.userRepo.GetByOpenID(ctx, openID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrBanUserNotFound
+	}
+
+	existing, err := s.repo.GetActiveUserBan(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrUserAlreadyBanned
+	}
+
+	ban := s.newBan(model.BanTargetUser, req, op)
+	ban.UserID = user.ID
+	ban.UserOpenID = user.OpenID
+	if err := s.repo.CreateUserBan(ctx, ban); err != nil {
+		logger.Error("Create user ban failed", "user_id", user.ID, "error", err)
+		return nil, err
+	}
+
+	logger.Info("User banned", "ban_id", ban.ID, "user_id", user.ID, "reason", ban.Reason,
+		"expires_at", ban.ExpiresAt, "admin", op.Username)
+
+	s.notify(ctx, ban, "账号已被封禁", fmt.Sprintf("您的账号因「%s」被封禁，%s。如有异议，可提交申诉",
+		ban.Reason, describeBanExpiry(ban.ExpiresAt)))
+	return ban, nil
+}
+
+// UnbanUser lifts the active ban of a user account
+func (s *BanService) UnbanUser(ctx context.Context, openID string, op model.BanOperator) (*model.Ban, error) {
+	user, err := s.userRepo.GetByOpenID(ctx, openID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrBanUserNotFound
+	}
+
+	ban, err := s.repo.GetActiveUserBan(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if ban == nil {
+		// banned before ban records existed, only restore the status
+		return nil, s.userService.AdminUnbanUser(ctx, openID)
+	}
+	if err := s.liftBan(ctx, ban, model.BanStatusLifted, op.Username); err != nil {
+		return nil, err
+	}
+	return ban, nil
+}
+
+// BanTrip bans a trip with a reason and optional duration
+func (s *BanService) BanTrip(ctx context.Context, tripID uint64, req *model.AdminBanReq, op model.BanOperator) (*model.Ban, error) {
+	trip, err := s.tripRepo.GetByID(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	if trip == nil {
+		return nil, ErrBanTripNotFound
+	}
+
+	existing, err := s.repo.GetActiveTripBan(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrTripAlreadyBanned
+	}
+
+	ban := s.newBan(model.BanTargetTrip, req, op)
+	ban.UserID = trip.UserID
+	ban.UserOpenID = trip.UserOpenID
+	ban.TripID = tripID
+	if err := s.repo.CreateTripBan(ctx, ban); err != nil {
+		logger.Error("Create trip ban failed", "trip_id", tripID, "error", err)
+		return nil, err
+	}
+	s.tripService.AdminBanTrip(ctx, trip)
+
+	logger.Info("Trip banned", "ban_id", ban.ID, "trip_id", tripID, "reason", ban.Reason,
+		"expires_at", ban.ExpiresAt, "admin", op.Username)
+
+	s.notify(ctx, ban, "行程已被下架", fmt.Sprintf("您发布的行程（%s→%s）因「%s」被下架，%s。如有异议，可提交申诉",
+		trip.DepartureCity, trip.DestinationCity, ban.Reason, describeBanExpiry(ban.ExpiresAt)))
+	return ban, nil
+}
+
+// UnbanTrip lifts the active ban of a trip
+func (s *BanService) UnbanTrip(ctx context.Context, tripID uint64, op model.BanOperator) (*model.Ban, error) {
+	ban, err := s.repo.GetActiveTripBan(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	if ban == nil {
+		// banned before ban records existed, only restore the status
+		return nil, s.tripService.AdminUnbanTrip(ctx, tripID, model.TripStatusPending)
+	}
+	if err := s.liftBan(ctx, ban, model.BanStatusLifted, op.Username); err != nil {
+		return nil, err
+	}
+	return ban, nil
+}
+
+// ExpireBans lifts bans whose expiry has passed, run periodically by the job scheduler
+func (s *BanService) ExpireBans(ctx context.Context) error {
+	bans, err := s.repo.ListExpired(ctx, time.Now(), banExpiryBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, ban := range bans {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := s.liftBan(ctx, ban, model.BanStatusExpired, "system"); err != nil {
+			logger.Error("Lift expired ban failed", "ban_id", ban.ID, "error", err)
+		}
+	}
+	if len(bans) > 0 {
+		logger.Info("Expired bans lifted", "count", len(bans))
+	}
+	return nil
+}
+
+// GetMyBans returns the active bans affecting a user
+func (s *BanService) GetMyBans(ctx context.Context, userID uint64) ([]*model.Ban, error) {
+	bans, err := s.repo.ListActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if bans == nil {
+		bans = []*model.Ban{}
+	}
+	return bans, nil
+}
+
+// CreateAppeal submits an appeal against one of the user's active bans
+func (s *BanService) CreateAppeal(ctx context.Context, userID uint64, req *model.AppealCreateReq) (*model.Appeal, error) {
+	ban, err := s.repo.GetByID(ctx, req.BanID)
+	if err != nil {
+		return nil, err
+	}
+	if ban == nil || ban.UserID != userID {
+		return nil, errors.New("封禁记录不存在")
+	}
+	return s.createAppeal(ctx, ban, req.Content)
+}
+
+// CreateAppealByCredentials lets a banned user, who cannot log in, appeal the account ban
+func (s *BanService) CreateAppealByCredentials(ctx context.Context, req *model.BannedUserAppealReq, clientIP string) (*model.Appeal, error) {
+	user, err := s.userService.VerifyCredentials(ctx, req.Phone, req.Password, req.CaptchaID, req.CaptchaCode, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	ban, err := s.repo.GetActiveUserBan(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if ban == nil {
+		return nil, errors.New("账号未被封禁，无需申诉")
+	}
+	return s.createAppeal(ctx, ban, req.Content)
+}
+
+func (s *BanService) createAppeal(ctx context.Context, ban *model.Ban, content string) (*model.Appeal, error) {
+	if ban.Status != model.BanStatusActive {
+		return nil, errors.New("封禁已解除，无需申诉")
+	}
+
+	pending, err := s.repo.HasPendingAppeal(ctx, ban.ID)
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return nil, errors.New("申诉正在处理中，请耐心等待")
+	}
+
+	appeal := &model.Appeal{
+		BanID:      ban.ID,
+		UserID:     ban.UserID,
+		UserOpenID: ban.UserOpenID,
+		Content:    content,
+		Status:     model.AppealStatusPending,
+	}
+	if err := s.repo.CreateAppeal(ctx, appeal); err != nil {
+		logger.Error("Create appeal failed", "ban_id", ban.ID, "user_id", ban.UserID, "error", err)
+		return nil, err
+	}
+
+	logger.Info("Appeal submitted", "appeal_id", appeal.ID, "ban_id", ban.ID, "user_id", ban.UserID)
+	return appeal, nil
+}
+
+func (s *BanService) ListBans(ctx context.Context, req *model.BanListReq) (*model.BanListResp, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 || req.PageSize > 100 {
+		req.PageSize = 20
+	}
+
+	bans, total, err := s.repo.List(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if bans == nil {
+		bans = []*model.Ban{}
+	}
+
+	return &model.BanListResp{
+		List:  bans,
+		Total: total,
+	}, nil
+}
+
+// ListAppeals returns the admin appeal queue with the appealed bans attached
+func (s *BanService) ListAppeals(ctx context.Context, req *model.AppealListReq) (*model.AppealListResp, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 || req.PageSize > 100 {
+		req.PageSize = 20
+	}
+
+	appeals, total, err := s.repo.ListAppeals(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if appeals == nil {
+		appeals = []*model.Appeal{}
+	}
+	for _, appeal := range appeals {
+		ban, err := s.repo.GetByID(ctx, appeal.BanID)
+		if err != nil {
+			return nil, err
+		}
+		appeal.Ban = ban
+	}
+
+	return &model.AppealListResp{
+		List:  appeals,
+		Total: total,
+	}, nil
+}
+
+func (s *BanService) GetAppeal(ctx context.Context, id uint64) (*model.Appeal, error) {
+	return s.repo.GetAppealByID(ctx, id)
+}
+
+// ApproveAppeal accepts an appeal and lifts the ban
+func (s *BanService) ApproveAppeal(ctx context.Context, id uint64, note string, op model.BanOperator) (*model.Appeal, error) {
+	appeal, err := s.reviewAppeal(ctx, id, model.AppealStatusApproved, note, op)
+	if err != nil {
+		return nil, err
+	}
+
+	ban, err := s.repo.GetByID(ctx, appeal.BanID)
+	if err != nil {
+		return nil, err
+	}
+	if ban != nil && ban.Status == model.BanStatusActive {
+		if err := s.liftBan(ctx, ban, model.BanStatusLifted, op.Username); err != nil {
+			return nil, err
+		}
+	}
+	appeal.Ban = ban
+	return appeal, nil
+}
+
+// RejectAppeal rejects an appeal, the ban stays in effect
+func (s *BanService) RejectAppeal(ctx context.Context, id uint64, note string, op model.BanOperator) (*model.Appeal, error) {
+	appeal, err := s.reviewAppeal(ctx, id, model.AppealStatusRejected, note, op)
+	if err != nil {
+		return nil, err
+	}
+
+	ban, err := s.repo.GetByID(ctx, appeal.BanID)
+	if err != nil {
+		return nil, err
+	}
+	if ban != nil {
+		content := "您的申诉经审核未通过，封禁继续有效"
+		if note != "" {
+			content += fmt.Sprintf("。审核意见：%s", note)
+		}
+		s.notify(ctx, ban, "申诉未通过", content)
+	}
+	appeal.Ban = ban
+	return appeal, nil
+}
+
+func (s *BanService) reviewAppeal(ctx context.Context, id uint64, status int8, note string, op model.BanOperator) (*model.Appeal, error) {
+	appeal, err := s.repo.GetAppealByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if appeal == nil {
+		return nil, errors.New("申诉不存在")
+	}
+
+	ok, err := s.repo.ReviewAppeal(ctx, id, status, op.AdminID, op.Username, note)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("申诉已处理")
+	}
+
+	logger.Info("Appeal reviewed", "appeal_id", id, "ban_id", appeal.BanID, "status", status, "admin", op.Username)
+	return s.repo.GetAppealByID(ctx, id)
+}
+
+// liftBan ends a ban, restores the target and notifies the user
+func (s *BanService) liftBan(ctx context.Context, ban *model.Ban, status int8, liftedBy string) error {
+	ok, err := s.repo.Lift(ctx, ban.ID, status, liftedBy)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// already lifted concurrently
+		return nil
+	}
+
+	var title, content string
+	if ban.TargetType == model.BanTargetUser {
+		if err := s.userRepo.UpdateStatus(ctx, ban.UserID, 0); err != nil {
+			return err
+		}
+		title = "账号已解封"
+		content = "您的账号已解除封禁，请遵守平台使用规范"
+	} else {
+		if err := s.tripService.AdminUnbanTrip(ctx, ban.TripID, banTripStatus(ban)); err != nil {
+			return err
+		}
+		title = "行程已恢复"
+		content = "您被下架的行程已恢复展示"
+	}
+
+	now := time.Now()
+	ban.Status = status
+	ban.LiftedAt = &now
+	ban.LiftedBy = liftedBy
+	logger.Info("Ban lifted", "ban_id", ban.ID, "target_type", ban.TargetType, "status", status, "lifted_by", liftedBy)
+
+	s.notify(ctx, ban, title, content)
+	return nil
+}
+
+func (s *BanService) newBan(targetType string, req *model.AdminBanReq, op model.BanOperator) *model.Ban {
+	ban := &model.Ban{
+		TargetType:    targetType,
+		Reason:        req.Reason,
+		AdminID:       op.AdminID,
+		AdminUsername: op.Username,
+		Status:        model.BanStatusActive,
+	}
+	if ban.Reason == "" {
+		ban.Reason = model.DefaultBanReason
+	}
+	if req.DurationHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.DurationHours) * time.Hour)
+		ban.ExpiresAt = &expiresAt
+	}
+	return ban
+}
+
+// notify stores a notification about a ban and pushes it in real time
+func (s *BanService) notify(ctx context.Context, ban *model.Ban, title, content string) {
+	notification := &model.Notification{
+		UserID:  ban.UserID,
+		TripID:  ban.TripID,
+		Title:   title,
+		Content: content,
+	}
+	if err := s.notifyRepo.Create(ctx, notification); err != nil {
+		logger.Error("Create ban notification failed", "ban_id", ban.ID, "user_id", ban.UserID, "error", err)
+		return
+	}
+
+	if s.wsHub != nil {
+		s.wsHub.SendToUserContext(ctx, ban.UserID, websocket.Message{
+			Type: "ban_updated",
+			Data: map[string]interface{}{
+				"ban":          ban,
+				"notification": notification,
+			},
+		})
+	}
+}
+
+// banTripStatus is the status a trip returns to when its ban is lifted,
+// bans recorded before the status was kept had only pending trips restored
+func banTripStatus(ban *model.Ban) int8 {
+	if ban.TripStatus == 0 {
+		return model.TripStatusPending
+	}
+	return ban.TripStatus
+}
+
+func describeBanExpiry(expiresAt *time.Time) string {
+	if expiresAt == nil {
+		return "封禁期限为永久"
+	}
+	return fmt.Sprintf("将于%s自动解除", expiresAt.Format("2006-01-02 15:04"))
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"pinche/config"
+	"pinche/internal/logger"
+	"pinche/internal/model"
+	"pinche/internal/repository"
+	"pinche/internal/websocket"
+)
+
+// BroadcastService delivers admin messages to many users from a rate-limited background job
+type BroadcastService struct {
+	repo        *repository.BroadcastRepository
+	userRepo    *repository.UserRepository
+	messageRepo *repository.MessageRepository
+	notifyRepo  *repository.NotificationRepository
+	wsHub       *websocket.Hub
+	config      *config.Config
+}
+
+func NewBroadcastService(cfg *config.Config, wsHub *websocket.Hub) *BroadcastService {
+	return &BroadcastService{
+		repo:        repository.NewBroadcastRepository(),
+		userRepo:    repository.NewUserRepository(),
+		messageRepo: repository.NewMessageRepository(),
+		notifyRepo:  repository.NewNotificationRepository(),
+		wsHub:       wsHub,
+		config:      cfg,
+	}
+}
+
+// Create validates the audience and queues a broadcast for delivery
+func (s *BroadcastService) Create(ctx context.Context, req *model.BroadcastCreateReq, adminID uint64, adminUsername string) (*model.Broadcast, error) {
+	b := &model.Broadcast{
+		Channel:       req.Channel,
+		Title:         strings.TrimSpace(req.Title),
+		Content:       strings.TrimSpace(req.Content),
+		AudienceType:  req.AudienceType,
+		AdminID:       adminID,
+		AdminUsername: adminUsername,
+	}
+	if b.Content == "" {
+		return nil, errors.New("内容不能为空")
+	}
+	if b.Channel == model.BroadcastChannelNotification && b.Title == "" {
+		return nil, errors.New("通知标题不能为空")
+	}
+
+	switch req.AudienceType {
+	case model.BroadcastAudienceCity:
+		b.City = strings.TrimSpace(req.City)
+		if b.City == "" {
+			return nil, errors.New("请指定城市")
+		}
+	case model.BroadcastAudienceRoute:
+		b.DepartureCity = strings.TrimSpace(req.DepartureCity)
+		b.DestinationCity = strings.TrimSpace(req.DestinationCity)
+		if b.DepartureCity == "" || b.DestinationCity == "" {
+			return nil, errors.New("请指定出发城市和目的城市")
+		}
+		if _, err := time.ParseInLocation("2006-01-02", req.TripDate, time.Local); err != nil {
+			return nil, errors.New("出发日期格式错误，应为YYYY-MM-DD")
+		}
+		b.TripDate = req.TripDate
+	case model.BroadcastAudienceUsers:
+		openIDs := normalizeTargetList(strings.Join(req.OpenIDs, ","))
+		if len(openIDs) == 0 {
+			return nil, errors.New("请指定接收用户")
+		}
+		if len(openIDs) > model.MaxBroadcastOpenIDs {
+			return nil, errors.New("接收用户数量超出上限")
+		}
+		b.OpenIDs = strings.Join(openIDs, ",")
+	}
+
+	total, err := s.repo.CountRecipients(ctx, b)
+	if err != nil {
+		logger.Error("Count broadcast recipients failed", "audience_type", b.AudienceType, "error", err)
+		return nil, err
+	}
+	if total == 0 {
+		return nil, errors.New("没有符合条件的接收用户")
+	}
+	b.TotalCount = total
+
+	if err := s.repo.Create(ctx, b); err != nil {
+		logger.Error("Create broadcast failed", "audience_type", b.AudienceType, "error", err)
+		return nil, err
+	}
+	b.Status = model.BroadcastStatusPending
+	b.CreatedAt = time.Now()
+	b.UpdatedAt = b.CreatedAt
+
+	logger.Info("Broadcast created", "id", b.ID, "channel", b.Channel, "audience_type", b.AudienceType,
+		"total", total, "admin", adminUsername)
+	return b, nil
+}
+
+func (s *BroadcastService) GetByID(ctx context.Context, id uint64) (*model.Broadcast, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *BroadcastService) List(ctx context.Context, req *model.BroadcastListReq) (*model.BroadcastListResp, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 || req.PageSize > 100 {
+		req.PageSize = 20
+	}
+
+	list, total, err := s.repo.List(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []*model.Broadcast{}
+	}
+	return &model.BroadcastListResp{List: list, Total: total}, nil
+}
+
+// Cancel stops a broadcast that has not finished, users already reached keep their message
+func (s *BroadcastService) Cancel(ctx context.Context, id uint64) (*model.Broadcast, error) {
+	b, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, errors.New("广播不存在")
+	}
+
+	ok, err := s.repo.Finish(ctx, id, model.BroadcastStatusCancelled, time.Now())
+	if err != nil {
+		logger.Error("Cancel broadcast failed", "id", id, "error", err)
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("广播已结束，无法取消")
+	}
+
+	logger.Info("Broadcast cancelled", "id", id, "sent", b.SentCount, "total", b.TotalCount)
+	return s.repo.GetByID(ctx, id)
+}
+
+// Deliver sends the next batch of the oldest unfinished broadcast, run periodically by the scheduler
+// Sends are paced to the configured rate and progress is checkpointed after every batch,
+// so a restart resumes where delivery stopped. When ctx is cancelled the batch stops early
+// and the recipients reached so far are checkpointed
+func (s *BroadcastService) Deliver(ctx context.Context) error {
+	b, err := s.repo.GetNextUnfinished(ctx)
+	if err != nil || b == nil {
+		return err
+	}
+
+	sender, err := s.userRepo.GetByID(ctx, model.SystemUserID)
+	if err != nil {
+		return err
+	}
+	if sender == nil {
+		return errors.New("system user not found, run init.sql to seed it")
+	}
+
+	if b.Status == model.BroadcastStatusPending {
+		if err := s.repo.MarkRunning(ctx, b.ID, time.Now()); err != nil {
+			return err
+		}
+		logger.Info("Broadcast delivery started", "id", b.ID, "total", b.TotalCount)
+	}
+
+	batchSize := s.config.Job.BroadcastBatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	recipients, err := s.repo.ListRecipients(ctx, b, b.LastUserID, batchSize)
+	if err != nil {
+		return err
+	}
+
+	if len(recipients) > 0 {
+		rate := s.config.Job.BroadcastRatePerSecond
+		if rate <= 0 {
+			rate = 1
+		}
+		ticker := time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+
+		sent, failed, done := 0, 0, 0
+	send:
+		for i, rcpt := range recipients {
+			if i > 0 {
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					break send
+				}
+			}
+			// about once a second, stop if an admin cancelled the broadcast meanwhile
+			if i > 0 && i%rate == 0 && s.cancelled(ctx, b.ID) {
+				break send
+			}
+			done++
+			if err := s.deliverOne(ctx, b, sender, rcpt); err != nil {
+				logger.Warn("Broadcast delivery to user failed", "id", b.ID, "user_id", rcpt.UserID, "error", err)
+				failed++
+				continue
+			}
+			sent++
+		}
+
+		last := recipients[done-1].UserID
+		if err := s.repo.SaveProgress(ctx, b.ID, last, sent, failed); err != nil {
+			return err
+		}
+		logger.Debug("Broadcast batch delivered", "id", b.ID, "sent", sent, "failed", failed, "last_user_id", last)
+		if done < len(recipients) {
+			logger.Info("Broadcast batch stopped early", "id", b.ID, "last_user_id", last)
+			return nil
+		}
+	}
+
+	if len(recipients) < batchSize {
+		ok, err := s.repo.Finish(ctx, b.ID, model.BroadcastStatusCompleted, time.Now())
+		if err != nil {
+			return err
+		}
+		if ok {
+			logger.Info("Broadcast delivery completed", "id", b.ID)
+		}
+	}
+	return nil
+}
+
+// cancelled reports whether the broadcast was cancelled, a failed lookup keeps delivering
+func (s *BroadcastService) cancelled(ctx context.Context, id uint64) bool {
+	b, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		logger.Warn("Check broadcast status failed", "id", id, "error", err)
+		return false
+	}
+	return b != nil && b.Status == model.BroadcastStatusCancelled
+}
+
+// deliverOne stores the broadcast for one user and pushes it if they are online
+func (s *BroadcastService) deliverOne(ctx context.Context, b *model.Broadcast, sender *model.User, rcpt *model.BroadcastRecipient) error {
+	if b.Channel == model.BroadcastChannelNotification {
+		notification := &model.Notification{
+			UserID:  rcpt.UserID,
+			Title:   b.Title,
+			Content: b.Content,
+		}
+		if err := s.notifyRepo.Create(ctx, notification); err != nil {
+			return err
+		}
+		notification.CreatedAt = time.Now()
+		s.wsHub.SendToUserContext(ctx, rcpt.UserID, websocket.Message{
+			Type: "notification",
+			Data: map[string]interface{}{
+				"notification": notification,
+			},
+		})
+		return nil
+	}
+
+	msg := &model.Message{
+		SenderID:       sender.ID,
+		ReceiverID:     rcpt.UserID,
+		SenderOpenID:   sender.OpenID,
+		ReceiverOpenID: rcpt.OpenID,
+		Content:        b.Content,
+		MsgType:        model.MsgTypeText,
+	}
+	if err := s.messageRepo.Create(ctx, msg); err != nil {
+		return err
+	}
+	msg.CreatedAt = time.Now()
+	s.wsHub.SendAlertContext(ctx, rcpt.UserID, sender.ID, websocket.Message{
+		Type: "new_message",
+		Data: msg,
+	})
+	return nil
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"pinche/internal/export"
+	"pinche/internal/logger"
+	"pinche/internal/model"
+	"pinche/internal/moderation"
+	"pinche/internal/repository"
+)
+
+const exportTimeLayout = "2006-01-02 15:04:05"
+
+var (
+	userStatusNames = map[int8]string{0: "正常", 1: "封禁"}
+	genderNames     = map[int8]string{0: "未知", 1: "男", 2: "女"}
+	tripTypeNames   = map[int8]string{model.TripTypeDriver: "司机", model.TripTypePassenger: "乘客"}
+	tripStatusNames = map[int8]string{
+		model.TripStatusPending:   "待匹配",
+		model.TripStatusMatched:   "已匹配",
+		model.TripStatusCompleted: "已完成",
+		model.TripStatusCancelled: "已取消",
+		model.TripStatusBanned:    "已封禁",
+	}
+	matchStatusNames = map[int8]string{
+		model.MatchStatusPending: "待确认",
+		model.MatchStatusSuccess: "匹配成功",
+		model.MatchStatusFailed:  "匹配失败",
+	}
+	confirmStatusNames = map[int8]string{
+		model.ConfirmStatusPending:  "待确认",
+		model.ConfirmStatusAccepted: "已接受",
+		model.ConfirmStatusRejected: "已拒绝",
+	}
+	contentFlagStatusNames = map[int8]string{
+		model.ContentFlagStatusPending:  "待审核",
+		model.ContentFlagStatusApproved: "审核通过",
+		model.ContentFlagStatusRejected: "违规",
+	}
+	moderationSceneNames = map[string]string{
+		model.ModerationSceneTripRemark:    "行程备注",
+		model.ModerationSceneMessage:       "私信",
+		model.ModerationSceneGroupMessage:  "群聊消息",
+		model.ModerationSceneFriendRequest: "好友申请",
+		model.ModerationSceneNickname:      "昵称",
+		model.ModerationSceneGrabMessage:   "抢单留言",
+		model.ModerationSceneChatSpam:      "聊天刷屏",
+	}
+)
+
+// ExportService streams admin list data into export writers
+type ExportService struct {
+	userRepo       *repository.UserRepository
+	tripRepo       *repository.TripRepository
+	matchRepo      *repository.MatchRepository
+	moderationRepo *repository.ModerationRepository
+}
+
+func NewExportService() *ExportService {
+	return &ExportService{
+		userRepo:       repository.NewUserRepository(),
+		tripRepo:       repository.NewTripRepository(),
+		matchRepo:      repository.NewMatchRepository(),
+		moderationRepo: repository.NewModerationRepository(),
+	}
+}
+
+// ExportUsers writes users matching the filters, returns the number of data rows written
+// Contact fields are masked unless showSensitive is set
+func (s *ExportService) ExportUsers(ctx context.Context, w export.Writer, req *model.AdminUserListReq, showSensitive bool) (int, error) {
+	header := []string{"用户ID", "手机号", "昵称", "性别", "状态", "省份", "城市", "联系电话", "微信",
+		"车牌号", "车辆品牌", "车辆型号", "车辆颜色", "注册时间"}
+	if err := w.WriteRow(header); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := s.userRepo.IterateAdmin(ctx, req, func(u *model.User) error {
+		count++
+		return w.WriteRow([]string{
+			u.OpenID,
+			maskPhoneIf(u.Phone, !showSensitive),
+			u.Nickname,
+			genderNames[u.Gender],
+			userStatusNames[u.Status],
+			u.Province,
+			u.City,
+			maskPhoneIf(u.ContactPhone, !showSensitive),
+			maskContactIf(u.ContactWechat, !showSensitive),
+			u.CarNumber,
+			u.CarBrand,
+			u.CarModel,
+			u.CarColor,
+			u.CreatedAt.Format(exportTimeLayout),
+		})
+	})
+	return count, err
+}
+
+// ExportTrips writes trips matching the filters, returns the number of data rows written
+func (s *ExportService) ExportTrips(ctx context.Context, w export.Writer, req *model.AdminTripListReq, showSensitive bool) (int, error) {
+	header := []string{"行程ID", "类型", "状态", "出发省份", "出发城市", "出发地址", "目的省份", "目的城市", "目的地址",
+		"出发时间", "座位数", "价格", "备注", "浏览次数", "发布者ID", "发布者昵称", "发布者手机号", "发布时间"}
+	if err := w.WriteRow(header); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := s.tripRepo.IterateAdmin(ctx, req, func(t *model.Trip) error {
+		count++
+		return w.WriteRow([]string{
+			strconv.FormatUint(t.ID, 10),
+			tripTypeNames[t.TripType],
+			tripStatusNames[t.Status],
+			t.DepartureProvince,
+			t.DepartureCity,
+			t.DepartureAddress,
+			t.DestinationProvince,
+			t.DestinationCity,
+			t.DestinationAddress,
+			t.DepartureTime.Format(exportTimeLayout),
+			strconv.Itoa(t.Seats),
+			strconv.FormatFloat(t.Price, 'f', 2, 64),
+			t.Remark,
+			strconv.Itoa(t.ViewCount),
+			t.UserOpenID,
+			t.User.Nickname,
+			maskPhoneIf(t.User.Phone, !showSensitive),
+			t.CreatedAt.Format(exportTimeLayout),
+		})
+	})
+	return count, err
+}
+
+// ExportMatches writes matches matching the filters, returns the number of data rows written
+func (s *ExportService) ExportMatches(ctx context.Context, w export.Writer, req *model.AdminMatchExportReq, showSensitive bool) (int, error) {
+	header := []string{"匹配ID", "状态", "匹配度", "司机行程ID", "乘客行程ID", "出发城市", "目的城市", "出发时间",
+		"司机ID", "司机昵称", "司机手机号", "司机确认", "乘客ID", "乘客昵称", "乘客手机号", "乘客确认", "创建时间", "更新时间"}
+	if err := w.WriteRow(header); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := s.matchRepo.IterateAdmin(ctx, req, func(m *model.Match) error {
+		count++
+		departureTime := ""
+		if !m.DriverTrip.DepartureTime.IsZero() {
+			departureTime = m.DriverTrip.DepartureTime.Format(exportTimeLayout)
+		}
+		return w.WriteRow([]string{
+			strconv.FormatUint(m.ID, 10),
+			matchStatusNames[m.Status],
+			fmt.Sprintf("%.2f", m.MatchScore),
+			strconv.FormatUint(m.DriverTripID, 10),
+			strconv.FormatUint(m.PassengerTripID, 10),
+			m.DriverTrip.DepartureCity,
+			m.DriverTrip.DestinationCity,
+			departureTime,
+			m.DriverOpenID,
+			m.Driver.Nickname,
+			maskPhoneIf(m.Driver.Phone, !showSensitive),
+			confirmStatusNames[m.DriverStatus],
+			m.PassengerOpenID,
+			m.Passenger.Nickname,
+			maskPhoneIf(m.Passenger.Phone, !showSensitive),
+			confirmStatusNames[m.PassengerStatus],
+			m.CreatedAt.Format(exportTimeLayout),
+			m.UpdatedAt.Format(exportTimeLayout),
+		})
+	})
+	return count, err
+}
+
+// ExportReports writes the content flag review queue matching the filters, returns the number of data rows written
+// Phone numbers and WeChat IDs in the flagged content are masked unless showSensitive is set
+func (s *ExportService) ExportReports(ctx context.Context, w export.Writer, req *model.ContentFlagExportReq, showSensitive bool) (int, error) {
+	header := []string{"记录ID", "场景", "对象ID", "用户ID", "用户昵称", "内容", "命中词", "状态",
+		"审核人", "审核意见", "审核时间", "创建时间"}
+	if err := w.WriteRow(header); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := s.moderationRepo.IterateFlags(ctx, req, func(f *model.ContentFlag) error {
+		count++
+		content := f.Content
+		if !showSensitive {
+			content = moderation.Mask(content, moderation.FindContacts(content))
+		}
+		reviewedAt := ""
+		if f.ReviewedAt != nil {
+			reviewedAt = f.ReviewedAt.Format(exportTimeLayout)
+		}
+		scene := moderationSceneNames[f.Scene]
+		if scene == "" {
+			scene = f.Scene
+		}
+		return w.WriteRow([]string{
+			strconv.FormatUint(f.ID, 10),
+			scene,
+			strconv.FormatUint(f.TargetID, 10),
+			f.UserOpenID,
+			f.UserNickname,
+			content,
+			f.MatchedWords,
+			contentFlagStatusNames[f.Status],
+			f.ReviewerName,
+			f.ReviewNote,
+			reviewedAt,
+			f.CreatedAt.Format(exportTimeLayout),
+		})
+	})
+	return count, err
+}
+
+// ExportFilename builds a timestamped download filename for an export
+func ExportFilename(entity, format string) string {
+	return fmt.Sprintf("%s_%s.%s", entity, time.Now().Format("20060102_150405"), format)
+}
+
+func maskPhoneIf(phone string, mask bool) string {
+	if !mask || phone == "" {
+		return phone
+	}
+	return logger.MaskPhone(phone)
+}
+
+// maskContactIf keeps only the first two characters of a contact handle
+func maskContactIf(contact string, mask bool) string {
+	if !mask || contact == "" {
+		return contact
+	}
+	runes := []rune(contact)
+	if len(runes) <= 2 {
+		return "****"
+	}
+	return string(runes[:2]) + "****"
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 
 	"pinche/internal/logger"
@@ -11,19 +12,21 @@ import (
 type FriendService struct {
 	friendRepo *repository.FriendRepository
 	userRepo   *repository.UserRepository
+	moderation *ModerationService
 }
 
-func NewFriendService() *FriendService {
+func NewFriendService(moderation *ModerationService) *FriendService {
 	return &FriendService{
 		friendRepo: repository.NewFriendRepository(),
 		userRepo:   repository.NewUserRepository(),
+		moderation: moderation,
 	}
 }
 
 // SendFriendRequest sends a friend request from current user to target user
-func (s *FriendService) SendFriendRequest(userID uint64, req *model.FriendRequestReq) error {
+func (s *FriendService) SendFriendRequest(ctx context.Context, userID uint64, req *model.FriendRequestReq) error {
 	// get target user by open_id
-	targetUser, err := s.userRepo.GetByOpenID(req.FriendOpenID)
+	targetUser, err := s.userRepo.GetByOpenID(ctx, req.FriendOpenID)
 	if err != nil {
 		logger.Error("Get target user failed", "open_id", req.FriendOpenID, "error", err)
 		return err
@@ -37,8 +40,13 @@ func (s *FriendService) SendFriendRequest(userID uint64, req *model.FriendReques
 		return errors.New("不能添加自己为好友")
 	}
 
+	message, err := s.moderation.Check(model.ModerationSceneFriendRequest, userID, req.Message)
+	if err != nil {
+		return err
+	}
+
 	// check existing friendship record
-	existing, err := s.friendRepo.GetFriendshipRecord(userID, targetUser.ID)
+	existing, err := s.friendRepo.GetFriendshipRecord(ctx, userID, targetUser.ID)
 	if err != nil {
 		logger.Error("Check existing friendship failed", "user_id", userID, "target_id", targetUser.ID, "error", err)
 		return err
@@ -58,15 +66,16 @@ func (s *FriendService) SendFriendRequest(userID uint64, req *model.FriendReques
 			// if previously rejected, allow re-sending (reset the record)
 			if existing.UserID == userID {
 				// user sent before and was rejected, can re-send
-				if err := s.friendRepo.ResetRejectedRequest(existing.ID, req.Message); err != nil {
+				if err := s.friendRepo.ResetRejectedRequest(ctx, existing.ID, message.Text); err != nil {
 					logger.Error("Reset rejected request failed", "id", existing.ID, "error", err)
 					return err
 				}
+				s.moderation.Flag(ctx, message, existing.ID)
 				logger.Info("Friend request re-sent", "from", userID, "to", targetUser.ID)
 				return nil
 			}
 			// user rejected the other's request before, need to delete and create new
-			if err := s.friendRepo.Delete(existing.ID); err != nil {
+			if err := s.friendRepo.Delete(ctx, existing.ID); err != nil {
 				logger.Error("Delete rejected record failed", "id", existing.ID, "error", err)
 				return err
 			}
@@ -78,21 +87,22 @@ func (s *FriendService) SendFriendRequest(userID uint64, req *model.FriendReques
 		UserID:   userID,
 		FriendID: targetUser.ID,
 		Status:   model.FriendStatusPending,
-		Message:  req.Message,
+		Message:  message.Text,
 	}
 
-	if err := s.friendRepo.Create(friend); err != nil {
+	if err := s.friendRepo.Create(ctx, friend); err != nil {
 		logger.Error("Create friend request failed", "from", userID, "to", targetUser.ID, "error", err)
 		return err
 	}
+	s.moderation.Flag(ctx, message, friend.ID)
 
 	logger.Info("Friend request sent", "from", userID, "to", targetUser.ID)
 	return nil
 }
 
 // AcceptFriendRequest accepts a friend request
-func (s *FriendService) AcceptFriendRequest(userID uint64, requestID uint64) error {
-	friend, err := s.friendRepo.GetByID(requestID)
+func (s *FriendService) AcceptFriendRequest(ctx context.Context, userID uint64, requestID uint64) error {
+	friend, err := s.friendRepo.GetByID(ctx, requestID)
 	if err != nil {
 		logger.Error("Get friend request failed", "id", requestID, "error", err)
 		return err
@@ -110,7 +120,7 @@ func (s *FriendService) AcceptFriendRequest(userID uint64, requestID uint64) err
 		return errors.New("该申请已处理")
 	}
 
-	if err := s.friendRepo.UpdateStatus(requestID, model.FriendStatusAccepted); err != nil {
+	if err := s.friendRepo.UpdateStatus(ctx, requestID, model.FriendStatusAccepted); err != nil {
 		logger.Error("Accept friend request failed", "id", requestID, "error", err)
 		return err
 	}
@@ -120,8 +130,8 @@ func (s *FriendService) AcceptFriendRequest(userID uint64, requestID uint64) err
 }
 
 // RejectFriendRequest rejects a friend request
-func (s *FriendService) RejectFriendRequest(userID uint64, requestID uint64) error {
-	friend, err := s.friendRepo.GetByID(requestID)
+func (s *FriendService) RejectFriendRequest(ctx context.Context, userID uint64, requestID uint64) error {
+	friend, err := s.friendRepo.GetByID(ctx, requestID)
 	if err != nil {
 		logger.Error("Get friend request failed", "id", requestID, "error", err)
 		return err
@@ -139,7 +149,7 @@ func (s *FriendService) RejectFriendRequest(userID uint64, requestID uint64) err
 		return errors.New("该申请已处理")
 	}
 
-	if err := s.friendRepo.UpdateStatus(requestID, model.FriendStatusRejected); err != nil {
+	if err := s.friendRepo.UpdateStatus(ctx, requestID, model.FriendStatusRejected); err != nil {
 		logger.Error("Reject friend request failed", "id", requestID, "error", err)
 		return err
 	}
@@ -149,8 +159,8 @@ func (s *FriendService) RejectFriendRequest(userID uint64, requestID uint64) err
 }
 
 // GetFriendRequests gets pending friend requests for current user
-func (s *FriendService) GetFriendRequests(userID uint64) (*model.FriendRequestListResp, error) {
-	friends, total, err := s.friendRepo.GetPendingRequests(userID)
+func (s *FriendService) GetFriendRequests(ctx context.Context, userID uint64) (*model.FriendRequestListResp, error) {
+	friends, total, err := s.friendRepo.GetPendingRequests(ctx, userID)
 	if err != nil {
 		logger.Error("Get friend requests failed", "user_id", userID, "error", err)
 		return nil, err
@@ -163,8 +173,8 @@ func (s *FriendService) GetFriendRequests(userID uint64) (*model.FriendRequestLi
 }
 
 // GetFriends gets all friends for current user
-func (s *FriendService) GetFriends(userID uint64) (*model.FriendListResp, error) {
-	friends, total, err := s.friendRepo.GetFriends(userID)
+func (s *FriendService) GetFriends(ctx context.Context, userID uint64) (*model.FriendListResp, error) {
+	friends, total, err := s.friendRepo.GetFriends(ctx, userID)
 	if err != nil {
 		logger.Error("Get friends failed", "user_id", userID, "error", err)
 		return nil, err
@@ -177,9 +187,9 @@ func (s *FriendService) GetFriends(userID uint64) (*model.FriendListResp, error)
 }
 
 // DeleteFriend deletes a friend relationship
-func (s *FriendService) DeleteFriend(userID uint64, friendOpenID string) error {
+func (s *FriendService) DeleteFriend(ctx context.Context, userID uint64, friendOpenID string) error {
 	// get target user
-	targetUser, err := s.userRepo.GetByOpenID(friendOpenID)
+	targetUser, err := s.userRepo.GetByOpenID(ctx, friendOpenID)
 	if err != nil {
 		logger.Error("Get target user failed", "open_id", friendOpenID, "error", err)
 		return err
@@ -189,7 +199,7 @@ func (s *FriendService) DeleteFriend(userID uint64, friendOpenID string) error {
 	}
 
 	// check friendship exists
-	isFriend, err := s.friendRepo.CheckFriendship(userID, targetUser.ID)
+	isFriend, err := s.friendRepo.CheckFriendship(ctx, userID, targetUser.ID)
 	if err != nil {
 		logger.Error("Check friendship failed", "user_id", userID, "target_id", targetUser.ID, "error", err)
 		return err
@@ -199,7 +209,7 @@ func (s *FriendService) DeleteFriend(userID uint64, friendOpenID string) error {
 	}
 
 	// delete both directions
-	if err := s.friendRepo.DeleteByUserAndFriend(userID, targetUser.ID); err != nil {
+	if err := s.friendRepo.DeleteByUserAndFriend(ctx, userID, targetUser.ID); err != nil {
 		logger.Error("Delete friend failed", "user_id", userID, "target_id", targetUser.ID, "error", err)
 		return err
 	}
@@ -209,9 +219,9 @@ func (s *FriendService) DeleteFriend(userID uint64, friendOpenID string) error {
 }
 
 // GetUserPublicProfile gets the public profile of a user
-func (s *FriendService) GetUserPublicProfile(currentUserID uint64, targetOpenID string) (*model.UserPublicProfile, error) {
+func (s *FriendService) GetUserPublicProfile(ctx context.Context, currentUserID uint64, targetOpenID string) (*model.UserPublicProfile, error) {
 	// get target user
-	targetUser, err := s.userRepo.GetByOpenID(targetOpenID)
+	targetUser, err := s.userRepo.GetByOpenID(ctx, targetOpenID)
 	if err != nil {
 		logger.Error("Get target user failed", "open_id", targetOpenID, "error", err)
 		return nil, err
@@ -231,7 +241,7 @@ func (s *FriendService) GetUserPublicProfile(currentUserID uint64, targetOpenID
 
 	// check friendship status
 	if currentUserID != targetUser.ID {
-		friendship, err := s.friendRepo.GetFriendshipRecord(currentUserID, targetUser.ID)
+		friendship, err := s.friendRepo.GetFriendshipRecord(ctx, currentUserID, targetUser.ID)
 		if err != nil {
 			logger.Error("Get friendship record failed", "user_id", currentUserID, "target_id", targetUser.ID, "error", err)
 			return nil, err
@@ -263,7 +273,7 @@ func (s *FriendService) GetUserPublicProfile(currentUserID uint64, targetOpenID
 	}
 
 	// get recent trips (last 3 days)
-	trips, err := s.friendRepo.GetRecentTripsByUserID(targetUser.ID, 3)
+	trips, err := s.friendRepo.GetRecentTripsByUserID(ctx, targetUser.ID, 3)
 	if err != nil {
 		logger.Error("Get recent trips failed", "user_id", targetUser.ID, "error", err)
 		return nil, err
@@ -274,14 +284,14 @@ func (s *FriendService) GetUserPublicProfile(currentUserID uint64, targetOpenID
 }
 
 // GetFriendCount gets friend and request counts for current user
-func (s *FriendService) GetFriendCount(userID uint64) (*model.FriendCountResp, error) {
-	friendCount, err := s.friendRepo.GetFriendCount(userID)
+func (s *FriendService) GetFriendCount(ctx context.Context, userID uint64) (*model.FriendCountResp, error) {
+	friendCount, err := s.friendRepo.GetFriendCount(ctx, userID)
 	if err != nil {
 		logger.Error("Get friend count failed", "user_id", userID, "error", err)
 		return nil, err
 	}
 
-	requestCount, err := s.friendRepo.GetPendingRequestCount(userID)
+	requestCount, err := s.friendRepo.GetPendingRequestCount(ctx, userID)
 	if err != nil {
 		logger.Error("Get request count failed", "user_id", userID, "error", err)
 		return nil, err
@@ -294,8 +304,8 @@ func (s *FriendService) GetFriendCount(userID uint64) (*model.FriendCountResp, e
 }
 
 // CancelFriendRequest cancels a sent friend request
-func (s *FriendService) CancelFriendRequest(userID uint64, requestID uint64) error {
-	friend, err := s.friendRepo.GetByID(requestID)
+func (s *FriendService) CancelFriendRequest(ctx context.Context, userID uint64, requestID uint64) error {
+	friend, err := s.friendRepo.GetByID(ctx, requestID)
 	if err != nil {
 		logger.Error("Get friend request failed", "id", requestID, "error", err)
 		return err
@@ -313,7 +323,7 @@ func (s *FriendService) CancelFriendRequest(userID uint64, requestID uint64) err
 		return errors.New("该申请已处理")
 	}
 
-	if err := s.friendRepo.Delete(requestID); err != nil {
+	if err := s.friendRepo.Delete(ctx, requestID); err != nil {
 		logger.Error("Cancel friend request failed", "id", requestID, "error", err)
 		return err
 	}
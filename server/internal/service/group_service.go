@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"pinche/internal/logger"
+	"pinche/internal/model"
+	"pinche/internal/repository"
+	"pinche/internal/websocket"
+)
+
+// GroupService manages the group chat of a driver trip and its passengers
+type GroupService struct {
+	repo       *repository.GroupRepository
+	tripRepo   *repository.TripRepository
+	userRepo   *repository.UserRepository
+	moderation *ModerationService
+	wsHub      *websocket.Hub
+}
+
+func NewGroupService(moderation *ModerationService, wsHub *websocket.Hub) *GroupService {
+	return &GroupService{
+		repo:       repository.NewGroupRepository(),
+		tripRepo:   repository.NewTripRepository(),
+		userRepo:   repository.NewUserRepository(),
+		moderation: moderation,
+		wsHub:      wsHub,
+	}
+}
+
+// JoinTripGroup adds a passenger to the group of a driver trip, creating the group with the
+// driver as owner on first use. Called once a match succeeds or the driver accepts a booking,
+// passengerTripID is 0 for accepted bookings.
+// Trips that are not driver trips have no group and are ignored.
+func (s *GroupService) JoinTripGroup(ctx context.Context, driverTripID, passengerID, passengerTripID uint64) error {
+	trip, err := s.tripRepo.GetByID(ctx, driverTripID)
+	if err != nil {
+		return err
+	}
+	if trip == nil || trip.TripType != model.TripTypeDriver || trip.UserID == passengerID {
+		return nil
+	}
+
+	group, err := s.getOrCreateGroup(ctx, trip)
+	if err != nil {
+		return err
+	}
+	if group.Status != model.TripGroupStatusActive {
+		return nil
+	}
+
+	existing, err := s.repo.GetMember(ctx, group.ID, passengerID)
+	if err != nil {
+		return err
+	}
+	lastID, err := s.repo.GetLastMessageID(ctx, group.ID)
+	if err != nil {
+		return err
+	}
+	member := &model.TripGroupMember{
+		GroupID: group.ID,
+		UserID:  passengerID,
+		Role:    model.GroupMemberRolePassenger,
+		TripID:  passengerTripID,
+	}
+	if err := s.repo.AddMember(ctx, member, lastID); err != nil {
+		return err
+	}
+
+	if existing == nil || existing.Status != model.GroupMemberStatusActive {
+		logger.Info("Passenger joined trip group", "group_id", group.ID, "trip_id", driverTripID, "user_id", passengerID)
+		s.notifyMembers(ctx, group.ID, "joined", passengerID)
+	}
+	return nil
+}
+
+func (s *GroupService) getOrCreateGroup(ctx context.Context, trip *model.Trip) (*model.TripGroup, error) {
+	group, err := s.repo.GetByTripID(ctx, trip.ID)
+	if err != nil || group != nil {
+		return group, err
+	}
+
+	group = &model.TripGroup{
+		TripID:  trip.ID,
+		OwnerID: trip.UserID,
+		Name:    fmt.Sprintf("%s→%s %s", trip.DepartureCity, trip.DestinationCity, trip.DepartureTime.Format("01-02 15:04")),
+		Status:  model.TripGroupStatusActive,
+	}
+	if err := s.repo.Create(ctx, group); err != nil {
+		// another match of the same trip may have created it concurrently
+		if existing, _ := s.repo.GetByTripID(ctx, trip.ID); existing != nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	owner := &model.TripGroupMember{
+		GroupID: group.ID,
+		UserID:  trip.UserID,
+		Role:    model.GroupMemberRoleDriver,
+	}
+	if err := s.repo.AddMember(ctx, owner, 0); err != nil {
+		return nil, err
+	}
+	logger.Info("Trip group created", "group_id", group.ID, "trip_id", trip.ID, "owner_id", trip.UserID)
+	return group, nil
+}
+
+// LeaveByPassengerTrip removes a passenger from the groups they joined through a trip that was cancelled
+func (s *GroupService) LeaveByPassengerTrip(ctx context.Context, passengerTripID uint64) error {
+	memberships, err := s.repo.ListActiveMembershipsByTrip(ctx, passengerTripID)
+	if err != nil {
+		return err
+	}
+	for _, m := range memberships {
+		if err := s.removeMember(ctx, m.GroupID, m.UserID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CloseTripGroup closes the group of a driver trip that ended, was cancelled, deleted or banned
+// Passengers who booked without a trip of their own are only let go this way
+func (s *GroupService) CloseTripGroup(ctx context.Context, driverTripID uint64) error {
+	group, err := s.repo.GetByTripID(ctx, driverTripID)
+	if err != nil || group == nil || group.Status == model.TripGroupStatusClosed {
+		return err
+	}
+	if err := s.repo.UpdateStatus(ctx, group.ID, model.TripGroupStatusClosed); err != nil {
+		return err
+	}
+	logger.Info("Trip group closed", "group_id", group.ID, "trip_id", driverTripID)
+	s.notifyMembers(ctx, group.ID, "closed", group.OwnerID)
+	return nil
+}
+
+// ReopenTripGroup reopens the group of a driver trip whose ban was lifted, members are kept while closed
+func (s *GroupService) ReopenTripGroup(ctx context.Context, driverTripID uint64) error {
+	group, err := s.repo.GetByTripID(ctx, driverTripID)
+	if err != nil || group == nil || group.Status == model.TripGroupStatusActive {
+		return err
+	}
+	if err := s.repo.UpdateStatus(ctx, group.ID, model.TripGroupStatusActive); err != nil {
+		return err
+	}
+	logger.Info("Trip group reopened", "group_id", group.ID, "trip_id", driverTripID)
+	s.notifyMembers(ctx, group.ID, "reopened", group.OwnerID)
+	return nil
+}
+
+// Leave lets a passenger leave a group, the driver owns the group and cannot leave it
+func (s *GroupService) Leave(ctx context.Context, groupID, userID uint64) error {
+	member, err := s.activeMember(ctx, groupID, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role == model.GroupMemberRoleDriver {
+		return errors.New("司机不能退出自己行程的群聊")
+	}
+	return s.removeMember(ctx, groupID, userID)
+}
+
+func (s *GroupService) removeMember(ctx context.Context, groupID, userID uint64) error {
+	removed, err := s.repo.RemoveMember(ctx, groupID, userID, time.Now())
+	if err != nil || !removed {
+		return err
+	}
+	logger.Info("Passenger left trip group", "group_id", groupID, "user_id", userID)
+	s.notifyMembers(ctx, groupID, "left", userID)
+	// the member who left is no longer in the member list, tell them too
+	s.wsHub.SendToUserContext(ctx, userID, websocket.Message{
+		Type: "group_updated",
+		Data: map[string]interface{}{"group_id": groupID, "event": "left"},
+	})
+	return nil
+}
+
+// activeMember returns the caller's membership, rejecting users who are not in the group
+func (s *GroupService) activeMember(ctx context.Context, groupID, userID uint64) (*model.TripGroupMember, error) {
+	member, err := s.repo.GetMember(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil || member.Status != model.GroupMemberStatusActive {
+		return nil, errors.New("你不在该群聊中")
+	}
+	return member, nil
+}
+
+// ListMyGroups returns the groups the user belongs to with unread counts
+func (s *GroupService) ListMyGroups(ctx context.Context, userID uint64) (*model.TripGroupListResp, error) {
+	groups, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if groups == nil {
+		groups = []*model.TripGroup{}
+	}
+	return &model.TripGroupListResp{List: groups}, nil
+}
+
+// GetGroup returns a group with its current members
+func (s *GroupService) GetGroup(ctx context.Context, groupID, userID uint64) (*model.TripGroup, error) {
+	member, err := s.activeMember(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	group, err := s.repo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, errors.New("群聊不存在")
+	}
+
+	members, err := s.repo.ListActiveMembers(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		m.User, _ = s.userRepo.GetByID(ctx, m.UserID)
+	}
+	group.Members = members
+	group.MemberCount = len(members)
+	group.Muted = member.Muted
+	return group, nil
+}
+
+// UpdateSetting changes the caller's settings of a group, muted members get messages without alerts
+func (s *GroupService) UpdateSetting(ctx context.Context, groupID, userID uint64, req *model.GroupSettingReq) (*model.TripGroup, error) {
+	if _, err := s.activeMember(ctx, groupID, userID); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetMuted(ctx, groupID, userID, *req.Muted); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(ctx, groupID, userID)
+}
+
+// SendMessage posts a message to the group and pushes it to the other members
+func (s *GroupService) SendMessage(ctx context.Context, groupID, userID uint64, req *model.GroupMessageSendReq) (*model.GroupMessage, error) {
+	if req.MsgType == model.MsgTypeText && len(req.Content) > 2000 {
+		return nil, errors.New("文本消息内容不能超过2000字符")
+	}
+	if req.MsgType == model.MsgTypeVoice && (req.Duration <= 0 || req.Duration > 60) {
+		return nil, errors.New("语音消息时长必须在1-60秒之间")
+	}
+	if req.MsgType == model.MsgTypeLocation {
+		content, err := normalizeLocationContent(req.Content)
+		if err != nil {
+			return nil, err
+		}
+		req.Content = content
+	}
+
+	if _, err := s.activeMember(ctx, groupID, userID); err != nil {
+		return nil, err
+	}
+	group, err := s.repo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil || group.Status != model.TripGroupStatusActive {
+		return nil, errors.New("群聊已关闭")
+	}
+
+	var checked *model.ModerationResult
+	content := req.Content
+	if req.MsgType == model.MsgTypeText {
+		checked, err = s.moderation.Check(model.ModerationSceneGroupMessage, userID, req.Content)
+		if err != nil {
+			return nil, err
+		}
+		content = checked.Text
+	}
+
+	sender, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	msg := &model.GroupMessage{
+		GroupID:      groupID,
+		SenderID:     userID,
+		SenderOpenID: sender.OpenID,
+		Content:      content,
+		MsgType:      req.MsgType,
+		Duration:     req.Duration,
+		CreatedAt:    time.Now(),
+		Sender:       sender,
+	}
+	if err := s.repo.CreateMessage(ctx, msg); err != nil {
+		logger.Error("Create group message failed", "group_id", groupID, "user_id", userID, "error", err)
+		return nil, err
+	}
+	s.moderation.Flag(ctx, checked, msg.ID)
+
+	// the sender has read their own message
+	if err := s.repo.MarkRead(ctx, groupID, userID, msg.ID); err != nil {
+		logger.Warn("Mark group read failed", "group_id", groupID, "user_id", userID, "error", err)
+	}
+
+	members, err := s.repo.ListActiveMembers(ctx, groupID)
+	if err != nil {
+		logger.Error("List group members failed", "group_id", groupID, "error", err)
+		return msg, nil
+	}
+	for _, m := range members {
+		if m.UserID == userID {
+			continue
+		}
+		s.wsHub.SendToUserContext(ctx, m.UserID, websocket.Message{
+			Type:   "group_message",
+			Silent: m.Muted,
+			Data:   msg,
+		})
+	}
+	return msg, nil
+}
+
+// GetMessages returns the group history, newest first
+func (s *GroupService) GetMessages(ctx context.Context, groupID, userID uint64, req *model.GroupMessageListReq) (*model.GroupMessageListResp, error) {
+	member, err := s.activeMember(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+	// messages sent before the member joined are not theirs to read
+	messages, hasMore, err := s.repo.ListMessages(ctx, groupID, member.JoinedMessageID, req.BeforeID, req.AfterID, req.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	userCache := make(map[uint64]*model.User)
+	for _, msg := range messages {
+		if _, ok := userCache[msg.SenderID]; !ok {
+			user, _ := s.userRepo.GetByID(ctx, msg.SenderID)
+			userCache[msg.SenderID] = user
+		}
+		msg.Sender = userCache[msg.SenderID]
+	}
+	if messages == nil {
+		messages = []*model.GroupMessage{}
+	}
+	return &model.GroupMessageListResp{List: messages, HasMore: hasMore}, nil
+}
+
+// MarkAsRead clears the caller's unread count of the group
+func (s *GroupService) MarkAsRead(ctx context.Context, groupID, userID uint64) error {
+	if _, err := s.activeMember(ctx, groupID, userID); err != nil {
+		return err
+	}
+	lastID, err := s.repo.GetLastMessageID(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	return s.repo.MarkRead(ctx, groupID, userID, lastID)
+}
+
+// notifyMembers pushes a membership change to the current members
+func (s *GroupService) notifyMembers(ctx context.Context, groupID uint64, event string, userID uint64) {
+	members, err := s.repo.ListActiveMembers(ctx, groupID)
+	if err != nil {
+		logger.Error("List group members failed", "group_id", groupID, "error", err)
+		return
+	}
+	var openID string
+	if user, _ := s.userRepo.GetByID(ctx, userID); user != nil {
+		openID = user.OpenID
+	}
+	for _, m := range members {
+		s.wsHub.SendToUserContext(ctx, m.UserID, websocket.Message{
+			Type:   "group_updated",
+			Silent: m.Muted,
+			Data: map[string]interface{}{
+				"group_id": groupID,
+				"event":    event,
+				"user_id":  openID,
+			},
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pinche/config"
+	"pinche/internal/cache"
+	"pinche/internal/database"
+	"pinche/internal/logger"
+	"pinche/internal/model"
+)
+
+// healthCheckTimeout bounds each dependency check, so a hung dependency fails readiness instead of the probe
+const healthCheckTimeout = 2 * time.Second
+
+// HealthService answers the liveness and readiness probes of the load balancer
+type HealthService struct {
+	config       *config.Config
+	shuttingDown atomic.Bool
+}
+
+func NewHealthService(cfg *config.Config) *HealthService {
+	return &HealthService{config: cfg}
+}
+
+// SetShuttingDown makes readiness fail from now on, so traffic is moved away before the server stops
+func (s *HealthService) SetShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+// Ready checks the database, Redis and the COS configuration concurrently
+func (s *HealthService) Ready(ctx context.Context) *model.Readiness {
+	if s.shuttingDown.Load() {
+		return &model.Readiness{Status: model.HealthStatusShuttingDown}
+	}
+
+	checks := map[string]func(ctx context.Context) error{
+		"database": func(ctx context.Context) error {
+			return database.DB.PingContext(ctx)
+		},
+		"redis": func(ctx context.Context) error {
+			return cache.Client.Ping(ctx).Err()
+		},
+		"cos": func(ctx context.Context) error {
+			return s.checkCOSConfig()
+		},
+	}
+
+	resp := &model.Readiness{
+		Status: model.HealthStatusReady,
+		Checks: make(map[string]*model.HealthCheck, len(checks)),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			result := runHealthCheck(ctx, name, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Checks[name] = result
+			if result.Status != model.HealthStatusOK {
+				resp.Status = model.HealthStatusNotReady
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return resp
+}
+
+// checkCOSConfig makes sure uploads can be signed, without calling COS on every probe
+func (s *HealthService) checkCOSConfig() error {
+	cos := s.config.COS
+	if cos.SecretID == "" || cos.SecretKey == "" {
+		return errors.New("COS credentials not configured")
+	}
+	if cos.Bucket == "" || cos.Region == "" {
+		return errors.New("COS bucket or region not configured")
+	}
+	return nil
+}
+
+// runHealthCheck runs one check, the error is only logged since /readyz is not authenticated
+func runHealthCheck(ctx context.Context, name string, check func(ctx context.Context) error) *model.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := &model.HealthCheck{
+		Status:    model.HealthStatusOK,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = model.HealthStatusDown
+		logger.Warn("Health check failed", "check", name, "latency_ms", result.LatencyMs, "error", err)
+	}
+	return result
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"pinche/config"
+	"pinche/internal/logger"
+	"pinche/internal/model"
+	"pinche/internal/repository"
+	"pinche/internal/websocket"
+)
+
+const (
+	// liveLocationThrottle is the minimum interval between positions forwarded for one share
+	liveLocationThrottle = 2 * time.Second
+	// liveLocationRecheck is how often an active share reloads its match and trips,
+	// so a cancelled trip stops the share without a database query per frame
+	liveLocationRecheck = 30 * time.Second
+	// liveLocationIdle drops shares that received no frames for this long
+	liveLocationIdle = 10 * time.Minute
+)
+
+type liveLocationKey struct {
+	matchID uint64
+	userID  uint64
+}
+
+// liveLocationShare is the cached permission of a user to share live location for a match
+type liveLocationShare struct {
+	peerID    uint64
+	expiresAt time.Time
+	reason    string // non-empty once the share can no longer be used
+	checkedAt time.Time
+	sentAt    time.Time
+	stopped   bool // live_location_stopped was already pushed
+}
+
+// LocationService relays live location between the driver and passenger of a successful match.
+// Positions only pass through the websocket hub and are never stored.
+type LocationService struct {
+	matchRepo *repository.MatchRepository
+	tripRepo  *repository.TripRepository
+	wsHub     *websocket.Hub
+	config    *config.Config
+
+	mu     sync.Mutex
+	shares map[liveLocationKey]*liveLocationShare
+}
+
+func NewLocationService(cfg *config.Config, wsHub *websocket.Hub) *LocationService {
+	return &LocationService{
+		matchRepo: repository.NewMatchRepository(),
+		tripRepo:  repository.NewTripRepository(),
+		wsHub:     wsHub,
+		config:    cfg,
+		shares:    make(map[liveLocationKey]*liveLocationShare),
+	}
+}
+
+// GetLiveLocationStatus tells a participant whether live location can be shared for the match
+func (s *LocationService) GetLiveLocationStatus(ctx context.Context, matchID, userID uint64) (*model.LiveLocationStatus, error) {
+	share, err := s.loadShare(ctx, matchID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &model.LiveLocationStatus{
+		MatchID: matchID,
+		Reason:  share.reason,
+	}
+	if !share.expiresAt.IsZero() {
+		status.ExpiresAt = &share.expiresAt
+	}
+	if share.reason == "" && time.Now().After(share.expiresAt) {
+		status.Reason = model.LiveLocationStopExpired
+	}
+	status.Available = status.Reason == ""
+	return status, nil
+}
+
+// ShareLiveLocation forwards a position to the match counterpart, dropping updates faster than
+// the throttle. Once the share window passes or the match ends both sides get live_location_stopped.
+func (s *LocationService) ShareLiveLocation(ctx context.Context, userID uint64, openID string, frame *model.LiveLocationFrame) error {
+	if !validCoordinate(frame.Lat, frame.Lng) {
+		return errors.New("位置坐标无效")
+	}
+
+	now := time.Now()
+	share, err := s.share(ctx, frame.MatchID, userID, now)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if share.reason == "" && now.After(share.expiresAt) {
+		share.reason = model.LiveLocationStopExpired
+	}
+	if share.reason != "" {
+		notify := !share.stopped
+		share.stopped = true
+		peerID, reason := share.peerID, share.reason
+		s.mu.Unlock()
+
+		if notify {
+			stopped := model.LiveLocationStopped{MatchID: frame.MatchID, UserID: openID, Reason: reason}
+			s.wsHub.SendToUserContext(ctx, userID, websocket.Message{Type: "live_location_stopped", Data: stopped})
+			s.wsHub.SendToUserContext(ctx, peerID, websocket.Message{Type: "live_location_stopped", Data: stopped})
+			logger.Info("Live location stopped", "match_id", frame.MatchID, "user_id", userID, "reason", reason)
+		}
+		return nil
+	}
+	if now.Sub(share.sentAt) < liveLocationThrottle {
+		s.mu.Unlock()
+		return nil
+	}
+	share.sentAt = now
+	peerID := share.peerID
+	s.mu.Unlock()
+
+	s.wsHub.SendToUserContext(ctx, peerID, websocket.Message{
+		Type: "live_location",
+		Data: model.LiveLocation{
+			MatchID:   frame.MatchID,
+			UserID:    openID,
+			Lat:       frame.Lat,
+			Lng:       frame.Lng,
+			Accuracy:  frame.Accuracy,
+			Heading:   frame.Heading,
+			UpdatedAt: now,
+		},
+	})
+	return nil
+}
+
+// StopLiveLocation ends the user's share for the match and tells the counterpart
+func (s *LocationService) StopLiveLocation(ctx context.Context, userID uint64, openID string, matchID uint64) error {
+	key := liveLocationKey{matchID: matchID, userID: userID}
+	s.mu.Lock()
+	share, ok := s.shares[key]
+	delete(s.shares, key)
+	s.mu.Unlock()
+
+	if !ok {
+		// nothing cached on this instance, still make sure the user belongs to the match
+		loaded, err := s.loadShare(ctx, matchID, userID)
+		if err != nil {
+			return err
+		}
+		share = loaded
+	}
+	if share.stopped {
+		return nil
+	}
+
+	s.wsHub.SendToUserContext(ctx, share.peerID, websocket.Message{
+		Type: "live_location_stopped",
+		Data: model.LiveLocationStopped{MatchID: matchID, UserID: openID, Reason: model.LiveLocationStopUser},
+	})
+	return nil
+}
+
+// share returns the cached share, loading it on first use and reloading it every liveLocationRecheck
+func (s *LocationService) share(ctx context.Context, matchID, userID uint64, now time.Time) (*liveLocationShare, error) {
+	key := liveLocationKey{matchID: matchID, userID: userID}
+	s.mu.Lock()
+	cached, ok := s.shares[key]
+	fresh := ok && (cached.reason != "" || now.Sub(cached.checkedAt) < liveLocationRecheck)
+	s.mu.Unlock()
+	if fresh {
+		return cached, nil
+	}
+
+	loaded, err := s.loadShare(ctx, matchID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ok {
+		loaded.sentAt = cached.sentAt
+	} else {
+		s.pruneShares(now)
+	}
+	s.shares[key] = loaded
+	return loaded, nil
+}
+
+// pruneShares drops shares that stopped receiving frames. s.mu must be held.
+func (s *LocationService) pruneShares(now time.Time) {
+	for key, share := range s.shares {
+		last := share.checkedAt
+		if share.sentAt.After(last) {
+			last = share.sentAt
+		}
+		if now.Sub(last) > liveLocationIdle {
+			delete(s.shares, key)
+		}
+	}
+}
+
+// loadShare checks the user takes part in the match and works out until when they may share.
+// A match that is not successful, or whose trips were cancelled, banned or completed, gets a reason.
+func (s *LocationService) loadShare(ctx context.Context, matchID, userID uint64) (*liveLocationShare, error) {
+	match, err := s.matchRepo.GetByID(ctx, matchID)
+	if err != nil {
+		return nil, err
+	}
+	if match == nil {
+		return nil, errors.New("匹配记录不存在")
+	}
+
+	share := &liveLocationShare{checkedAt: time.Now()}
+	switch userID {
+	case match.DriverID:
+		share.peerID = match.PassengerID
+	case match.PassengerID:
+		share.peerID = match.DriverID
+	default:
+		return nil, errors.New("无权操作此匹配")
+	}
+
+	if match.Status != model.MatchStatusSuccess {
+		share.reason = model.LiveLocationStopEnded
+		return share, nil
+	}
+	driverTrip, err := s.tripRepo.GetByID(ctx, match.DriverTripID)
+	if err != nil {
+		return nil, err
+	}
+	passengerTrip, err := s.tripRepo.GetByID(ctx, match.PassengerTripID)
+	if err != nil {
+		return nil, err
+	}
+	if driverTrip == nil || passengerTrip == nil ||
+		driverTrip.Status != model.TripStatusMatched || passengerTrip.Status != model.TripStatusMatched {
+		share.reason = model.LiveLocationStopEnded
+		return share, nil
+	}
+
+	share.expiresAt = driverTrip.DepartureTime.Add(time.Duration(s.config.Chat.LiveLocationHours) * time.Hour)
+	return share, nil
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"pinche/config"
+	"pinche/internal/cache"
+	"pinche/internal/logger"
+)
+
+// LoginError is a rejected login attempt. It tells the client to show a captcha
+// for the next attempt, or how long the account or IP is locked.
+type LoginError struct {
+	Message         string
+	CaptchaRequired bool
+	RetryAfter      time.Duration // non-zero while locked
+}
+
+func (e *LoginError) Error() string {
+	return e.Message
+}
+
+// LoginGuard protects password login against brute force. Failures are counted per account and
+// per client IP: past the captcha threshold every attempt needs a captcha, past the lock threshold
+// logins are locked for a duration that doubles with every further failure.
+// Redis errors never block a login, the guard then only logs.
+type LoginGuard struct {
+	scope   string // separates user and admin accounts
+	config  *config.LoginGuardConfig
+	store   *cache.LoginGuard
+	captcha *CaptchaService
+}
+
+func NewLoginGuard(cfg *config.Config, scope string) *LoginGuard {
+	return &LoginGuard{
+		scope:   scope,
+		config:  &cfg.LoginGuard,
+		store:   cache.NewLoginGuard(),
+		captcha: NewCaptchaService(),
+	}
+}
+
+// Check runs before the password is verified and rejects locked accounts and IPs,
+// and attempts without a valid captcha once one is required
+func (g *LoginGuard) Check(ctx context.Context, account, ip, captchaID, captchaCode string) error {
+	if err := g.CheckLocked(ctx, account, ip); err != nil {
+		return err
+	}
+
+	counts, err := g.store.Failures(ctx, g.accountKey(account), g.ipKey(ip))
+	if err != nil {
+		logger.Warn("Login guard: get failures failed", "scope", g.scope, "error", err)
+		return nil
+	}
+	if !g.captchaRequired(counts[0], counts[1]) {
+		return nil
+	}
+	if captchaID == "" || captchaCode == "" {
+		return &LoginError{Message: "请输入图形验证码", CaptchaRequired: true}
+	}
+	if !g.captcha.Verify(ctx, captchaID, captchaCode) {
+		return &LoginError{Message: "图形验证码错误或已过期", CaptchaRequired: true}
+	}
+	return nil
+}
+
+// CheckLocked only rejects locked accounts and IPs, Check also requires the captcha when due
+func (g *LoginGuard) CheckLocked(ctx context.Context, account, ip string) error {
+	for _, key := range []string{g.accountKey(account), g.ipKey(ip)} {
+		locked, err := g.store.LockedFor(ctx, key)
+		if err != nil {
+			logger.Warn("Login guard: check lock failed", "scope", g.scope, "error", err)
+			continue
+		}
+		if locked > 0 {
+			return &LoginError{
+				Message:    fmt.Sprintf("登录失败次数过多，请%s后再试", describeWait(locked)),
+				RetryAfter: locked,
+			}
+		}
+	}
+	return nil
+}
+
+// Fail records a failed attempt and returns the error for the client, whose message is used
+// unless the attempt locked the account or IP. locked is true for the failure that first locks
+// the account, so the owner is told once and not on every further failure.
+func (g *LoginGuard) Fail(ctx context.Context, account, ip, message string) (loginErr *LoginError, locked bool) {
+	loginErr = &LoginError{Message: message}
+
+	accountFailures, err := g.store.HitFailure(ctx, g.accountKey(account))
+	if err != nil {
+		logger.Warn("Login guard: count account failure failed", "scope", g.scope, "error", err)
+		return loginErr, false
+	}
+	ipFailures, err := g.store.HitFailure(ctx, g.ipKey(ip))
+	if err != nil {
+		logger.Warn("Login guard: count IP failure failed", "scope", g.scope, "error", err)
+	}
+	loginErr.CaptchaRequired = g.captchaRequired(accountFailures, ipFailures)
+
+	if d := g.lock(ctx, g.accountKey(account), accountFailures, g.config.LockAfter); d > 0 {
+		loginErr.RetryAfter = d
+		locked = accountFailures == int64(g.config.LockAfter)
+		logger.Warn("Login guard: account locked", "scope", g.scope, "client_ip", ip, "failures", accountFailures, "duration", d)
+	}
+	if d := g.lock(ctx, g.ipKey(ip), ipFailures, g.config.IPLockAfter); d > loginErr.RetryAfter {
+		loginErr.RetryAfter = d
+		logger.Warn("Login guard: IP locked", "scope", g.scope, "client_ip", ip, "failures", ipFailures, "duration", d)
+	}
+	if loginErr.RetryAfter > 0 {
+		loginErr.Message = fmt.Sprintf("密码错误次数过多，请%s后再试", describeWait(loginErr.RetryAfter))
+	}
+	return loginErr, locked
+}
+
+// Succeed clears the account's failures after a successful login. Failures of the IP are kept,
+// so logging into an own account does not reset guessing on others.
+func (g *LoginGuard) Succeed(ctx context.Context, account string) {
+	if err := g.store.ClearFailures(ctx, g.accountKey(account)); err != nil {
+		logger.Warn("Login guard: clear failures failed", "scope", g.scope, "error", err)
+	}
+}
+
+func (g *LoginGuard) captchaRequired(accountFailures, ipFailures int64) bool {
+	return (g.config.CaptchaAfter > 0 && accountFailures >= int64(g.config.CaptchaAfter)) ||
+		(g.config.IPCaptchaAfter > 0 && ipFailures >= int64(g.config.IPCaptchaAfter))
+}
+
+// lock locks the subject once failures reach threshold and returns the lock duration
+func (g *LoginGuard) lock(ctx context.Context, subject string, failures int64, threshold int) time.Duration {
+	d := g.lockDuration(failures, threshold)
+	if d <= 0 {
+		return 0
+	}
+
+	if err := g.store.Lock(ctx, subject, d); err != nil {
+		logger.Warn("Login guard: lock failed", "scope", g.scope, "error", err)
+		return 0
+	}
+	return d
+}
+
+// lockDuration returns 0 below threshold, LockMinutes for the first lock and doubled for
+// every failure after it, up to MaxLockMinutes
+func (g *LoginGuard) lockDuration(failures int64, threshold int) time.Duration {
+	if threshold <= 0 || failures < int64(threshold) {
+		return 0
+	}
+
+	d := time.Duration(g.config.LockMinutes) * time.Minute
+	limit := time.Duration(g.config.MaxLockMinutes) * time.Minute
+	for i := int64(threshold); i < failures && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		d = limit
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// accountKey hashes the phone number or username so it is not stored in Redis as is
+func (g *LoginGuard) accountKey(account string) string {
+	sum := sha256.Sum256([]byte(account))
+	return g.scope + ":account:" + hex.EncodeToString(sum[:8])
+}
+
+func (g *LoginGuard) ipKey(ip string) string {
+	return g.scope + ":ip:" + ip
+}
+
+// describeWait formats a lock duration for users, rounded up to minutes or hours
+func describeWait(d time.Duration) string {
+	if d >= time.Hour {
+		return fmt.Sprintf("%d小时", int((d+time.Hour-1)/time.Hour))
+	}
+	return fmt.Sprintf("%d分钟", int((d+time.Minute-1)/time.Minute))
+}
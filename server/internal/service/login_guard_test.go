@@ -0,0 +1,107 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"pinche/config"
+)
+
+func newTestLoginGuard() *LoginGuard {
+	return &LoginGuard{
+		scope: "test",
+		config: &config.LoginGuardConfig{
+			CaptchaAfter:   3,
+			LockAfter:      5,
+			IPCaptchaAfter: 10,
+			IPLockAfter:    20,
+			LockMinutes:    15,
+			MaxLockMinutes: 60,
+		},
+	}
+}
+
+func TestLoginGuardCaptchaRequired(t *testing.T) {
+	g := newTestLoginGuard()
+	tests := []struct {
+		account, ip int64
+		want        bool
+	}{
+		{0, 0, false},
+		{2, 9, false},
+		{3, 0, true},
+		{0, 10, true},
+		{4, 12, true},
+	}
+	for _, tt := range tests {
+		if got := g.captchaRequired(tt.account, tt.ip); got != tt.want {
+			t.Errorf("captchaRequired(%d, %d) = %v, want %v", tt.account, tt.ip, got, tt.want)
+		}
+	}
+
+	g.config.CaptchaAfter = 0
+	g.config.IPCaptchaAfter = 0
+	if g.captchaRequired(100, 100) {
+		t.Error("captchaRequired with thresholds 0 = true, want false")
+	}
+}
+
+func TestLoginGuardLockDuration(t *testing.T) {
+	g := newTestLoginGuard()
+	tests := []struct {
+		failures  int64
+		threshold int
+		want      time.Duration
+	}{
+		{4, 5, 0},
+		{5, 5, 15 * time.Minute},
+		{6, 5, 30 * time.Minute},
+		{7, 5, 60 * time.Minute},
+		{8, 5, 60 * time.Minute},
+		{1000, 5, 60 * time.Minute},
+		{20, 20, 15 * time.Minute},
+		{100, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := g.lockDuration(tt.failures, tt.threshold); got != tt.want {
+			t.Errorf("lockDuration(%d, %d) = %v, want %v", tt.failures, tt.threshold, got, tt.want)
+		}
+	}
+
+	g.config.MaxLockMinutes = 20
+	if got := g.lockDuration(6, 5); got != 20*time.Minute {
+		t.Errorf("lockDuration past the max = %v, want 20m", got)
+	}
+}
+
+func TestLoginGuardKeys(t *testing.T) {
+	g := newTestLoginGuard()
+	key := g.accountKey("13800138000")
+	if key == g.accountKey("13800138001") {
+		t.Error("accountKey is the same for different accounts")
+	}
+	if len(key) != len("test:account:")+16 {
+		t.Errorf("accountKey = %q, want a 16 character hash", key)
+	}
+	if got := g.ipKey("1.2.3.4"); got != "test:ip:1.2.3.4" {
+		t.Errorf("ipKey = %q", got)
+	}
+}
+
+func TestDescribeWait(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, "1分钟"},
+		{15 * time.Minute, "15分钟"},
+		{15*time.Minute + time.Second, "16分钟"},
+		{time.Hour, "1小时"},
+		{90 * time.Minute, "2小时"},
+	}
+	for _, tt := range tests {
+		if got := describeWait(tt.d); got != tt.want {
+			t.Errorf("describeWait(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
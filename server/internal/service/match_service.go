@@ -1,14 +1,19 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
 
 	"pinche/internal/logger"
+	"pinche/internal/metrics"
 	"pinche/internal/model"
 	"pinche/internal/repository"
 	"pinche/internal/websocket"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type MatchService struct {
@@ -16,32 +21,36 @@ type MatchService struct {
 	tripRepo     *repository.TripRepository
 	userRepo     *repository.UserRepository
 	notifyRepo   *repository.NotificationRepository
+	groupService *GroupService
 	wsHub        *websocket.Hub
 }
 
-func NewMatchService(wsHub *websocket.Hub) *MatchService {
+func NewMatchService(groupService *GroupService, wsHub *websocket.Hub) *MatchService {
 	return &MatchService{
-		repo:       repository.NewMatchRepository(),
-		tripRepo:   repository.NewTripRepository(),
-		userRepo:   repository.NewUserRepository(),
-		notifyRepo: repository.NewNotificationRepository(),
-		wsHub:      wsHub,
+		repo:         repository.NewMatchRepository(),
+		tripRepo:     repository.NewTripRepository(),
+		userRepo:     repository.NewUserRepository(),
+		notifyRepo:   repository.NewNotificationRepository(),
+		groupService: groupService,
+		wsHub:        wsHub,
 	}
 }
 
 // FindAndNotifyMatches finds matching trips and sends notifications
-func (s *MatchService) FindAndNotifyMatches(trip *model.Trip) {
-	matchingTrips, err := s.tripRepo.FindMatchingTrips(trip)
+func (s *MatchService) FindAndNotifyMatches(ctx context.Context, trip *model.Trip) {
+	matchingTrips, err := s.tripRepo.FindMatchingTrips(ctx, trip)
 	if err != nil {
 		logger.Error("Find matching trips failed", "trip_id", trip.ID, "error", err)
 		return
 	}
 
 	logger.Debug("Finding matches for trip", "trip_id", trip.ID, "candidates", len(matchingTrips))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("match.candidates", len(matchingTrips)))
 
 	for _, matchTrip := range matchingTrips {
 		score := s.calculateMatchScore(trip, matchTrip)
 		if score < 50 {
+			metrics.MatchScore.WithLabelValues(metrics.MatchScoreBelowThreshold).Observe(score)
 			continue
 		}
 
@@ -60,8 +69,9 @@ func (s *MatchService) FindAndNotifyMatches(trip *model.Trip) {
 		}
 
 		// check if match already exists
-		existing, _ := s.repo.GetByTrips(driverTripID, passengerTripID)
+		existing, _ := s.repo.GetByTrips(ctx, driverTripID, passengerTripID)
 		if existing != nil {
+			metrics.MatchScore.WithLabelValues(metrics.MatchScoreExists).Observe(score)
 			continue
 		}
 
@@ -76,7 +86,7 @@ func (s *MatchService) FindAndNotifyMatches(trip *model.Trip) {
 			Status:          model.MatchStatusPending,
 		}
 
-		if err := s.repo.Create(match); err != nil {
+		if err := s.repo.Create(ctx, match); err != nil {
 			logger.Error("Create match failed",
 				"driver_trip_id", driverTripID,
 				"passenger_trip_id", passengerTripID,
@@ -84,6 +94,8 @@ func (s *MatchService) FindAndNotifyMatches(trip *model.Trip) {
 			continue
 		}
 
+		metrics.MatchScore.WithLabelValues(metrics.MatchScoreCreated).Observe(score)
+		metrics.MatchesCreated.Inc()
 		logger.Info("Match created",
 			"match_id", match.ID,
 			"driver_id", driverID,
@@ -91,7 +103,7 @@ func (s *MatchService) FindAndNotifyMatches(trip *model.Trip) {
 			"score", score)
 
 		// send notifications
-		s.sendMatchNotification(match, trip, matchTrip)
+		s.sendMatchNotification(ctx, match, trip, matchTrip)
 	}
 }
 
@@ -132,7 +144,7 @@ func haversineDistance(lat1, lng1, lat2, lng2 float64) float64 {
 	return R * c
 }
 
-func (s *MatchService) sendMatchNotification(match *model.Match, trip1, trip2 *model.Trip) {
+func (s *MatchService) sendMatchNotification(ctx context.Context, match *model.Match, trip1, trip2 *model.Trip) {
 	// notify driver
 	driverNotify := &model.Notification{
 		UserID:  match.DriverID,
@@ -140,8 +152,8 @@ func (s *MatchService) sendMatchNotification(match *model.Match, trip1, trip2 *m
 		Title:   "发现匹配乘客",
 		Content: fmt.Sprintf("有乘客想从%s到%s，匹配度%.0f%%，请确认是否接受", trip2.DepartureCity, trip2.DestinationCity, match.MatchScore),
 	}
-	if err := s.notifyRepo.Create(driverNotify); err == nil {
-		s.wsHub.SendToUser(match.DriverID, websocket.Message{
+	if err := s.notifyRepo.Create(ctx, driverNotify); err == nil {
+		s.wsHub.SendToUserContext(ctx, match.DriverID, websocket.Message{
 			Type: "match_found",
 			Data: map[string]interface{}{
 				"match_id":     match.ID,
@@ -157,8 +169,8 @@ func (s *MatchService) sendMatchNotification(match *model.Match, trip1, trip2 *m
 		Title:   "发现匹配司机",
 		Content: fmt.Sprintf("有司机从%s到%s，匹配度%.0f%%，请确认是否接受", trip1.DepartureCity, trip1.DestinationCity, match.MatchScore),
 	}
-	if err := s.notifyRepo.Create(passengerNotify); err == nil {
-		s.wsHub.SendToUser(match.PassengerID, websocket.Message{
+	if err := s.notifyRepo.Create(ctx, passengerNotify); err == nil {
+		s.wsHub.SendToUserContext(ctx, match.PassengerID, websocket.Message{
 			Type: "match_found",
 			Data: map[string]interface{}{
 				"match_id":     match.ID,
@@ -168,12 +180,12 @@ func (s *MatchService) sendMatchNotification(match *model.Match, trip1, trip2 *m
 	}
 }
 
-func (s *MatchService) GetMyMatches(userID uint64) ([]*model.Match, error) {
-	return s.repo.GetByUserID(userID)
+func (s *MatchService) GetMyMatches(ctx context.Context, userID uint64) ([]*model.Match, error) {
+	return s.repo.GetByUserID(ctx, userID)
 }
 
-func (s *MatchService) Confirm(matchID uint64, userID uint64, accept bool) error {
-	match, err := s.repo.GetByID(matchID)
+func (s *MatchService) Confirm(ctx context.Context, matchID uint64, userID uint64, accept bool) error {
+	match, err := s.repo.GetByID(ctx, matchID)
 	if err != nil {
 		return err
 	}
@@ -199,7 +211,7 @@ func (s *MatchService) Confirm(matchID uint64, userID uint64, accept bool) error
 		if match.DriverStatus != model.ConfirmStatusPending {
 			return errors.New("您已确认过")
 		}
-		if err := s.repo.UpdateDriverStatus(matchID, int8(status)); err != nil {
+		if err := s.repo.UpdateDriverStatus(ctx, matchID, int8(status)); err != nil {
 			return err
 		}
 		match.DriverStatus = int8(status)
@@ -207,22 +219,22 @@ func (s *MatchService) Confirm(matchID uint64, userID uint64, accept bool) error
 		if match.PassengerStatus != model.ConfirmStatusPending {
 			return errors.New("您已确认过")
 		}
-		if err := s.repo.UpdatePassengerStatus(matchID, int8(status)); err != nil {
+		if err := s.repo.UpdatePassengerStatus(ctx, matchID, int8(status)); err != nil {
 			return err
 		}
 		match.PassengerStatus = int8(status)
 	}
 
 	// check if both confirmed
-	s.checkMatchComplete(match)
+	s.checkMatchComplete(ctx, match)
 
 	return nil
 }
 
-func (s *MatchService) checkMatchComplete(match *model.Match) {
+func (s *MatchService) checkMatchComplete(ctx context.Context, match *model.Match) {
 	// if either rejected, match failed
 	if match.DriverStatus == model.ConfirmStatusRejected || match.PassengerStatus == model.ConfirmStatusRejected {
-		s.repo.UpdateStatus(match.ID, model.MatchStatusFailed)
+		s.repo.UpdateStatus(ctx, match.ID, model.MatchStatusFailed)
 
 		// notify the other party
 		var notifyUserID uint64
@@ -238,8 +250,8 @@ func (s *MatchService) checkMatchComplete(match *model.Match) {
 			Title:   "匹配未成功",
 			Content: "对方已拒绝本次匹配，您可以继续寻找其他匹配",
 		}
-		if err := s.notifyRepo.Create(notify); err == nil {
-			s.wsHub.SendToUser(notifyUserID, websocket.Message{
+		if err := s.notifyRepo.Create(ctx, notify); err == nil {
+			s.wsHub.SendToUserContext(ctx, notifyUserID, websocket.Message{
 				Type: "match_rejected",
 				Data: map[string]interface{}{
 					"match_id":     match.ID,
@@ -252,14 +264,20 @@ func (s *MatchService) checkMatchComplete(match *model.Match) {
 
 	// if both accepted, match success
 	if match.DriverStatus == model.ConfirmStatusAccepted && match.PassengerStatus == model.ConfirmStatusAccepted {
-		s.repo.UpdateStatus(match.ID, model.MatchStatusSuccess)
+		s.repo.UpdateStatus(ctx, match.ID, model.MatchStatusSuccess)
+		metrics.MatchesSucceeded.Inc()
 
 		// update trip status
-		s.tripRepo.UpdateStatus(match.DriverTripID, model.TripStatusMatched)
-		s.tripRepo.UpdateStatus(match.PassengerTripID, model.TripStatusMatched)
+		s.tripRepo.UpdateStatus(ctx, match.DriverTripID, model.TripStatusMatched)
+		s.tripRepo.UpdateStatus(ctx, match.PassengerTripID, model.TripStatusMatched)
+
+		// passenger joins the group chat of the driver trip
+		if err := s.groupService.JoinTripGroup(ctx, match.DriverTripID, match.PassengerID, match.PassengerTripID); err != nil {
+			logger.Error("Join trip group failed", "match_id", match.ID, "trip_id", match.DriverTripID, "error", err)
+		}
 
 		// get contact info
-		contactInfo, _ := s.repo.GetContactInfo(match.ID)
+		contactInfo, _ := s.repo.GetContactInfo(ctx, match.ID)
 
 		// notify both parties
 		driverNotify := &model.Notification{
@@ -275,10 +293,10 @@ func (s *MatchService) checkMatchComplete(match *model.Match) {
 			Content: fmt.Sprintf("恭喜！拼车成功，司机：%s，联系电话：%s。请自行联系对方确认出行细节。", contactInfo.DriverNickname, contactInfo.DriverPhone),
 		}
 
-		s.notifyRepo.Create(driverNotify)
-		s.notifyRepo.Create(passengerNotify)
+		s.notifyRepo.Create(ctx, driverNotify)
+		s.notifyRepo.Create(ctx, passengerNotify)
 
-		s.wsHub.SendToUser(match.DriverID, websocket.Message{
+		s.wsHub.SendToUserContext(ctx, match.DriverID, websocket.Message{
 			Type: "match_success",
 			Data: map[string]interface{}{
 				"match_id":     match.ID,
@@ -286,7 +304,7 @@ func (s *MatchService) checkMatchComplete(match *model.Match) {
 				"notification": driverNotify,
 			},
 		})
-		s.wsHub.SendToUser(match.PassengerID, websocket.Message{
+		s.wsHub.SendToUserContext(ctx, match.PassengerID, websocket.Message{
 			Type: "match_success",
 			Data: map[string]interface{}{
 				"match_id":     match.ID,
@@ -297,8 +315,8 @@ func (s *MatchService) checkMatchComplete(match *model.Match) {
 	}
 }
 
-func (s *MatchService) GetContactInfo(matchID uint64, userID uint64) (*model.ContactInfo, error) {
-	match, err := s.repo.GetByID(matchID)
+func (s *MatchService) GetContactInfo(ctx context.Context, matchID uint64, userID uint64) (*model.ContactInfo, error) {
+	match, err := s.repo.GetByID(ctx, matchID)
 	if err != nil {
 		return nil, err
 	}
@@ -311,9 +329,9 @@ func (s *MatchService) GetContactInfo(matchID uint64, userID uint64) (*model.Con
 	if match.Status != model.MatchStatusSuccess {
 		return nil, errors.New("匹配未成功，无法查看联系信息")
 	}
-	return s.repo.GetContactInfo(matchID)
+	return s.repo.GetContactInfo(ctx, matchID)
 }
 
-func (s *MatchService) GetByID(id uint64) (*model.Match, error) {
-	return s.repo.GetByID(id)
+func (s *MatchService) GetByID(ctx context.Context, id uint64) (*model.Match, error) {
+	return s.repo.GetByID(ctx, id)
 }
@@ -1,93 +1,458 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"pinche/config"
+	"pinche/internal/cache"
+	"pinche/internal/logger"
 	"pinche/internal/model"
 	"pinche/internal/repository"
 )
 
+// errSendFailed is returned to the sender for storage errors, the cause is logged
+var errSendFailed = errors.New("发送消息失败，请稍后重试")
+
 type MessageService struct {
-	repo     *repository.MessageRepository
-	userRepo *repository.UserRepository
+	repo        *repository.MessageRepository
+	userRepo    *repository.UserRepository
+	settingRepo *repository.ConversationSettingRepository
+	friendRepo  *repository.FriendRepository
+	groupRepo   *repository.GroupRepository
+	limiter     *cache.ChatLimiter
+	moderation  *ModerationService
+	config      *config.Config
 }
 
-func NewMessageService() *MessageService {
+func NewMessageService(cfg *config.Config, moderation *ModerationService) *MessageService {
 	return &MessageService{
-		repo:     repository.NewMessageRepository(),
-		userRepo: repository.NewUserRepository(),
+		repo:        repository.NewMessageRepository(),
+		userRepo:    repository.NewUserRepository(),
+		settingRepo: repository.NewConversationSettingRepository(),
+		friendRepo:  repository.NewFriendRepository(),
+		groupRepo:   repository.NewGroupRepository(),
+		limiter:     cache.NewChatLimiter(),
+		moderation:  moderation,
+		config:      cfg,
 	}
 }
 
 // SendMessage creates a new message from sender to receiver
-func (s *MessageService) SendMessage(senderID uint64, req *model.MessageSendReq) (*model.Message, error) {
+// created is false when the client_msg_id was already used, the earlier message is returned instead
+func (s *MessageService) SendMessage(ctx context.Context, senderID uint64, req *model.MessageSendReq) (msg *model.Message, created bool, err error) {
 	// validate content length for text messages
 	if req.MsgType == model.MsgTypeText && len(req.Content) > 2000 {
-		return nil, errors.New("文本消息内容不能超过2000字符")
+		return nil, false, errors.New("文本消息内容不能超过2000字符")
 	}
 
 	// validate voice message duration
 	if req.MsgType == model.MsgTypeVoice {
 		if req.Duration <= 0 || req.Duration > 60 {
-			return nil, errors.New("语音消息时长必须在1-60秒之间")
+			return nil, false, errors.New("语音消息时长必须在1-60秒之间")
 		}
 	}
 
+	// location content is stored re-encoded, so only the known fields reach the receiver
+	if req.MsgType == model.MsgTypeLocation {
+		content, err := normalizeLocationContent(req.Content)
+		if err != nil {
+			return nil, false, err
+		}
+		req.Content = content
+	}
+
 	// get receiver by open_id
-	receiver, err := s.userRepo.GetByOpenID(req.ReceiverID)
+	receiver, err := s.userRepo.GetByOpenID(ctx, req.ReceiverID)
 	if err != nil {
-		return nil, err
+		return nil, false, sendFailed(senderID, err)
 	}
 	if receiver == nil {
-		return nil, errors.New("接收者不存在")
+		return nil, false, errors.New("接收者不存在")
 	}
 
 	// cannot send message to self
 	if senderID == receiver.ID {
-		return nil, errors.New("不能给自己发送消息")
+		return nil, false, errors.New("不能给自己发送消息")
 	}
 
 	// get sender info
-	sender, err := s.userRepo.GetByID(senderID)
+	sender, err := s.userRepo.GetByID(ctx, senderID)
 	if err != nil {
-		return nil, err
+		return nil, false, sendFailed(senderID, err)
 	}
 
-	msg := &model.Message{
+	// a retry of a message that was already stored
+	if req.ClientMsgID != "" {
+		existing, err := s.repo.GetByClientMsgID(ctx, senderID, req.ClientMsgID)
+		if err != nil {
+			return nil, false, sendFailed(senderID, err)
+		}
+		if existing, err = retryOf(existing, receiver.ID); err != nil {
+			return nil, false, err
+		}
+		if existing != nil {
+			logger.Debug("Duplicate message dropped", "sender_id", senderID, "client_msg_id", req.ClientMsgID, "message_id", existing.ID)
+			attachMessageUsers(existing, sender, receiver)
+			return existing, false, nil
+		}
+	}
+
+	newConversation, err := s.checkSendLimits(ctx, senderID, receiver.ID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var reply *model.MessageReply
+	if req.ReplyToID > 0 {
+		reply, err = s.buildReply(ctx, senderID, receiver.ID, req.ReplyToID)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	// only text is moderated, other types carry resource keys
+	var checked *model.ModerationResult
+	content := req.Content
+	if req.MsgType == model.MsgTypeText {
+		checked, err = s.moderation.Check(model.ModerationSceneMessage, senderID, req.Content)
+		if err != nil {
+			return nil, false, err
+		}
+		content = checked.Text
+	}
+
+	now := time.Now()
+	msg = &model.Message{
 		SenderID:       senderID,
 		ReceiverID:     receiver.ID,
 		SenderOpenID:   sender.OpenID,
 		ReceiverOpenID: receiver.OpenID,
-		Content:        req.Content,
+		Content:        content,
 		MsgType:        req.MsgType,
 		Duration:       req.Duration,
 		IsRead:         0,
-		CreatedAt:      time.Now(),
+		ClientMsgID:    req.ClientMsgID,
+		ReplyTo:        reply,
+		CreatedAt:      now,
+		UpdatedAt:      now,
 	}
 
-	if err := s.repo.Create(msg); err != nil {
-		return nil, err
+	if err := s.repo.Create(ctx, msg); err != nil {
+		// a concurrent retry may have won the unique key on (sender_id, client_msg_id)
+		if req.ClientMsgID != "" {
+			if existing, _ := s.repo.GetByClientMsgID(ctx, senderID, req.ClientMsgID); existing != nil {
+				if _, err := retryOf(existing, receiver.ID); err != nil {
+					return nil, false, err
+				}
+				attachMessageUsers(existing, sender, receiver)
+				return existing, false, nil
+			}
+		}
+		return nil, false, sendFailed(senderID, err)
+	}
+
+	s.moderation.Flag(ctx, checked, msg.ID)
+	s.countSend(ctx, senderID, receiver.ID, newConversation)
+
+	// a new message brings an archived conversation back to the receiver's inbox, unless muted
+	if err := s.settingRepo.UnarchiveUnmuted(ctx, receiver.ID, senderID); err != nil {
+		logger.Warn("Unarchive conversation failed", "user_id", receiver.ID, "peer_id", senderID, "error", err)
 	}
 
 	// attach user info
+	msg.SetParticipants(sender.OpenID, receiver.OpenID)
 	msg.Sender = sender
 	msg.Receiver = receiver
 
-	return msg, nil
+	return msg, true, nil
+}
+
+// retryOf returns the stored message when a send is a retry of it, or nil when the client_msg_id is new
+// A client_msg_id is only unique per sender, so one reused for another receiver is rejected
+func retryOf(existing *model.Message, receiverID uint64) (*model.Message, error) {
+	if existing == nil {
+		return nil, nil
+	}
+	if existing.ReceiverID != receiverID {
+		return nil, errors.New("client_msg_id已用于其他会话的消息")
+	}
+	return existing, nil
 }
 
-// GetConversationMessages retrieves messages between current user and peer
-func (s *MessageService) GetConversationMessages(userID uint64, req *model.MessageListReq) (*model.MessageListResp, error) {
-	if req.Page <= 0 {
-		req.Page = 1
+// sendFailed logs a storage error while sending a message and hides it from the sender
+func sendFailed(senderID uint64, err error) error {
+	logger.Error("Send message failed", "sender_id", senderID, "error", err)
+	return errSendFailed
+}
+
+// checkSendLimits applies the anti-spam limits in config.Chat before a message is stored.
+// It only reads the counters, countSend counts the message once it is stored, so a message
+// rejected later on does not use up the limits. It reports whether the message starts a new
+// conversation. Friends are only subject to the per-minute rate. Redis errors let the message through.
+func (s *MessageService) checkSendLimits(ctx context.Context, senderID, receiverID uint64) (bool, error) {
+	limits := s.config.Chat
+
+	if limits.RatePerMinute > 0 {
+		n, err := s.limiter.SendRate(ctx, senderID)
+		if err != nil {
+			logger.Warn("Chat rate limit check failed", "user_id", senderID, "error", err)
+		} else if n >= int64(limits.RatePerMinute) {
+			return false, s.limitExceeded(ctx, senderID, receiverID, "rate", errors.New("发送消息过于频繁，请稍后再试"))
+		}
+	}
+
+	if limits.UnrepliedLimit <= 0 && limits.NewConversationsPerDay <= 0 {
+		return false, nil
+	}
+	friends, err := s.friendRepo.CheckFriendship(ctx, senderID, receiverID)
+	if err != nil {
+		return false, sendFailed(senderID, err)
+	}
+	if friends {
+		return false, nil
+	}
+
+	if limits.UnrepliedLimit > 0 {
+		n, err := s.limiter.Unreplied(ctx, senderID, receiverID)
+		if err != nil {
+			logger.Warn("Chat unreplied limit check failed", "user_id", senderID, "error", err)
+		} else if n >= int64(limits.UnrepliedLimit) {
+			// the counter only knows replies made since it was created, the receiver may have replied before
+			replied, err := s.repo.HasSentTo(ctx, receiverID, senderID)
+			if err != nil {
+				return false, sendFailed(senderID, err)
+			}
+			if !replied {
+				return false, s.limitExceeded(ctx, senderID, receiverID, "unreplied", fmt.Errorf("对方回复前最多只能发送%d条消息", limits.UnrepliedLimit))
+			}
+			if err := s.limiter.ResetUnreplied(ctx, senderID, receiverID); err != nil {
+				logger.Warn("Reset unreplied counter failed", "sender_id", senderID, "receiver_id", receiverID, "error", err)
+			}
+		}
+	}
+
+	if limits.NewConversationsPerDay > 0 {
+		sent, err := s.repo.HasSentTo(ctx, senderID, receiverID)
+		if err != nil {
+			return false, sendFailed(senderID, err)
+		}
+		received := false
+		if !sent {
+			if received, err = s.repo.HasSentTo(ctx, receiverID, senderID); err != nil {
+				return false, sendFailed(senderID, err)
+			}
+		}
+		if !sent && !received {
+			n, err := s.limiter.NewConversations(ctx, senderID)
+			if err != nil {
+				logger.Warn("Chat new conversation limit check failed", "user_id", senderID, "error", err)
+			} else if n >= int64(limits.NewConversationsPerDay) {
+				return false, s.limitExceeded(ctx, senderID, receiverID, "new_conversation", errors.New("今日发起新会话次数已达上限，请明天再试"))
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// countSend counts a stored message against the limits checked by checkSendLimits
+func (s *MessageService) countSend(ctx context.Context, senderID, receiverID uint64, newConversation bool) {
+	if s.config.Chat.RatePerMinute > 0 {
+		if err := s.limiter.HitSendRate(ctx, senderID); err != nil {
+			logger.Warn("Count chat send rate failed", "user_id", senderID, "error", err)
+		}
+	}
+	if newConversation {
+		if err := s.limiter.HitNewConversation(ctx, senderID); err != nil {
+			logger.Warn("Count new conversation failed", "user_id", senderID, "error", err)
+		}
+	}
+
+	// the receiver replied, and the sender waits for a reply
+	if err := s.limiter.ResetUnreplied(ctx, receiverID, senderID); err != nil {
+		logger.Warn("Reset unreplied counter failed", "sender_id", receiverID, "receiver_id", senderID, "error", err)
+	}
+	if err := s.limiter.AddUnreplied(ctx, senderID, receiverID); err != nil {
+		logger.Warn("Add unreplied counter failed", "sender_id", senderID, "receiver_id", receiverID, "error", err)
+	}
+}
+
+// limitExceeded records that the sender ran into a chat limit and flags them to admins
+// once it happens SpamFlagThreshold times within a day. It returns err for the sender.
+func (s *MessageService) limitExceeded(ctx context.Context, senderID, receiverID uint64, limit string, err error) error {
+	logger.Warn("Chat limit exceeded", "user_id", senderID, "receiver_id", receiverID, "limit", limit)
+
+	threshold := int64(s.config.Chat.SpamFlagThreshold)
+	if threshold <= 0 {
+		return err
+	}
+	n, hitErr := s.limiter.HitViolation(ctx, senderID)
+	if hitErr != nil {
+		logger.Warn("Count chat limit violation failed", "user_id", senderID, "error", hitErr)
+		return err
+	}
+	// flag once when the threshold is reached, the counter expires after a day
+	if n == threshold {
+		s.moderation.Flag(ctx, &model.ModerationResult{
+			Scene:   model.ModerationSceneChatSpam,
+			UserID:  senderID,
+			Text:    fmt.Sprintf("24小时内%d次触发私信限制，最近一次: %s", n, err.Error()),
+			Flagged: []string{limit},
+		}, senderID)
+	}
+	return err
+}
+
+// attachMessageUsers fills the participant fields of a message loaded from the database
+func attachMessageUsers(msg *model.Message, sender, receiver *model.User) {
+	msg.SetParticipants(sender.OpenID, receiver.OpenID)
+	msg.Sender = sender
+	msg.Receiver = receiver
+}
+
+// replySnippetRunes is the longest text excerpt stored for a quoted message
+const replySnippetRunes = 50
+
+// buildReply checks the sender can see the quoted message and that it belongs to
+// the conversation with the receiver, then takes a snippet of it for the reply
+func (s *MessageService) buildReply(ctx context.Context, senderID, receiverID, replyToID uint64) (*model.MessageReply, error) {
+	quoted, err := s.repo.GetVisibleByID(ctx, replyToID, senderID)
+	if err != nil {
+		return nil, sendFailed(senderID, err)
 	}
+	if quoted == nil {
+		return nil, errors.New("引用的消息不存在")
+	}
+	if quoted.SenderID != receiverID && quoted.ReceiverID != receiverID {
+		return nil, errors.New("只能引用当前会话中的消息")
+	}
+	if quoted.RecalledAt != nil {
+		return nil, errors.New("引用的消息已撤回")
+	}
+
+	return &model.MessageReply{
+		ID:       quoted.ID,
+		SenderID: quoted.SenderID,
+		Snippet:  replySnippet(quoted),
+	}, nil
+}
+
+// replySnippet describes a quoted message, text is cut to replySnippetRunes and other types use a placeholder
+func replySnippet(msg *model.Message) string {
+	switch msg.MsgType {
+	case model.MsgTypeText:
+		runes := []rune(msg.Content)
+		if len(runes) > replySnippetRunes {
+			return string(runes[:replySnippetRunes]) + "…"
+		}
+		return msg.Content
+	case model.MsgTypeImage:
+		return "[图片]"
+	case model.MsgTypeVoice:
+		return "[语音]"
+	case model.MsgTypeEmoji:
+		return "[表情]"
+	case model.MsgTypeCall:
+		return "[通话记录]"
+	case model.MsgTypeVideo:
+		return "[视频]"
+	case model.MsgTypeLocation:
+		var loc model.LocationContent
+		if err := json.Unmarshal([]byte(msg.Content), &loc); err == nil && (loc.Name != "" || loc.Address != "") {
+			if loc.Name != "" {
+				return "[位置] " + loc.Name
+			}
+			return "[位置] " + loc.Address
+		}
+		return "[位置]"
+	}
+	return ""
+}
+
+// normalizeLocationContent validates a location message and returns it re-encoded
+func normalizeLocationContent(content string) (string, error) {
+	var loc model.LocationContent
+	if err := json.Unmarshal([]byte(content), &loc); err != nil {
+		return "", errors.New("位置消息格式错误")
+	}
+	if !validCoordinate(loc.Lat, loc.Lng) {
+		return "", errors.New("位置坐标无效")
+	}
+	loc.Address = strings.TrimSpace(loc.Address)
+	loc.Name = strings.TrimSpace(loc.Name)
+	if loc.Address == "" {
+		return "", errors.New("位置地址不能为空")
+	}
+	if utf8.RuneCountInString(loc.Address) > 200 || utf8.RuneCountInString(loc.Name) > 100 {
+		return "", errors.New("位置地址或名称过长")
+	}
+
+	data, err := json.Marshal(loc)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// validCoordinate reports whether lat/lng is a real position, clients without a fix send 0,0
+func validCoordinate(lat, lng float64) bool {
+	if lat == 0 && lng == 0 {
+		return false
+	}
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// ForwardMessage copies a text, image, video or location message the user can see into the conversation
+// with another user. Resources are shared by COS key, nothing is uploaded again.
+func (s *MessageService) ForwardMessage(ctx context.Context, userID, msgID uint64, req *model.MessageForwardReq) (*model.Message, bool, error) {
+	source, err := s.repo.GetVisibleByID(ctx, msgID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if source == nil {
+		return nil, false, errors.New("消息不存在")
+	}
+	if source.RecalledAt != nil {
+		return nil, false, errors.New("消息已撤回")
+	}
+	switch source.MsgType {
+	case model.MsgTypeText, model.MsgTypeImage, model.MsgTypeVideo, model.MsgTypeLocation:
+	default:
+		return nil, false, errors.New("该类型消息不支持转发")
+	}
+
+	msg, created, err := s.SendMessage(ctx, userID, &model.MessageSendReq{
+		ReceiverID:  req.ReceiverID,
+		Content:     source.Content,
+		MsgType:     source.MsgType,
+		Duration:    source.Duration,
+		ClientMsgID: req.ClientMsgID,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		logger.Info("Message forwarded", "message_id", msg.ID, "source_id", msgID, "user_id", userID)
+	}
+	return msg, created, nil
+}
+
+// GetConversationMessages retrieves a page of messages between current user and peer
+func (s *MessageService) GetConversationMessages(ctx context.Context, userID uint64, req *model.MessageListReq) (*model.MessageListResp, error) {
 	if req.PageSize <= 0 {
 		req.PageSize = 20
 	}
 
 	// get peer by open_id
-	peer, err := s.userRepo.GetByOpenID(req.PeerID)
+	peer, err := s.userRepo.GetByOpenID(ctx, req.PeerID)
 	if err != nil {
 		return nil, err
 	}
@@ -95,7 +460,7 @@ func (s *MessageService) GetConversationMessages(userID uint64, req *model.Messa
 		return nil, errors.New("用户不存在")
 	}
 
-	messages, total, err := s.repo.GetConversationMessages(userID, peer.ID, req.Page, req.PageSize)
+	messages, hasMore, err := s.repo.GetConversationMessages(ctx, userID, peer.ID, req.BeforeID, req.AfterID, req.PageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -104,56 +469,283 @@ func (s *MessageService) GetConversationMessages(userID uint64, req *model.Messa
 	userCache := make(map[uint64]*model.User)
 	for _, msg := range messages {
 		if _, ok := userCache[msg.SenderID]; !ok {
-			user, _ := s.userRepo.GetByID(msg.SenderID)
+			user, _ := s.userRepo.GetByID(ctx, msg.SenderID)
 			userCache[msg.SenderID] = user
 		}
 		msg.Sender = userCache[msg.SenderID]
 	}
+	if messages == nil {
+		messages = []*model.Message{}
+	}
 
 	return &model.MessageListResp{
-		List:  messages,
-		Total: total,
+		List:    messages,
+		HasMore: hasMore,
 	}, nil
 }
 
-// GetConversations retrieves all conversations for a user
-func (s *MessageService) GetConversations(userID uint64) (*model.ConversationListResp, error) {
-	conversations, err := s.repo.GetConversations(userID)
+// GetConversations retrieves a page of conversations for a user.
+// Pinned conversations are loaded alongside the first page and whenever the client refreshes with after_id.
+func (s *MessageService) GetConversations(ctx context.Context, userID uint64, req *model.ConversationListReq) (*model.ConversationListResp, error) {
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	folder := model.ConversationFolderInbox
+	if req.Archived {
+		folder = model.ConversationFolderArchived
+	}
+	conversations, hasMore, err := s.repo.GetConversations(ctx, userID, folder, req.BeforeID, req.AfterID, req.PageSize)
 	if err != nil {
 		return nil, err
 	}
 
+	var pinned []*model.Conversation
+	if !req.Archived && req.BeforeID == 0 {
+		pinned, _, err = s.repo.GetConversations(ctx, userID, model.ConversationFolderPinned, 0, 0, maxPinnedConversations)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// attach peer user info
-	for _, conv := range conversations {
-		peer, _ := s.userRepo.GetByID(conv.PeerID)
+	for _, conv := range append(pinned, conversations...) {
+		peer, _ := s.userRepo.GetByID(ctx, conv.PeerID)
 		conv.Peer = peer
 	}
+	if conversations == nil {
+		conversations = []*model.Conversation{}
+	}
 
 	return &model.ConversationListResp{
-		List: conversations,
+		Pinned:  pinned,
+		List:    conversations,
+		HasMore: hasMore,
 	}, nil
 }
 
-// MarkAsRead marks all messages from peer as read
-func (s *MessageService) MarkAsRead(userID uint64, peerOpenID string) error {
-	peer, err := s.userRepo.GetByOpenID(peerOpenID)
+// maxPinnedConversations is how many conversations a user can pin
+const maxPinnedConversations = 10
+
+// GetConversationSetting returns the user's settings for the conversation with a peer
+func (s *MessageService) GetConversationSetting(ctx context.Context, userID uint64, peerOpenID string) (*model.ConversationSetting, error) {
+	peer, err := s.userRepo.GetByOpenID(ctx, peerOpenID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if peer == nil {
+		return nil, errors.New("用户不存在")
+	}
+
+	setting, err := s.conversationSetting(ctx, userID, peer.ID)
+	if err != nil {
+		return nil, err
+	}
+	setting.PeerOpenID = peer.OpenID
+	return setting, nil
+}
+
+// UpdateConversationSetting changes the fields set in req and keeps the others
+func (s *MessageService) UpdateConversationSetting(ctx context.Context, userID uint64, peerOpenID string, req *model.ConversationSettingReq) (*model.ConversationSetting, error) {
+	peer, err := s.userRepo.GetByOpenID(ctx, peerOpenID)
+	if err != nil {
+		return nil, err
+	}
+	if peer == nil {
+		return nil, errors.New("用户不存在")
+	}
+	if peer.ID == userID {
+		return nil, errors.New("不能设置与自己的会话")
+	}
+
+	setting, err := s.conversationSetting(ctx, userID, peer.ID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Pinned != nil {
+		if *req.Pinned && !setting.Pinned {
+			count, err := s.settingRepo.CountPinned(ctx, userID)
+			if err != nil {
+				return nil, err
+			}
+			if count >= maxPinnedConversations {
+				return nil, fmt.Errorf("最多置顶%d个会话", maxPinnedConversations)
+			}
+		}
+		setting.Pinned = *req.Pinned
+	}
+	if req.Muted != nil {
+		setting.Muted = *req.Muted
+	}
+	if req.Archived != nil {
+		setting.Archived = *req.Archived
+	}
+	if req.Remark != nil {
+		setting.Remark = strings.TrimSpace(*req.Remark)
+	}
+
+	if err := s.settingRepo.Save(ctx, setting); err != nil {
+		logger.Error("Save conversation setting failed", "user_id", userID, "peer_id", peer.ID, "error", err)
+		return nil, err
+	}
+	setting.PeerOpenID = peer.OpenID
+	return setting, nil
+}
+
+// conversationSetting loads the settings of a conversation, defaults if the user never changed them
+func (s *MessageService) conversationSetting(ctx context.Context, userID, peerID uint64) (*model.ConversationSetting, error) {
+	setting, err := s.settingRepo.Get(ctx, userID, peerID)
+	if err != nil || setting != nil {
+		return setting, err
+	}
+	return &model.ConversationSetting{UserID: userID, PeerID: peerID}, nil
+}
+
+// IsConversationMuted reports whether the user muted the conversation with peer,
+// used by the websocket hub to deliver new messages without alerts
+func (s *MessageService) IsConversationMuted(ctx context.Context, userID, peerID uint64) bool {
+	muted, err := s.settingRepo.IsMuted(ctx, userID, peerID)
+	if err != nil {
+		logger.Warn("Check conversation muted failed", "user_id", userID, "peer_id", peerID, "error", err)
+		return false
+	}
+	return muted
+}
+
+// Sync returns what changed for the user since the cursor, for clients catching up after a reconnect:
+// private messages, messages of the groups they are in and conversation settings. Each kind is paged
+// on its own and the cursor keeps the position of all three, see syncCursor
+func (s *MessageService) Sync(ctx context.Context, userID uint64, req *model.SyncReq) (*model.SyncResp, error) {
+	if req.Limit <= 0 {
+		req.Limit = 200
+	}
+
+	cursor, err := parseSyncCursor(req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, moreMessages, err := s.repo.ListChangedSince(ctx, userID, time.UnixMilli(cursor.messageMs), cursor.messageID, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	groupMessages, moreGroupMessages, err := s.groupRepo.ListMessagesSince(ctx, userID, cursor.groupMessageID, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	settings, moreSettings, err := s.settingRepo.ListChangedSince(ctx, userID, time.UnixMilli(cursor.settingMs), cursor.settingPeerID, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.SyncResp{
+		Messages:      []*model.Message{},
+		DeletedIDs:    []uint64{},
+		GroupMessages: []*model.GroupMessage{},
+		Settings:      []*model.ConversationSetting{},
+		HasMore:       moreMessages || moreGroupMessages || moreSettings,
+	}
+	for _, msg := range messages {
+		if msg.Deleted {
+			resp.DeletedIDs = append(resp.DeletedIDs, msg.ID)
+		} else {
+			resp.Messages = append(resp.Messages, msg)
+		}
+	}
+	if n := len(messages); n > 0 {
+		cursor.messageMs, cursor.messageID = messages[n-1].UpdatedAt.UnixMilli(), messages[n-1].ID
+	}
+	if n := len(groupMessages); n > 0 {
+		resp.GroupMessages = groupMessages
+		cursor.groupMessageID = groupMessages[n-1].ID
+	}
+	if n := len(settings); n > 0 {
+		resp.Settings = settings
+		cursor.settingMs, cursor.settingPeerID = settings[n-1].UpdatedAt.UnixMilli(), settings[n-1].PeerID
+	}
+	resp.NextCursor = cursor.String()
+	return resp, nil
+}
+
+// syncCursor is the position of a sync in each kind of change, encoded as
+// "<message updated_at ms>_<message id>_<group message id>_<setting updated_at ms>_<setting peer id>"
+// Cursors of two parts, from before groups and settings were synced, start those from the beginning
+type syncCursor struct {
+	messageMs      int64
+	messageID      uint64
+	groupMessageID uint64
+	settingMs      int64
+	settingPeerID  uint64
+}
+
+func (c syncCursor) String() string {
+	return fmt.Sprintf("%d_%d_%d_%d_%d", c.messageMs, c.messageID, c.groupMessageID, c.settingMs, c.settingPeerID)
+}
+
+func parseSyncCursor(cursor string) (syncCursor, error) {
+	var c syncCursor
+	if cursor == "" {
+		return c, nil
+	}
+	parts := strings.Split(cursor, "_")
+	if len(parts) != 2 && len(parts) != 5 {
+		return c, errors.New("无效的同步游标")
+	}
+	values := make([]uint64, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 63)
+		if err != nil {
+			return c, errors.New("无效的同步游标")
+		}
+		values[i] = v
+	}
+	c.messageMs, c.messageID = int64(values[0]), values[1]
+	if len(values) == 5 {
+		c.groupMessageID, c.settingMs, c.settingPeerID = values[2], int64(values[3]), values[4]
+	}
+	return c, nil
+}
+
+// MarkAsRead marks all messages from peer as read.
+// Returns a receipt for the peer, or nil if there was nothing new to mark.
+func (s *MessageService) MarkAsRead(ctx context.Context, userID uint64, peerOpenID string) (*model.ReadReceipt, error) {
+	peer, err := s.userRepo.GetByOpenID(ctx, peerOpenID)
+	if err != nil {
+		return nil, err
 	}
 	if peer == nil {
-		return errors.New("用户不存在")
+		return nil, errors.New("用户不存在")
+	}
+
+	marked, err := s.repo.MarkAsRead(ctx, userID, peer.ID)
+	if err != nil || marked == 0 {
+		return nil, err
+	}
+
+	lastID, err := s.repo.GetLastReadMessageID(ctx, userID, peer.ID)
+	if err != nil {
+		return nil, err
 	}
-	return s.repo.MarkAsRead(userID, peer.ID)
+	reader, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil || reader == nil {
+		return nil, err
+	}
+	return &model.ReadReceipt{
+		ReaderID:          userID,
+		ReaderOpenID:      reader.OpenID,
+		SenderID:          peer.ID,
+		LastReadMessageID: lastID,
+	}, nil
 }
 
 // GetUnreadCount returns total unread message count for user
-func (s *MessageService) GetUnreadCount(userID uint64) (int, error) {
-	return s.repo.GetUnreadCount(userID)
+func (s *MessageService) GetUnreadCount(ctx context.Context, userID uint64) (int, error) {
+	return s.repo.GetUnreadCount(ctx, userID)
 }
 
 // GetMessageByID retrieves a message by ID with permission check
-func (s *MessageService) GetMessageByID(userID, msgID uint64) (*model.Message, error) {
-	msg, err := s.repo.GetByID(msgID)
+func (s *MessageService) GetMessageByID(ctx context.Context, userID, msgID uint64) (*model.Message, error) {
+	msg, err := s.repo.GetByID(ctx, msgID)
 	if err != nil {
 		return nil, err
 	}
@@ -168,3 +760,229 @@ func (s *MessageService) GetMessageByID(userID, msgID uint64) (*model.Message, e
 
 	return msg, nil
 }
+
+// RecallMessage wipes a message the user sent within the recall window, for both sides
+func (s *MessageService) RecallMessage(ctx context.Context, userID, msgID uint64) (*model.Message, error) {
+	msg, err := s.repo.GetByID(ctx, msgID)
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		return nil, errors.New("消息不存在")
+	}
+	if msg.SenderID != userID {
+		return nil, errors.New("只能撤回自己发送的消息")
+	}
+	if msg.RecalledAt != nil {
+		return nil, errors.New("消息已撤回")
+	}
+
+	now := time.Now()
+	window := time.Duration(s.config.Chat.RecallWindowSeconds) * time.Second
+	ok, err := s.repo.Recall(ctx, msgID, userID, now.Add(-window), now)
+	if err != nil {
+		logger.Error("Recall message failed", "message_id", msgID, "user_id", userID, "error", err)
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("消息发送时间过长，无法撤回")
+	}
+
+	// replies keep their own text but must no longer show the recalled content
+	if err := s.repo.UpdateReplySnippets(ctx, msgID, model.RecalledMessageContent); err != nil {
+		logger.Error("Update reply snippets of recalled message failed", "message_id", msgID, "error", err)
+	}
+
+	msg.Content = model.RecalledMessageContent
+	msg.MsgType = model.MsgTypeRecalled
+	msg.Duration = 0
+	msg.RecalledAt = &now
+	msg.ReplyTo = nil
+	if sender, _ := s.userRepo.GetByID(ctx, msg.SenderID); sender != nil {
+		msg.SenderOpenID = sender.OpenID
+	}
+	if receiver, _ := s.userRepo.GetByID(ctx, msg.ReceiverID); receiver != nil {
+		msg.ReceiverOpenID = receiver.OpenID
+	}
+
+	logger.Info("Message recalled", "message_id", msgID, "user_id", userID)
+	return msg, nil
+}
+
+// DeleteMessage hides a message from the user's own view, the peer still sees it
+func (s *MessageService) DeleteMessage(ctx context.Context, userID, msgID uint64) error {
+	if _, err := s.GetMessageByID(ctx, userID, msgID); err != nil {
+		return err
+	}
+	return s.repo.DeleteForUser(ctx, msgID, userID)
+}
+
+// searchSnippetRunes is the longest highlight snippet returned for a search result
+const searchSnippetRunes = 100
+
+// SearchMessages searches text messages in the user's own conversations
+func (s *MessageService) SearchMessages(ctx context.Context, userID uint64, req *model.MessageSearchReq) (*model.MessageSearchResp, error) {
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	terms, query, err := buildSearchQuery(req.Keyword)
+	if err != nil {
+		return nil, err
+	}
+
+	var from, to *time.Time
+	if req.StartDate != "" {
+		t, err := time.ParseInLocation("2006-01-02", req.StartDate, time.Local)
+		if err != nil {
+			return nil, errors.New("开始日期格式错误")
+		}
+		from = &t
+	}
+	if req.EndDate != "" {
+		t, err := time.ParseInLocation("2006-01-02", req.EndDate, time.Local)
+		if err != nil {
+			return nil, errors.New("结束日期格式错误")
+		}
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+	if from != nil && to != nil && !to.After(*from) {
+		return nil, errors.New("结束日期不能早于开始日期")
+	}
+
+	var peerID uint64
+	if req.PeerID != "" {
+		peer, err := s.userRepo.GetByOpenID(ctx, req.PeerID)
+		if err != nil {
+			return nil, err
+		}
+		if peer == nil {
+			return nil, errors.New("用户不存在")
+		}
+		peerID = peer.ID
+	}
+
+	messages, hasMore, err := s.repo.Search(ctx, userID, peerID, query, from, to, req.BeforeID, req.PageSize)
+	if err != nil {
+		logger.Error("Search messages failed", "user_id", userID, "error", err)
+		return nil, errors.New("搜索消息失败")
+	}
+
+	userCache := make(map[uint64]*model.User)
+	list := make([]*model.MessageSearchResult, 0, len(messages))
+	for _, msg := range messages {
+		otherID := msg.SenderID
+		if otherID == userID {
+			otherID = msg.ReceiverID
+		}
+		if _, ok := userCache[otherID]; !ok {
+			user, _ := s.userRepo.GetByID(ctx, otherID)
+			userCache[otherID] = user
+		}
+		list = append(list, &model.MessageSearchResult{
+			Message:   msg,
+			Peer:      userCache[otherID],
+			Highlight: highlightSnippet(msg.Content, terms),
+		})
+	}
+
+	return &model.MessageSearchResp{
+		List:    list,
+		HasMore: hasMore,
+	}, nil
+}
+
+// buildSearchQuery turns the keyword into a boolean mode query requiring every term as a phrase.
+// The ngram parser indexes 2-character tokens, so shorter terms can never match.
+func buildSearchQuery(keyword string) ([]string, string, error) {
+	clean := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`+-<>()~*"@`, r) {
+			return ' '
+		}
+		return r
+	}, keyword)
+
+	terms := strings.Fields(clean)
+	if len(terms) == 0 {
+		return nil, "", errors.New("请输入搜索关键词")
+	}
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < 2 {
+			return nil, "", errors.New("搜索关键词每个词至少2个字")
+		}
+		parts = append(parts, `+"`+term+`"`)
+	}
+	return terms, strings.Join(parts, " "), nil
+}
+
+// highlightSnippet returns the HTML escaped content around the first match,
+// with every occurrence of the terms wrapped in <em></em>
+func highlightSnippet(content string, terms []string) string {
+	runes := []rune(content)
+	lower := []rune(strings.ToLower(content))
+	if len(lower) != len(runes) {
+		// lowercasing changed the length, match case-sensitively
+		lower = runes
+	}
+	lowerTerms := make([][]rune, 0, len(terms))
+	for _, term := range terms {
+		lowerTerms = append(lowerTerms, []rune(strings.ToLower(term)))
+	}
+
+	// matchAt returns the length of the term matching at i, 0 if none
+	matchAt := func(i int) int {
+		for _, term := range lowerTerms {
+			if i+len(term) <= len(lower) && string(lower[i:i+len(term)]) == string(term) {
+				return len(term)
+			}
+		}
+		return 0
+	}
+
+	start, end := 0, len(runes)
+	if len(runes) > searchSnippetRunes {
+		first := 0
+		for i := range lower {
+			if matchAt(i) > 0 {
+				first = i
+				break
+			}
+		}
+		start = first - searchSnippetRunes/5
+		if start < 0 {
+			start = 0
+		}
+		end = start + searchSnippetRunes
+		if end > len(runes) {
+			end = len(runes)
+			start = end - searchSnippetRunes
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	plain := start
+	for i := start; i < end; {
+		n := matchAt(i)
+		if n == 0 {
+			i++
+			continue
+		}
+		if i+n > end {
+			n = end - i
+		}
+		b.WriteString(html.EscapeString(string(runes[plain:i])))
+		b.WriteString("<em>" + html.EscapeString(string(runes[i:i+n])) + "</em>")
+		i += n
+		plain = i
+	}
+	b.WriteString(html.EscapeString(string(runes[plain:end])))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
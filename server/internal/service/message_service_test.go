@@ -0,0 +1,89 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"pinche/internal/model"
+)
+
+func TestRetryOf(t *testing.T) {
+	stored := &model.Message{ID: 7, SenderID: 1, ReceiverID: 2, ClientMsgID: "c1"}
+
+	got, err := retryOf(nil, 2)
+	if got != nil || err != nil {
+		t.Errorf("retryOf(nil) = %v, %v, want nil, nil", got, err)
+	}
+
+	got, err = retryOf(stored, 2)
+	if got != stored || err != nil {
+		t.Errorf("retryOf(same receiver) = %v, %v, want the stored message", got, err)
+	}
+
+	got, err = retryOf(stored, 3)
+	if got != nil || err == nil {
+		t.Errorf("retryOf(other receiver) = %v, %v, want an error", got, err)
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	long := strings.Repeat("啊", 150) + "拼车" + strings.Repeat("哦", 150)
+
+	tests := []struct {
+		name    string
+		content string
+		terms   []string
+		want    string
+	}{
+		{"single match", "明天去上海拼车吗", []string{"拼车"}, "明天去上海<em>拼车</em>吗"},
+		{"every occurrence", "拼车拼车", []string{"拼车"}, "<em>拼车</em><em>拼车</em>"},
+		{"several terms", "北京到上海", []string{"北京", "上海"}, "<em>北京</em>到<em>上海</em>"},
+		{"case insensitive", "Go to SHANGHAI", []string{"shanghai"}, "Go to <em>SHANGHAI</em>"},
+		{"no match", "你好", []string{"拼车"}, "你好"},
+		{"escapes html", "<b>拼车</b>", []string{"拼车"}, "&lt;b&gt;<em>拼车</em>&lt;/b&gt;"},
+		{"escapes html in match", "a<b", []string{"<"}, "a<em>&lt;</em>b"},
+		{
+			"long content centred on the match", long, []string{"拼车"},
+			"…" + strings.Repeat("啊", 20) + "<em>拼车</em>" + strings.Repeat("哦", 78) + "…",
+		},
+		{
+			"match near the end", strings.Repeat("啊", 150) + "拼车", []string{"拼车"},
+			"…" + strings.Repeat("啊", 98) + "<em>拼车</em>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightSnippet(tt.content, tt.terms); got != tt.want {
+				t.Errorf("highlightSnippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSyncCursor(t *testing.T) {
+	tests := []struct {
+		cursor string
+		want   syncCursor
+		ok     bool
+	}{
+		{"", syncCursor{}, true},
+		{"1700000000000_42", syncCursor{messageMs: 1700000000000, messageID: 42}, true},
+		{"1700000000000_42_7_1700000000500_3", syncCursor{1700000000000, 42, 7, 1700000000500, 3}, true},
+		{"1700000000000", syncCursor{}, false},
+		{"1_2_3", syncCursor{}, false},
+		{"a_2", syncCursor{}, false},
+		{"1_-2", syncCursor{}, false},
+		{"1_2_3_4_5_6", syncCursor{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseSyncCursor(tt.cursor)
+		if (err == nil) != tt.ok || (tt.ok && got != tt.want) {
+			t.Errorf("parseSyncCursor(%q) = %+v, %v, want %+v, ok %v", tt.cursor, got, err, tt.want, tt.ok)
+		}
+	}
+
+	c := syncCursor{1700000000000, 42, 7, 1700000000500, 3}
+	if got, err := parseSyncCursor(c.String()); err != nil || got != c {
+		t.Errorf("parseSyncCursor(%q) = %+v, %v, want %+v", c.String(), got, err, c)
+	}
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"pinche/config"
+	"pinche/internal/logger"
+	"pinche/internal/model"
+	"pinche/internal/moderation"
+	"pinche/internal/repository"
+)
+
+// ModerationService filters user content against the sensitive word dictionary
+// and keeps the list of flagged content for admin review
+type ModerationService struct {
+	repo   *repository.ModerationRepository
+	config *config.Config
+
+	mu      sync.RWMutex
+	matcher *moderation.Matcher
+	words   []*model.SensitiveWord // indexed like the matcher patterns
+}
+
+func NewModerationService(cfg *config.Config) *ModerationService {
+	s := &ModerationService{
+		repo:   repository.NewModerationRepository(),
+		config: cfg,
+	}
+	if err := s.ReloadWords(context.Background()); err != nil {
+		logger.Error("Load sensitive words failed", "error", err)
+	}
+	return s
+}
+
+// ReloadWords rebuilds the matcher from the dictionary, run periodically so changes
+// made through other instances are picked up
+func (s *ModerationService) ReloadWords(ctx context.Context) error {
+	words, err := s.repo.ListAllWords(ctx)
+	if err != nil {
+		return err
+	}
+	patterns := make([]string, len(words))
+	for i, w := range words {
+		patterns[i] = w.Word
+	}
+	matcher := moderation.NewMatcher(patterns)
+
+	s.mu.Lock()
+	s.matcher = matcher
+	s.words = words
+	s.mu.Unlock()
+	return nil
+}
+
+// Check runs content of a scene through the dictionary. It returns an error when a block rule
+// matches, otherwise the text to store with mask rules applied and the words of flag rules found.
+// Phone numbers and WeChat IDs are also detected in trip remarks when enabled in config.
+func (s *ModerationService) Check(scene string, userID uint64, text string) (*model.ModerationResult, error) {
+	result := &model.ModerationResult{Scene: scene, UserID: userID, Text: text}
+	if strings.TrimSpace(text) == "" {
+		return result, nil
+	}
+
+	s.mu.RLock()
+	matcher, words := s.matcher, s.words
+	s.mu.RUnlock()
+
+	var masked []moderation.Match
+	seen := make(map[string]bool)
+	for _, m := range matcher.FindAll(text) {
+		w := words[m.Pattern]
+		switch w.Action {
+		case model.ModerationActionBlock:
+			logger.Info("Content blocked", "scene", scene, "user_id", userID, "word", w.Word)
+			return nil, errors.New("内容包含违规信息，请修改后重试")
+		case model.ModerationActionMask:
+			masked = append(masked, m)
+		case model.ModerationActionFlag:
+			if !seen[w.Word] {
+				seen[w.Word] = true
+				result.Flagged = append(result.Flagged, w.Word)
+			}
+		}
+	}
+
+	if scene == model.ModerationSceneTripRemark && s.config.Moderation.ContactDetection {
+		contacts := moderation.FindContacts(text)
+		if len(contacts) > 0 {
+			switch s.config.Moderation.ContactAction {
+			case model.ModerationActionBlock:
+				logger.Info("Content blocked for contact info", "scene", scene, "user_id", userID)
+				return nil, errors.New("行程备注中不能包含手机号或微信号，请通过站内私信联系")
+			case model.ModerationActionFlag:
+				for _, c := range contacts {
+					word := model.ContactWordPhone
+					if c.Pattern == 1 {
+						word = model.ContactWordWechat
+					}
+					if !seen[word] {
+						seen[word] = true
+						result.Flagged = append(result.Flagged, word)
+					}
+				}
+			default:
+				masked = append(masked, contacts...)
+			}
+		}
+	}
+
+	result.Text = moderation.Mask(text, masked)
+	return result, nil
+}
+
+// Flag adds the content to the review list if flag rules matched. targetID is the ID of
+// the stored trip, message, friend request or user. Failures are logged, not returned,
+// the content is already saved.
+func (s *ModerationService) Flag(ctx context.Context, result *model.ModerationResult, targetID uint64) {
+	if result == nil || len(result.Flagged) == 0 {
+		return
+	}
+	flag := &model.ContentFlag{
+		UserID:       result.UserID,
+		Scene:        result.Scene,
+		TargetID:     targetID,
+		Content:      result.Text,
+		MatchedWords: strings.Join(result.Flagged, ","),
+	}
+	if err := s.repo.CreateFlag(ctx, flag); err != nil {
+		logger.Error("Create content flag failed", "scene", result.Scene, "user_id", result.UserID, "target_id", targetID, "error", err)
+		return
+	}
+	logger.Info("Content flagged for review", "flag_id", flag.ID, "scene", result.Scene, "user_id", result.UserID, "target_id", targetID)
+}
+
+// Admin functions
+
+func (s *ModerationService) ListWords(ctx context.Context, req *model.SensitiveWordListReq) (*model.SensitiveWordListResp, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 || req.PageSize > 100 {
+		req.PageSize = 20
+	}
+
+	words, total, err := s.repo.ListWords(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if words == nil {
+		words = []*model.SensitiveWord{}
+	}
+	return &model.SensitiveWordListResp{List: words, Total: total}, nil
+}
+
+// dictionary errors shown to the admin as they are, any other failure is logged and reported as a fixed message
+var (
+	ErrSensitiveWordEmpty    = errors.New("敏感词不能为空")
+	ErrSensitiveWordExists   = errors.New("敏感词已存在")
+	ErrSensitiveWordNotFound = errors.New("敏感词不存在")
+)
+
+func (s *ModerationService) CreateWord(ctx context.Context, req *model.SensitiveWordCreateReq, adminID uint64, adminUsername string) (*model.SensitiveWord, error) {
+	word := strings.TrimSpace(req.Word)
+	if word == "" {
+		return nil, ErrSensitiveWordEmpty
+	}
+	existing, err := s.repo.GetWordByWord(ctx, word)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrSensitiveWordExists
+	}
+
+	w := &model.SensitiveWord{
+		Word:          word,
+		Action:        req.Action,
+		AdminID:       adminID,
+		AdminUsername: adminUsername,
+	}
+	if err := s.repo.CreateWord(ctx, w); err != nil {
+		// another admin may have added the same word concurrently
+		if existing, _ := s.repo.GetWordByWord(ctx, word); existing != nil {
+			return nil, ErrSensitiveWordExists
+		}
+		return nil, err
+	}
+	s.reloadAfterChange(ctx)
+	return s.repo.GetWordByID(ctx, w.ID)
+}
+
+// UpdateWord changes the action of a word, returning the word before and after
+func (s *ModerationService) UpdateWord(ctx context.Context, id uint64, req *model.SensitiveWordUpdateReq) (*model.SensitiveWord, *model.SensitiveWord, error) {
+	before, err := s.repo.GetWordByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if before == nil {
+		return nil, nil, ErrSensitiveWordNotFound
+	}
+	if err := s.repo.UpdateWordAction(ctx, id, req.Action); err != nil {
+		return nil, nil, err
+	}
+	s.reloadAfterChange(ctx)
+	after, err := s.repo.GetWordByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// DeleteWord removes a word, returning it for the audit log
+func (s *ModerationService) DeleteWord(ctx context.Context, id uint64) (*model.SensitiveWord, error) {
+	w, err := s.repo.GetWordByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, ErrSensitiveWordNotFound
+	}
+	if err := s.repo.DeleteWord(ctx, id); err != nil {
+		return nil, err
+	}
+	s.reloadAfterChange(ctx)
+	return w, nil
+}
+
+// reloadAfterChange applies a dictionary change on this instance right away
+func (s *ModerationService) reloadAfterChange(ctx context.Context) {
+	if err := s.ReloadWords(ctx); err != nil {
+		logger.Error("Reload sensitive words failed", "error", err)
+	}
+}
+
+func (s *ModerationService) ListFlags(ctx context.Context, req *model.ContentFlagListReq) (*model.ContentFlagListResp, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 || req.PageSize > 100 {
+		req.PageSize = 20
+	}
+
+	flags, total, err := s.repo.ListFlags(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if flags == nil {
+		flags = []*model.ContentFlag{}
+	}
+	return &model.ContentFlagListResp{List: flags, Total: total}, nil
+}
+
+// ReviewFlag closes a pending flag as approved (content is fine) or rejected (violation),
+// moderators act on rejected content with the existing ban tools
+func (s *ModerationService) ReviewFlag(ctx context.Context, id uint64, approve bool, note string, adminID uint64, adminUsername string) (*model.ContentFlag, error) {
+	flag, err := s.repo.GetFlagByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if flag == nil {
+		return nil, errors.New("审核记录不存在")
+	}
+
+	status := int8(model.ContentFlagStatusRejected)
+	if approve {
+		status = model.ContentFlagStatusApproved
+	}
+	ok, err := s.repo.ReviewFlag(ctx, id, status, adminID, adminUsername, note, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("该内容已审核")
+	}
+
+	logger.Info("Content flag reviewed", "flag_id", id, "status", status, "admin_id", adminID)
+	return s.repo.GetFlagByID(ctx, id)
+}
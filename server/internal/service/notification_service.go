@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"pinche/internal/model"
 	"pinche/internal/repository"
 )
@@ -15,7 +16,7 @@ func NewNotificationService() *NotificationService {
 	}
 }
 
-func (s *NotificationService) GetList(userID uint64, page, pageSize int) (*model.NotificationListResp, error) {
+func (s *NotificationService) GetList(ctx context.Context, userID uint64, page, pageSize int) (*model.NotificationListResp, error) {
 	if page <= 0 {
 		page = 1
 	}
@@ -23,7 +24,7 @@ func (s *NotificationService) GetList(userID uint64, page, pageSize int) (*model
 		pageSize = 20
 	}
 
-	list, total, unread, err := s.repo.GetByUserID(userID, page, pageSize)
+	list, total, unread, err := s.repo.GetByUserID(ctx, userID, page, pageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -35,10 +36,10 @@ func (s *NotificationService) GetList(userID uint64, page, pageSize int) (*model
 	}, nil
 }
 
-func (s *NotificationService) MarkAsRead(id, userID uint64) error {
-	return s.repo.MarkAsRead(id, userID)
+func (s *NotificationService) MarkAsRead(ctx context.Context, id, userID uint64) error {
+	return s.repo.MarkAsRead(ctx, id, userID)
 }
 
-func (s *NotificationService) MarkAllAsRead(userID uint64) error {
-	return s.repo.MarkAllAsRead(userID)
+func (s *NotificationService) MarkAllAsRead(ctx context.Context, userID uint64) error {
+	return s.repo.MarkAllAsRead(ctx, userID)
 }
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"pinche/config"
+	"pinche/internal/logger"
+	"pinche/internal/model"
+	"pinche/internal/repository"
+)
+
+const (
+	maxStatsHourRangeDays = 31
+	maxStatsDayRangeDays  = 366
+)
+
+// StatsService serves admin dashboards from rollup tables maintained by a background job
+type StatsService struct {
+	repo       *repository.StatsRepository
+	config     *config.Config
+	backfilled bool
+}
+
+func NewStatsService(cfg *config.Config) *StatsService {
+	return &StatsService{
+		repo:   repository.NewStatsRepository(),
+		config: cfg,
+	}
+}
+
+// Rollup recomputes the recent window of the rollup tables, run periodically by the job scheduler
+// The first run backfills history when the rollup tables are empty
+func (s *StatsService) Rollup(ctx context.Context) error {
+	now := time.Now()
+	hourTo := now.Truncate(time.Hour).Add(time.Hour)
+	hourFrom := hourTo.Add(-time.Duration(s.config.Job.StatsRollupWindowHours) * time.Hour)
+	dayTo := startOfDay(now).AddDate(0, 0, 1)
+	dayFrom := dayTo.AddDate(0, 0, -s.config.Job.StatsFunnelWindowDays)
+
+	if !s.backfilled {
+		hasData, err := s.repo.HasHourlyData(ctx)
+		if err != nil {
+			return err
+		}
+		if !hasData {
+			backfillFrom := startOfDay(now).AddDate(0, 0, -s.config.Job.StatsBackfillDays)
+			hourFrom = backfillFrom
+			dayFrom = backfillFrom
+			logger.Info("Backfilling stats rollup", "from", backfillFrom.Format("2006-01-02"))
+		}
+		s.backfilled = true
+	}
+
+	for _, metric := range model.StatsMetrics {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.repo.RollupHourly(ctx, metric, hourFrom, hourTo); err != nil {
+			logger.Error("Rollup hourly stats failed", "metric", metric, "error", err)
+			return err
+		}
+	}
+	if err := s.repo.RollupRouteFunnel(ctx, dayFrom, dayTo); err != nil {
+		logger.Error("Rollup route funnel failed", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Timeseries returns metric values per hour or day over a date range, empty buckets are zero
+func (s *StatsService) Timeseries(ctx context.Context, req *model.StatsTimeseriesReq) (*model.StatsTimeseriesResp, error) {
+	if req.Granularity != model.StatsGranularityDay && req.Granularity != model.StatsGranularityHour {
+		return nil, errors.New("无效的时间粒度")
+	}
+
+	maxDays := maxStatsDayRangeDays
+	if req.Granularity == model.StatsGranularityHour {
+		maxDays = maxStatsHourRangeDays
+	}
+	from, to, err := parseStatsDateRange(req.StartDate, req.EndDate, maxDays)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := model.StatsMetrics
+	if req.Metrics != "" {
+		metrics = nil
+		for _, m := range strings.Split(req.Metrics, ",") {
+			m = strings.TrimSpace(m)
+			if !model.IsValidStatsMetric(m) {
+				return nil, errors.New("无效的统计指标: " + m)
+			}
+			metrics = append(metrics, m)
+		}
+	}
+
+	points, err := s.repo.QueryTimeseries(ctx, metrics, req.Granularity, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// build the full bucket axis so gaps show up as zero
+	var buckets []string
+	if req.Granularity == model.StatsGranularityHour {
+		for t := from; t.Before(to); t = t.Add(time.Hour) {
+			buckets = append(buckets, t.Format("2006-01-02 15:00"))
+		}
+	} else {
+		for t := from; t.Before(to); t = t.AddDate(0, 0, 1) {
+			buckets = append(buckets, t.Format("2006-01-02"))
+		}
+	}
+	index := make(map[string]int, len(buckets))
+	for i, b := range buckets {
+		index[b] = i
+	}
+
+	resp := &model.StatsTimeseriesResp{
+		Granularity: req.Granularity,
+		Buckets:     buckets,
+		Series:      make(map[string][]int64, len(metrics)),
+		Totals:      make(map[string]int64, len(metrics)),
+	}
+	for _, metric := range metrics {
+		values := make([]int64, len(buckets))
+		var total int64
+		for _, p := range points[metric] {
+			if i, ok := index[p.Bucket]; ok {
+				values[i] = p.Value
+				total += p.Value
+			}
+		}
+		resp.Series[metric] = values
+		resp.Totals[metric] = total
+	}
+	return resp, nil
+}
+
+// Funnel returns the publish -> engaged -> confirmed -> completed funnel by route
+func (s *StatsService) Funnel(ctx context.Context, req *model.StatsFunnelReq) (*model.StatsFunnelResp, error) {
+	from, to, err := parseStatsDateRange(req.StartDate, req.EndDate, maxStatsDayRangeDays)
+	if err != nil {
+		return nil, err
+	}
+	if req.Limit <= 0 || req.Limit > 200 {
+		req.Limit = 50
+	}
+
+	total, err := s.repo.QueryFunnelTotal(ctx, req, from, to)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := s.repo.QueryRouteFunnel(ctx, req, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if routes == nil {
+		routes = []*model.RouteFunnel{}
+	}
+
+	fillFunnelRates(total)
+	for _, r := range routes {
+		fillFunnelRates(r)
+	}
+	return &model.StatsFunnelResp{
+		Total:  total,
+		Routes: routes,
+	}, nil
+}
+
+func fillFunnelRates(f *model.RouteFunnel) {
+	if f.Published == 0 {
+		return
+	}
+	published := float64(f.Published)
+	f.EngagedRate = float64(f.Engaged) / published
+	f.ConfirmedRate = float64(f.Confirmed) / published
+	f.CompletedRate = float64(f.Completed) / published
+}
+
+// parseStatsDateRange parses inclusive YYYY-MM-DD dates into a [from, to) range
+func parseStatsDateRange(startDate, endDate string, maxDays int) (time.Time, time.Time, error) {
+	from, err := time.ParseInLocation("2006-01-02", startDate, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("开始日期格式错误")
+	}
+	end, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("结束日期格式错误")
+	}
+	if end.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("结束日期不能早于开始日期")
+	}
+	to := end.AddDate(0, 0, 1)
+	if to.Sub(from) > time.Duration(maxDays)*24*time.Hour {
+		return time.Time{}, time.Time{}, errors.New("查询时间范围过大")
+	}
+	return from, to, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
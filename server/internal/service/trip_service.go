@@ -1,12 +1,15 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"pinche/internal/cache"
+	"pinche/internal/job"
 	"pinche/internal/logger"
 	"pinche/internal/model"
 	"pinche/internal/repository"
@@ -18,24 +21,32 @@ type TripService struct {
 	userRepo     *repository.UserRepository
 	notifyRepo   *repository.NotificationRepository
 	matchService *MatchService
+	groupService *GroupService
+	moderation   *ModerationService
 	wsHub        *websocket.Hub
+	workers      *job.Group
 	tripCache    *cache.TripCache
+	routeCache   *cache.RouteCache
 }
 
-func NewTripService(matchService *MatchService, wsHub *websocket.Hub) *TripService {
+func NewTripService(matchService *MatchService, groupService *GroupService, moderation *ModerationService, wsHub *websocket.Hub, workers *job.Group) *TripService {
 	return &TripService{
 		repo:         repository.NewTripRepository(),
 		userRepo:     repository.NewUserRepository(),
 		notifyRepo:   repository.NewNotificationRepository(),
 		matchService: matchService,
+		groupService: groupService,
+		moderation:   moderation,
 		wsHub:        wsHub,
+		workers:      workers,
 		tripCache:    cache.NewTripCache(),
+		routeCache:   cache.NewRouteCache(),
 	}
 }
 
-func (s *TripService) Create(userID uint64, req *model.TripCreateReq) (*model.Trip, error) {
+func (s *TripService) Create(ctx context.Context, userID uint64, req *model.TripCreateReq) (*model.Trip, error) {
 	// check active trips limit (max 2)
-	activeCount, err := s.repo.CountActiveByUserID(userID)
+	activeCount, err := s.repo.CountActiveByUserID(ctx, userID)
 	if err != nil {
 		logger.Error("Count active trips failed", "user_id", userID, "error", err)
 		return nil, errors.New("查询行程数量失败")
@@ -46,7 +57,7 @@ func (s *TripService) Create(userID uint64, req *model.TripCreateReq) (*model.Tr
 	}
 
 	// check daily publish limit (max 5)
-	todayCount, err := s.repo.CountTodayByUserID(userID)
+	todayCount, err := s.repo.CountTodayByUserID(ctx, userID)
 	if err != nil {
 		logger.Error("Count today trips failed", "user_id", userID, "error", err)
 		return nil, errors.New("查询今日发布数量失败")
@@ -65,6 +76,11 @@ func (s *TripService) Create(userID uint64, req *model.TripCreateReq) (*model.Tr
 		return nil, errors.New("出发时间不能早于当前时间")
 	}
 
+	remark, err := s.moderation.Check(model.ModerationSceneTripRemark, userID, req.Remark)
+	if err != nil {
+		return nil, err
+	}
+
 	trip := &model.Trip{
 		UserID:              userID,
 		TripType:            req.TripType,
@@ -81,15 +97,16 @@ func (s *TripService) Create(userID uint64, req *model.TripCreateReq) (*model.Tr
 		DepartureTime:       departureTime,
 		Seats:               req.Seats,
 		Price:               req.Price,
-		Remark:              req.Remark,
+		Remark:              remark.Text,
 		Images:              req.Images,
 		Status:              model.TripStatusPending,
 	}
 
-	if err := s.repo.Create(trip); err != nil {
+	if err := s.repo.Create(ctx, trip); err != nil {
 		logger.Error("Create trip failed", "user_id", userID, "error", err)
 		return nil, err
 	}
+	s.moderation.Flag(ctx, remark, trip.ID)
 
 	logger.Info("Trip created",
 		"trip_id", trip.ID,
@@ -99,22 +116,22 @@ func (s *TripService) Create(userID uint64, req *model.TripCreateReq) (*model.Tr
 		"to", req.DestinationCity)
 
 	// invalidate trip list cache
-	go s.tripCache.InvalidateTripLists()
+	s.workers.Go(ctx, "invalidate_trip_lists", func(ctx context.Context) { s.tripCache.InvalidateTripLists(ctx) })
 
 	// async match
-	go s.matchService.FindAndNotifyMatches(trip)
+	s.workers.Go(ctx, "find_matches", func(ctx context.Context) { s.matchService.FindAndNotifyMatches(ctx, trip) })
 
 	return trip, nil
 }
 
-func (s *TripService) GetByID(id uint64) (*model.Trip, error) {
+func (s *TripService) GetByID(ctx context.Context, id uint64) (*model.Trip, error) {
 	// Cache Aside: try cache first
-	if trip, err := s.tripCache.GetTrip(id); err == nil && trip != nil {
+	if trip, err := s.tripCache.GetTrip(ctx, id); err == nil && trip != nil {
 		return trip, nil
 	}
 
 	// cache miss, get from DB
-	trip, err := s.repo.GetByID(id)
+	trip, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -123,17 +140,17 @@ func (s *TripService) GetByID(id uint64) (*model.Trip, error) {
 	}
 
 	// store in cache
-	go s.tripCache.SetTrip(trip)
+	s.workers.Go(ctx, "cache_trip", func(ctx context.Context) { s.tripCache.SetTrip(ctx, trip) })
 	return trip, nil
 }
 
 // GetByIDAndIncrementView gets trip by ID and increments view count (for non-owner views)
-func (s *TripService) GetByIDAndIncrementView(id uint64, viewerID uint64) (*model.Trip, error) {
+func (s *TripService) GetByIDAndIncrementView(ctx context.Context, id uint64, viewerID uint64) (*model.Trip, error) {
 	// Cache Aside: try cache first
-	trip, err := s.tripCache.GetTrip(id)
+	trip, err := s.tripCache.GetTrip(ctx, id)
 	if err != nil || trip == nil {
 		// cache miss, get from DB
-		trip, err = s.repo.GetByID(id)
+		trip, err = s.repo.GetByID(ctx, id)
 		if err != nil {
 			return nil, err
 		}
@@ -141,22 +158,22 @@ func (s *TripService) GetByIDAndIncrementView(id uint64, viewerID uint64) (*mode
 			return nil, nil
 		}
 		// store in cache
-		go s.tripCache.SetTrip(trip)
+		s.workers.Go(ctx, "cache_trip", func(ctx context.Context) { s.tripCache.SetTrip(ctx, trip) })
 	}
 
 	// only increment view count if viewer is not the owner
 	if trip.UserID != viewerID {
-		go s.repo.IncrementViewCount(id)
+		s.workers.Go(ctx, "increment_view_count", func(ctx context.Context) { s.repo.IncrementViewCount(ctx, id) })
 	}
 	return trip, nil
 }
 
 // GetMyTripDetail gets trip detail with grabbers list (only for trip owner)
-func (s *TripService) GetMyTripDetail(tripID uint64, userID uint64) (*model.Trip, error) {
+func (s *TripService) GetMyTripDetail(ctx context.Context, tripID uint64, userID uint64) (*model.Trip, error) {
 	// Cache Aside: try cache first for basic trip info
-	trip, err := s.tripCache.GetTrip(tripID)
+	trip, err := s.tripCache.GetTrip(ctx, tripID)
 	if err != nil || trip == nil {
-		trip, err = s.repo.GetByID(tripID)
+		trip, err = s.repo.GetByID(ctx, tripID)
 		if err != nil {
 			return nil, err
 		}
@@ -164,7 +181,7 @@ func (s *TripService) GetMyTripDetail(tripID uint64, userID uint64) (*model.Trip
 			return nil, errors.New("行程不存在")
 		}
 		// store in cache
-		go s.tripCache.SetTrip(trip)
+		s.workers.Go(ctx, "cache_trip", func(ctx context.Context) { s.tripCache.SetTrip(ctx, trip) })
 	}
 
 	if trip.UserID != userID {
@@ -172,7 +189,7 @@ func (s *TripService) GetMyTripDetail(tripID uint64, userID uint64) (*model.Trip
 	}
 
 	// get grabbers list (not cached as it changes frequently)
-	grabbers, err := s.repo.GetGrabsByTripID(tripID)
+	grabbers, err := s.repo.GetGrabsByTripID(ctx, tripID)
 	if err == nil {
 		trip.Grabbers = grabbers
 	}
@@ -180,7 +197,7 @@ func (s *TripService) GetMyTripDetail(tripID uint64, userID uint64) (*model.Trip
 	return trip, nil
 }
 
-func (s *TripService) List(req *model.TripListReq) (*model.TripListResp, error) {
+func (s *TripService) List(ctx context.Context, req *model.TripListReq) (*model.TripListResp, error) {
 	if req.Page <= 0 {
 		req.Page = 1
 	}
@@ -189,7 +206,7 @@ func (s *TripService) List(req *model.TripListReq) (*model.TripListResp, error)
 	}
 
 	// Cache Aside: try cache first
-	if cached, err := s.tripCache.GetTripList(req); err == nil && cached != nil {
+	if cached, err := s.tripCache.GetTripList(ctx, req); err == nil && cached != nil {
 		return &model.TripListResp{
 			List:  cached.List,
 			Total: cached.Total,
@@ -197,13 +214,13 @@ func (s *TripService) List(req *model.TripListReq) (*model.TripListResp, error)
 	}
 
 	// cache miss, get from DB
-	trips, total, err := s.repo.List(req)
+	trips, total, err := s.repo.List(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
 	// store in cache
-	go s.tripCache.SetTripList(req, trips, total)
+	s.workers.Go(ctx, "cache_trip_list", func(ctx context.Context) { s.tripCache.SetTripList(ctx, req, trips, total) })
 
 	return &model.TripListResp{
 		List:  trips,
@@ -211,12 +228,87 @@ func (s *TripService) List(req *model.TripListReq) (*model.TripListResp, error)
 	}, nil
 }
 
-func (s *TripService) GetMyTrips(userID uint64) ([]*model.Trip, error) {
-	return s.repo.GetByUserID(userID)
+// AdminRouteSupplyDemand returns supply and demand per route and departure date
+func (s *TripService) AdminRouteSupplyDemand(ctx context.Context, req *model.RouteSupplyDemandReq) ([]*model.RouteSupplyDemand, error) {
+	from, err := time.ParseInLocation("2006-01-02", req.StartDate, time.Local)
+	if err != nil {
+		return nil, errors.New("开始日期格式错误")
+	}
+	end, err := time.ParseInLocation("2006-01-02", req.EndDate, time.Local)
+	if err != nil {
+		return nil, errors.New("结束日期格式错误")
+	}
+	if end.Before(from) {
+		return nil, errors.New("结束日期不能早于开始日期")
+	}
+	if end.Sub(from) > 90*24*time.Hour {
+		return nil, errors.New("查询时间范围不能超过90天")
+	}
+	if req.Limit <= 0 || req.Limit > 500 {
+		req.Limit = 100
+	}
+	if req.MinTrips <= 0 {
+		req.MinTrips = 1
+	}
+
+	// cancelled and banned trips are neither supply nor demand
+	req.Statuses = []int8{model.TripStatusPending, model.TripStatusMatched, model.TripStatusCompleted}
+	req.GroupByDate = true
+
+	routes, err := s.repo.AggregateRouteSupplyDemand(ctx, req, from, end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	if routes == nil {
+		routes = []*model.RouteSupplyDemand{}
+	}
+	return routes, nil
 }
 
-func (s *TripService) Cancel(id uint64, userID uint64) error {
-	trip, err := s.repo.GetByID(id)
+// GetHotRoutes returns the busiest routes among open trips departing in the next days
+func (s *TripService) GetHotRoutes(ctx context.Context, req *model.HotRoutesReq) ([]*model.RouteSupplyDemand, error) {
+	if req.Days <= 0 || req.Days > 30 {
+		req.Days = 7
+	}
+	if req.Limit <= 0 || req.Limit > 50 {
+		req.Limit = 10
+	}
+	req.City = strings.TrimSpace(req.City)
+
+	// Cache Aside: try cache first
+	if cached, err := s.routeCache.GetHotRoutes(ctx, req); err == nil && cached != nil {
+		return cached, nil
+	}
+
+	now := time.Now()
+	routes, err := s.repo.AggregateRouteSupplyDemand(ctx, &model.RouteSupplyDemandReq{
+		City:     req.City,
+		Sort:     model.RouteSortVolume,
+		MinTrips: 1,
+		Limit:    req.Limit,
+		Statuses: []int8{model.TripStatusPending},
+	}, now, now.AddDate(0, 0, req.Days))
+	if err != nil {
+		return nil, err
+	}
+	if routes == nil {
+		routes = []*model.RouteSupplyDemand{}
+	}
+
+	// store in cache, a city without any trip is not cached so arbitrary city values cannot fill redis
+	if req.City == "" || len(routes) > 0 {
+		s.workers.Go(ctx, "cache_hot_routes", func(ctx context.Context) { s.routeCache.SetHotRoutes(ctx, req, routes) })
+	}
+
+	return routes, nil
+}
+
+func (s *TripService) GetMyTrips(ctx context.Context, userID uint64) ([]*model.Trip, error) {
+	return s.repo.GetByUserID(ctx, userID)
+}
+
+func (s *TripService) Cancel(ctx context.Context, id uint64, userID uint64) error {
+	trip, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -229,19 +321,20 @@ func (s *TripService) Cancel(id uint64, userID uint64) error {
 	if trip.Status != model.TripStatusPending {
 		return errors.New("只能取消待匹配的行程")
 	}
-	if err := s.repo.UpdateStatus(id, model.TripStatusCancelled); err != nil {
+	if err := s.repo.UpdateStatus(ctx, id, model.TripStatusCancelled); err != nil {
 		return err
 	}
+	s.leaveTripGroup(ctx, trip)
 	// invalidate cache
-	go func() {
-		s.tripCache.InvalidateTrip(id)
-		s.tripCache.InvalidateTripLists()
-	}()
+	s.workers.Go(ctx, "invalidate_trip", func(ctx context.Context) {
+		s.tripCache.InvalidateTrip(ctx, id)
+		s.tripCache.InvalidateTripLists(ctx)
+	})
 	return nil
 }
 
-func (s *TripService) Complete(id uint64, userID uint64) error {
-	trip, err := s.repo.GetByID(id)
+func (s *TripService) Complete(ctx context.Context, id uint64, userID uint64) error {
+	trip, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -254,19 +347,20 @@ func (s *TripService) Complete(id uint64, userID uint64) error {
 	if trip.Status != model.TripStatusPending && trip.Status != model.TripStatusMatched {
 		return errors.New("只能标记待匹配或已匹配的行程为已成行")
 	}
-	if err := s.repo.UpdateStatus(id, model.TripStatusCompleted); err != nil {
+	if err := s.repo.UpdateStatus(ctx, id, model.TripStatusCompleted); err != nil {
 		return err
 	}
+	s.leaveTripGroup(ctx, trip)
 	// invalidate cache
-	go func() {
-		s.tripCache.InvalidateTrip(id)
-		s.tripCache.InvalidateTripLists()
-	}()
+	s.workers.Go(ctx, "invalidate_trip", func(ctx context.Context) {
+		s.tripCache.InvalidateTrip(ctx, id)
+		s.tripCache.InvalidateTripLists(ctx)
+	})
 	return nil
 }
 
-func (s *TripService) Delete(id uint64, userID uint64) error {
-	trip, err := s.repo.GetByID(id)
+func (s *TripService) Delete(ctx context.Context, id uint64, userID uint64) error {
+	trip, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -276,20 +370,34 @@ func (s *TripService) Delete(id uint64, userID uint64) error {
 	if trip.UserID != userID {
 		return errors.New("无权操作此行程")
 	}
-	if err := s.repo.Delete(id, userID); err != nil {
+	if err := s.repo.Delete(ctx, id, userID); err != nil {
 		return err
 	}
+	s.leaveTripGroup(ctx, trip)
 	// invalidate cache
-	go func() {
-		s.tripCache.InvalidateTrip(id)
-		s.tripCache.InvalidateTripLists()
-	}()
+	s.workers.Go(ctx, "invalidate_trip", func(ctx context.Context) {
+		s.tripCache.InvalidateTrip(ctx, id)
+		s.tripCache.InvalidateTripLists(ctx)
+	})
 	return nil
 }
 
+// leaveTripGroup closes the group of a driver trip, or removes the passenger from the groups joined with the trip
+func (s *TripService) leaveTripGroup(ctx context.Context, trip *model.Trip) {
+	var err error
+	if trip.TripType == model.TripTypeDriver {
+		err = s.groupService.CloseTripGroup(ctx, trip.ID)
+	} else {
+		err = s.groupService.LeaveByPassengerTrip(ctx, trip.ID)
+	}
+	if err != nil {
+		logger.Error("Update trip group failed", "trip_id", trip.ID, "error", err)
+	}
+}
+
 // Admin functions
 
-func (s *TripService) AdminListTrips(req *model.AdminTripListReq) (*model.TripListResp, error) {
+func (s *TripService) AdminListTrips(ctx context.Context, req *model.AdminTripListReq) (*model.TripListResp, error) {
 	if req.Page <= 0 {
 		req.Page = 1
 	}
@@ -297,7 +405,7 @@ func (s *TripService) AdminListTrips(req *model.AdminTripListReq) (*model.TripLi
 		req.PageSize = 20
 	}
 
-	trips, total, err := s.repo.AdminListAll(req)
+	trips, total, err := s.repo.AdminListAll(ctx, req)
 	if err != nil {
 		logger.Error("Admin list trips failed", "error", err)
 		return nil, err
@@ -309,38 +417,48 @@ func (s *TripService) AdminListTrips(req *model.AdminTripListReq) (*model.TripLi
 	}, nil
 }
 
-func (s *TripService) AdminBanTrip(id uint64) error {
-	logger.Info("Admin banning trip", "trip_id", id)
-	if err := s.repo.UpdateStatus(id, model.TripStatusBanned); err != nil {
-		return err
-	}
+// AdminBanTrip takes a trip the ban record already marked banned off its group and out of the caches
+func (s *TripService) AdminBanTrip(ctx context.Context, trip *model.Trip) {
+	logger.Info("Admin banning trip", "trip_id", trip.ID)
+	s.leaveTripGroup(ctx, trip)
 	// invalidate cache
-	go func() {
-		s.tripCache.InvalidateTrip(id)
-		s.tripCache.InvalidateTripLists()
-	}()
-	return nil
+	s.workers.Go(ctx, "invalidate_trip", func(ctx context.Context) {
+		s.tripCache.InvalidateTrip(ctx, trip.ID)
+		s.tripCache.InvalidateTripLists(ctx)
+	})
 }
 
-func (s *TripService) AdminUnbanTrip(id uint64) error {
-	logger.Info("Admin unbanning trip", "trip_id", id)
-	if err := s.repo.UpdateStatus(id, model.TripStatusPending); err != nil {
+// AdminUnbanTrip restores the status the trip had before it was banned
+func (s *TripService) AdminUnbanTrip(ctx context.Context, id uint64, status int8) error {
+	logger.Info("Admin unbanning trip", "trip_id", id, "status", status)
+	trip, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdateStatus(ctx, id, status); err != nil {
 		return err
 	}
+	// passengers removed with a banned passenger trip stay out, the group of an ongoing driver trip is back
+	ongoing := status == model.TripStatusPending || status == model.TripStatusMatched
+	if trip != nil && trip.TripType == model.TripTypeDriver && ongoing {
+		if err := s.groupService.ReopenTripGroup(ctx, id); err != nil {
+			logger.Error("Reopen trip group failed", "trip_id", id, "error", err)
+		}
+	}
 	// invalidate cache
-	go func() {
-		s.tripCache.InvalidateTrip(id)
-		s.tripCache.InvalidateTripLists()
-	}()
+	s.workers.Go(ctx, "invalidate_trip", func(ctx context.Context) {
+		s.tripCache.InvalidateTrip(ctx, id)
+		s.tripCache.InvalidateTripLists(ctx)
+	})
 	return nil
 }
 
 // GrabTrip handles when a user wants to grab/accept a trip
 // For driver trips (trip_type=1): passenger grabs the trip
 // For passenger trips (trip_type=2): driver grabs the trip
-func (s *TripService) GrabTrip(tripID uint64, grabberID uint64, message string) (*model.GrabTripResp, error) {
+func (s *TripService) GrabTrip(ctx context.Context, tripID uint64, grabberID uint64, message string) (*model.GrabTripResp, error) {
 	// get trip info
-	trip, err := s.repo.GetByID(tripID)
+	trip, err := s.repo.GetByID(ctx, tripID)
 	if err != nil {
 		logger.Error("Get trip failed for grab", "trip_id", tripID, "error", err)
 		return nil, errors.New("获取行程信息失败")
@@ -359,8 +477,15 @@ func (s *TripService) GrabTrip(tripID uint64, grabberID uint64, message string)
 		return nil, errors.New("该行程已不可抢单")
 	}
 
+	// the grab message is shown to the trip owner
+	checked, err := s.moderation.Check(model.ModerationSceneGrabMessage, grabberID, message)
+	if err != nil {
+		return nil, err
+	}
+	message = checked.Text
+
 	// get grabber info
-	grabber, err := s.userRepo.GetByID(grabberID)
+	grabber, err := s.userRepo.GetByID(ctx, grabberID)
 	if err != nil || grabber == nil {
 		logger.Error("Get grabber info failed", "grabber_id", grabberID, "error", err)
 		return nil, errors.New("获取用户信息失败")
@@ -372,10 +497,11 @@ func (s *TripService) GrabTrip(tripID uint64, grabberID uint64, message string)
 		UserID:  grabberID,
 		Message: message,
 	}
-	if err := s.repo.CreateGrab(grab); err != nil {
+	if err := s.repo.CreateGrab(ctx, grab); err != nil {
 		// ignore duplicate errors, continue to notify
 		logger.Debug("Create grab record failed (may be duplicate)", "trip_id", tripID, "grabber_id", grabberID, "error", err)
 	}
+	s.moderation.Flag(ctx, checked, tripID)
 
 	logger.Info("Trip grabbed",
 		"trip_id", tripID,
@@ -414,14 +540,14 @@ func (s *TripService) GrabTrip(tripID uint64, grabberID uint64, message string)
 		Title:   title,
 		Content: content,
 	}
-	if err := s.notifyRepo.Create(notification); err != nil {
+	if err := s.notifyRepo.Create(ctx, notification); err != nil {
 		logger.Error("Create grab notification failed", "trip_id", tripID, "owner_id", trip.UserID, "error", err)
 		return nil, errors.New("发送通知失败")
 	}
 
 	// send real-time notification via websocket
 	if s.wsHub != nil {
-		s.wsHub.SendToUser(trip.UserID, websocket.Message{
+		s.wsHub.SendToUserContext(ctx, trip.UserID, websocket.Message{
 			Type: "trip_grabbed",
 			Data: map[string]interface{}{
 				"trip_id":      tripID,
@@ -438,6 +564,48 @@ func (s *TripService) GrabTrip(tripID uint64, grabberID uint64, message string)
 	}, nil
 }
 
+// AcceptGrab lets the driver accept the booking of a passenger who grabbed the trip, the passenger joins the trip group
+func (s *TripService) AcceptGrab(ctx context.Context, tripID, ownerID uint64, passengerOpenID string) error {
+	trip, err := s.repo.GetByID(ctx, tripID)
+	if err != nil {
+		return err
+	}
+	if trip == nil {
+		return errors.New("行程不存在")
+	}
+	if trip.UserID != ownerID {
+		return errors.New("无权操作此行程")
+	}
+	if trip.TripType != model.TripTypeDriver {
+		return errors.New("只有司机行程可以接受预约")
+	}
+	if trip.Status != model.TripStatusPending && trip.Status != model.TripStatusMatched {
+		return errors.New("行程已结束，无法接受预约")
+	}
+
+	passenger, err := s.userRepo.GetByOpenID(ctx, passengerOpenID)
+	if err != nil {
+		return err
+	}
+	if passenger == nil {
+		return errors.New("用户不存在")
+	}
+	grabbed, err := s.repo.HasGrab(ctx, tripID, passenger.ID)
+	if err != nil {
+		return err
+	}
+	if !grabbed {
+		return errors.New("该用户未预约此行程")
+	}
+
+	if err := s.groupService.JoinTripGroup(ctx, tripID, passenger.ID, 0); err != nil {
+		logger.Error("Join trip group failed", "trip_id", tripID, "user_id", passenger.ID, "error", err)
+		return errors.New("接受预约失败")
+	}
+	logger.Info("Trip booking accepted", "trip_id", tripID, "owner_id", ownerID, "passenger_id", passenger.ID)
+	return nil
+}
+
 func (s *TripService) maskNickname(nickname string) string {
 	if nickname == "" {
 		return "用户**"
@@ -455,8 +623,8 @@ func (s *TripService) maskNickname(nickname string) string {
 // UpdateTrip updates a trip
 // Direct updates: images, remark, seats, price
 // Review required: departure/destination city/address, departure_time
-func (s *TripService) UpdateTrip(tripID uint64, userID uint64, req *model.TripUpdateReq) (bool, string, error) {
-	trip, err := s.repo.GetByID(tripID)
+func (s *TripService) UpdateTrip(ctx context.Context, tripID uint64, userID uint64, req *model.TripUpdateReq) (bool, string, error) {
+	trip, err := s.repo.GetByID(ctx, tripID)
 	if err != nil {
 		return false, "", errors.New("获取行程失败")
 	}
@@ -470,6 +638,15 @@ func (s *TripService) UpdateTrip(tripID uint64, userID uint64, req *model.TripUp
 		return false, "", errors.New("只能修改待匹配或已匹配的行程")
 	}
 
+	// check the new remark before anything is submitted
+	var remarkResult *model.ModerationResult
+	if req.Remark != "" {
+		remarkResult, err = s.moderation.Check(model.ModerationSceneTripRemark, userID, req.Remark)
+		if err != nil {
+			return false, "", err
+		}
+	}
+
 	needsReview := false
 	reviewMessage := ""
 
@@ -504,7 +681,7 @@ func (s *TripService) UpdateTrip(tripID uint64, userID uint64, req *model.TripUp
 			NewValue:   string(newJSON),
 			Status:     model.TripUpdateStatusPending,
 		}
-		if err := s.repo.CreateTripUpdate(update); err != nil {
+		if err := s.repo.CreateTripUpdate(ctx, update); err != nil {
 			return false, "", errors.New("提交修改审核失败")
 		}
 		reviewMessage += "起终点修改已提交审核；"
@@ -528,7 +705,7 @@ func (s *TripService) UpdateTrip(tripID uint64, userID uint64, req *model.TripUp
 				NewValue:   string(newJSON),
 				Status:     model.TripUpdateStatusPending,
 			}
-			if err := s.repo.CreateTripUpdate(update); err != nil {
+			if err := s.repo.CreateTripUpdate(ctx, update); err != nil {
 				return false, "", errors.New("提交时间修改审核失败")
 			}
 			reviewMessage += "出发时间修改已提交审核；"
@@ -540,20 +717,21 @@ func (s *TripService) UpdateTrip(tripID uint64, userID uint64, req *model.TripUp
 	if images == "" {
 		images = trip.Images
 	}
-	remark := req.Remark
-	if remark == "" {
-		remark = trip.Remark
+	remark := trip.Remark
+	if remarkResult != nil {
+		remark = remarkResult.Text
 	}
 
-	if err := s.repo.UpdateTrip(tripID, userID, images, remark, req.Seats, req.Price); err != nil {
+	if err := s.repo.UpdateTrip(ctx, tripID, userID, images, remark, req.Seats, req.Price); err != nil {
 		return false, "", errors.New("更新行程失败")
 	}
+	s.moderation.Flag(ctx, remarkResult, tripID)
 
 	// invalidate cache
-	go func() {
-		s.tripCache.InvalidateTrip(tripID)
-		s.tripCache.InvalidateTripLists()
-	}()
+	s.workers.Go(ctx, "invalidate_trip", func(ctx context.Context) {
+		s.tripCache.InvalidateTrip(ctx, tripID)
+		s.tripCache.InvalidateTripLists(ctx)
+	})
 
 	if needsReview {
 		return true, reviewMessage, nil
@@ -16,8 +16,10 @@ import (
 	"time"
 
 	"pinche/config"
+	"pinche/internal/tracing"
 
 	"github.com/tencentyun/cos-go-sdk-v5"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // UploadBizType defines the business type for upload
@@ -78,7 +80,7 @@ func NewUploadService(cfg *config.Config) *UploadService {
 
 // Upload handles file upload to COS based on biz type
 // Returns object key for private files, or public URL for avatar/trip
-func (s *UploadService) Upload(file *multipart.FileHeader, bizType UploadBizType) (string, error) {
+func (s *UploadService) Upload(ctx context.Context, file *multipart.FileHeader, bizType UploadBizType) (string, error) {
 	// validate biz type
 	if !IsValidBizType(bizType) {
 		bizType = BizTypeImage
@@ -128,8 +130,6 @@ func (s *UploadService) Upload(file *multipart.FileHeader, bizType UploadBizType
 	objectKey := fmt.Sprintf("%s/%s", bizType, filename)
 
 	// upload to COS
-	ctx := context.Background()
-
 	// trip images should be public-read
 	if bizType == BizTypeTrip {
 		opt := &cos.ObjectPutOptions{
@@ -137,7 +137,7 @@ func (s *UploadService) Upload(file *multipart.FileHeader, bizType UploadBizType
 				XCosACL: "public-read",
 			},
 		}
-		_, err = s.cosClient.Object.Put(ctx, objectKey, bytes.NewReader(content), opt)
+		err = s.putObject(ctx, objectKey, content, opt)
 		if err != nil {
 			return "", fmt.Errorf("上传到COS失败: %w", err)
 		}
@@ -184,7 +184,7 @@ func (s *UploadService) Upload(file *multipart.FileHeader, bizType UploadBizType
 		}
 	}
 
-	_, err = s.cosClient.Object.Put(ctx, objectKey, bytes.NewReader(content), putOpt)
+	err = s.putObject(ctx, objectKey, content, putOpt)
 	if err != nil {
 		return "", fmt.Errorf("上传到COS失败: %w", err)
 	}
@@ -194,7 +194,7 @@ func (s *UploadService) Upload(file *multipart.FileHeader, bizType UploadBizType
 }
 
 // UploadAvatar uploads avatar with openID as filename, returns the public URL
-func (s *UploadService) UploadAvatar(file *multipart.FileHeader, openID string) (string, error) {
+func (s *UploadService) UploadAvatar(ctx context.Context, file *multipart.FileHeader, openID string) (string, error) {
 	// validate file type
 	ext := strings.ToLower(filepath.Ext(file.Filename))
 	allowedExts := map[string]bool{
@@ -226,13 +226,12 @@ func (s *UploadService) UploadAvatar(file *multipart.FileHeader, openID string)
 	objectKey := fmt.Sprintf("avatar/%s%s", openID, ext)
 
 	// upload to COS with public-read ACL
-	ctx := context.Background()
 	opt := &cos.ObjectPutOptions{
 		ACLHeaderOptions: &cos.ACLHeaderOptions{
 			XCosACL: "public-read",
 		},
 	}
-	_, err = s.cosClient.Object.Put(ctx, objectKey, bytes.NewReader(content), opt)
+	err = s.putObject(ctx, objectKey, content, opt)
 	if err != nil {
 		return "", fmt.Errorf("上传到COS失败: %w", err)
 	}
@@ -242,6 +241,19 @@ func (s *UploadService) UploadAvatar(file *multipart.FileHeader, openID string)
 	return avatarURL, nil
 }
 
+// putObject uploads content to COS, traced as a span of the upload request
+func (s *UploadService) putObject(ctx context.Context, objectKey string, content []byte, opt *cos.ObjectPutOptions) (err error) {
+	ctx, span := tracing.Start(ctx, "cos PutObject",
+		attribute.String("cos.bucket", s.bucket),
+		attribute.String("cos.key", objectKey),
+		attribute.Int("cos.size", len(content)),
+	)
+	defer tracing.End(span, &err)
+
+	_, err = s.cosClient.Object.Put(ctx, objectKey, bytes.NewReader(content), opt)
+	return err
+}
+
 // GetSignedURL generates a presigned URL for the given object key
 func (s *UploadService) GetSignedURL(objectKey string, expireSeconds int) (string, error) {
 	if objectKey == "" {
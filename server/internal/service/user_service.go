@@ -1,7 +1,9 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"pinche/config"
@@ -14,20 +16,30 @@ import (
 )
 
 type UserService struct {
-	repo   *repository.UserRepository
-	config *config.Config
+	repo        *repository.UserRepository
+	banRepo     *repository.BanRepository
+	loginIPRepo *repository.LoginIPRepository
+	notifyRepo  *repository.NotificationRepository
+	guard       *LoginGuard
+	moderation  *ModerationService
+	config      *config.Config
 }
 
-func NewUserService(cfg *config.Config) *UserService {
+func NewUserService(cfg *config.Config, moderation *ModerationService) *UserService {
 	return &UserService{
-		repo:   repository.NewUserRepository(),
-		config: cfg,
+		repo:        repository.NewUserRepository(),
+		banRepo:     repository.NewBanRepository(),
+		loginIPRepo: repository.NewLoginIPRepository(),
+		notifyRepo:  repository.NewNotificationRepository(),
+		guard:       NewLoginGuard(cfg, "user"),
+		moderation:  moderation,
+		config:      cfg,
 	}
 }
 
-func (s *UserService) Register(req *model.UserRegisterReq) (*model.User, error) {
+func (s *UserService) Register(ctx context.Context, req *model.UserRegisterReq) (*model.User, error) {
 	// check phone exists
-	existing, err := s.repo.GetByPhone(req.Phone)
+	existing, err := s.repo.GetByPhone(ctx, req.Phone)
 	if err != nil {
 		logger.Error("Check phone exists failed", "phone", logger.MaskPhone(req.Phone), "error", err)
 		return nil, err
@@ -37,6 +49,11 @@ func (s *UserService) Register(req *model.UserRegisterReq) (*model.User, error)
 		return nil, errors.New("手机号已注册")
 	}
 
+	nickname, err := s.moderation.Check(model.ModerationSceneNickname, 0, req.Nickname)
+	if err != nil {
+		return nil, err
+	}
+
 	// password is already MD5 hashed from frontend, bcrypt it for storage
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -47,33 +64,37 @@ func (s *UserService) Register(req *model.UserRegisterReq) (*model.User, error)
 	user := &model.User{
 		Phone:    req.Phone,
 		Password: string(hashedPassword),
-		Nickname: req.Nickname,
+		Nickname: nickname.Text,
 	}
 
-	if err := s.repo.Create(user); err != nil {
+	if err := s.repo.Create(ctx, user); err != nil {
 		logger.Error("Create user failed", "phone", logger.MaskPhone(req.Phone), "error", err)
 		return nil, err
 	}
+	nickname.UserID = user.ID
+	s.moderation.Flag(ctx, nickname, user.ID)
 
 	logger.Info("User registered", "user_id", user.ID, "phone", logger.MaskPhone(req.Phone))
 	return user, nil
 }
 
-func (s *UserService) Login(req *model.UserLoginReq) (*model.UserLoginResp, error) {
-	user, err := s.repo.GetByPhone(req.Phone)
+func (s *UserService) Login(ctx context.Context, req *model.UserLoginReq, clientIP string) (*model.UserLoginResp, error) {
+	if err := s.guard.Check(ctx, req.Phone, clientIP, req.CaptchaID, req.CaptchaCode); err != nil {
+		logger.Warn("Login rejected by guard", "phone", logger.MaskPhone(req.Phone), "client_ip", clientIP, "reason", err.Error())
+		return nil, err
+	}
+
+	user, err := s.repo.GetByPhone(ctx, req.Phone)
 	if err != nil {
 		logger.Error("Get user by phone failed", "phone", logger.MaskPhone(req.Phone), "error", err)
 		return nil, err
 	}
 	if user == nil {
-		logger.Warn("Login failed: user not found", "phone", logger.MaskPhone(req.Phone))
-		return nil, errors.New("用户不存在，请先注册")
-	}
-
-	// check if user is banned
-	if user.Status == 1 {
-		logger.Warn("Login failed: user banned", "user_id", user.ID, "phone", logger.MaskPhone(req.Phone))
-		return nil, errors.New("账号已被封禁，请联系客服")
+		logger.Warn("Login failed: user not found", "phone", logger.MaskPhone(req.Phone), "client_ip", clientIP)
+		// counted as well, otherwise probing for registered numbers is free
+		// same message as a wrong password, so the answer does not tell whether the number is registered
+		loginErr, _ := s.guard.Fail(ctx, req.Phone, clientIP, "用户名或密码错误")
+		return nil, loginErr
 	}
 
 	// validate stored password is a valid bcrypt hash (must start with $2a$ or $2b$ and be 60 chars)
@@ -85,8 +106,14 @@ func (s *UserService) Login(req *model.UserLoginReq) (*model.UserLoginResp, erro
 
 	// password from frontend is MD5 hashed, compare with bcrypt stored password
 	if err := bcrypt.CompareHashAndPassword([]byte(storedPwd), []byte(req.Password)); err != nil {
-		logger.Warn("Login failed: wrong password", "user_id", user.ID, "phone", logger.MaskPhone(req.Phone))
-		return nil, errors.New("用户名或密码错误")
+		logger.Warn("Login failed: wrong password", "user_id", user.ID, "phone", logger.MaskPhone(req.Phone), "client_ip", clientIP)
+		return nil, s.loginFailed(ctx, user, req.Phone, clientIP)
+	}
+
+	// check if user is banned, only after the password so the ban reason is not told to anyone knowing the phone number
+	if user.Status == 1 {
+		logger.Warn("Login failed: user banned", "user_id", user.ID, "phone", logger.MaskPhone(req.Phone))
+		return nil, s.bannedError(ctx, user.ID)
 	}
 
 	// generate token with internal ID
@@ -96,7 +123,9 @@ func (s *UserService) Login(req *model.UserLoginReq) (*model.UserLoginResp, erro
 		return nil, err
 	}
 
-	logger.Info("User logged in", "user_id", user.ID, "phone", logger.MaskPhone(req.Phone))
+	s.guard.Succeed(ctx, req.Phone)
+	s.recordLoginIP(ctx, user.ID, clientIP)
+	logger.Info("User logged in", "user_id", user.ID, "phone", logger.MaskPhone(req.Phone), "client_ip", clientIP)
 
 	return &model.UserLoginResp{
 		Token: token,
@@ -104,16 +133,107 @@ func (s *UserService) Login(req *model.UserLoginReq) (*model.UserLoginResp, erro
 	}, nil
 }
 
-func (s *UserService) GetByID(id uint64) (*model.User, error) {
-	return s.repo.GetByID(id)
+// loginFailed counts a wrong password and tells the owner when it locked the account from an IP
+// they never logged in from
+func (s *UserService) loginFailed(ctx context.Context, user *model.User, phone, clientIP string) error {
+	loginErr, locked := s.guard.Fail(ctx, phone, clientIP, "用户名或密码错误")
+	if !locked {
+		return loginErr
+	}
+
+	known, _, err := s.loginIPRepo.IsKnown(ctx, user.ID, clientIP)
+	if err != nil {
+		logger.Error("Check login IP failed", "user_id", user.ID, "error", err)
+		return loginErr
+	}
+	if !known {
+		s.notifyLogin(ctx, user.ID, "账号异常登录提醒", fmt.Sprintf(
+			"你的账号于%s在新的IP地址（%s）多次输入错误密码，已临时锁定%s。如非本人操作，说明有人正在尝试登录你的账号，请留意账号安全。",
+			time.Now().Format("2006-01-02 15:04"), clientIP, describeWait(loginErr.RetryAfter)))
+	}
+	return loginErr
+}
+
+// recordLoginIP remembers the IP of a successful login and tells the owner when it is a new one.
+// Users without any recorded IP, e.g. on their first login, are not notified.
+func (s *UserService) recordLoginIP(ctx context.Context, userID uint64, clientIP string) {
+	known, hasHistory, err := s.loginIPRepo.IsKnown(ctx, userID, clientIP)
+	if err != nil {
+		logger.Error("Check login IP failed", "user_id", userID, "error", err)
+		return
+	}
+	if err := s.loginIPRepo.Record(ctx, userID, clientIP); err != nil {
+		logger.Error("Record login IP failed", "user_id", userID, "error", err)
+	}
+	if known || !hasHistory {
+		return
+	}
+
+	logger.Info("User logged in from new IP", "user_id", userID, "client_ip", clientIP)
+	s.notifyLogin(ctx, userID, "新IP登录提醒", fmt.Sprintf(
+		"你的账号于%s在新的IP地址（%s）登录。如非本人操作，请留意账号安全并联系客服。",
+		time.Now().Format("2006-01-02 15:04"), clientIP))
+}
+
+func (s *UserService) notifyLogin(ctx context.Context, userID uint64, title, content string) {
+	notification := &model.Notification{
+		UserID:  userID,
+		Title:   title,
+		Content: content,
+	}
+	if err := s.notifyRepo.Create(ctx, notification); err != nil {
+		logger.Error("Create login notification failed", "user_id", userID, "error", err)
+	}
+}
+
+// bannedError explains the active ban to a user who tries to log in
+func (s *UserService) bannedError(ctx context.Context, userID uint64) error {
+	ban, err := s.banRepo.GetActiveUserBan(ctx, userID)
+	if err != nil || ban == nil {
+		return errors.New("账号已被封禁，请联系客服")
+	}
+	msg := fmt.Sprintf("账号因「%s」已被封禁", ban.Reason)
+	if ban.ExpiresAt != nil {
+		msg += fmt.Sprintf("，将于%s自动解除", ban.ExpiresAt.Format("2006-01-02 15:04"))
+	}
+	return errors.New(msg + "，如有异议可提交申诉")
+}
+
+// VerifyCredentials checks phone and password without rejecting banned users,
+// used where banned users must still identify themselves (e.g. appeals).
+// Wrong passwords count towards the login lock and the captcha like failed logins do.
+func (s *UserService) VerifyCredentials(ctx context.Context, phone, hashedPassword, captchaID, captchaCode, clientIP string) (*model.User, error) {
+	if err := s.guard.Check(ctx, phone, clientIP, captchaID, captchaCode); err != nil {
+		logger.Warn("Verify credentials rejected by guard", "phone", logger.MaskPhone(phone), "client_ip", clientIP, "reason", err.Error())
+		return nil, err
+	}
+
+	user, err := s.repo.GetByPhone(ctx, phone)
+	if err != nil {
+		logger.Error("Get user by phone failed", "phone", logger.MaskPhone(phone), "error", err)
+		return nil, err
+	}
+	if user == nil {
+		loginErr, _ := s.guard.Fail(ctx, phone, clientIP, "用户名或密码错误")
+		return nil, loginErr
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(hashedPassword)); err != nil {
+		logger.Warn("Verify credentials failed: wrong password", "user_id", user.ID, "phone", logger.MaskPhone(phone), "client_ip", clientIP)
+		return nil, s.loginFailed(ctx, user, phone, clientIP)
+	}
+	return user, nil
+}
+
+func (s *UserService) GetByID(ctx context.Context, id uint64) (*model.User, error) {
+	return s.repo.GetByID(ctx, id)
 }
 
-func (s *UserService) GetByOpenID(openID string) (*model.User, error) {
-	return s.repo.GetByOpenID(openID)
+func (s *UserService) GetByOpenID(ctx context.Context, openID string) (*model.User, error) {
+	return s.repo.GetByOpenID(ctx, openID)
 }
 
-func (s *UserService) Update(userID uint64, req *model.UserUpdateReq) (*model.User, error) {
-	user, err := s.repo.GetByID(userID)
+func (s *UserService) Update(ctx context.Context, userID uint64, req *model.UserUpdateReq) (*model.User, error) {
+	user, err := s.repo.GetByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -121,8 +241,13 @@ func (s *UserService) Update(userID uint64, req *model.UserUpdateReq) (*model.Us
 		return nil, errors.New("用户不存在")
 	}
 
+	var nickname *model.ModerationResult
 	if req.Nickname != "" {
-		user.Nickname = req.Nickname
+		nickname, err = s.moderation.Check(model.ModerationSceneNickname, userID, req.Nickname)
+		if err != nil {
+			return nil, err
+		}
+		user.Nickname = nickname.Text
 	}
 	if req.Avatar != "" {
 		user.Avatar = req.Avatar
@@ -149,9 +274,10 @@ func (s *UserService) Update(userID uint64, req *model.UserUpdateReq) (*model.Us
 	user.CarModel = req.CarModel
 	user.CarColor = req.CarColor
 
-	if err := s.repo.Update(user); err != nil {
+	if err := s.repo.Update(ctx, user); err != nil {
 		return nil, err
 	}
+	s.moderation.Flag(ctx, nickname, userID)
 	return user, nil
 }
 
@@ -182,7 +308,7 @@ func (s *UserService) ParseToken(tokenString string) (uint64, error) {
 
 // Admin functions
 
-func (s *UserService) AdminListUsers(req *model.AdminUserListReq) (*model.AdminUserListResp, error) {
+func (s *UserService) AdminListUsers(ctx context.Context, req *model.AdminUserListReq) (*model.AdminUserListResp, error) {
 	if req.Page <= 0 {
 		req.Page = 1
 	}
@@ -190,7 +316,7 @@ func (s *UserService) AdminListUsers(req *model.AdminUserListReq) (*model.AdminU
 		req.PageSize = 20
 	}
 
-	users, total, err := s.repo.ListAll(req)
+	users, total, err := s.repo.ListAll(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -201,16 +327,16 @@ func (s *UserService) AdminListUsers(req *model.AdminUserListReq) (*model.AdminU
 	}, nil
 }
 
-func (s *UserService) AdminBanUser(openID string) error {
+func (s *UserService) AdminBanUser(ctx context.Context, openID string) error {
 	logger.Info("Admin banning user", "open_id", openID)
-	return s.repo.UpdateStatusByOpenID(openID, 1)
+	return s.repo.UpdateStatusByOpenID(ctx, openID, 1)
 }
 
-func (s *UserService) AdminUnbanUser(openID string) error {
+func (s *UserService) AdminUnbanUser(ctx context.Context, openID string) error {
 	logger.Info("Admin unbanning user", "open_id", openID)
-	return s.repo.UpdateStatusByOpenID(openID, 0)
+	return s.repo.UpdateStatusByOpenID(ctx, openID, 0)
 }
 
-func (s *UserService) AdminGetStats() (map[string]interface{}, error) {
-	return s.repo.GetStats()
+func (s *UserService) AdminGetStats(ctx context.Context) (map[string]interface{}, error) {
+	return s.repo.GetStats(ctx)
 }
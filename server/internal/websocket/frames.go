@@ -0,0 +1,79 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"pinche/internal/logger"
+	"pinche/internal/tracing"
+)
+
+// typingThrottle is the minimum interval between typing events forwarded to the same peer
+const typingThrottle = 2 * time.Second
+
+// FrameHandler processes a client frame of a registered type, ctx carries the trace of the frame.
+// It runs on the client's read goroutine, so frames from one client are handled in order.
+type FrameHandler func(ctx context.Context, client *Client, data json.RawMessage)
+
+// TypingData is the payload of a typing frame from client
+type TypingData struct {
+	PeerID string `json:"peer_id"` // open_id of the user being typed to
+}
+
+// Handle registers a handler for client frames of the given type
+func (h *Hub) Handle(msgType string, fn FrameHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handlers[msgType] = fn
+}
+
+func (h *Hub) frameHandler(msgType string) FrameHandler {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.handlers[msgType]
+}
+
+// handleFrame runs fn in a trace of its own, frames are the websocket counterpart of requests
+func (h *Hub) handleFrame(client *Client, msgType string, data json.RawMessage, fn FrameHandler) {
+	ctx, span := tracing.Start(context.Background(), "websocket frame "+msgType,
+		semconv.EnduserID(strconv.FormatUint(client.UserID, 10)),
+		attribute.String("websocket.message_type", msgType),
+	)
+	defer span.End()
+	fn(ctx, client, data)
+}
+
+// handleTyping forwards a typing indicator to the peer, at most once per throttle interval.
+// Typing events are ephemeral and never persisted.
+func (h *Hub) handleTyping(sender *Client, raw json.RawMessage) {
+	var data TypingData
+	if err := json.Unmarshal(raw, &data); err != nil || data.PeerID == "" {
+		logger.Debug("WebSocket: invalid typing frame", "sender_id", sender.UserID)
+		return
+	}
+	if data.PeerID == sender.OpenID {
+		return
+	}
+
+	// only touched from the sender's read goroutine, no locking needed
+	if sender.lastTyping == nil {
+		sender.lastTyping = make(map[string]time.Time)
+	}
+	now := time.Now()
+	if last, ok := sender.lastTyping[data.PeerID]; ok && now.Sub(last) < typingThrottle {
+		return
+	}
+	sender.lastTyping[data.PeerID] = now
+
+	h.SendToUserByOpenID(data.PeerID, Message{
+		Type: "typing",
+		Data: map[string]interface{}{
+			"from_user_id": sender.UserID,
+			"from_open_id": sender.OpenID,
+		},
+	})
+}
@@ -1,18 +1,40 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"math/rand"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"pinche/internal/logger"
+	"pinche/internal/metrics"
+	"pinche/internal/tracing"
+)
+
+const (
+	// closeWriteWait bounds writing the close frame to a client that may no longer read
+	closeWriteWait = time.Second
+	// restartReconnectMin and restartReconnectMax bound the reconnect delay suggested on shutdown,
+	// spread so that clients do not all reconnect to the new instance at once
+	restartReconnectMin = 1 * time.Second
+	restartReconnectMax = 5 * time.Second
 )
 
 type Message struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+	Type   string      `json:"type"`
+	Data   interface{} `json:"data"`
+	Silent bool        `json:"silent,omitempty"` // deliver without sound or notification, set by SendAlert for muted conversations
 }
 
+// MuteChecker reports whether userID muted the conversation with fromUserID
+type MuteChecker func(ctx context.Context, userID, fromUserID uint64) bool
+
 // SignalingMessage represents call signaling messages from client
 type SignalingMessage struct {
 	Type string          `json:"type"`
@@ -32,17 +54,49 @@ type SignalingData struct {
 }
 
 type Client struct {
-	UserID uint64
-	OpenID string
-	Conn   *websocket.Conn
-	Send   chan []byte
+	UserID   uint64
+	OpenID   string
+	Platform string // client platform reported on connect, may be empty
+	Conn     *websocket.Conn
+	Send     chan []byte // closed exactly once, by whoever removes the client from the hub
+
+	lastTyping map[string]time.Time // peer open_id -> last forwarded typing event
+	closeFrame []byte               // close frame written after Send is closed, set before closing it
+	done       chan struct{}        // closed when WritePump returned
+}
+
+// ServerRestartData is sent to every client before the server shuts down
+type ServerRestartData struct {
+	ReconnectAfterMs int64 `json:"reconnect_after_ms"`
+}
+
+func NewClient(conn *websocket.Conn, userID uint64, openID, platform string) *Client {
+	return &Client{
+		UserID:   userID,
+		OpenID:   openID,
+		Platform: platform,
+		Conn:     conn,
+		Send:     make(chan []byte, 256),
+		done:     make(chan struct{}),
+	}
+}
+
+// OnlineUser identifies a connected user and the platform they connected from
+type OnlineUser struct {
+	UserID   uint64
+	Platform string
 }
 
 type Hub struct {
 	clients       map[uint64]*Client
-	clientsByOpen map[string]*Client // open_id -> client mapping
+	clientsByOpen map[string]*Client   // open_id -> client mapping
+	connected     map[*Client]struct{} // all open clients, including ones replaced by a newer connection of the user
 	register      chan *Client
 	unregister    chan *Client
+	handlers      map[string]FrameHandler // client frame type -> handler
+	muteChecker   MuteChecker
+	stopping      bool          // set by Stop, new clients are turned away
+	quit          chan struct{} // closed by Stop to end Run
 	mu            sync.RWMutex
 }
 
@@ -50,8 +104,11 @@ func NewHub() *Hub {
 	return &Hub{
 		clients:       make(map[uint64]*Client),
 		clientsByOpen: make(map[string]*Client),
+		connected:     make(map[*Client]struct{}),
 		register:      make(chan *Client),
 		unregister:    make(chan *Client),
+		handlers:      make(map[string]FrameHandler),
+		quit:          make(chan struct{}),
 	}
 }
 
@@ -60,6 +117,13 @@ func (h *Hub) Run() {
 		select {
 		case client := <-h.register:
 			h.mu.Lock()
+			if h.stopping {
+				h.mu.Unlock()
+				client.closeFrame = restartCloseFrame()
+				close(client.Send)
+				continue
+			}
+			h.connected[client] = struct{}{}
 			h.clients[client.UserID] = client
 			if client.OpenID != "" {
 				h.clientsByOpen[client.OpenID] = client
@@ -68,96 +132,253 @@ func (h *Hub) Run() {
 			logger.Info("WebSocket client registered", "user_id", client.UserID, "open_id", client.OpenID)
 
 		case client := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.clients[client.UserID]; ok {
-				delete(h.clients, client.UserID)
-				if client.OpenID != "" {
-					delete(h.clientsByOpen, client.OpenID)
-				}
-				close(client.Send)
-			}
-			h.mu.Unlock()
+			h.removeClient(client)
 			logger.Info("WebSocket client unregistered", "user_id", client.UserID, "open_id", client.OpenID)
+
+		case <-h.quit:
+			return
 		}
 	}
 }
 
 func (h *Hub) Register(client *Client) {
-	h.register <- client
+	select {
+	case h.register <- client:
+	case <-h.quit:
+		client.closeFrame = restartCloseFrame()
+		close(client.Send)
+	}
 }
 
 func (h *Hub) Unregister(client *Client) {
-	h.unregister <- client
+	select {
+	case h.unregister <- client:
+	case <-h.quit:
+	}
 }
 
-func (h *Hub) SendToUser(userID uint64, msg Message) {
-	h.mu.RLock()
-	client, ok := h.clients[userID]
-	h.mu.RUnlock()
+// Stop disconnects all clients for a server shutdown. Each client gets a server_restart event
+// with a randomized reconnect delay, then a close frame with code 1012 (service restart).
+// It returns once the close frames are written or ctx is done.
+func (h *Hub) Stop(ctx context.Context) {
+	h.mu.Lock()
+	if h.stopping {
+		h.mu.Unlock()
+		return
+	}
+	h.stopping = true
+	clients := make([]*Client, 0, len(h.connected))
+	for client := range h.connected {
+		clients = append(clients, client)
+	}
+	h.clients = make(map[uint64]*Client)
+	h.clientsByOpen = make(map[string]*Client)
+	h.connected = make(map[*Client]struct{})
+	h.mu.Unlock()
+	close(h.quit)
+
+	for _, client := range clients {
+		delay := restartReconnectMin + time.Duration(rand.Int63n(int64(restartReconnectMax-restartReconnectMin)))
+		data, _ := json.Marshal(Message{
+			Type: "server_restart",
+			Data: ServerRestartData{ReconnectAfterMs: delay.Milliseconds()},
+		})
+		select {
+		case client.Send <- data:
+		default:
+		}
+		client.closeFrame = restartCloseFrame()
+		close(client.Send)
+	}
 
-	if !ok {
-		logger.Debug("WebSocket SendToUser: user not connected", "user_id", userID)
+	for _, client := range clients {
+		select {
+		case <-client.done:
+		case <-ctx.Done():
+			logger.Warn("WebSocket hub stop timed out", "clients", len(clients))
+			return
+		}
+	}
+	logger.Info("WebSocket hub stopped", "clients", len(clients))
+}
+
+func restartCloseFrame() []byte {
+	return websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting")
+}
+
+// removeClient drops the client and closes its Send channel, unless it was already removed.
+// A client replaced by a newer connection of the same user leaves the newer one registered.
+func (h *Hub) removeClient(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.connected[client]; !ok {
 		return
 	}
+	delete(h.connected, client)
+	if h.clients[client.UserID] == client {
+		delete(h.clients, client.UserID)
+	}
+	if client.OpenID != "" && h.clientsByOpen[client.OpenID] == client {
+		delete(h.clientsByOpen, client.OpenID)
+	}
+	close(client.Send)
+}
+
+// deliver queues data for the client looked up by lookup. The read lock is held while queueing,
+// so Send can not be closed meanwhile. A client whose channel is full is removed.
+func (h *Hub) deliver(lookup func() (*Client, bool), data []byte) (found, sent bool) {
+	h.mu.RLock()
+	client, ok := lookup()
+	if !ok {
+		h.mu.RUnlock()
+		metrics.WebSocketMessagesDropped.WithLabelValues(metrics.DropOffline).Inc()
+		return false, false
+	}
+	select {
+	case client.Send <- data:
+		h.mu.RUnlock()
+		metrics.WebSocketMessagesSent.Inc()
+		return true, true
+	default:
+		h.mu.RUnlock()
+		h.removeClient(client)
+		metrics.WebSocketMessagesDropped.WithLabelValues(metrics.DropChannelFull).Inc()
+		metrics.WebSocketEvictions.Inc()
+		return true, false
+	}
+}
+
+// ClientCount returns the number of open websocket connections
+func (h *Hub) ClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.connected)
+}
+
+// SendToUser queues msg for userID if connected
+func (h *Hub) SendToUser(userID uint64, msg Message) {
+	h.SendToUserContext(context.Background(), userID, msg)
+}
+
+// SendToUserContext is SendToUser within the trace of ctx. Its span records whether the message
+// was queued, or dropped because the user is offline or the client is not keeping up.
+func (h *Hub) SendToUserContext(ctx context.Context, userID uint64, msg Message) {
+	_, span := tracing.StartChild(ctx, "websocket send",
+		semconv.EnduserID(strconv.FormatUint(userID, 10)),
+		attribute.String("websocket.message_type", msg.Type),
+	)
+	defer span.End()
 
 	data, err := json.Marshal(msg)
 	if err != nil {
 		logger.Error("WebSocket SendToUser: failed to marshal message", "user_id", userID, "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "marshal message failed")
 		return
 	}
 
 	logger.Debug("WebSocket SendToUser: sending message", "user_id", userID, "type", msg.Type)
 
-	select {
-	case client.Send <- data:
+	found, sent := h.deliver(func() (*Client, bool) {
+		client, ok := h.clients[userID]
+		return client, ok
+	}, data)
+	switch {
+	case !found:
+		logger.Debug("WebSocket SendToUser: user not connected", "user_id", userID)
+		span.SetAttributes(attribute.String("websocket.result", metrics.DropOffline))
+	case sent:
 		logger.Debug("WebSocket SendToUser: message sent", "user_id", userID)
+		span.SetAttributes(attribute.String("websocket.result", "sent"))
 	default:
 		logger.Warn("WebSocket SendToUser: channel full, removing client", "user_id", userID)
-		h.mu.Lock()
-		delete(h.clients, userID)
-		if client.OpenID != "" {
-			delete(h.clientsByOpen, client.OpenID)
-		}
-		close(client.Send)
-		h.mu.Unlock()
+		span.SetAttributes(attribute.String("websocket.result", metrics.DropChannelFull))
 	}
 }
 
-// SendToUserByOpenID sends a message to a user by their open_id
-func (h *Hub) SendToUserByOpenID(openID string, msg Message) {
+// SetMuteChecker sets how SendAlert finds out whether a conversation is muted
+func (h *Hub) SetMuteChecker(fn MuteChecker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.muteChecker = fn
+}
+
+// SendAlert sends an alert-style event, such as a new message, from fromUserID to userID.
+// The event is still delivered when the conversation is muted, but marked silent.
+func (h *Hub) SendAlert(userID, fromUserID uint64, msg Message) {
+	h.SendAlertContext(context.Background(), userID, fromUserID, msg)
+}
+
+// SendAlertContext is SendAlert within the trace of ctx, the mute lookup included
+func (h *Hub) SendAlertContext(ctx context.Context, userID, fromUserID uint64, msg Message) {
 	h.mu.RLock()
-	client, ok := h.clientsByOpen[openID]
+	_, online := h.clients[userID]
+	checker := h.muteChecker
 	h.mu.RUnlock()
 
-	if !ok {
-		logger.Debug("WebSocket SendToUserByOpenID: user not connected", "open_id", openID)
-		return
+	// skip the mute lookup for users who would not receive the event anyway
+	if online && checker != nil && checker(ctx, userID, fromUserID) {
+		msg.Silent = true
 	}
+	h.SendToUserContext(ctx, userID, msg)
+}
+
+// OnlineUsers returns a snapshot of the currently connected users
+func (h *Hub) OnlineUsers() []OnlineUser {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	users := make([]OnlineUser, 0, len(h.clients))
+	for userID, client := range h.clients {
+		users = append(users, OnlineUser{UserID: userID, Platform: client.Platform})
+	}
+	return users
+}
+
+// SendToUserByOpenID sends a message to a user by their open_id
+func (h *Hub) SendToUserByOpenID(openID string, msg Message) {
+	h.SendToUserByOpenIDContext(context.Background(), openID, msg)
+}
+
+// SendToUserByOpenIDContext is SendToUserByOpenID within the trace of ctx, its span is the one of SendToUserContext
+func (h *Hub) SendToUserByOpenIDContext(ctx context.Context, openID string, msg Message) {
+	_, span := tracing.StartChild(ctx, "websocket send",
+		semconv.EnduserID(openID),
+		attribute.String("websocket.message_type", msg.Type),
+	)
+	defer span.End()
 
 	data, err := json.Marshal(msg)
 	if err != nil {
 		logger.Error("WebSocket SendToUserByOpenID: failed to marshal message", "open_id", openID, "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "marshal message failed")
 		return
 	}
 
 	logger.Debug("WebSocket SendToUserByOpenID: sending message", "open_id", openID, "type", msg.Type)
 
-	select {
-	case client.Send <- data:
+	found, sent := h.deliver(func() (*Client, bool) {
+		client, ok := h.clientsByOpen[openID]
+		return client, ok
+	}, data)
+	switch {
+	case !found:
+		logger.Debug("WebSocket SendToUserByOpenID: user not connected", "open_id", openID)
+		span.SetAttributes(attribute.String("websocket.result", metrics.DropOffline))
+	case sent:
 		logger.Debug("WebSocket SendToUserByOpenID: message sent", "open_id", openID)
+		span.SetAttributes(attribute.String("websocket.result", "sent"))
 	default:
 		logger.Warn("WebSocket SendToUserByOpenID: channel full, removing client", "open_id", openID)
-		h.mu.Lock()
-		delete(h.clients, client.UserID)
-		delete(h.clientsByOpen, openID)
-		close(client.Send)
-		h.mu.Unlock()
+		span.SetAttributes(attribute.String("websocket.result", metrics.DropChannelFull))
 	}
 }
 
 func (c *Client) WritePump() {
 	defer func() {
 		c.Conn.Close()
+		close(c.done)
 	}()
 
 	for message := range c.Send {
@@ -165,6 +386,13 @@ func (c *Client) WritePump() {
 			return
 		}
 	}
+
+	// Send was closed by the hub, say goodbye instead of just dropping the connection
+	closeFrame := c.closeFrame
+	if closeFrame == nil {
+		closeFrame = websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	}
+	c.Conn.WriteControl(websocket.CloseMessage, closeFrame, time.Now().Add(closeWriteWait))
 }
 
 func (c *Client) ReadPump(hub *Hub) {
@@ -189,6 +417,17 @@ func (c *Client) ReadPump(hub *Hub) {
 		// Handle call signaling messages
 		if isCallSignaling(sigMsg.Type) {
 			hub.handleCallSignaling(c, sigMsg)
+			continue
+		}
+
+		if sigMsg.Type == "typing" {
+			hub.handleTyping(c, sigMsg.Data)
+			continue
+		}
+
+		// frames handled outside the hub, e.g. read receipts persisted by the message service
+		if fn := hub.frameHandler(sigMsg.Type); fn != nil {
+			hub.handleFrame(c, sigMsg.Type, sigMsg.Data, fn)
 		}
 	}
 }
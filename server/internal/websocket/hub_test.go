@@ -0,0 +1,169 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"pinche/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Init(&logger.Config{Level: "error"}); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newRunningHub starts a hub and registers the clients in order
+func newRunningHub(t *testing.T, clients ...*Client) *Hub {
+	t.Helper()
+	h := NewHub()
+	go h.Run()
+	for i, c := range clients {
+		h.Register(c)
+		waitFor(t, func() bool { return h.ClientCount() == i+1 })
+	}
+	return h
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// drain reads Send until the hub closes it and returns the messages, then acts as a
+// WritePump that returned
+func drain(c *Client) <-chan []Message {
+	out := make(chan []Message, 1)
+	go func() {
+		var msgs []Message
+		for data := range c.Send {
+			var msg Message
+			json.Unmarshal(data, &msg)
+			msgs = append(msgs, msg)
+		}
+		close(c.done)
+		out <- msgs
+	}()
+	return out
+}
+
+func isClosed(c *Client) bool {
+	for {
+		select {
+		case _, ok := <-c.Send:
+			if !ok {
+				return true
+			}
+		default:
+			return false
+		}
+	}
+}
+
+func TestRemoveClientClosesSendOnce(t *testing.T) {
+	c := NewClient(nil, 1, "o1", "")
+	h := newRunningHub(t, c)
+
+	h.removeClient(c)
+	h.removeClient(c)
+	h.Unregister(c)
+
+	if !isClosed(c) {
+		t.Error("Send is not closed after removeClient")
+	}
+	if n := h.ClientCount(); n != 0 {
+		t.Errorf("ClientCount = %d, want 0", n)
+	}
+}
+
+func TestRemoveReplacedClientKeepsNewer(t *testing.T) {
+	older := NewClient(nil, 1, "o1", "")
+	newer := NewClient(nil, 1, "o1", "")
+	h := newRunningHub(t, older, newer)
+
+	h.removeClient(older)
+	if !isClosed(older) {
+		t.Error("Send of the replaced client is not closed")
+	}
+
+	h.SendToUser(1, Message{Type: "ping"})
+	h.SendToUserByOpenID("o1", Message{Type: "ping"})
+	if n := len(newer.Send); n != 2 {
+		t.Errorf("newer client got %d messages, want 2", n)
+	}
+}
+
+func TestFullClientIsEvicted(t *testing.T) {
+	c := NewClient(nil, 1, "o1", "")
+	h := newRunningHub(t, c)
+
+	for i := 0; i < cap(c.Send); i++ {
+		h.SendToUser(1, Message{Type: "ping"})
+	}
+	h.SendToUser(1, Message{Type: "ping"})
+
+	if n := h.ClientCount(); n != 0 {
+		t.Errorf("ClientCount = %d, want 0 after the channel filled up", n)
+	}
+	// further sends and removals must not touch the closed channel
+	h.SendToUser(1, Message{Type: "ping"})
+	h.removeClient(c)
+}
+
+func TestStopClosesEveryClientOnce(t *testing.T) {
+	older := NewClient(nil, 1, "o1", "")
+	newer := NewClient(nil, 1, "o1", "")
+	other := NewClient(nil, 2, "o2", "")
+	h := newRunningHub(t, older, newer, other)
+
+	results := []<-chan []Message{drain(older), drain(newer), drain(other)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	h.Stop(ctx)
+	if ctx.Err() != nil {
+		t.Fatal("Stop did not return before the clients finished")
+	}
+
+	for i, c := range []*Client{older, newer, other} {
+		msgs := <-results[i]
+		if len(msgs) != 1 || msgs[0].Type != "server_restart" {
+			t.Errorf("client %d got %+v, want a single server_restart", i, msgs)
+		}
+		if c.closeFrame == nil {
+			t.Errorf("client %d has no close frame", i)
+		}
+	}
+
+	// clients unregistering while the server shuts down, and a second Stop, are no-ops
+	h.removeClient(older)
+	h.Unregister(newer)
+	h.Stop(ctx)
+	if n := h.ClientCount(); n != 0 {
+		t.Errorf("ClientCount = %d, want 0", n)
+	}
+}
+
+func TestRegisterAfterStop(t *testing.T) {
+	h := newRunningHub(t)
+	h.Stop(context.Background())
+
+	c := NewClient(nil, 1, "o1", "")
+	h.Register(c)
+	if !isClosed(c) {
+		t.Error("Send of a client registered after Stop is not closed")
+	}
+	if c.closeFrame == nil {
+		t.Error("client registered after Stop has no close frame")
+	}
+}
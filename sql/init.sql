@@ -33,7 +33,8 @@ CREATE TABLE IF NOT EXISTS users (
     PRIMARY KEY (id),
     UNIQUE KEY uk_open_id (open_id),
     UNIQUE KEY uk_phone (phone),
-    KEY idx_status (status)
+    KEY idx_status (status),
+    KEY idx_created_at (created_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='用户表';
 
 -- 行程表 (司机发布)
@@ -67,7 +68,8 @@ CREATE TABLE IF NOT EXISTS trips (
     KEY idx_departure_city (departure_city),
     KEY idx_destination_city (destination_city),
     KEY idx_departure_province (departure_province),
-    KEY idx_destination_province (destination_province)
+    KEY idx_destination_province (destination_province),
+    KEY idx_created_at (created_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='行程表';
 
 -- 匹配记录表
@@ -81,13 +83,17 @@ CREATE TABLE IF NOT EXISTS matches (
     driver_status TINYINT NOT NULL DEFAULT 0 COMMENT '司机确认状态: 0-待确认 1-已接受 2-已拒绝',
     passenger_status TINYINT NOT NULL DEFAULT 0 COMMENT '乘客确认状态: 0-待确认 1-已接受 2-已拒绝',
     status TINYINT NOT NULL DEFAULT 0 COMMENT '匹配状态: 0-待确认 1-匹配成功 2-匹配失败',
+    succeeded_at DATETIME NULL DEFAULT NULL COMMENT '匹配成功时间',
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
     PRIMARY KEY (id),
     UNIQUE KEY uk_trips (driver_trip_id, passenger_trip_id),
     KEY idx_driver_id (driver_id),
     KEY idx_passenger_id (passenger_id),
-    KEY idx_status (status)
+    KEY idx_status (status),
+    KEY idx_passenger_trip_id (passenger_trip_id),
+    KEY idx_created_at (created_at),
+    KEY idx_succeeded_at (succeeded_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='匹配记录表';
 
 -- 通知表
@@ -113,14 +119,27 @@ CREATE TABLE IF NOT EXISTS messages (
     sender_id BIGINT UNSIGNED NOT NULL COMMENT '发送者ID',
     receiver_id BIGINT UNSIGNED NOT NULL COMMENT '接收者ID',
     content TEXT NOT NULL COMMENT '消息内容(文字或图片URL)',
-    msg_type TINYINT NOT NULL DEFAULT 1 COMMENT '消息类型: 1-文字 2-图片 3-系统消息',
+    msg_type TINYINT NOT NULL DEFAULT 1 COMMENT '消息类型: 1-文字 2-图片 3-语音 4-表情 5-通话记录 6-视频 7-已撤回 8-位置',
     is_read TINYINT NOT NULL DEFAULT 0 COMMENT '是否已读: 0-未读 1-已读',
+    recalled_at DATETIME NULL DEFAULT NULL COMMENT '撤回时间',
+    sender_deleted TINYINT NOT NULL DEFAULT 0 COMMENT '发送者是否已删除: 0-否 1-是',
+    receiver_deleted TINYINT NOT NULL DEFAULT 0 COMMENT '接收者是否已删除: 0-否 1-是',
+    client_msg_id VARCHAR(64) NULL DEFAULT NULL COMMENT '客户端消息ID(幂等)',
+    reply_to_id BIGINT UNSIGNED NULL DEFAULT NULL COMMENT '引用的消息ID',
+    reply_sender_id BIGINT UNSIGNED NULL DEFAULT NULL COMMENT '被引用消息的发送者ID',
+    reply_snippet VARCHAR(200) NOT NULL DEFAULT '' COMMENT '被引用消息摘要',
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3) COMMENT '更新时间(增量同步游标)',
     PRIMARY KEY (id),
+    UNIQUE KEY uk_sender_client_msg (sender_id, client_msg_id),
     KEY idx_sender_id (sender_id),
     KEY idx_receiver_id (receiver_id),
-    KEY idx_conversation (sender_id, receiver_id, created_at),
-    KEY idx_created_at (created_at)
+    KEY idx_conversation (sender_id, receiver_id, id),
+    KEY idx_sender_updated (sender_id, updated_at, id),
+    KEY idx_receiver_updated (receiver_id, updated_at, id),
+    KEY idx_reply_to_id (reply_to_id),
+    KEY idx_created_at (created_at),
+    FULLTEXT KEY ft_content (content) WITH PARSER ngram
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='私聊消息表';
 
 -- 公告表
@@ -133,6 +152,11 @@ CREATE TABLE IF NOT EXISTS announcements (
     sort_order INT NOT NULL DEFAULT 0 COMMENT '排序权重',
     start_time DATETIME NOT NULL COMMENT '开始时间',
     end_time DATETIME NOT NULL COMMENT '结束时间',
+    target_provinces VARCHAR(500) NOT NULL DEFAULT '' COMMENT '目标省份, 逗号分隔, 为空表示不限',
+    target_cities VARCHAR(500) NOT NULL DEFAULT '' COMMENT '目标城市, 逗号分隔, 为空表示不限',
+    target_role TINYINT NOT NULL DEFAULT 0 COMMENT '目标角色: 0-全部 1-司机 2-乘客',
+    target_platforms VARCHAR(100) NOT NULL DEFAULT '' COMMENT '目标平台(ios/android/web/miniprogram), 逗号分隔, 为空表示不限',
+    pushed_at DATETIME NULL DEFAULT NULL COMMENT '紧急公告推送时间',
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
     PRIMARY KEY (id),
@@ -151,7 +175,8 @@ CREATE TABLE IF NOT EXISTS trip_grabs (
     PRIMARY KEY (id),
     UNIQUE KEY uk_trip_user (trip_id, user_id),
     KEY idx_trip_id (trip_id),
-    KEY idx_user_id (user_id)
+    KEY idx_user_id (user_id),
+    KEY idx_created_at (created_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='行程抢单记录表';
 
 -- 行程修改审核表
@@ -172,6 +197,260 @@ CREATE TABLE IF NOT EXISTS trip_updates (
     KEY idx_status (status)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='行程修改审核表';
 
+-- 管理员表
+CREATE TABLE IF NOT EXISTS admins (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '管理员ID',
+    username VARCHAR(32) NOT NULL COMMENT '用户名',
+    password VARCHAR(128) NOT NULL COMMENT '密码哈希(bcrypt)',
+    role VARCHAR(20) NOT NULL DEFAULT 'viewer' COMMENT '角色: super_admin-超级管理员 moderator-审核员 content_editor-内容编辑 viewer-只读',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '状态: 0-正常 1-禁用',
+    last_login_at DATETIME NULL DEFAULT NULL COMMENT '最后登录时间',
+    last_login_ip VARCHAR(64) NOT NULL DEFAULT '' COMMENT '最后登录IP',
+    created_by VARCHAR(32) NOT NULL DEFAULT '' COMMENT '创建人',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_username (username),
+    KEY idx_role (role),
+    KEY idx_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='管理员表';
+
+-- 管理员审计日志表
+CREATE TABLE IF NOT EXISTS admin_audit_logs (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '日志ID',
+    admin_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '管理员ID',
+    admin_username VARCHAR(32) NOT NULL DEFAULT '' COMMENT '管理员用户名',
+    action VARCHAR(64) NOT NULL COMMENT '操作, 如 user.ban / announcement.update',
+    target_type VARCHAR(20) NOT NULL DEFAULT '' COMMENT '目标类型: user/trip/announcement/admin',
+    target_id VARCHAR(64) NOT NULL DEFAULT '' COMMENT '目标ID(数字ID或open_id)',
+    before_state TEXT NULL COMMENT '操作前状态(JSON)',
+    after_state TEXT NULL COMMENT '操作后状态(JSON)',
+    method VARCHAR(10) NOT NULL DEFAULT '' COMMENT 'HTTP方法',
+    path VARCHAR(255) NOT NULL DEFAULT '' COMMENT '请求路径',
+    result_code INT NOT NULL DEFAULT 0 COMMENT '响应码: 0-成功',
+    ip VARCHAR(64) NOT NULL DEFAULT '' COMMENT '操作IP',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '操作时间',
+    PRIMARY KEY (id),
+    KEY idx_admin_username (admin_username, created_at),
+    KEY idx_target (target_type, target_id),
+    KEY idx_action (action),
+    KEY idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='管理员审计日志表';
+
+-- 封禁记录表
+CREATE TABLE IF NOT EXISTS bans (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '封禁ID',
+    target_type VARCHAR(10) NOT NULL COMMENT '封禁对象: user-账号 trip-行程',
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '受影响用户ID(行程封禁为行程发布者)',
+    trip_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '行程ID(账号封禁为0)',
+    trip_status TINYINT NOT NULL DEFAULT 0 COMMENT '封禁前的行程状态, 解封时恢复(账号封禁为0)',
+    reason VARCHAR(255) NOT NULL DEFAULT '' COMMENT '封禁原因',
+    expires_at DATETIME NULL DEFAULT NULL COMMENT '到期时间, NULL为永久',
+    admin_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '操作管理员ID',
+    admin_username VARCHAR(32) NOT NULL DEFAULT '' COMMENT '操作管理员用户名',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '状态: 0-生效中 1-已解除 2-已到期',
+    lifted_at DATETIME NULL DEFAULT NULL COMMENT '解除时间',
+    lifted_by VARCHAR(32) NOT NULL DEFAULT '' COMMENT '解除人(管理员用户名或system)',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (id),
+    KEY idx_user_status (user_id, status),
+    KEY idx_trip_status (trip_id, status),
+    KEY idx_status_expires (status, expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='封禁记录表';
+
+-- 封禁申诉表
+CREATE TABLE IF NOT EXISTS ban_appeals (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '申诉ID',
+    ban_id BIGINT UNSIGNED NOT NULL COMMENT '封禁ID',
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '申诉用户ID',
+    content VARCHAR(500) NOT NULL COMMENT '申诉内容',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '状态: 0-待审核 1-已通过 2-已驳回',
+    reviewer_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '审核管理员ID',
+    reviewer_name VARCHAR(32) NOT NULL DEFAULT '' COMMENT '审核管理员用户名',
+    review_note VARCHAR(255) NOT NULL DEFAULT '' COMMENT '审核意见',
+    reviewed_at DATETIME NULL DEFAULT NULL COMMENT '审核时间',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (id),
+    KEY idx_ban_id (ban_id),
+    KEY idx_user_id (user_id),
+    KEY idx_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='封禁申诉表';
+
+-- 小时级统计汇总表
+CREATE TABLE IF NOT EXISTS stats_hourly (
+    metric VARCHAR(32) NOT NULL COMMENT '指标: new_users/driver_trips/passenger_trips/grabs/matches_created/matches_succeeded/messages_sent',
+    bucket_hour DATETIME NOT NULL COMMENT '统计小时(整点)',
+    value BIGINT NOT NULL DEFAULT 0 COMMENT '数值',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '汇总时间',
+    PRIMARY KEY (metric, bucket_hour),
+    KEY idx_bucket_hour (bucket_hour)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='小时级统计汇总表';
+
+-- 路线转化漏斗日汇总表
+CREATE TABLE IF NOT EXISTS stats_route_funnel_daily (
+    stat_date DATE NOT NULL COMMENT '行程发布日期',
+    departure_city VARCHAR(50) NOT NULL COMMENT '出发城市',
+    destination_city VARCHAR(50) NOT NULL COMMENT '目的城市',
+    published INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '发布行程数',
+    engaged INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '被抢单或匹配的行程数',
+    confirmed INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '双方确认的行程数',
+    completed INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '已完成的行程数',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '汇总时间',
+    PRIMARY KEY (stat_date, departure_city, destination_city),
+    KEY idx_route (departure_city, destination_city)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='路线转化漏斗日汇总表';
+
+-- 用户关闭公告记录表
+CREATE TABLE IF NOT EXISTS announcement_dismissals (
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '用户ID',
+    announcement_id BIGINT UNSIGNED NOT NULL COMMENT '公告ID',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '关闭时间',
+    PRIMARY KEY (user_id, announcement_id),
+    KEY idx_announcement_id (announcement_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='公告关闭记录表';
+
+-- 系统广播表
+CREATE TABLE IF NOT EXISTS broadcasts (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '广播ID',
+    channel VARCHAR(20) NOT NULL COMMENT '投递方式: message-私信 notification-通知',
+    title VARCHAR(100) NOT NULL DEFAULT '' COMMENT '通知标题',
+    content TEXT NOT NULL COMMENT '内容',
+    audience_type VARCHAR(10) NOT NULL COMMENT '接收范围: all-全部 city-城市 route-路线及日期 users-指定用户',
+    city VARCHAR(50) NOT NULL DEFAULT '' COMMENT '城市',
+    departure_city VARCHAR(50) NOT NULL DEFAULT '' COMMENT '路线出发城市',
+    destination_city VARCHAR(50) NOT NULL DEFAULT '' COMMENT '路线目的城市',
+    trip_date VARCHAR(10) NOT NULL DEFAULT '' COMMENT '出发日期 YYYY-MM-DD',
+    open_ids TEXT NOT NULL COMMENT '指定用户open_id, 逗号分隔',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '状态: 0-待投递 1-投递中 2-已完成 3-已取消',
+    total_count INT NOT NULL DEFAULT 0 COMMENT '创建时的接收人数',
+    sent_count INT NOT NULL DEFAULT 0 COMMENT '已发送数',
+    failed_count INT NOT NULL DEFAULT 0 COMMENT '发送失败数',
+    last_user_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '投递进度: 最后处理的用户ID',
+    admin_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '创建管理员ID',
+    admin_username VARCHAR(32) NOT NULL DEFAULT '' COMMENT '创建管理员用户名',
+    started_at DATETIME NULL DEFAULT NULL COMMENT '开始投递时间',
+    finished_at DATETIME NULL DEFAULT NULL COMMENT '结束时间',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (id),
+    KEY idx_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='系统广播表';
+
+-- 行程群聊表
+CREATE TABLE IF NOT EXISTS trip_groups (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '群聊ID',
+    trip_id BIGINT UNSIGNED NOT NULL COMMENT '司机行程ID',
+    owner_id BIGINT UNSIGNED NOT NULL COMMENT '群主(司机)ID',
+    name VARCHAR(100) NOT NULL DEFAULT '' COMMENT '群名称',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '状态: 0-正常 1-已关闭',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_trip_id (trip_id),
+    KEY idx_owner_id (owner_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='行程群聊表';
+
+-- 行程群聊成员表
+CREATE TABLE IF NOT EXISTS trip_group_members (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '成员记录ID',
+    group_id BIGINT UNSIGNED NOT NULL COMMENT '群聊ID',
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '用户ID',
+    role TINYINT NOT NULL COMMENT '角色: 1-司机 2-乘客',
+    trip_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '乘客加入时的行程ID, 0表示直接预约司机行程',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '状态: 0-在群 1-已退出',
+    joined_message_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '加入时群聊最后一条消息ID, 成员只能看到之后的消息',
+    last_read_message_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '最后已读消息ID',
+    muted TINYINT NOT NULL DEFAULT 0 COMMENT '是否免打扰(仍推送消息, 不提醒): 0-否 1-是',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '加入时间',
+    left_at DATETIME NULL DEFAULT NULL COMMENT '退出时间',
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_group_user (group_id, user_id),
+    KEY idx_user_id (user_id),
+    KEY idx_trip_id (trip_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='行程群聊成员表';
+
+-- 群聊消息表
+CREATE TABLE IF NOT EXISTS group_messages (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '消息ID',
+    group_id BIGINT UNSIGNED NOT NULL COMMENT '群聊ID',
+    sender_id BIGINT UNSIGNED NOT NULL COMMENT '发送者ID',
+    content TEXT NOT NULL COMMENT '消息内容(文字或资源URL)',
+    msg_type TINYINT NOT NULL DEFAULT 1 COMMENT '消息类型: 1-文字 2-图片 3-语音 4-表情 6-视频 8-位置',
+    duration INT NOT NULL DEFAULT 0 COMMENT '语音时长(秒)',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    PRIMARY KEY (id),
+    KEY idx_group_id (group_id, id),
+    KEY idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='群聊消息表';
+
+-- 敏感词表
+CREATE TABLE IF NOT EXISTS sensitive_words (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '敏感词ID',
+    word VARCHAR(50) NOT NULL COMMENT '敏感词, 匹配时不区分大小写',
+    action VARCHAR(10) NOT NULL COMMENT '处理方式: block-拒绝 mask-打码 flag-转人工审核',
+    admin_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '添加管理员ID',
+    admin_username VARCHAR(32) NOT NULL DEFAULT '' COMMENT '添加管理员用户名',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_word (word)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='敏感词表';
+
+-- 内容审核表
+CREATE TABLE IF NOT EXISTS content_flags (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '审核记录ID',
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '发布内容的用户ID',
+    scene VARCHAR(20) NOT NULL COMMENT '场景: trip_remark message group_message friend_request nickname grab_message chat_spam',
+    target_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '行程/消息/好友申请/用户ID, 依场景而定(chat_spam 为用户ID)',
+    content TEXT NOT NULL COMMENT '被标记的内容',
+    matched_words VARCHAR(500) NOT NULL DEFAULT '' COMMENT '命中的敏感词, 逗号分隔',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '状态: 0-待审核 1-通过 2-违规',
+    reviewer_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '审核管理员ID',
+    reviewer_name VARCHAR(32) NOT NULL DEFAULT '' COMMENT '审核管理员用户名',
+    review_note VARCHAR(500) NOT NULL DEFAULT '' COMMENT '审核备注',
+    reviewed_at DATETIME NULL DEFAULT NULL COMMENT '审核时间',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    PRIMARY KEY (id),
+    KEY idx_status (status, id),
+    KEY idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='内容审核表';
+
+-- 私聊会话表, 发送、删除消息时维护, 会话列表按最后一条消息ID分页
+CREATE TABLE IF NOT EXISTS conversations (
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '用户ID',
+    peer_id BIGINT UNSIGNED NOT NULL COMMENT '会话对方用户ID',
+    last_message_id BIGINT UNSIGNED NOT NULL COMMENT '该用户可见的最后一条消息ID',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (user_id, peer_id),
+    KEY idx_user_last_message (user_id, last_message_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='私聊会话表';
+
+-- 会话设置表
+CREATE TABLE IF NOT EXISTS conversation_settings (
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '用户ID',
+    peer_id BIGINT UNSIGNED NOT NULL COMMENT '会话对方用户ID',
+    pinned TINYINT NOT NULL DEFAULT 0 COMMENT '是否置顶: 0-否 1-是',
+    muted TINYINT NOT NULL DEFAULT 0 COMMENT '是否免打扰(仍投递消息, 不提醒): 0-否 1-是',
+    archived TINYINT NOT NULL DEFAULT 0 COMMENT '是否归档: 0-否 1-是',
+    remark VARCHAR(50) NOT NULL DEFAULT '' COMMENT '对方备注名',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3) COMMENT '更新时间(增量同步游标)',
+    PRIMARY KEY (user_id, peer_id),
+    KEY idx_user_updated (user_id, updated_at, peer_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='私聊会话设置表';
+
+-- 用户登录IP表
+CREATE TABLE IF NOT EXISTS user_login_ips (
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '用户ID',
+    ip VARCHAR(64) NOT NULL COMMENT '登录IP',
+    login_count INT UNSIGNED NOT NULL DEFAULT 1 COMMENT '从该IP登录次数',
+    first_login_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '首次登录时间',
+    last_login_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '最近登录时间',
+    PRIMARY KEY (user_id, ip)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='用户登录IP表';
+
 -- 插入系统用户 (用于系统通知)
 INSERT INTO users (id, open_id, phone, password, nickname, avatar, gender, status) VALUES 
 (1, 'system_000000000000000000', '00000000000', '', '系统通知', '', 0, 0)
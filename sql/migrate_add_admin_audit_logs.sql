@@ -0,0 +1,26 @@
+-- 管理员审计日志表迁移脚本
+-- 记录运营后台所有写操作: 操作人、操作、目标、操作前后状态、IP及时间
+
+USE pinche;
+
+-- 管理员审计日志表
+CREATE TABLE IF NOT EXISTS admin_audit_logs (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '日志ID',
+    admin_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '管理员ID',
+    admin_username VARCHAR(32) NOT NULL DEFAULT '' COMMENT '管理员用户名',
+    action VARCHAR(64) NOT NULL COMMENT '操作, 如 user.ban / announcement.update',
+    target_type VARCHAR(20) NOT NULL DEFAULT '' COMMENT '目标类型: user/trip/announcement/admin',
+    target_id VARCHAR(64) NOT NULL DEFAULT '' COMMENT '目标ID(数字ID或open_id)',
+    before_state TEXT NULL COMMENT '操作前状态(JSON)',
+    after_state TEXT NULL COMMENT '操作后状态(JSON)',
+    method VARCHAR(10) NOT NULL DEFAULT '' COMMENT 'HTTP方法',
+    path VARCHAR(255) NOT NULL DEFAULT '' COMMENT '请求路径',
+    result_code INT NOT NULL DEFAULT 0 COMMENT '响应码: 0-成功',
+    ip VARCHAR(64) NOT NULL DEFAULT '' COMMENT '操作IP',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '操作时间',
+    PRIMARY KEY (id),
+    KEY idx_admin_username (admin_username, created_at),
+    KEY idx_target (target_type, target_id),
+    KEY idx_action (action),
+    KEY idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='管理员审计日志表';
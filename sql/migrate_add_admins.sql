@@ -0,0 +1,23 @@
+-- 运营后台管理员账号表迁移脚本
+-- 支持多管理员账号及角色权限: super_admin / moderator / content_editor / viewer
+-- 首次启动时若表为空, 服务端会使用 ADMIN_USERNAME / ADMIN_PASSWORD 自动创建超级管理员
+
+USE pinche;
+
+-- 管理员表
+CREATE TABLE IF NOT EXISTS admins (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '管理员ID',
+    username VARCHAR(32) NOT NULL COMMENT '用户名',
+    password VARCHAR(128) NOT NULL COMMENT '密码哈希(bcrypt)',
+    role VARCHAR(20) NOT NULL DEFAULT 'viewer' COMMENT '角色: super_admin-超级管理员 moderator-审核员 content_editor-内容编辑 viewer-只读',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '状态: 0-正常 1-禁用',
+    last_login_at DATETIME NULL DEFAULT NULL COMMENT '最后登录时间',
+    last_login_ip VARCHAR(64) NOT NULL DEFAULT '' COMMENT '最后登录IP',
+    created_by VARCHAR(32) NOT NULL DEFAULT '' COMMENT '创建人',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_username (username),
+    KEY idx_role (role),
+    KEY idx_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='管理员表';
@@ -0,0 +1,22 @@
+-- 公告定向投放迁移脚本
+-- 公告可按省份/城市、用户角色(近期发布的行程类型)及客户端平台定向, 用户可关闭公告, 紧急公告生效时通过WebSocket推送
+
+USE pinche;
+
+ALTER TABLE announcements ADD COLUMN target_provinces VARCHAR(500) NOT NULL DEFAULT '' COMMENT '目标省份, 逗号分隔, 为空表示不限' AFTER end_time;
+ALTER TABLE announcements ADD COLUMN target_cities VARCHAR(500) NOT NULL DEFAULT '' COMMENT '目标城市, 逗号分隔, 为空表示不限' AFTER target_provinces;
+ALTER TABLE announcements ADD COLUMN target_role TINYINT NOT NULL DEFAULT 0 COMMENT '目标角色: 0-全部 1-司机 2-乘客' AFTER target_cities;
+ALTER TABLE announcements ADD COLUMN target_platforms VARCHAR(100) NOT NULL DEFAULT '' COMMENT '目标平台(ios/android/web/miniprogram), 逗号分隔, 为空表示不限' AFTER target_role;
+ALTER TABLE announcements ADD COLUMN pushed_at DATETIME NULL DEFAULT NULL COMMENT '紧急公告推送时间' AFTER target_platforms;
+
+-- 已生效的紧急公告视为已推送, 避免上线后重复推送
+UPDATE announcements SET pushed_at = NOW() WHERE type = 3 AND start_time <= NOW();
+
+-- 用户关闭公告记录表
+CREATE TABLE IF NOT EXISTS announcement_dismissals (
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '用户ID',
+    announcement_id BIGINT UNSIGNED NOT NULL COMMENT '公告ID',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '关闭时间',
+    PRIMARY KEY (user_id, announcement_id),
+    KEY idx_announcement_id (announcement_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='公告关闭记录表';
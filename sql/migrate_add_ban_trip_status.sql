@@ -0,0 +1,7 @@
+-- 行程封禁记录封禁前状态迁移脚本
+-- 解封或到期时恢复行程封禁前的状态, 已成行、已取消的行程不再重新变为待匹配
+
+USE pinche;
+
+ALTER TABLE bans
+    ADD COLUMN trip_status TINYINT NOT NULL DEFAULT 0 COMMENT '封禁前的行程状态, 解封时恢复(账号封禁为0)' AFTER trip_id;
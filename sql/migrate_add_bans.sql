@@ -0,0 +1,44 @@
+-- 封禁记录与申诉表迁移脚本
+-- 封禁支持原因、到期时间(到期后由定时任务自动解封)及操作管理员, 用户可对封禁提交申诉
+
+USE pinche;
+
+-- 封禁记录表
+CREATE TABLE IF NOT EXISTS bans (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '封禁ID',
+    target_type VARCHAR(10) NOT NULL COMMENT '封禁对象: user-账号 trip-行程',
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '受影响用户ID(行程封禁为行程发布者)',
+    trip_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '行程ID(账号封禁为0)',
+    reason VARCHAR(255) NOT NULL DEFAULT '' COMMENT '封禁原因',
+    expires_at DATETIME NULL DEFAULT NULL COMMENT '到期时间, NULL为永久',
+    admin_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '操作管理员ID',
+    admin_username VARCHAR(32) NOT NULL DEFAULT '' COMMENT '操作管理员用户名',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '状态: 0-生效中 1-已解除 2-已到期',
+    lifted_at DATETIME NULL DEFAULT NULL COMMENT '解除时间',
+    lifted_by VARCHAR(32) NOT NULL DEFAULT '' COMMENT '解除人(管理员用户名或system)',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (id),
+    KEY idx_user_status (user_id, status),
+    KEY idx_trip_status (trip_id, status),
+    KEY idx_status_expires (status, expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='封禁记录表';
+
+-- 封禁申诉表
+CREATE TABLE IF NOT EXISTS ban_appeals (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '申诉ID',
+    ban_id BIGINT UNSIGNED NOT NULL COMMENT '封禁ID',
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '申诉用户ID',
+    content VARCHAR(500) NOT NULL COMMENT '申诉内容',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '状态: 0-待审核 1-已通过 2-已驳回',
+    reviewer_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '审核管理员ID',
+    reviewer_name VARCHAR(32) NOT NULL DEFAULT '' COMMENT '审核管理员用户名',
+    review_note VARCHAR(255) NOT NULL DEFAULT '' COMMENT '审核意见',
+    reviewed_at DATETIME NULL DEFAULT NULL COMMENT '审核时间',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (id),
+    KEY idx_ban_id (ban_id),
+    KEY idx_user_id (user_id),
+    KEY idx_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='封禁申诉表';
@@ -0,0 +1,30 @@
+-- 系统广播表迁移脚本
+-- 管理员以"系统通知"用户向筛选出的用户批量发送私信或通知, 由后台任务限速投递并记录进度
+
+USE pinche;
+
+CREATE TABLE IF NOT EXISTS broadcasts (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '广播ID',
+    channel VARCHAR(20) NOT NULL COMMENT '投递方式: message-私信 notification-通知',
+    title VARCHAR(100) NOT NULL DEFAULT '' COMMENT '通知标题',
+    content TEXT NOT NULL COMMENT '内容',
+    audience_type VARCHAR(10) NOT NULL COMMENT '接收范围: all-全部 city-城市 route-路线及日期 users-指定用户',
+    city VARCHAR(50) NOT NULL DEFAULT '' COMMENT '城市',
+    departure_city VARCHAR(50) NOT NULL DEFAULT '' COMMENT '路线出发城市',
+    destination_city VARCHAR(50) NOT NULL DEFAULT '' COMMENT '路线目的城市',
+    trip_date VARCHAR(10) NOT NULL DEFAULT '' COMMENT '出发日期 YYYY-MM-DD',
+    open_ids TEXT NOT NULL COMMENT '指定用户open_id, 逗号分隔',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '状态: 0-待投递 1-投递中 2-已完成 3-已取消',
+    total_count INT NOT NULL DEFAULT 0 COMMENT '创建时的接收人数',
+    sent_count INT NOT NULL DEFAULT 0 COMMENT '已发送数',
+    failed_count INT NOT NULL DEFAULT 0 COMMENT '发送失败数',
+    last_user_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '投递进度: 最后处理的用户ID',
+    admin_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '创建管理员ID',
+    admin_username VARCHAR(32) NOT NULL DEFAULT '' COMMENT '创建管理员用户名',
+    started_at DATETIME NULL DEFAULT NULL COMMENT '开始投递时间',
+    finished_at DATETIME NULL DEFAULT NULL COMMENT '结束时间',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (id),
+    KEY idx_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='系统广播表';
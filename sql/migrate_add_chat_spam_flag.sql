@@ -0,0 +1,7 @@
+-- 私信防骚扰迁移脚本
+-- 私信限流计数保存在 Redis, 24 小时内多次触发限制的用户以 chat_spam 场景提交内容审核
+
+USE pinche;
+
+ALTER TABLE content_flags MODIFY COLUMN scene VARCHAR(20) NOT NULL COMMENT '场景: trip_remark message group_message friend_request nickname grab_message chat_spam';
+ALTER TABLE content_flags MODIFY COLUMN target_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '行程/消息/好友申请/用户ID, 依场景而定(chat_spam 为用户ID)';
@@ -0,0 +1,17 @@
+-- 会话设置迁移脚本
+-- 用户可对私聊会话置顶、免打扰、归档及设置对方备注名, 多端同步
+
+USE pinche;
+
+-- 会话设置表
+CREATE TABLE IF NOT EXISTS conversation_settings (
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '用户ID',
+    peer_id BIGINT UNSIGNED NOT NULL COMMENT '会话对方用户ID',
+    pinned TINYINT NOT NULL DEFAULT 0 COMMENT '是否置顶: 0-否 1-是',
+    muted TINYINT NOT NULL DEFAULT 0 COMMENT '是否免打扰(仍投递消息, 不提醒): 0-否 1-是',
+    archived TINYINT NOT NULL DEFAULT 0 COMMENT '是否归档: 0-否 1-是',
+    remark VARCHAR(50) NOT NULL DEFAULT '' COMMENT '对方备注名',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (user_id, peer_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='私聊会话设置表';
@@ -0,0 +1,23 @@
+-- 私聊会话表迁移脚本
+-- 会话列表不再对用户全部消息做聚合, 改为读取按 (user_id, last_message_id) 索引的会话表分页
+
+USE pinche;
+
+CREATE TABLE IF NOT EXISTS conversations (
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '用户ID',
+    peer_id BIGINT UNSIGNED NOT NULL COMMENT '会话对方用户ID',
+    last_message_id BIGINT UNSIGNED NOT NULL COMMENT '该用户可见的最后一条消息ID',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (user_id, peer_id),
+    KEY idx_user_last_message (user_id, last_message_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='私聊会话表';
+
+-- 由现有消息回填, 不含用户自己删除的消息
+INSERT INTO conversations (user_id, peer_id, last_message_id)
+SELECT user_id, peer_id, MAX(id) FROM (
+    SELECT sender_id AS user_id, receiver_id AS peer_id, id FROM messages WHERE sender_deleted = 0
+    UNION ALL
+    SELECT receiver_id AS user_id, sender_id AS peer_id, id FROM messages WHERE receiver_deleted = 0
+) AS visible
+GROUP BY user_id, peer_id
+ON DUPLICATE KEY UPDATE last_message_id = GREATEST(last_message_id, VALUES(last_message_id));
@@ -0,0 +1,10 @@
+-- 群聊成员加入位置迁移脚本
+-- 成员只能看到加入之后的群消息; 抢单不再直接入群, 由司机接受预约或匹配成功后加入
+
+USE pinche;
+
+ALTER TABLE trip_group_members
+    ADD COLUMN joined_message_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '加入时群聊最后一条消息ID, 成员只能看到之后的消息' AFTER status;
+
+-- 此前仅抢单会以行程ID 0 加入, 这些成员未经司机同意, 移出群聊
+UPDATE trip_group_members SET status = 1, left_at = NOW() WHERE role = 2 AND trip_id = 0 AND status = 0;
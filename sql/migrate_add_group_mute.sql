@@ -0,0 +1,7 @@
+-- 群聊免打扰迁移脚本
+-- 成员可对群聊开启免打扰, 群消息仍通过WebSocket推送但标记为静默
+
+USE pinche;
+
+ALTER TABLE trip_group_members
+    ADD COLUMN muted TINYINT NOT NULL DEFAULT 0 COMMENT '是否免打扰(仍推送消息, 不提醒): 0-否 1-是' AFTER last_read_message_id;
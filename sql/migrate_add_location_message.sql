@@ -0,0 +1,7 @@
+-- 位置消息迁移脚本
+-- 私信及群聊支持位置消息(msg_type=8), content 为包含经纬度、地址和地点名称的 JSON
+
+USE pinche;
+
+ALTER TABLE messages MODIFY COLUMN msg_type TINYINT NOT NULL DEFAULT 1 COMMENT '消息类型: 1-文字 2-图片 3-语音 4-表情 5-通话记录 6-视频 7-已撤回 8-位置';
+ALTER TABLE group_messages MODIFY COLUMN msg_type TINYINT NOT NULL DEFAULT 1 COMMENT '消息类型: 1-文字 2-图片 3-语音 4-表情 6-视频 8-位置';
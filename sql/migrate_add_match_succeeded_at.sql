@@ -0,0 +1,14 @@
+-- 匹配成功时间迁移脚本
+-- 统计"匹配成功"按成功时间归入小时, 匹配记录之后的更新不再使其重复计入新的小时; "消息发送"同时统计群聊消息
+
+USE pinche;
+
+ALTER TABLE matches
+    ADD COLUMN succeeded_at DATETIME NULL DEFAULT NULL COMMENT '匹配成功时间' AFTER status,
+    ADD INDEX idx_succeeded_at (succeeded_at),
+    DROP INDEX idx_status_updated;
+
+-- 已成功的匹配以最后更新时间作为成功时间
+UPDATE matches SET succeeded_at = updated_at WHERE status = 1 AND succeeded_at IS NULL;
+
+ALTER TABLE group_messages ADD INDEX idx_created_at (created_at);
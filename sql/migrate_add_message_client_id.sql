@@ -0,0 +1,7 @@
+-- 消息客户端幂等ID迁移脚本
+-- 客户端通过WebSocket发送消息时携带client_msg_id, 重试时按(发送者, client_msg_id)去重
+
+USE pinche;
+
+ALTER TABLE messages ADD COLUMN client_msg_id VARCHAR(64) NULL DEFAULT NULL COMMENT '客户端消息ID(幂等)' AFTER receiver_deleted;
+ALTER TABLE messages ADD UNIQUE KEY uk_sender_client_msg (sender_id, client_msg_id);
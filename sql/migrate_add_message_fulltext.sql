@@ -0,0 +1,6 @@
+-- 私信全文搜索迁移脚本
+-- 中文无空格分词, 使用 ngram 解析器 (默认 ngram_token_size=2) 为消息内容建立全文索引
+
+USE pinche;
+
+ALTER TABLE messages ADD FULLTEXT KEY ft_content (content) WITH PARSER ngram;
@@ -0,0 +1,11 @@
+-- 消息撤回与删除迁移脚本
+-- 发送者可在时限内撤回消息(双方内容均被替换), 用户可仅对自己删除消息
+
+USE pinche;
+
+ALTER TABLE messages ADD COLUMN recalled_at DATETIME NULL DEFAULT NULL COMMENT '撤回时间' AFTER is_read;
+ALTER TABLE messages ADD COLUMN sender_deleted TINYINT NOT NULL DEFAULT 0 COMMENT '发送者是否已删除: 0-否 1-是' AFTER recalled_at;
+ALTER TABLE messages ADD COLUMN receiver_deleted TINYINT NOT NULL DEFAULT 0 COMMENT '接收者是否已删除: 0-否 1-是' AFTER sender_deleted;
+
+-- 更新 msg_type 注释
+ALTER TABLE messages MODIFY COLUMN msg_type TINYINT NOT NULL DEFAULT 1 COMMENT '消息类型: 1-文字 2-图片 3-语音 4-表情 5-通话记录 6-视频 7-已撤回';
@@ -0,0 +1,9 @@
+-- 消息引用回复迁移脚本
+-- 回复时记录被引用消息及其发送者和内容摘要(冗余存储, 列表无需再查询原消息)
+
+USE pinche;
+
+ALTER TABLE messages ADD COLUMN reply_to_id BIGINT UNSIGNED NULL DEFAULT NULL COMMENT '引用的消息ID' AFTER client_msg_id;
+ALTER TABLE messages ADD COLUMN reply_sender_id BIGINT UNSIGNED NULL DEFAULT NULL COMMENT '被引用消息的发送者ID' AFTER reply_to_id;
+ALTER TABLE messages ADD COLUMN reply_snippet VARCHAR(200) NOT NULL DEFAULT '' COMMENT '被引用消息摘要' AFTER reply_sender_id;
+ALTER TABLE messages ADD KEY idx_reply_to_id (reply_to_id);
@@ -0,0 +1,16 @@
+-- 私信游标分页及增量同步迁移脚本
+-- 会话消息按 id 游标分页; updated_at 在消息新建、已读、撤回、删除时更新, 作为 /api/sync 的同步游标
+
+USE pinche;
+
+ALTER TABLE messages
+    ADD COLUMN updated_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3) COMMENT '更新时间(增量同步游标)' AFTER created_at;
+
+-- 已有消息以创建时间作为更新时间
+UPDATE messages SET updated_at = created_at;
+
+ALTER TABLE messages
+    DROP INDEX idx_conversation,
+    ADD KEY idx_conversation (sender_id, receiver_id, id),
+    ADD KEY idx_sender_updated (sender_id, updated_at, id),
+    ADD KEY idx_receiver_updated (receiver_id, updated_at, id);
@@ -0,0 +1,36 @@
+-- 内容审核迁移脚本
+-- 后台维护的敏感词库 (拒绝/打码/转人工审核), 以及待人工审核的用户内容
+
+USE pinche;
+
+-- 敏感词表
+CREATE TABLE IF NOT EXISTS sensitive_words (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '敏感词ID',
+    word VARCHAR(50) NOT NULL COMMENT '敏感词, 匹配时不区分大小写',
+    action VARCHAR(10) NOT NULL COMMENT '处理方式: block-拒绝 mask-打码 flag-转人工审核',
+    admin_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '添加管理员ID',
+    admin_username VARCHAR(32) NOT NULL DEFAULT '' COMMENT '添加管理员用户名',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_word (word)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='敏感词表';
+
+-- 内容审核表
+CREATE TABLE IF NOT EXISTS content_flags (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '审核记录ID',
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '发布内容的用户ID',
+    scene VARCHAR(20) NOT NULL COMMENT '场景: trip_remark message group_message friend_request nickname grab_message',
+    target_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '行程/消息/好友申请/用户ID, 依场景而定',
+    content TEXT NOT NULL COMMENT '被标记的内容',
+    matched_words VARCHAR(500) NOT NULL DEFAULT '' COMMENT '命中的敏感词, 逗号分隔',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '状态: 0-待审核 1-通过 2-违规',
+    reviewer_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '审核管理员ID',
+    reviewer_name VARCHAR(32) NOT NULL DEFAULT '' COMMENT '审核管理员用户名',
+    review_note VARCHAR(500) NOT NULL DEFAULT '' COMMENT '审核备注',
+    reviewed_at DATETIME NULL DEFAULT NULL COMMENT '审核时间',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    PRIMARY KEY (id),
+    KEY idx_status (status, id),
+    KEY idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='内容审核表';
@@ -0,0 +1,36 @@
+-- 运营统计汇总表迁移脚本
+-- 后台趋势图与路线漏斗从汇总表读取, 由服务端定时任务维护, 首次运行时自动回填历史数据
+
+USE pinche;
+
+-- 小时级统计汇总表
+CREATE TABLE IF NOT EXISTS stats_hourly (
+    metric VARCHAR(32) NOT NULL COMMENT '指标: new_users/driver_trips/passenger_trips/grabs/matches_created/matches_succeeded/messages_sent',
+    bucket_hour DATETIME NOT NULL COMMENT '统计小时(整点)',
+    value BIGINT NOT NULL DEFAULT 0 COMMENT '数值',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '汇总时间',
+    PRIMARY KEY (metric, bucket_hour),
+    KEY idx_bucket_hour (bucket_hour)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='小时级统计汇总表';
+
+-- 路线转化漏斗日汇总表
+CREATE TABLE IF NOT EXISTS stats_route_funnel_daily (
+    stat_date DATE NOT NULL COMMENT '行程发布日期',
+    departure_city VARCHAR(50) NOT NULL COMMENT '出发城市',
+    destination_city VARCHAR(50) NOT NULL COMMENT '目的城市',
+    published INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '发布行程数',
+    engaged INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '被抢单或匹配的行程数',
+    confirmed INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '双方确认的行程数',
+    completed INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '已完成的行程数',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '汇总时间',
+    PRIMARY KEY (stat_date, departure_city, destination_city),
+    KEY idx_route (departure_city, destination_city)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='路线转化漏斗日汇总表';
+
+-- 汇总任务按时间窗口扫描源表所需的索引
+ALTER TABLE users ADD INDEX idx_created_at (created_at);
+ALTER TABLE trips ADD INDEX idx_created_at (created_at);
+ALTER TABLE trip_grabs ADD INDEX idx_created_at (created_at);
+ALTER TABLE matches ADD INDEX idx_passenger_trip_id (passenger_trip_id);
+ALTER TABLE matches ADD INDEX idx_created_at (created_at);
+ALTER TABLE matches ADD INDEX idx_status_updated (status, updated_at);
@@ -0,0 +1,8 @@
+-- 增量同步扩展迁移脚本
+-- /api/sync 同时返回群聊消息及会话设置变更; 会话设置以 updated_at 作为同步游标, 精确到毫秒避免同一秒内的变更被跳过
+
+USE pinche;
+
+ALTER TABLE conversation_settings
+    MODIFY COLUMN updated_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3) COMMENT '更新时间(增量同步游标)',
+    ADD KEY idx_user_updated (user_id, updated_at, peer_id);
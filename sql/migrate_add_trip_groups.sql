@@ -0,0 +1,48 @@
+-- 行程群聊迁移脚本
+-- 司机行程在首次匹配或预约后自动建群, 成功匹配的乘客加入, 取消行程的乘客退出
+
+USE pinche;
+
+-- 行程群聊表
+CREATE TABLE IF NOT EXISTS trip_groups (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '群聊ID',
+    trip_id BIGINT UNSIGNED NOT NULL COMMENT '司机行程ID',
+    owner_id BIGINT UNSIGNED NOT NULL COMMENT '群主(司机)ID',
+    name VARCHAR(100) NOT NULL DEFAULT '' COMMENT '群名称',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '状态: 0-正常 1-已关闭',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_trip_id (trip_id),
+    KEY idx_owner_id (owner_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='行程群聊表';
+
+-- 行程群聊成员表
+CREATE TABLE IF NOT EXISTS trip_group_members (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '成员记录ID',
+    group_id BIGINT UNSIGNED NOT NULL COMMENT '群聊ID',
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '用户ID',
+    role TINYINT NOT NULL COMMENT '角色: 1-司机 2-乘客',
+    trip_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '乘客加入时的行程ID, 0表示直接预约司机行程',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '状态: 0-在群 1-已退出',
+    last_read_message_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '最后已读消息ID',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '加入时间',
+    left_at DATETIME NULL DEFAULT NULL COMMENT '退出时间',
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_group_user (group_id, user_id),
+    KEY idx_user_id (user_id),
+    KEY idx_trip_id (trip_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='行程群聊成员表';
+
+-- 群聊消息表
+CREATE TABLE IF NOT EXISTS group_messages (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '消息ID',
+    group_id BIGINT UNSIGNED NOT NULL COMMENT '群聊ID',
+    sender_id BIGINT UNSIGNED NOT NULL COMMENT '发送者ID',
+    content TEXT NOT NULL COMMENT '消息内容(文字或资源URL)',
+    msg_type TINYINT NOT NULL DEFAULT 1 COMMENT '消息类型: 1-文字 2-图片 3-语音 4-表情 6-视频',
+    duration INT NOT NULL DEFAULT 0 COMMENT '语音时长(秒)',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    PRIMARY KEY (id),
+    KEY idx_group_id (group_id, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='群聊消息表';
@@ -0,0 +1,14 @@
+-- 登录IP记录迁移脚本
+-- 记录用户成功登录过的IP, 从新IP登录或新IP连续密码错误导致账号锁定时通知用户
+
+USE pinche;
+
+-- 用户登录IP表
+CREATE TABLE IF NOT EXISTS user_login_ips (
+    user_id BIGINT UNSIGNED NOT NULL COMMENT '用户ID',
+    ip VARCHAR(64) NOT NULL COMMENT '登录IP',
+    login_count INT UNSIGNED NOT NULL DEFAULT 1 COMMENT '从该IP登录次数',
+    first_login_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '首次登录时间',
+    last_login_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '最近登录时间',
+    PRIMARY KEY (user_id, ip)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='用户登录IP表';